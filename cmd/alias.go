@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"github.com/EscanBE/go-ienumerable/goe"
 	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/node"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"os"
+	"os/exec"
 	"os/user"
 	"path"
 	"regexp"
@@ -26,6 +29,7 @@ var longestUseDesc int
 /*
 Sample content for alias file .hkd_alias:
 echo "say-hello	echo \"Hello World\"" >> ~/.hkd_alias
+echo "update	!git fetch --all && git pull" >> ~/.hkd_alias
 hkd a say-hello
 */
 
@@ -40,6 +44,7 @@ var aliasCmd = &cobra.Command{
 
 		registerStartupPredefinedAliases()
 		registerPredefinedAliasesFromFile()
+		registerPredefinedAliasesFromStructuredFiles()
 
 		if len(args) < 1 {
 			lineFormat := " %-" + fmt.Sprintf("%d", longestUseDesc+1) + "s: %s\n"
@@ -50,9 +55,14 @@ var aliasCmd = &cobra.Command{
 				if pa.overridden {
 					fmt.Printf(" *overriden*")
 				}
-				fmt.Printf(lineFormat, pa.use, strings.Join(pa.command, " "))
+				if pa.definition != nil {
+					fmt.Printf(lineFormat, pa.use, pa.definition.summary())
+				} else {
+					fmt.Printf(lineFormat, pa.use, strings.Join(pa.command, " "))
+				}
 			}
-			fmt.Printf("Alias can be customized by adding into ~/%s (TSV format with each line content \"<alias><tab><command>\")\n", constants.PREDEFINED_ALIAS_FILE_NAME)
+			fmt.Printf("Alias can be customized by adding into ~/%s (TSV format with each line content \"<alias><tab><command>\", prefix the command with '!' to execute by bash)\n", constants.PREDEFINED_ALIAS_FILE_NAME)
+			fmt.Printf("or into ~/%s.yaml or ~/%s.toml with description, parameters, working directory, environment variables...\n", constants.PREDEFINED_ALIAS_FILE_NAME, constants.PREDEFINED_ALIAS_FILE_NAME)
 			return
 		}
 
@@ -63,30 +73,51 @@ var aliasCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		command := pa.command
-		if len(args) > 1 && pa.alter != nil {
-			command = (*pa.alter)(command, args[1:])
+		var executions []aliasExecution
+		if pa.definition != nil {
+			var err error
+			executions, err = pa.definition.render(args[1:])
+			if err != nil {
+				libutils.PrintlnStdErr("ERR:", err.Error())
+				fmt.Println("Usage:", pa.use)
+				os.Exit(1)
+			}
+		} else if pa.steps != nil {
+			executions = (*pa.steps)(args[1:])
+		} else {
+			execution := aliasExecution{
+				command: pa.command,
+				shell:   pa.shell,
+			}
+			if len(args) > 1 && pa.alter != nil {
+				execution.command = (*pa.alter)(execution.command, args[1:])
+			}
+			executions = []aliasExecution{execution}
 		}
 
-		if len(command) < 1 {
-			panic("empty command")
+		for _, execution := range executions {
+			if len(execution.command) < 1 {
+				panic("empty command")
+			}
 		}
 
-		joinedCommand := strings.Join(command, " ")
-
 		confirmExecution, _ := cmd.Flags().GetBool(flagConfirmExecution)
+		confirmMode := aliasConfirmDefault
+		if pa.definition != nil && len(pa.definition.Confirm) > 0 {
+			confirmMode = pa.definition.Confirm
+		}
 
-		if confirmExecution {
+		if confirmMode == aliasConfirmNever {
+			printAliasExecutions(executions)
+		} else if confirmExecution && confirmMode != aliasConfirmAlways {
 			const waitingTime = 10
 			fmt.Println("Pending execution command:")
-			fmt.Printf("> %s\n", joinedCommand)
-			fmt.Printf("(actual command: [/bin/bash] [-c] [%s])\n", joinedCommand)
+			printAliasExecutions(executions)
 			fmt.Printf("Executing in %d seconds...\n", waitingTime)
 			time.Sleep(waitingTime * time.Second)
 		} else {
 			fmt.Println("Are you sure want to execute the following command?")
-			fmt.Printf("> %s\n", joinedCommand)
-			fmt.Printf("(actual command: [/bin/bash] [-c] [%s])\n", joinedCommand)
+			printAliasExecutions(executions)
 			fmt.Println("Yes/No?")
 
 			reader := bufio.NewReader(os.Stdin)
@@ -113,7 +144,7 @@ var aliasCmd = &cobra.Command{
 
 		fmt.Println("Executing...")
 
-		ec := utils.LaunchAppWithDirectStd("/bin/bash", []string{"-c", joinedCommand}, nil)
+		ec := runAliasExecutions(executions)
 		if ec != 0 {
 			os.Exit(ec)
 		}
@@ -127,22 +158,26 @@ func registerStartupPredefinedAliases() {
 		libutils.PrintlnStdErr("ERR: failed to get current user:", errGetUser.Error())
 		os.Exit(1)
 	}
-	home, errGetUserHomeDir := os.UserHomeDir()
-	if errGetUserHomeDir != nil {
-		libutils.PrintlnStdErr("ERR: failed to get home directory:", errGetUserHomeDir.Error())
-		os.Exit(1)
-	}
 	isUserRoot := currentUser.Username == "root"
 
-	registerPredefinedAliasForNode := func(binaryName, prefix string) {
+	registerPredefinedAliasForNode := func(n node.Node) {
+		binaryName := n.Binary
+		prefix := n.Prefix
+		serviceName := n.ServiceName()
 		hasBinary := utils.HasBinaryName(binaryName)
-		if hasBinary || isExistsServiceFile(binaryName) {
-			registerPredefinedAlias(fmt.Sprintf("%srs", prefix), []string{"sudo", "systemctl", "restart", binaryName}, nil)
-			registerPredefinedAlias(fmt.Sprintf("%sstop", prefix), []string{"sudo", "systemctl", "stop", binaryName}, nil)
-			registerPredefinedAlias(fmt.Sprintf("%sl [?since]", prefix), []string{"sudo", "journalctl", "-fu", binaryName}, &genericAlterJournalctl)
+		if hasBinary || utils.IsExistsServiceFile(serviceName) {
+			if n.HasAction(node.ActionRestart) {
+				registerPredefinedAlias(fmt.Sprintf("%srs", prefix), []string{"sudo", "systemctl", "restart", serviceName}, nil)
+			}
+			if n.HasAction(node.ActionStop) {
+				registerPredefinedAlias(fmt.Sprintf("%sstop", prefix), []string{"sudo", "systemctl", "stop", serviceName}, nil)
+			}
+			if n.HasAction(node.ActionLogs) {
+				registerPredefinedAlias(fmt.Sprintf("%sl [?since]", prefix), []string{"sudo", "journalctl", "-fu", serviceName}, &genericAlterJournalctl)
+			}
 		}
-		if !isUserRoot && hasBinary {
-			nodeHome := path.Join(home, "."+binaryName)
+		if n.HasAction(node.ActionReset) && !isUserRoot && hasBinary {
+			nodeHome := n.HomeDir()
 			if _, err := os.Stat(nodeHome); err == nil {
 				homeActualPath, err := utils.TryReadSymlink(nodeHome)
 				if err != nil { // blind accept
@@ -172,30 +207,14 @@ func registerStartupPredefinedAliases() {
 		}
 	}
 
-	// Manage Evmos nodes
-	registerPredefinedAliasForNode("evmosd", "es")
-
-	// Manage Dymension nodes
-	registerPredefinedAliasForNode("dymd", "dym")
-
-	// Manage Ethermint dev nodes
-	registerPredefinedAliasForNode("ethermintd", "eth")
-
-	// Manage CosmosHub nodes
-	registerPredefinedAliasForNode("gaid", "ga")
-
-	// Manage indexer
-	if utils.HasBinaryName("crawld") || isExistsServiceFile("crawld") {
-		registerPredefinedAlias("ecrs", []string{"sudo", "systemctl", "restart", "crawld"}, nil)
-		registerPredefinedAlias("ecstop", []string{"sudo", "systemctl", "stop", "crawld"}, nil)
-		registerPredefinedAlias("ecl [?since]", []string{"sudo", "journalctl", "-fu", "crawld"}, &genericAlterJournalctl)
+	// Manage nodes and daemons declared in the node registry
+	nodes, err := node.LoadRegistry(node.DefaultRegistryFilePath())
+	if err != nil {
+		libutils.PrintlnStdErr("ERR:", err.Error())
+		os.Exit(1)
 	}
-
-	// Manage proxy
-	if utils.HasBinaryName("epod") || isExistsServiceFile("epod") {
-		registerPredefinedAlias("eprs", []string{"sudo", "systemctl", "restart", "epod"}, nil)
-		registerPredefinedAlias("epstop", []string{"sudo", "systemctl", "stop", "epod"}, nil)
-		registerPredefinedAlias("epl [?since]", []string{"sudo", "journalctl", "-fu", "epod"}, &genericAlterJournalctl)
+	for _, n := range nodes {
+		registerPredefinedAliasForNode(n)
 	}
 
 	// Read logging
@@ -203,7 +222,7 @@ func registerStartupPredefinedAliases() {
 
 	// Git
 	if _, err := os.Stat(".git"); err == nil {
-		registerPredefinedAlias("pull [?branch] [?branch2] [...]", []string{"git", "fetch", "--all", "&&", "git", "checkout", "main", "&&", "git", "pull"}, &gitPullHandler)
+		registerPredefinedStepsAlias("pull [?branch] [?branch2] [...]", "git fetch --all, then checkout and pull each branch (default main)", &gitPullSteps)
 	}
 }
 
@@ -219,17 +238,40 @@ var aliasLogHandler commandAlter = func(_, args []string) []string {
 }
 
 var genericAlterJournalctl commandAlter = func(command, args []string) []string {
-	return append(command, "--since", "'"+strings.Join(args, " ")+"'")
+	return append(command, "--since", strings.Join(args, " "))
 }
 
-var gitPullHandler commandAlter = func(_, args []string) []string {
-	command := []string{"git", "fetch", "--all"}
+var gitPullSteps stepsBuilder = func(args []string) []aliasExecution {
+	branches := args
+	if len(branches) < 1 {
+		branches = []string{"main"}
+	}
+
+	const retries = 2
+	const retryDelay = 3 * time.Second
 
-	for _, branch := range args {
-		command = append(command, "&&", "git", "checkout", branch, "&&", "git", "pull")
+	steps := []aliasExecution{
+		{
+			name:       "fetch",
+			command:    []string{"git", "fetch", "--all"},
+			retries:    retries,
+			retryDelay: retryDelay,
+		},
 	}
 
-	return command
+	for _, branch := range branches {
+		steps = append(steps, aliasExecution{
+			name:    fmt.Sprintf("checkout %s", branch),
+			command: []string{"git", "checkout", branch},
+		}, aliasExecution{
+			name:       fmt.Sprintf("pull %s", branch),
+			command:    []string{"git", "pull"},
+			retries:    retries,
+			retryDelay: retryDelay,
+		})
+	}
+
+	return steps
 }
 
 func registerPredefinedAliasesFromFile() {
@@ -264,7 +306,7 @@ func registerPredefinedAliasesFromFile() {
 		return strings.TrimSpace(line)
 	}).CastString()
 
-	regexReplaceContinousSpace := regexp.MustCompile("[\\s\\t]+")
+	regexAliasLine := regexp.MustCompile("^(\\S+)\\s+(.+)$")
 
 	for _, line := range tsvLines.ToArray() {
 		if strings.HasPrefix(line, "#") {
@@ -274,28 +316,91 @@ func registerPredefinedAliasesFromFile() {
 			continue
 		}
 
-		spl := strings.Split(
-			regexReplaceContinousSpace.ReplaceAllString(strings.Replace(line, "\t", " ", -1), " "),
-			" ",
-		)
-
-		if len(spl) < 2 {
+		matches := regexAliasLine.FindStringSubmatch(line)
+		if len(matches) < 3 {
 			panic(fmt.Errorf("malformed %s", constants.PREDEFINED_ALIAS_FILE_NAME))
 		}
 
-		alias := spl[0]
-		command := spl[1:]
+		alias := matches[1]
+		var command []string
+		var shell bool
+		if strings.HasPrefix(matches[2], "!") {
+			// shell mode, the rest of line is the script
+			command = []string{strings.TrimSpace(matches[2][1:])}
+			shell = true
+		} else if utils.FindShellSyntax(matches[2]) != "" {
+			// legacy lines were executed by bash, keep it for lines rely on shell features like expansions and operators
+			command = []string{strings.TrimSpace(matches[2])}
+			shell = true
+		} else {
+			var err error
+			command, err = utils.SplitShellWords(matches[2])
+			if err != nil {
+				panic(errors.Wrap(err, fmt.Sprintf("malformed %s, alias %s", constants.PREDEFINED_ALIAS_FILE_NAME, alias)))
+			}
+		}
+
 		if pa, found := predefinedAliases[alias]; found {
 			pa.command = command
+			pa.shell = shell
 			pa.use = alias
+			pa.alter = nil
+			pa.steps = nil
 			pa.overridden = true
 			predefinedAliases[alias] = pa
+		} else if shell {
+			registerPredefinedShellAlias(alias, command[0], nil)
 		} else {
 			registerPredefinedAlias(alias, command, nil)
 		}
 	}
 }
 
+// registerPredefinedAliasesFromStructuredFiles registers aliases defined in ~/.hkd_alias.yaml, ~/.hkd_alias.yml
+// and ~/.hkd_alias.toml, in that order. Aliases defined later override the earlier ones.
+func registerPredefinedAliasesFromStructuredFiles() {
+	home, errGetUserHomeDir := os.UserHomeDir()
+	if errGetUserHomeDir != nil {
+		fmt.Println("ERR: failed to get home directory:", errGetUserHomeDir.Error())
+		return
+	}
+
+	for _, extension := range []string{".yaml", ".yml", ".toml"} {
+		aliasFilePath := path.Join(home, constants.PREDEFINED_ALIAS_FILE_NAME+extension)
+
+		file, errFile := os.Stat(aliasFilePath)
+		if errFile != nil {
+			if os.IsNotExist(errFile) {
+				continue
+			}
+			fmt.Printf("ERR: unable to check alias file %s: %s\n", aliasFilePath, errFile.Error())
+			continue
+		}
+
+		if file.IsDir() {
+			continue
+		}
+
+		af, err := loadAliasFile(aliasFilePath)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, alias := range libutils.GetKeys(af.Aliases) {
+			definition := af.Aliases[alias]
+			_, overridden := predefinedAliases[alias]
+			use := definition.use(alias)
+			predefinedAliases[alias] = predefinedAlias{
+				alias:      alias,
+				use:        use,
+				definition: &definition,
+				overridden: overridden,
+			}
+			longestUseDesc = libutils.MaxInt(longestUseDesc, len(use))
+		}
+	}
+}
+
 func registerPredefinedAlias(use string, defaultCommand []string, alter *commandAlter) {
 	spl := strings.Split(use, " ")
 	alias := spl[0]
@@ -308,33 +413,101 @@ func registerPredefinedAlias(use string, defaultCommand []string, alter *command
 	longestUseDesc = libutils.MaxInt(longestUseDesc, len(use))
 }
 
-func isExistsServiceFile(serviceName string) bool {
-	file, err := os.Stat(path.Join("/etc/systemd/system", serviceName+".service"))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return false
-		}
-		return false // treat as not
-	}
-	return !file.IsDir()
+// registerPredefinedShellAlias registers alias those command is a script executed by bash,
+// the alter must quote the arguments provided by user
+func registerPredefinedShellAlias(use string, script string, alter *commandAlter) {
+	registerPredefinedAlias(use, []string{script}, alter)
+	pa := predefinedAliases[strings.Split(use, " ")[0]]
+	pa.shell = true
+	predefinedAliases[pa.alias] = pa
+}
+
+// registerPredefinedStepsAlias registers alias those steps are built from the arguments provided by user
+func registerPredefinedStepsAlias(use string, description string, steps *stepsBuilder) {
+	registerPredefinedAlias(use, []string{description}, nil)
+	pa := predefinedAliases[strings.Split(use, " ")[0]]
+	pa.steps = steps
+	predefinedAliases[pa.alias] = pa
 }
 
 func init() {
 	aliasCmd.PersistentFlags().Bool(
 		flagConfirmExecution,
 		false,
-		"skip confirmation before executing the command, but wait few seconds before executing",
+		"skip confirmation before executing the command, but wait few seconds before executing. Not applied to aliases defined with 'confirm: always'",
 	)
 
 	rootCmd.AddCommand(aliasCmd)
 }
 
 type predefinedAlias struct {
-	alias      string
-	use        string
+	alias string
+	use   string
+	// command is the argv, or a single element contains the script in shell mode
 	command    []string
+	shell      bool
 	alter      *commandAlter
+	steps      *stepsBuilder
+	definition *aliasDefinition // defined in the structured alias file
 	overridden bool
 }
 
 type commandAlter func(command, args []string) []string
+
+// stepsBuilder builds the steps to be executed, from the arguments provided by user
+type stepsBuilder func(args []string) []aliasExecution
+
+// aliasExecution is the command to be executed of an alias, or of a step of alias
+type aliasExecution struct {
+	name string // name of the step, empty for single command alias
+	// command is the argv, or a single element contains the script in shell mode
+	command         []string
+	shell           bool
+	dir             string
+	env             []string // KEY=value, on top of current environment variables
+	continueOnError bool
+	retries         int
+	retryDelay      time.Duration
+	when            *aliasCondition
+}
+
+// print prints the command, the actual command and the execution context
+func (e aliasExecution) print() {
+	if e.shell {
+		fmt.Printf("> %s\n", e.command[0])
+		fmt.Printf("(actual command: [/bin/bash] [-c] [%s])\n", e.command[0])
+	} else {
+		quotedWords := make([]string, len(e.command))
+		for i, word := range e.command {
+			quotedWords[i] = utils.ShellQuote(word)
+		}
+		fmt.Printf("> %s\n", strings.Join(quotedWords, " "))
+		fmt.Printf("(actual command: [%s])\n", strings.Join(e.command, "] ["))
+	}
+	if len(e.dir) > 0 {
+		fmt.Printf("(working directory: %s)\n", e.dir)
+	}
+	if len(e.env) > 0 {
+		fmt.Printf("(environment variables: %s)\n", strings.Join(e.env, " "))
+	}
+}
+
+// run executes the command with direct std, returns the exit code
+func (e aliasExecution) run() int {
+	appName := e.command[0]
+	args := e.command[1:]
+	if e.shell {
+		appName = "/bin/bash"
+		args = []string{"-c", e.command[0]}
+	}
+
+	return utils.LaunchAppWithSetup(appName, args, func(launchCmd *exec.Cmd) {
+		launchCmd.Dir = e.dir
+		if len(e.env) > 0 {
+			launchCmd.Env = utils.OverlayEnvVars(os.Environ(), e.env...)
+		}
+		launchCmd.Stdin = os.Stdin
+		launchCmd.Stdout = os.Stdout
+		launchCmd.Stderr = os.Stderr
+	})
+}
@@ -0,0 +1,575 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// confirmation modes of alias defined in the structured alias file
+const (
+	aliasConfirmDefault = "default" // ask for confirmation, or wait few seconds when --yes is provided
+	aliasConfirmAlways  = "always"  // always ask for confirmation, even when --yes is provided
+	aliasConfirmNever   = "never"   // execute immediately
+)
+
+var regexAliasName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.-]*$`)
+var regexAliasParamName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z\d_-]*$`)
+
+// regexAliasPlaceholder matches named placeholder like {{name}} and positional placeholder like {{1}}
+var regexAliasPlaceholder = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*}}`)
+var regexAliasPlaceholderPrefix = regexp.MustCompile(`^\{\{\s*([^{}\s]*)\s*}}`)
+
+/*
+Sample content for structured alias file .hkd_alias.yaml:
+aliases:
+  say-hello:
+    description: Say hello to someone
+    command: echo "Hello {{name}}"
+    params:
+      - name: name
+        default: World
+*/
+
+// aliasFile is the structured alias file, YAML or TOML
+type aliasFile struct {
+	Aliases map[string]aliasDefinition `yaml:"aliases" toml:"aliases"`
+}
+
+// aliasDefinition is an alias defined in the structured alias file
+type aliasDefinition struct {
+	Description string `yaml:"description" toml:"description"`
+	// Command is a list of words, or a string which will be split into words, quotes are respected.
+	// In shell mode, the string is the script to be executed by bash.
+	Command aliasCommand `yaml:"command" toml:"command"`
+	// Shell executes the command (and the commands of steps) by bash, so shell features like pipes and && can be used
+	Shell bool `yaml:"shell" toml:"shell"`
+	// Steps are executed in order, mutually exclusive with Command
+	Steps []aliasStep `yaml:"steps" toml:"steps"`
+	// Params are bound to the input arguments, in order
+	Params []aliasParam `yaml:"params" toml:"params"`
+	// Dir is the working directory
+	Dir string `yaml:"dir" toml:"dir"`
+	// Env are the environment variables to be set, on top of current environment variables
+	Env     map[string]string `yaml:"env" toml:"env"`
+	Confirm string            `yaml:"confirm" toml:"confirm"`
+}
+
+// aliasParam is a parameter of alias, without default value the parameter is required
+type aliasParam struct {
+	Name        string  `yaml:"name" toml:"name"`
+	Default     *string `yaml:"default" toml:"default"`
+	Description string  `yaml:"description" toml:"description"`
+}
+
+// aliasCommand is the command, can be provided as a list of words or as a string
+type aliasCommand struct {
+	script string   // provided as a string
+	words  []string // provided as a list of words
+}
+
+func (c *aliasCommand) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		c.script = value.Value
+		return nil
+	}
+
+	var words []string
+	if err := value.Decode(&words); err != nil {
+		return errors.Wrap(err, "command must be a string or a list of string")
+	}
+	c.words = words
+	return nil
+}
+
+func (c *aliasCommand) UnmarshalTOML(data interface{}) error {
+	switch value := data.(type) {
+	case string:
+		c.script = value
+		return nil
+	case []interface{}:
+		words := make([]string, len(value))
+		for i, word := range value {
+			str, ok := word.(string)
+			if !ok {
+				return fmt.Errorf("command must be a string or a list of string")
+			}
+			words[i] = str
+		}
+		c.words = words
+		return nil
+	default:
+		return fmt.Errorf("command must be a string or a list of string")
+	}
+}
+
+func (c aliasCommand) isEmpty() bool {
+	return len(c.words) < 1 && len(c.script) < 1
+}
+
+// validate checks the command can be executed as argv, or as script in shell mode
+func (c aliasCommand) validate(shell bool) error {
+	if shell {
+		if len(strings.TrimSpace(c.shellScript())) < 1 {
+			return fmt.Errorf("missing command")
+		}
+		if placeholder := findAliasPlaceholderWithinBackticks(c.shellScript()); len(placeholder) > 0 {
+			return fmt.Errorf("placeholder %s is not supported within backticks, use $(...) instead", placeholder)
+		}
+		return nil
+	}
+
+	words, err := c.argv()
+	if err != nil {
+		return err
+	}
+	if len(words) < 1 || len(strings.TrimSpace(words[0])) < 1 {
+		return fmt.Errorf("missing command")
+	}
+	return nil
+}
+
+// argv returns the command words, the string command is split into words, quotes are respected
+func (c aliasCommand) argv() ([]string, error) {
+	if len(c.words) > 0 {
+		return c.words, nil
+	}
+	words, err := utils.SplitShellWords(c.script)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to split command [%s]", c.script))
+	}
+	return words, nil
+}
+
+// shellScript returns the command as script to be executed by bash, words are joined as-is
+func (c aliasCommand) shellScript() string {
+	if len(c.words) > 0 {
+		return strings.Join(c.words, " ")
+	}
+	return c.script
+}
+
+// String returns the command as displayed
+func (c aliasCommand) String() string {
+	if len(c.words) > 0 {
+		return strings.Join(c.words, " ")
+	}
+	return c.script
+}
+
+// loadAliasFile reads and validates the structured alias file, format is detected by file extension
+func loadAliasFile(aliasFilePath string) (*aliasFile, error) {
+	bz, err := os.ReadFile(aliasFilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read alias file %s", aliasFilePath))
+	}
+
+	var af aliasFile
+	if strings.EqualFold(filepath.Ext(aliasFilePath), ".toml") {
+		metadata, err := toml.Decode(string(bz), &af)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to parse alias file %s", aliasFilePath))
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("unknown field %s in alias file %s", undecoded[0], aliasFilePath)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(bz))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&af); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to parse alias file %s", aliasFilePath))
+		}
+	}
+
+	for name, definition := range af.Aliases {
+		if err := definition.validate(name); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid alias file %s", aliasFilePath))
+		}
+	}
+
+	return &af, nil
+}
+
+func (d aliasDefinition) validate(name string) error {
+	if !regexAliasName.MatchString(name) {
+		return fmt.Errorf("malformed alias name \"%s\"", name)
+	}
+
+	if len(d.Steps) > 0 {
+		if !d.Command.isEmpty() {
+			return fmt.Errorf("alias %s: command and steps are mutually exclusive", name)
+		}
+		for i, step := range d.Steps {
+			if err := step.validate(d.Shell); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("alias %s: step #%d", name, i+1))
+			}
+		}
+	} else if err := d.Command.validate(d.Shell); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("alias %s", name))
+	}
+
+	switch d.Confirm {
+	case "", aliasConfirmDefault, aliasConfirmAlways, aliasConfirmNever:
+		break
+	default:
+		return fmt.Errorf("alias %s: confirm must be one of %s, %s or %s", name, aliasConfirmDefault, aliasConfirmAlways, aliasConfirmNever)
+	}
+
+	uniqueParams := make(map[string]bool)
+	var hasOptionalParam bool
+	for i, param := range d.Params {
+		if !regexAliasParamName.MatchString(param.Name) {
+			return fmt.Errorf("alias %s: param #%d: malformed name \"%s\"", name, i+1, param.Name)
+		}
+		if uniqueParams[param.Name] {
+			return fmt.Errorf("alias %s: param %s: name is not unique", name, param.Name)
+		}
+		uniqueParams[param.Name] = true
+
+		// params are bound to arguments in order, so required params must come first
+		if param.Default != nil {
+			hasOptionalParam = true
+		} else if hasOptionalParam {
+			return fmt.Errorf("alias %s: required param %s must not follow optional params", name, param.Name)
+		}
+	}
+
+	for _, text := range d.templates() {
+		for _, placeholder := range regexAliasPlaceholder.FindAllStringSubmatch(text, -1) {
+			key := placeholder[1]
+			if index, err := strconv.Atoi(key); err == nil {
+				if index < 1 {
+					return fmt.Errorf("alias %s: positional placeholder %s must starts from 1", name, placeholder[0])
+				}
+				continue
+			}
+			if !uniqueParams[key] {
+				return fmt.Errorf("alias %s: placeholder %s does not match any param", name, placeholder[0])
+			}
+		}
+	}
+
+	return nil
+}
+
+// templates returns all the texts those placeholders can be used
+func (d aliasDefinition) templates() []string {
+	texts := append([]string{d.Dir, d.Command.script}, d.Command.words...)
+	for _, value := range d.Env {
+		texts = append(texts, value)
+	}
+	for _, step := range d.Steps {
+		texts = append(texts, step.templates()...)
+	}
+	return texts
+}
+
+// summary returns the description, or the command, or the step names when the description was not provided
+func (d aliasDefinition) summary() string {
+	if len(d.Description) > 0 {
+		return d.Description
+	}
+	if len(d.Steps) < 1 {
+		return d.Command.String()
+	}
+	names := make([]string, len(d.Steps))
+	for i, step := range d.Steps {
+		names[i] = step.Name
+		if len(names[i]) < 1 {
+			names[i] = fmt.Sprintf("step %d", i+1)
+		}
+	}
+	return fmt.Sprintf("%d steps: %s", len(names), strings.Join(names, ", "))
+}
+
+// use returns the usage of the alias, eg: "say-hello [?name]"
+func (d aliasDefinition) use(name string) string {
+	parts := []string{name}
+	for _, param := range d.Params {
+		if param.Default == nil {
+			parts = append(parts, fmt.Sprintf("[%s]", param.Name))
+		} else {
+			parts = append(parts, fmt.Sprintf("[?%s]", param.Name))
+		}
+	}
+	for i := len(d.Params) + 1; i <= d.maxPositionalPlaceholder(); i++ {
+		parts = append(parts, fmt.Sprintf("[#%d]", i))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d aliasDefinition) maxPositionalPlaceholder() int {
+	var maxIndex int
+	for _, text := range d.templates() {
+		for _, placeholder := range regexAliasPlaceholder.FindAllStringSubmatch(text, -1) {
+			if index, err := strconv.Atoi(placeholder[1]); err == nil && index > maxIndex {
+				maxIndex = index
+			}
+		}
+	}
+	return maxIndex
+}
+
+// render binds the input arguments to params and replaces placeholders of commands, working directory,
+// environment variables and step conditions. Each placeholder is replaced within a word so the value is never split,
+// in shell mode the values are quoted based on the quoting context where the placeholders are placed.
+// Returns the executions of the command, or of each step.
+func (d aliasDefinition) render(args []string) (executions []aliasExecution, err error) {
+	positionalCount := d.maxPositionalPlaceholder()
+	if positionalCount < len(d.Params) {
+		positionalCount = len(d.Params)
+	}
+
+	if len(args) > positionalCount {
+		err = fmt.Errorf("too many arguments, expected at most %d but got %d", positionalCount, len(args))
+		return
+	}
+
+	positional := make([]string, positionalCount)
+	named := make(map[string]string)
+	for i := 0; i < positionalCount; i++ {
+		var param *aliasParam
+		if i < len(d.Params) {
+			param = &d.Params[i]
+		}
+
+		if i < len(args) {
+			positional[i] = args[i]
+		} else if param != nil && param.Default != nil {
+			positional[i] = *param.Default
+		} else if param != nil {
+			err = fmt.Errorf("missing value for param %s", param.Name)
+			return
+		} else {
+			err = fmt.Errorf("missing value for argument #%d", i+1)
+			return
+		}
+
+		if param != nil {
+			named[param.Name] = positional[i]
+		}
+	}
+
+	valueOf := func(key string) string {
+		if index, errConvert := strconv.Atoi(key); errConvert == nil {
+			return positional[index-1]
+		}
+		return named[key]
+	}
+	replace := func(text string) string {
+		return regexAliasPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+			return valueOf(regexAliasPlaceholder.FindStringSubmatch(placeholder)[1])
+		})
+	}
+
+	newExecution := func(command aliasCommand, shell bool, dir string, env map[string]string) aliasExecution {
+		var execution aliasExecution
+		if shell {
+			execution.shell = true
+			execution.command = []string{replaceAliasPlaceholdersInShellScript(command.shellScript(), valueOf)}
+		} else {
+			words, _ := command.argv() // validated
+			for _, word := range words {
+				execution.command = append(execution.command, replace(word))
+			}
+		}
+
+		if len(dir) > 0 {
+			execution.dir = expandAliasHomeDir(replace(dir))
+		}
+
+		for _, key := range libutils.GetKeys(env) {
+			execution.env = append(execution.env, fmt.Sprintf("%s=%s", key, replace(env[key])))
+		}
+		sort.Strings(execution.env)
+
+		return execution
+	}
+
+	if len(d.Steps) < 1 {
+		executions = append(executions, newExecution(d.Command, d.Shell, d.Dir, d.Env))
+		return
+	}
+
+	for i, step := range d.Steps {
+		dir := d.Dir
+		if len(step.Dir) > 0 {
+			dir = step.Dir
+		}
+
+		// environment variables of step override the ones of alias
+		env := make(map[string]string)
+		for key, value := range d.Env {
+			env[key] = value
+		}
+		for key, value := range step.Env {
+			env[key] = value
+		}
+
+		execution := newExecution(step.Command, d.Shell || step.Shell, dir, env)
+		execution.name = step.Name
+		if len(execution.name) < 1 {
+			execution.name = fmt.Sprintf("step %d", i+1)
+		}
+		execution.continueOnError = step.ContinueOnError
+		execution.retries = step.Retries
+		execution.retryDelay = step.RetryDelay
+		if step.When != nil {
+			execution.when = &aliasCondition{
+				FileExists:    expandAliasHomeDir(replace(step.When.FileExists)),
+				BinaryExists:  replace(step.When.BinaryExists),
+				ServiceActive: replace(step.When.ServiceActive),
+			}
+		}
+
+		executions = append(executions, execution)
+	}
+
+	return
+}
+
+// quoting contexts of placeholders within shell script
+const (
+	shellContextUnquoted = iota
+	shellContextSingleQuoted
+	shellContextDoubleQuoted
+	// shellContextBacktick is within legacy command substitution `...`, which applies another round of backslash processing
+	shellContextBacktick
+)
+
+// shellQuotingState is the quoting state of a level of command substitution
+type shellQuotingState struct {
+	inSingleQuote bool
+	inDoubleQuote bool
+	// inBacktick indicates the level was opened by a backtick
+	inBacktick bool
+	// parens is the number of opened parentheses within the level, eg: $((1+2)) or (subshell)
+	parens int
+}
+
+// replaceAliasPlaceholdersInShellScript replaces placeholders of the shell script with the values,
+// quoted based on the context: within single quotes, within double quotes or unquoted,
+// so the values are always treated as a single literal word.
+// Quoting is tracked per level of command substitution $(...).
+// Placeholders within backticks are rejected by validation, the values are quoted as unquoted context.
+func replaceAliasPlaceholdersInShellScript(script string, valueOf func(key string) string) string {
+	return walkShellScriptPlaceholders(script, func(key string, context int) string {
+		value := valueOf(key)
+		switch context {
+		case shellContextSingleQuoted:
+			return strings.ReplaceAll(value, "'", `'"'"'`)
+		case shellContextDoubleQuoted:
+			var sb strings.Builder
+			for _, r := range value {
+				if strings.ContainsRune("\\\"$`", r) {
+					sb.WriteRune('\\')
+				}
+				sb.WriteRune(r)
+			}
+			return sb.String()
+		default:
+			return utils.ShellQuote(value)
+		}
+	})
+}
+
+// findAliasPlaceholderWithinBackticks returns the first placeholder placed within backticks, empty if not any.
+// Values can not be quoted safely there because of the extra round of backslash processing.
+func findAliasPlaceholderWithinBackticks(script string) string {
+	var found string
+	walkShellScriptPlaceholders(script, func(key string, context int) string {
+		if context == shellContextBacktick && len(found) < 1 {
+			found = fmt.Sprintf("{{%s}}", key)
+		}
+		return ""
+	})
+	return found
+}
+
+// walkShellScriptPlaceholders walks through the shell script, replaces each placeholder by the output of replace,
+// which is provided the quoting context where the placeholder is placed.
+func walkShellScriptPlaceholders(script string, replace func(key string, context int) string) string {
+	var sb strings.Builder
+	levels := []shellQuotingState{{}}
+
+	for i := 0; i < len(script); {
+		level := &levels[len(levels)-1]
+
+		if match := regexAliasPlaceholderPrefix.FindStringSubmatch(script[i:]); match != nil {
+			context := shellContextUnquoted
+			switch {
+			case level.inSingleQuote:
+				context = shellContextSingleQuoted
+			case level.inDoubleQuote:
+				context = shellContextDoubleQuoted
+			}
+			for _, l := range levels {
+				if l.inBacktick {
+					context = shellContextBacktick
+				}
+			}
+			sb.WriteString(replace(match[1], context))
+			i += len(match[0])
+			continue
+		}
+
+		c := script[i]
+		isQuoted := level.inSingleQuote || level.inDoubleQuote
+		switch {
+		case c == '\\' && !level.inSingleQuote && i+1 < len(script):
+			sb.WriteString(script[i : i+2])
+			i += 2
+			continue
+		case c == '$' && !level.inSingleQuote && strings.HasPrefix(script[i:], "$("):
+			levels = append(levels, shellQuotingState{})
+			sb.WriteString("$(")
+			i += 2
+			continue
+		case c == '`' && !level.inSingleQuote:
+			if level.inBacktick && !level.inDoubleQuote {
+				levels = levels[:len(levels)-1]
+			} else {
+				levels = append(levels, shellQuotingState{inBacktick: true})
+			}
+		case c == '(' && !isQuoted && len(levels) > 1:
+			level.parens++
+		case c == ')' && !isQuoted && len(levels) > 1 && !level.inBacktick:
+			if level.parens > 0 {
+				level.parens--
+			} else {
+				levels = levels[:len(levels)-1]
+			}
+		case c == '\'' && !level.inDoubleQuote:
+			level.inSingleQuote = !level.inSingleQuote
+		case c == '"' && !level.inSingleQuote:
+			level.inDoubleQuote = !level.inDoubleQuote
+		}
+		sb.WriteByte(c)
+		i++
+	}
+
+	return sb.String()
+}
+
+// expandAliasHomeDir expands the leading ~ of the path into home directory
+func expandAliasHomeDir(dir string) string {
+	if dir != "~" && !strings.HasPrefix(dir, "~/") {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dir
+	}
+	return path.Join(home, dir[1:])
+}
@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_loadAliasFile(t *testing.T) {
+	dir := t.TempDir()
+
+	const yamlContent = `
+aliases:
+  say-hello:
+    description: Say hello to someone
+    command: echo "Hello {{name}}" '{{greeting}}'
+    params:
+      - name: name
+      - name: greeting
+        default: "Have a nice day"
+    dir: ~/{{name}}
+    env:
+      NAME: "{{name}}"
+    confirm: never
+  copy:
+    command: ["cp", "{{1}}", "{{2}}"]
+`
+	const tomlContent = `
+[aliases.say-hello]
+description = "Say hello to someone"
+command = 'echo "Hello {{name}}" "{{greeting}}"'
+dir = "~/{{name}}"
+env = { NAME = "{{name}}" }
+confirm = "never"
+
+[[aliases.say-hello.params]]
+name = "name"
+
+[[aliases.say-hello.params]]
+name = "greeting"
+default = "Have a nice day"
+
+[aliases.copy]
+command = ["cp", "{{1}}", "{{2}}"]
+`
+
+	home, _ := os.UserHomeDir()
+
+	for fileName, content := range map[string]string{
+		".hkd_alias.yaml": yamlContent,
+		".hkd_alias.toml": tomlContent,
+	} {
+		t.Run(fileName, func(t *testing.T) {
+			aliasFilePath := path.Join(dir, fileName)
+			if err := os.WriteFile(aliasFilePath, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			af, err := loadAliasFile(aliasFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sayHello := af.Aliases["say-hello"]
+			if got := sayHello.use("say-hello"); got != "say-hello [name] [?greeting]" {
+				t.Errorf("use = %s", got)
+			}
+			if sayHello.Confirm != aliasConfirmNever {
+				t.Errorf("confirm = %s", sayHello.Confirm)
+			}
+
+			executions, err := sayHello.render([]string{"John Doe"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			execution := executions[0]
+			if want := []string{"echo", "Hello John Doe", "Have a nice day"}; !reflect.DeepEqual(execution.command, want) {
+				t.Errorf("command = %q, want %q", execution.command, want)
+			}
+			if want := path.Join(home, "John Doe"); execution.dir != want {
+				t.Errorf("dir = %s, want %s", execution.dir, want)
+			}
+			if want := []string{"NAME=John Doe"}; !reflect.DeepEqual(execution.env, want) {
+				t.Errorf("env = %q, want %q", execution.env, want)
+			}
+
+			copyAlias := af.Aliases["copy"]
+			if got := copyAlias.use("copy"); got != "copy [#1] [#2]" {
+				t.Errorf("use = %s", got)
+			}
+			executions, err = copyAlias.render([]string{"a b", "c'd"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			execution = executions[0]
+			if want := []string{"cp", "a b", "c'd"}; !reflect.DeepEqual(execution.command, want) {
+				t.Errorf("command = %q, want %q", execution.command, want)
+			}
+		})
+	}
+}
+
+func Test_aliasDefinition_render(t *testing.T) {
+	defaultValue := "default"
+	definition := aliasDefinition{
+		Command: aliasCommand{words: []string{"echo", "{{required}}", "{{optional}}", "{{1}}-{{ 2 }}"}},
+		Params: []aliasParam{
+			{Name: "required"},
+			{Name: "optional", Default: &defaultValue},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "default value",
+			args: []string{"a"},
+			want: []string{"echo", "a", "default", "a-default"},
+		},
+		{
+			name: "all provided",
+			args: []string{"a", "$(reboot)"},
+			want: []string{"echo", "a", "$(reboot)", "a-$(reboot)"},
+		},
+		{
+			name:    "missing required",
+			args:    nil,
+			wantErr: "missing value for param required",
+		},
+		{
+			name:    "too many",
+			args:    []string{"a", "b", "c"},
+			wantErr: "too many arguments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executions, err := definition.render(tt.args)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("render() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(executions) != 1 || !reflect.DeepEqual(executions[0].command, tt.want) {
+				t.Errorf("render() = %v, want %q", executions, tt.want)
+			}
+		})
+	}
+}
+
+func Test_aliasDefinition_validate(t *testing.T) {
+	defaultValue := ""
+	tests := []struct {
+		name       string
+		alias      string
+		definition aliasDefinition
+		wantErr    bool
+	}{
+		{
+			name:       "valid",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo", "{{1}}"}}},
+		},
+		{
+			name:       "malformed alias name",
+			alias:      "say hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo"}}},
+			wantErr:    true,
+		},
+		{
+			name:       "malformed command",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{script: `echo "Hello`}},
+			wantErr:    true,
+		},
+		{
+			name:       "shell mode",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{script: "echo Hello && echo World"}, Shell: true},
+		},
+		{
+			name:       "shell mode, placeholder within backticks",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{script: "echo `echo {{1}}`"}, Shell: true},
+			wantErr:    true,
+		},
+		{
+			name:       "shell mode, placeholder within command substitution",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{script: "echo \"$(echo '{{1}}')\""}, Shell: true},
+		},
+		{
+			name:       "missing command",
+			alias:      "say-hello",
+			definition: aliasDefinition{},
+			wantErr:    true,
+		},
+		{
+			name:       "bad confirm",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo"}}, Confirm: "sometimes"},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown placeholder",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo", "{{name}}"}}},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown placeholder in env",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo"}}, Env: map[string]string{"A": "{{name}}"}},
+			wantErr:    true,
+		},
+		{
+			name:       "positional placeholder starts from 0",
+			alias:      "say-hello",
+			definition: aliasDefinition{Command: aliasCommand{words: []string{"echo", "{{0}}"}}},
+			wantErr:    true,
+		},
+		{
+			name:  "duplicated param",
+			alias: "say-hello",
+			definition: aliasDefinition{
+				Command: aliasCommand{words: []string{"echo"}},
+				Params:  []aliasParam{{Name: "a"}, {Name: "a"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "required param follows optional param",
+			alias: "say-hello",
+			definition: aliasDefinition{
+				Command: aliasCommand{words: []string{"echo"}},
+				Params:  []aliasParam{{Name: "a", Default: &defaultValue}, {Name: "b"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.definition.validate(tt.alias); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_replaceAliasPlaceholdersInShellScript(t *testing.T) {
+	values := map[string]string{
+		"name": `O'Neil "$(reboot)" \`,
+		"1":    "main",
+	}
+	valueOf := func(key string) string {
+		return values[key]
+	}
+
+	tests := []struct {
+		script string
+		want   string
+		// the output of bash must end with the value of name
+		printsName bool
+	}{
+		{
+			script: "git checkout {{1}} && git pull",
+			want:   "git checkout main && git pull",
+		},
+		{
+			script:     "echo {{name}}",
+			want:       `echo 'O'"'"'Neil "$(reboot)" \'`,
+			printsName: true,
+		},
+		{
+			script:     `echo "Hello {{name}}"`,
+			want:       `echo "Hello O'Neil \"\$(reboot)\" \\"`,
+			printsName: true,
+		},
+		{
+			script:     `echo 'Hello {{name}}'`,
+			want:       `echo 'Hello O'"'"'Neil "$(reboot)" \'`,
+			printsName: true,
+		},
+		{
+			script: `echo "it's" {{1}} 'say "hi"' {{1}} \' {{1}}`,
+			want:   `echo "it's" main 'say "hi"' main \' main`,
+		},
+		{
+			script:     `echo "$(printf '%s' '{{name}}')"`,
+			want:       `echo "$(printf '%s' 'O'"'"'Neil "$(reboot)" \')"`,
+			printsName: true,
+		},
+		{
+			script:     `echo "$(printf '%s' {{name}})"`,
+			want:       `echo "$(printf '%s' 'O'"'"'Neil "$(reboot)" \')"`,
+			printsName: true,
+		},
+		{
+			script:     `echo "$(echo "$((1 + 1)) {{name}}")"`,
+			want:       `echo "$(echo "$((1 + 1)) O'Neil \"\$(reboot)\" \\")"`,
+			printsName: true,
+		},
+		{
+			script:     `echo "$(printf '%s' "$(echo '(')")" '{{name}}'`,
+			want:       `echo "$(printf '%s' "$(echo '(')")" 'O'"'"'Neil "$(reboot)" \'`,
+			printsName: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			got := replaceAliasPlaceholdersInShellScript(tt.script, valueOf)
+			if got != tt.want {
+				t.Errorf("replaceAliasPlaceholdersInShellScript() = %s, want %s", got, tt.want)
+			}
+
+			if tt.printsName {
+				output, err := exec.Command("/bin/bash", "-c", got).Output()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasSuffix(strings.TrimSpace(string(output)), values["name"]) {
+					t.Errorf("bash output = %s", output)
+				}
+			}
+		})
+	}
+}
+
+func Test_findAliasPlaceholderWithinBackticks(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{
+			script: "echo `date` {{name}}",
+			want:   "",
+		},
+		{
+			script: "echo `printf '%s' '{{name}}'`",
+			want:   "{{name}}",
+		},
+		{
+			script: "echo \"`echo {{1}}`\"",
+			want:   "{{1}}",
+		},
+		{
+			script: "echo '`{{name}}`' \\`{{1}}",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			if got := findAliasPlaceholderWithinBackticks(tt.script); got != tt.want {
+				t.Errorf("findAliasPlaceholderWithinBackticks() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
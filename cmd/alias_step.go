@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// results of alias steps
+const (
+	aliasStepResultOk          = "OK"
+	aliasStepResultFailed      = "FAILED"
+	aliasStepResultIgnored     = "FAILED, IGNORED"
+	aliasStepResultSkipped     = "SKIPPED"
+	aliasStepResultNotExecuted = "NOT EXECUTED"
+)
+
+// aliasStep is a step of multi-step alias defined in the structured alias file
+type aliasStep struct {
+	Name    string       `yaml:"name" toml:"name"`
+	Command aliasCommand `yaml:"command" toml:"command"`
+	Shell   bool         `yaml:"shell" toml:"shell"`
+	// Dir is the working directory, default is the working directory of alias
+	Dir string `yaml:"dir" toml:"dir"`
+	// Env are the environment variables to be set, on top of the environment variables of alias
+	Env map[string]string `yaml:"env" toml:"env"`
+	// ContinueOnError executes the next steps even though this step failed
+	ContinueOnError bool `yaml:"continue_on_error" toml:"continue_on_error"`
+	// Retries is the number of retries when the step failed
+	Retries    int           `yaml:"retries" toml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay" toml:"retry_delay"`
+	// When is the conditions must be satisfied to execute the step, otherwise the step is skipped
+	When *aliasCondition `yaml:"when" toml:"when"`
+}
+
+// aliasCondition is the conditions to execute a step, all the provided conditions must be satisfied
+type aliasCondition struct {
+	FileExists    string `yaml:"file_exists" toml:"file_exists"`
+	BinaryExists  string `yaml:"binary_exists" toml:"binary_exists"`
+	ServiceActive string `yaml:"service_active" toml:"service_active"`
+}
+
+func (s aliasStep) validate(aliasShell bool) error {
+	if err := s.Command.validate(aliasShell || s.Shell); err != nil {
+		return err
+	}
+
+	if s.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+
+	if s.RetryDelay < 0 {
+		return fmt.Errorf("retry_delay must not be negative")
+	}
+
+	if s.When != nil && len(s.When.FileExists) < 1 && len(s.When.BinaryExists) < 1 && len(s.When.ServiceActive) < 1 {
+		return fmt.Errorf("when: at least one condition file_exists/binary_exists/service_active is required")
+	}
+
+	return nil
+}
+
+// templates returns all the texts those placeholders can be used
+func (s aliasStep) templates() []string {
+	texts := append([]string{s.Dir, s.Command.script}, s.Command.words...)
+	for _, value := range s.Env {
+		texts = append(texts, value)
+	}
+	if s.When != nil {
+		texts = append(texts, s.When.FileExists, s.When.BinaryExists, s.When.ServiceActive)
+	}
+	return texts
+}
+
+// evaluate checks the conditions, returns the reason when any condition is not satisfied
+func (c aliasCondition) evaluate() (satisfied bool, reason string) {
+	if len(c.FileExists) > 0 {
+		if _, err := os.Stat(c.FileExists); err != nil {
+			return false, fmt.Sprintf("file %s does not exist", c.FileExists)
+		}
+	}
+
+	if len(c.BinaryExists) > 0 && !utils.HasBinaryName(c.BinaryExists) {
+		return false, fmt.Sprintf("binary %s does not exist", c.BinaryExists)
+	}
+
+	if len(c.ServiceActive) > 0 {
+		if !utils.IsServiceActive(c.ServiceActive) {
+			return false, fmt.Sprintf("service %s is not active", c.ServiceActive)
+		}
+	}
+
+	return true, ""
+}
+
+func (c aliasCondition) String() string {
+	var conditions []string
+	if len(c.FileExists) > 0 {
+		conditions = append(conditions, fmt.Sprintf("file %s exists", c.FileExists))
+	}
+	if len(c.BinaryExists) > 0 {
+		conditions = append(conditions, fmt.Sprintf("binary %s exists", c.BinaryExists))
+	}
+	if len(c.ServiceActive) > 0 {
+		conditions = append(conditions, fmt.Sprintf("service %s is active", c.ServiceActive))
+	}
+	return strings.Join(conditions, " and ")
+}
+
+// printAliasExecutions prints the command, or each step, to be executed
+func printAliasExecutions(executions []aliasExecution) {
+	if len(executions) == 1 && len(executions[0].name) < 1 {
+		executions[0].print()
+		return
+	}
+
+	for i, execution := range executions {
+		fmt.Printf("[%d/%d] %s\n", i+1, len(executions), execution.name)
+		execution.print()
+		if execution.when != nil {
+			fmt.Printf("(only when %s)\n", execution.when)
+		}
+		if execution.retries > 0 {
+			fmt.Printf("(retries %d times, delay %s)\n", execution.retries, execution.retryDelay)
+		}
+		if execution.continueOnError {
+			fmt.Println("(continue on error)")
+		}
+	}
+}
+
+// runAliasExecutions executes the command, or executes the steps in order then prints the result of each step.
+// Returns the exit code.
+func runAliasExecutions(executions []aliasExecution) int {
+	if len(executions) == 1 && len(executions[0].name) < 1 {
+		return executions[0].run()
+	}
+
+	results := make([]string, len(executions))
+	var ec int
+	for i, execution := range executions {
+		if ec != 0 {
+			results[i] = aliasStepResultNotExecuted
+			continue
+		}
+
+		progress := fmt.Sprintf("[%d/%d] %s", i+1, len(executions), execution.name)
+
+		if execution.when != nil {
+			if satisfied, reason := execution.when.evaluate(); !satisfied {
+				results[i] = fmt.Sprintf("%s, %s", aliasStepResultSkipped, reason)
+				fmt.Printf("==> %s: %s\n", progress, results[i])
+				continue
+			}
+		}
+
+		fmt.Printf("==> %s\n", progress)
+
+		if stepEc := execution.runWithRetries(); stepEc == 0 {
+			results[i] = aliasStepResultOk
+		} else if execution.continueOnError {
+			results[i] = aliasStepResultIgnored
+		} else {
+			results[i] = aliasStepResultFailed
+			ec = stepEc
+		}
+
+		fmt.Printf("==> %s: %s\n", progress, results[i])
+	}
+
+	fmt.Println("Summary:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, execution := range executions {
+		_, _ = fmt.Fprintf(tw, " %d\t%s\t%s\n", i+1, execution.name, results[i])
+	}
+	_ = tw.Flush()
+
+	return ec
+}
+
+// runWithRetries executes the command, retries when failed. Returns the exit code of the last attempt.
+func (e aliasExecution) runWithRetries() int {
+	ec := e.run()
+	for attempt := 1; ec != 0 && attempt <= e.retries; attempt++ {
+		fmt.Printf("Failed with exit code %d, retrying (%d/%d) in %s...\n", ec, attempt, e.retries, e.retryDelay)
+		time.Sleep(e.retryDelay)
+		ec = e.run()
+	}
+	return ec
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_loadAliasFile_steps(t *testing.T) {
+	dir := t.TempDir()
+
+	const yamlContent = `
+aliases:
+  upgrade:
+    params:
+      - name: version
+    env:
+      VERSION: "{{version}}"
+    steps:
+      - name: stop
+        command: sudo systemctl stop evmosd
+        when:
+          service_active: evmosd
+      - name: download
+        command: curl -fsSLo /tmp/evmosd "https://example.com/{{version}}/evmosd"
+        retries: 3
+        retry_delay: 5s
+      - command: ls /tmp/evmosd && echo downloaded
+        shell: true
+        continue_on_error: true
+        env:
+          VERSION: "v{{version}}"
+`
+	const tomlContent = `
+[aliases.upgrade]
+params = [{ name = "version" }]
+env = { VERSION = "{{version}}" }
+
+[[aliases.upgrade.steps]]
+name = "stop"
+command = "sudo systemctl stop evmosd"
+when = { service_active = "evmosd" }
+
+[[aliases.upgrade.steps]]
+name = "download"
+command = 'curl -fsSLo /tmp/evmosd "https://example.com/{{version}}/evmosd"'
+retries = 3
+retry_delay = "5s"
+
+[[aliases.upgrade.steps]]
+command = "ls /tmp/evmosd && echo downloaded"
+shell = true
+continue_on_error = true
+env = { VERSION = "v{{version}}" }
+`
+
+	for fileName, content := range map[string]string{
+		".hkd_alias.yaml": yamlContent,
+		".hkd_alias.toml": tomlContent,
+	} {
+		t.Run(fileName, func(t *testing.T) {
+			aliasFilePath := path.Join(dir, fileName)
+			if err := os.WriteFile(aliasFilePath, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			af, err := loadAliasFile(aliasFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			executions, err := af.Aliases["upgrade"].render([]string{"1.0.0"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []aliasExecution{
+				{
+					name:    "stop",
+					command: []string{"sudo", "systemctl", "stop", "evmosd"},
+					env:     []string{"VERSION=1.0.0"},
+					when:    &aliasCondition{ServiceActive: "evmosd"},
+				},
+				{
+					name:       "download",
+					command:    []string{"curl", "-fsSLo", "/tmp/evmosd", "https://example.com/1.0.0/evmosd"},
+					env:        []string{"VERSION=1.0.0"},
+					retries:    3,
+					retryDelay: 5 * time.Second,
+				},
+				{
+					name:            "step 3",
+					command:         []string{"ls /tmp/evmosd && echo downloaded"},
+					shell:           true,
+					env:             []string{"VERSION=v1.0.0"},
+					continueOnError: true,
+				},
+			}
+			if !reflect.DeepEqual(executions, want) {
+				t.Errorf("render() = %+v, want %+v", executions, want)
+			}
+		})
+	}
+}
+
+func Test_aliasStep_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    aliasStep
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			step: aliasStep{Command: aliasCommand{script: "echo"}, Retries: 1, When: &aliasCondition{BinaryExists: "echo"}},
+		},
+		{
+			name:    "missing command",
+			step:    aliasStep{},
+			wantErr: true,
+		},
+		{
+			name:    "negative retries",
+			step:    aliasStep{Command: aliasCommand{script: "echo"}, Retries: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative retry delay",
+			step:    aliasStep{Command: aliasCommand{script: "echo"}, RetryDelay: -time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "empty condition",
+			step:    aliasStep{Command: aliasCommand{script: "echo"}, When: &aliasCondition{}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.step.validate(false); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("command and steps are mutually exclusive", func(t *testing.T) {
+		definition := aliasDefinition{
+			Command: aliasCommand{script: "echo"},
+			Steps:   []aliasStep{{Command: aliasCommand{script: "echo"}}},
+		}
+		if err := definition.validate("alias"); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+			t.Errorf("validate() error = %v", err)
+		}
+	})
+}
+
+func Test_runAliasExecutions(t *testing.T) {
+	dir := t.TempDir()
+	logFile := path.Join(dir, "log")
+
+	// appends the step name into the log file, then exit with the provided code
+	step := func(name string, ec int) aliasExecution {
+		return aliasExecution{
+			name:    name,
+			command: []string{fmt.Sprintf("echo %s >> %s; exit %d", name, logFile, ec)},
+			shell:   true,
+		}
+	}
+
+	readLog := func() []string {
+		bz, _ := os.ReadFile(logFile)
+		_ = os.Remove(logFile)
+		return strings.Fields(string(bz))
+	}
+
+	t.Run("retries then continue on error", func(t *testing.T) {
+		retried := step("retried", 1)
+		retried.retries = 2
+		retried.continueOnError = true
+		ignored := step("ignored", 1)
+		ignored.continueOnError = true
+		skipped := step("skipped", 0)
+		skipped.when = &aliasCondition{FileExists: path.Join(dir, "not-exists")}
+		executed := step("executed", 0)
+		executed.when = &aliasCondition{FileExists: dir, BinaryExists: "sh"}
+
+		ec := runAliasExecutions([]aliasExecution{retried, ignored, skipped, executed})
+		if ec != 0 {
+			t.Errorf("exit code = %d, want 0", ec)
+		}
+		if got, want := readLog(), []string{"retried", "retried", "retried", "ignored", "executed"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("executed = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stop at failed step", func(t *testing.T) {
+		ec := runAliasExecutions([]aliasExecution{step("first", 0), step("failed", 2), step("not-executed", 0)})
+		if ec == 0 {
+			t.Errorf("exit code must not be zero")
+		}
+		if got, want := readLog(), []string{"first", "failed"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("executed = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_genericAlterJournalctl(t *testing.T) {
+	got := genericAlterJournalctl([]string{"sudo", "journalctl", "-fu", "evmosd"}, []string{"1 hour ago';", "reboot"})
+	want := []string{"sudo", "journalctl", "-fu", "evmosd", "--since", "1 hour ago'; reboot"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("genericAlterJournalctl() = %q, want %q", got, want)
+	}
+}
+
+func Test_gitPullSteps(t *testing.T) {
+	var names []string
+	var commands [][]string
+	for _, step := range gitPullSteps([]string{"main", "feat/x;reboot"}) {
+		names = append(names, step.name)
+		commands = append(commands, step.command)
+		if step.shell {
+			t.Errorf("step %s must not be executed by shell", step.name)
+		}
+	}
+
+	if want := []string{"fetch", "checkout main", "pull main", "checkout feat/x;reboot", "pull feat/x;reboot"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+
+	if want := []string{"git", "checkout", "feat/x;reboot"}; !reflect.DeepEqual(commands[3], want) {
+		t.Errorf("command = %q, want %q", commands[3], want)
+	}
+
+	if got := gitPullSteps(nil); len(got) != 3 || got[1].name != "checkout main" {
+		t.Errorf("default branch must be main, got %v", got)
+	}
+}
@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"fmt"
 	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
 	"github.com/spf13/cobra"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	flagGitUser  = "git-user"
+	flagGitToken = "git-token"
 )
 
 // budCmd represents the Butler install command
@@ -23,45 +32,19 @@ var budCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		netrcPath := path.Join(home, ".netrc")
-		isNetrcFileExists, err := utils.IsFileAndExists(netrcPath)
-		if err != nil {
-			libutils.PrintlnStdErr("ERR: failed to check if .netrc file exists:", err.Error())
-			os.Exit(1)
-		}
-
-		var createNetrcFile bool
-		if !isNetrcFileExists {
-			createNetrcFile = true
-		} else {
-			bz, _ := os.ReadFile(netrcPath)
-			createNetrcFile = len(bz) < 23
+		gitUser, _ := cmd.Flags().GetString(flagGitUser)
+		gitUser = strings.TrimSpace(gitUser)
+		if len(gitUser) < 1 {
+			// GitHub ignores user name when authenticating using token
+			gitUser = "x-access-token"
 		}
 
-		if createNetrcFile {
-			gitUser := readStdIn("Git user:")
-			gitToken := readStdIn("Git token:")
-			netrcContent := fmt.Sprintf("\nmachine github.com\nlogin %s\npassword %s\n", gitUser, gitToken)
-
-			outputFile, err := os.OpenFile(netrcPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
-			if err != nil {
-				libutils.PrintlnStdErr("ERR: failed to open .netrc file:", err)
-				os.Exit(1)
-			}
-
-			if _, err := outputFile.WriteString(netrcContent); err != nil {
-				libutils.PrintlnStdErr("ERR: failed to write to .netrc file:", err)
-				_ = outputFile.Close()
-				os.Exit(1)
-			}
-
-			_ = outputFile.Close()
-		}
+		gitEnvVars := buildGitAuthEnvVars(os.Environ(), gitUser, readButlerGitToken(cmd))
 
 		butlerRepoPath := path.Join(home, constants.BUTLER_REPO_DIR_NAME)
 
 		runGit := func(workingDir string, args ...string) {
-			ec := utils.LaunchAppWithDirectStd("git", append([]string{"-C", workingDir}, args...), nil)
+			ec := utils.LaunchAppWithDirectStd("git", append([]string{"-C", workingDir}, args...), gitEnvVars)
 			if ec != 0 {
 				libutils.PrintlnStdErr("ERR: Exited with code", ec)
 				os.Exit(ec)
@@ -96,6 +79,7 @@ var budCmd = &cobra.Command{
 		fmt.Println("Installing Butler")
 		ec := utils.LaunchAppWithSetup("make", []string{"install"}, func(launchCmd *exec.Cmd) {
 			launchCmd.Dir = butlerRepoPath
+			launchCmd.Env = gitEnvVars
 			launchCmd.Stdin = os.Stdin
 			launchCmd.Stdout = os.Stdout
 			launchCmd.Stderr = os.Stderr
@@ -112,15 +96,63 @@ var budCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(budCmd)
+
+	budCmd.PersistentFlags().String(
+		flagGitUser,
+		"",
+		"git user to access Butler repo, not required when using token",
+	)
+
+	budCmd.PersistentFlags().String(
+		flagGitToken,
+		"",
+		fmt.Sprintf("file path which store git token to access Butler repo, %s. If not provided, token stored in vault as secret '%s' is used, or prompted then stored into vault", secrets.SourceUsage, constants.BUTLER_GIT_TOKEN_SECRET_NAME),
+	)
 }
 
-func readStdIn(question string) string {
-	fmt.Print(question)
-	var input string
-	_, _ = fmt.Scanln(&input)
-	if len(input) < 1 {
-		libutils.PrintlnStdErr("ERR: input is empty, please input again")
-		return readStdIn(question)
+// readButlerGitToken reads git token from the source provided via flag,
+// otherwise from the vault, token is prompted and stored into the vault if not exists.
+func readButlerGitToken(cmd *cobra.Command) string {
+	gitTokenSource, _ := cmd.Flags().GetString(flagGitToken)
+	if len(strings.TrimSpace(gitTokenSource)) > 0 {
+		return secrets.ReadSecret(gitTokenSource)
+	}
+
+	vault := secrets.OpenOrCreateVault()
+	if gitToken, found := vault.Get(constants.BUTLER_GIT_TOKEN_SECRET_NAME); found {
+		fmt.Println("Using git token stored in vault as secret", constants.BUTLER_GIT_TOKEN_SECRET_NAME)
+		return gitToken
 	}
-	return input
+
+	gitToken := secrets.PromptSecret("Git token:")
+	vault.Set(constants.BUTLER_GIT_TOKEN_SECRET_NAME, gitToken)
+	if err := vault.Save(); err != nil {
+		libutils.PrintlnStdErr("ERR: failed to save git token into vault:", err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("Stored git token into vault as secret", constants.BUTLER_GIT_TOKEN_SECRET_NAME)
+
+	return gitToken
+}
+
+// buildGitAuthEnvVars builds environment variables which provide HTTP authorization header to git,
+// so the token is not persisted into ~/.netrc or git config.
+// The header is appended after the GIT_CONFIG_* entries provided by the base environment variables.
+func buildGitAuthEnvVars(base []string, gitUser, gitToken string) []string {
+	var count int
+	for _, envVar := range base {
+		if value := strings.TrimPrefix(envVar, "GIT_CONFIG_COUNT="); value != envVar {
+			if existingCount, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && existingCount > 0 {
+				count = existingCount
+			}
+		}
+	}
+
+	credentials := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", gitUser, gitToken)))
+	return utils.OverlayEnvVars(
+		base,
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=http.https://github.com/.extraheader", count),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=AUTHORIZATION: basic %s", count, credentials),
+	)
 }
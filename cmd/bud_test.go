@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func Test_buildGitAuthEnvVars(t *testing.T) {
+	header := "AUTHORIZATION: basic " + base64.StdEncoding.EncodeToString([]byte("user:token"))
+
+	tests := []struct {
+		name string
+		base []string
+		want []string
+	}{
+		{
+			name: "no existing git config",
+			base: []string{"HOME=/home/user"},
+			want: []string{
+				"HOME=/home/user",
+				"GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.https://github.com/.extraheader",
+				"GIT_CONFIG_VALUE_0=" + header,
+			},
+		},
+		{
+			name: "existing git config entries are kept",
+			base: []string{
+				"GIT_CONFIG_COUNT=2",
+				"GIT_CONFIG_KEY_0=core.sshCommand",
+				"GIT_CONFIG_VALUE_0=ssh -i ~/.ssh/deploy",
+				"GIT_CONFIG_KEY_1=safe.directory",
+				"GIT_CONFIG_VALUE_1=*",
+			},
+			want: []string{
+				"GIT_CONFIG_KEY_0=core.sshCommand",
+				"GIT_CONFIG_VALUE_0=ssh -i ~/.ssh/deploy",
+				"GIT_CONFIG_KEY_1=safe.directory",
+				"GIT_CONFIG_VALUE_1=*",
+				"GIT_CONFIG_COUNT=3",
+				"GIT_CONFIG_KEY_2=http.https://github.com/.extraheader",
+				"GIT_CONFIG_VALUE_2=" + header,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildGitAuthEnvVars(tt.base, "user", "token"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildGitAuthEnvVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package config
 
 import (
 	"fmt"
-	"github.com/EscanBE/go-ienumerable/goe"
 	libutils "github.com/EscanBE/go-lib/utils"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
@@ -14,36 +13,99 @@ import (
 )
 
 const (
+	flagInputFilePath               = "input"
+	flagInputFormat                 = "input-format"
 	flagTsvInputFilePath            = "tsv-input"
 	flagSshConfigOutputFilePath     = "output-file"
 	flagOverrideSshConfigOutputFile = "override"
 	flagSshKeyPathRoot              = "key-root"
 	flagSshKeyPathUser              = "key-user"
 	flagSshKeyPathPerUser           = "key-per-user"
+	flagMergeSshConfigOutputFile    = "merge"
+	flagDiffSshConfigOutputFile     = "diff"
+	flagSshHostTags                 = "tag"
 
 	tsvLineFormat = "Host<tab>HostName<tab>User(<tab># Optional comment)"
 )
 
-var tsvInputFilePath, sshConfigOutputFilePath, sshKeyPathRoot, sshKeyPathUser string
-var overrideSshConfigOutputFile bool
-var sshKeyPathPerUser []string
+var inputFilePath, inputFormat, tsvInputFilePath, sshConfigOutputFilePath, sshKeyPathRoot, sshKeyPathUser string
+var overrideSshConfigOutputFile, mergeSshConfigOutputFile, diffSshConfigOutputFile bool
+var sshKeyPathPerUser, sshHostTags []string
 
 // ConfigureSshCommands registers a sub-tree of commands
 func ConfigureSshCommands() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "ssh",
 		Short: "Configure ~/.ssh/config file",
-		Args:  cobra.NoArgs,
-		Run:   configureSshConfigFile,
+		Long: fmt.Sprintf(`Configure ~/.ssh/config file.
+Generate SSH config from the input inventory into a new file, or merge into an existing SSH config file using --%s:
+only hosts between the marker comments '%s' and '%s' are managed, hand-written entries are left untouched.
+Use --%s to preview the change without writing.
+
+Inventory can be TSV, CSV or YAML, format is detected by file extension (.tsv, .csv, .yaml/.yml) or provided via --%s.
+- Legacy TSV without header, each line format: %s
+- TSV/CSV with header row, columns are mapped by name, supported columns: %s
+  Multiple LocalForward/tags are separated by '%s', eg: 8080 localhost:80%s9090 localhost:90
+- YAML:
+defaults:
+  user: ubuntu
+groups:
+  - name: prod
+    pattern: prod-*
+    tags: [prod]
+    defaults:
+      proxyjump: bastion
+      port: 2222
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+        tags: [web]
+        localforward: ["8080 localhost:80"]
+hosts:
+  - host: bastion
+    hostname: 1.2.3.4
+    user: root
+    comment: jump host
+
+Host-level values take precedence over group defaults, then global defaults.
+When a group has a pattern, its defaults are written once as a wildcard block (eg: Host prod-*) after all hosts,
+so values of each host are obtained first by ssh. The pattern must match every host of the group and no host of other groups.
+Use --%s to generate only hosts having any of the provided tags.
+Hosts without IdentityFile use the key provided via --%s/--%s/--%s.`,
+			flagMergeSshConfigOutputFile, sshManagedSectionBeginMarker, sshManagedSectionEndMarker,
+			flagDiffSshConfigOutputFile,
+			flagInputFormat,
+			tsvLineFormat,
+			strings.Join(supportedInventoryColumns, ", "),
+			listValueSeparator, listValueSeparator,
+			flagSshHostTags,
+			flagSshKeyPathRoot, flagSshKeyPathUser, flagSshKeyPathPerUser,
+		),
+		Args: cobra.NoArgs,
+		Run:  configureSshConfigFile,
 	}
 
 	utils.AddFlagWorkingDir(cmd)
 
+	cmd.PersistentFlags().StringVar(
+		&inputFilePath,
+		flagInputFilePath,
+		"",
+		"input inventory file (TSV, CSV or YAML)",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&inputFormat,
+		flagInputFormat,
+		inventoryFormatAuto,
+		fmt.Sprintf("format of the input inventory file: %s (detect by file extension), %s, %s, %s", inventoryFormatAuto, inventoryFormatTsv, inventoryFormatCsv, inventoryFormatYaml),
+	)
+
 	cmd.PersistentFlags().StringVar(
 		&tsvInputFilePath,
 		flagTsvInputFilePath,
 		"",
-		fmt.Sprintf("input tsv file, each line format: %s", tsvLineFormat),
+		fmt.Sprintf("(legacy, same as --%s) input tsv file, each line format: %s", flagInputFilePath, tsvLineFormat),
 	)
 
 	cmd.PersistentFlags().StringVar(
@@ -81,30 +143,111 @@ func ConfigureSshCommands() *cobra.Command {
 		"SSH key file path to be used for each non-root user (format: user1,path1,user2,path2)",
 	)
 
+	cmd.PersistentFlags().BoolVar(
+		&mergeSshConfigOutputFile,
+		flagMergeSshConfigOutputFile,
+		false,
+		"merge generated hosts into the managed section of the existing output SSH config file, hand-written entries are kept",
+	)
+
+	cmd.PersistentFlags().BoolVar(
+		&diffSshConfigOutputFile,
+		flagDiffSshConfigOutputFile,
+		false,
+		"print diff between the existing output SSH config file and the new content, without writing",
+	)
+
+	cmd.PersistentFlags().StringSliceVar(
+		&sshHostTags,
+		flagSshHostTags,
+		[]string{},
+		"only generate hosts having any of the tags, can be provided multiple times or comma separated",
+	)
+
+	cmd.AddCommand(
+		CheckSshCommands(),
+		KeygenSshCommands(),
+		RotateSshCommands(),
+		ImportSshCommands(),
+	)
+
 	return cmd
 }
 
 var regexReplaceContinousSpace = regexp.MustCompile("[\\s\\t]+")
 
+var regexSshUserName = regexp.MustCompile("^[a-z][-a-z\\d_]*\\$?$")
+
 func configureSshConfigFile(_ *cobra.Command, _ []string) {
-	if libutils.IsBlank(tsvInputFilePath) {
-		panic(fmt.Errorf("input TSV is required by supplying mandatory flag --%s", flagTsvInputFilePath))
-	}
+	inventory := readSshInventoryFromFlags()
 
-	if !isFileExists(tsvInputFilePath) {
-		panic(fmt.Errorf("mandatory input TSV file %s provided flag --%s does not exists", tsvInputFilePath, flagTsvInputFilePath))
+	validateSshConfigOutputFlags()
+
+	var identityFiles sshIdentityFiles
+	if inventory.requireDefaultIdentityFiles() {
+		identityFiles = readSshIdentityFilesFromFlags()
 	}
 
+	sshConfigContent := buildSshConfigContent(inventory, identityFiles)
+
+	writeSshConfigFile(sshConfigContent, inventory)
+}
+
+// validateSshConfigOutputFlags validates flags related to the output SSH config file
+func validateSshConfigOutputFlags() {
 	if libutils.IsBlank(sshConfigOutputFilePath) {
 		panic(fmt.Errorf("output SSH config file is required by supplying mandatory flag --%s", flagSshConfigOutputFilePath))
 	}
 
+	if mergeSshConfigOutputFile && overrideSshConfigOutputFile {
+		panic(fmt.Errorf("flags --%s and --%s can not be used together", flagMergeSshConfigOutputFile, flagOverrideSshConfigOutputFile))
+	}
+
 	if isFileExists(sshConfigOutputFilePath) {
-		if !overrideSshConfigOutputFile {
-			panic(fmt.Errorf("output SSH config file %s provided flag --%s is already exists, if you want to override, supply --%s flag", sshConfigOutputFilePath, flagSshConfigOutputFilePath, flagOverrideSshConfigOutputFile))
+		if !overrideSshConfigOutputFile && !mergeSshConfigOutputFile && !diffSshConfigOutputFile {
+			panic(fmt.Errorf("output SSH config file %s provided flag --%s is already exists, if you want to override, supply --%s flag, or --%s to merge", sshConfigOutputFilePath, flagSshConfigOutputFilePath, flagOverrideSshConfigOutputFile, flagMergeSshConfigOutputFile))
+		}
+	}
+}
+
+// readSshInventoryFromFlags reads the inventory file provided via flags
+func readSshInventoryFromFlags() *sshInventory {
+	if !libutils.IsBlank(tsvInputFilePath) {
+		if !libutils.IsBlank(inputFilePath) {
+			panic(fmt.Errorf("flags --%s and --%s can not be used together", flagInputFilePath, flagTsvInputFilePath))
+		}
+
+		inputFilePath = tsvInputFilePath
+		if inputFormat == inventoryFormatAuto {
+			inputFormat = inventoryFormatTsv
+		}
+	}
+
+	if libutils.IsBlank(inputFilePath) {
+		panic(fmt.Errorf("input inventory is required by supplying mandatory flag --%s", flagInputFilePath))
+	}
+
+	if !isFileExists(inputFilePath) {
+		panic(fmt.Errorf("mandatory input inventory file %s provided flag --%s does not exists", inputFilePath, flagInputFilePath))
+	}
+
+	inventory, err := readSshInventory(inputFilePath, inputFormat)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to read inventory %s", inputFilePath)))
+	}
+
+	if len(sshHostTags) > 0 {
+		inventory = inventory.filterByTags(sshHostTags)
+		if len(inventory.hosts) < 1 {
+			panic(fmt.Errorf("no host in the inventory has any of the tags provided via flag --%s: %s", flagSshHostTags, strings.Join(sshHostTags, ", ")))
 		}
 	}
 
+	return inventory
+}
+
+// readSshIdentityFilesFromFlags reads and validates SSH key file paths provided via flags
+func readSshIdentityFilesFromFlags() sshIdentityFiles {
 	if libutils.IsBlank(sshKeyPathRoot) {
 		panic(fmt.Errorf("SSH key for root user is required by supplying mandatory flag --%s", flagSshKeyPathRoot))
 	}
@@ -121,123 +264,201 @@ func configureSshConfigFile(_ *cobra.Command, _ []string) {
 		panic(fmt.Sprintf("SSH key (fallback for non-root user) could not be found: %s", sshKeyPathUser))
 	}
 
-	mapSshKeyPathPerUser := make(map[string]string)
-	if len(sshKeyPathPerUser) > 0 {
-		if len(sshKeyPathPerUser)%2 != 0 {
-			panic(fmt.Sprintf("SSH key pair supplied by flag --%s must satisfy the format: user1,path1,user2,path2", flagSshKeyPathPerUser))
+	return sshIdentityFiles{
+		root:     sshKeyPathRoot,
+		fallback: sshKeyPathUser,
+		perUser:  readSshKeyPathPerUser(sshKeyPathPerUser, flagSshKeyPathPerUser),
+	}
+}
+
+// readSshKeyPathPerUser reads and validates pairs of user & SSH key file path provided via flag, format: user1,path1,user2,path2
+func readSshKeyPathPerUser(pairs []string, flagName string) map[string]string {
+	perUser := make(map[string]string)
+	if len(pairs) < 1 {
+		return perUser
+	}
+
+	if len(pairs)%2 != 0 {
+		panic(fmt.Sprintf("SSH key pair supplied by flag --%s must satisfy the format: user1,path1,user2,path2", flagName))
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		user := pairs[i]
+		keyPath := pairs[i+1]
+
+		if strings.EqualFold(user, "root") {
+			panic(fmt.Sprintf("SSH user \"root\" is not accepted to be supplied by flag --%s", flagName))
 		}
 
-		for i := 0; i < len(sshKeyPathPerUser); i += 2 {
-			user := sshKeyPathPerUser[i]
-			keyPath := sshKeyPathPerUser[i+1]
+		if !regexSshUserName.MatchString(user) {
+			panic(fmt.Sprintf("SSH user \"%s\" supplied by flag --%s is malformed", user, flagName))
+		}
 
-			if strings.EqualFold(user, "root") {
-				panic(fmt.Sprintf("SSH user \"root\" is not accepted to be supplied by flag --%s", flagSshKeyPathPerUser))
-			}
+		if libutils.IsBlank(keyPath) {
+			panic(fmt.Sprintf("Key path for SSH user \"%s\" supplied by flag --%s is missing", user, flagName))
+		}
 
-			if !regexp.MustCompile("^[a-z][-a-z\\d_]*\\$?$").MatchString(user) {
-				panic(fmt.Sprintf("SSH user \"%s\" supplied by flag --%s is malformed", user, flagSshKeyPathPerUser))
-			}
+		if !isFileExists(keyPath) {
+			panic(fmt.Sprintf("Key path for SSH user \"%s\" supplied by flag --%s does not exists: %s", user, flagName, keyPath))
+		}
 
-			if libutils.IsBlank(keyPath) {
-				panic(fmt.Sprintf("Key path for SSH user \"%s\" supplied by flag --%s is missing", user, flagSshKeyPathPerUser))
-			}
+		perUser[user] = keyPath
+	}
 
-			if !isFileExists(keyPath) {
-				panic(fmt.Sprintf("Key path for SSH user \"%s\" supplied by flag --%s does not exists: %s", user, flagSshKeyPathPerUser, keyPath))
-			}
+	return perUser
+}
+
+// buildSshConfigContent builds SSH config content from the inventory
+func buildSshConfigContent(inventory *sshInventory, identityFiles sshIdentityFiles) string {
+	var sb strings.Builder
+	sb.WriteString("# Generated by House Keeper\n")
+
+	writeComments := func(comments []string) {
+		for _, comment := range comments {
+			sb.WriteString(fmt.Sprintf("\n%s\n", comment))
+		}
+	}
 
-			mapSshKeyPathPerUser[user] = keyPath
+	writeOptions := func(options sshHostOptions) {
+		if len(options.User) > 0 {
+			sb.WriteString(fmt.Sprintf("    User %s\n", options.User))
+		}
+		if options.Port > 0 {
+			sb.WriteString(fmt.Sprintf("    Port %d\n", options.Port))
+		}
+		if len(options.ProxyJump) > 0 {
+			sb.WriteString(fmt.Sprintf("    ProxyJump %s\n", options.ProxyJump))
+		}
+		if len(options.IdentityFile) > 0 {
+			sb.WriteString(fmt.Sprintf("    IdentityFile %s\n", quoteSshConfigArg(options.IdentityFile)))
+		}
+		for _, localForward := range options.LocalForward {
+			sb.WriteString(fmt.Sprintf("    LocalForward %s\n", localForward))
 		}
 	}
 
-	sshConfigContent := "# Generated by House Keeper\n"
+	for _, host := range inventory.hosts {
+		writeComments(host.headComments)
 
-	bz, err := os.ReadFile(tsvInputFilePath)
-	if err != nil {
-		panic(errors.Wrap(err, fmt.Sprintf("failed to read %s", tsvInputFilePath)))
-	}
-	tsvLines := goe.NewIEnumerable(strings.Split(string(bz), "\n")...).Select(func(line string) any {
-		return strings.TrimSpace(line)
-	}).CastString()
-	if !tsvLines.Any() {
-		panic(fmt.Errorf("input TSV %s is empty", tsvInputFilePath))
-	}
-	hostTracker := make(map[string]bool)
-	for _, line := range tsvLines.ToArray() {
-		if strings.HasPrefix(line, "#") {
-			fmt.Println("Skipped comment line:", line)
-			sshConfigContent += fmt.Sprintf(`
-%s
-`, line)
-			continue
+		sb.WriteString(fmt.Sprintf("\nHost %s%s\n", host.Host, libutils.ConditionalString(libutils.IsBlank(host.Comment), "", fmt.Sprintf(" # %s", host.Comment))))
+		sb.WriteString(fmt.Sprintf("    HostName %s\n", host.HostName))
+
+		options := host.sshHostOptions
+		if len(options.IdentityFile) < 1 && len(host.inherited.IdentityFile) < 1 {
+			options.IdentityFile = identityFiles.identityFileFor(host.effectiveOptions().User)
 		}
-		if libutils.IsBlank(line) {
+		writeOptions(options)
+	}
+
+	writeComments(inventory.tailComments)
+
+	// wildcard blocks must be placed after all hosts, ssh applies the first obtained value of each option
+	for _, group := range inventory.groups {
+		if len(group.Pattern) < 1 {
 			continue
 		}
-		spl := strings.SplitN(
-			regexReplaceContinousSpace.ReplaceAllString(strings.Replace(line, "\t", " ", -1), " "),
-			" ", 3,
-		)
-		if len(spl) != 3 {
-			panic(fmt.Sprintf("in input tsv file, each line must maintains format: %s", tsvLineFormat))
+
+		sb.WriteString(fmt.Sprintf("\n# Defaults of group %s\n", group.Name))
+		sb.WriteString(fmt.Sprintf("Host %s\n", strings.Join(group.patterns(), " ")))
+		writeOptions(group.Defaults)
+	}
+
+	return sb.String()
+}
+
+// quoteSshConfigArg quotes argument contains whitespace using double quotes
+func quoteSshConfigArg(arg string) string {
+	if strings.ContainsAny(arg, " \t") {
+		return `"` + arg + `"`
+	}
+	return arg
+}
+
+// sshIdentityFiles holds SSH key file paths to be used for each user
+type sshIdentityFiles struct {
+	root     string
+	fallback string
+	perUser  map[string]string
+}
+
+// identityFileFor returns SSH key file path to be used for the user
+func (f sshIdentityFiles) identityFileFor(user string) string {
+	if strings.EqualFold(user, "root") {
+		return f.root
+	}
+
+	if keyPath, found := f.perUser[user]; found {
+		return keyPath
+	}
+
+	return f.fallback
+}
+
+// writeSshConfigFile writes the generated content into the output file,
+// or merges into the managed section of the existing file, or prints the diff only.
+func writeSshConfigFile(generatedContent string, inventory *sshInventory) {
+	managedHosts := inventory.hostNames()
+
+	var existingContent string
+	fileMode := os.FileMode(0o644)
+
+	exists := isFileExists(sshConfigOutputFilePath)
+	if exists {
+		fi, err := os.Stat(sshConfigOutputFilePath)
+		if err != nil {
+			panic(errors.Wrap(err, "failed to stats output SSH config file"))
 		}
+		fileMode = fi.Mode().Perm()
 
-		host := strings.TrimSpace(spl[0])
-		hostName := strings.TrimSpace(spl[1])
-		user := strings.TrimSpace(spl[2])
+		bz, err := os.ReadFile(sshConfigOutputFilePath)
+		if err != nil {
+			panic(errors.Wrap(err, "failed to read output SSH config file"))
+		}
+		existingContent = string(bz)
+	}
 
-		var comment string
+	newContent := generatedContent
+	if mergeSshConfigOutputFile {
+		merged, handWrittenBefore, handWrittenAfter, err := mergeManagedSshConfig(existingContent, generatedContent, managedHosts)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to merge into SSH config file %s", sshConfigOutputFilePath)))
+		}
+		newContent = merged
 
-		if len(host) < 1 {
-			panic(fmt.Sprintf("SSH config host must not be empty at line: %s", line))
+		warnings, err := findDuplicatedHandWrittenHosts(handWrittenBefore+"\n"+handWrittenAfter, managedHosts)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to parse SSH config file %s", sshConfigOutputFilePath)))
+		}
+		shadowingWarnings, err := findShadowingHandWrittenBlocks(handWrittenBefore, managedHosts)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to parse SSH config file %s", sshConfigOutputFilePath)))
 		}
-		normalizedHost := strings.ToLower(host)
-		if _, found := hostTracker[normalizedHost]; found {
-			panic(fmt.Sprintf("SSH config host does not unique at line: %s", line))
+		shadowedWarnings, err := inventory.findHandWrittenHostsShadowedByGroups(handWrittenAfter)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to parse SSH config file %s", sshConfigOutputFilePath)))
 		}
-		hostTracker[normalizedHost] = true
-		if len(hostName) < 1 {
-			panic(fmt.Sprintf("SSH config host name must not be empty at line: %s", line))
+		warnings = append(append(warnings, shadowingWarnings...), shadowedWarnings...)
+		for _, warning := range warnings {
+			fmt.Println("WARN:", warning)
 		}
-		if len(user) < 1 {
-			panic(fmt.Sprintf("SSH config user must not be empty at line: %s", line))
+	}
+
+	if diffSshConfigOutputFile {
+		diff := utils.UnifiedDiff(sshConfigOutputFilePath, sshConfigOutputFilePath, existingContent, newContent)
+		if len(diff) < 1 {
+			fmt.Println("No changes")
 		} else {
-			commentSymbolIdx := strings.Index(user, "#")
-			if commentSymbolIdx == 0 {
-				panic("comment symbol '#' could not be exists at first character")
-			} else if commentSymbolIdx > 0 {
-				spl = strings.Split(user, "#")
-				user = strings.TrimSpace(spl[0])
-				comment = strings.TrimSpace(spl[1])
-			}
-		}
-
-		sshConfigContent += fmt.Sprintf(`
-Host %s%s
-    HostName %s
-    User %s
-    IdentityFile %s
-`,
-			host,
-			libutils.ConditionalString(libutils.IsBlank(comment), "", fmt.Sprintf(" # %s", comment)),
-			hostName,
-			user,
-			func() string {
-				if strings.EqualFold(user, "root") {
-					return sshKeyPathRoot
-				}
-
-				if keyPath, found := mapSshKeyPathPerUser[user]; found {
-					return keyPath
-				}
-
-				return sshKeyPathUser
-			}(),
-		)
-	}
-
-	err = os.WriteFile(sshConfigOutputFilePath, []byte(sshConfigContent), 0o644)
+			fmt.Print(diff)
+		}
+		return
+	}
+
+	if exists && newContent == existingContent {
+		fmt.Println("No changes to output file:", sshConfigOutputFilePath)
+		return
+	}
+
+	err := os.WriteFile(sshConfigOutputFilePath, []byte(newContent), fileMode)
 	if err != nil {
 		panic(errors.Wrap(err, "failed to write output SSH config file"))
 	}
@@ -0,0 +1,559 @@
+package config
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	flagSshCheckTimeout     = "timeout"
+	flagSshCheckConcurrency = "concurrency"
+	flagKnownHostsFilePath  = "known-hosts"
+	flagAddKnownHosts       = "add-known-hosts"
+)
+
+// status of each host after checking
+const (
+	sshCheckStatusOk              = "OK"
+	sshCheckStatusUnreachable     = "UNREACHABLE"
+	sshCheckStatusAuthFailed      = "AUTH FAILED"
+	sshCheckStatusHostKeyMismatch = "HOST KEY MISMATCH"
+	sshCheckStatusError           = "ERROR"
+	sshCheckStatusSkipped         = "SKIPPED"
+)
+
+// status of host key of each host, compare to the known_hosts file
+const (
+	hostKeyStatusKnown    = "known"
+	hostKeyStatusUnknown  = "unknown"
+	hostKeyStatusMismatch = "mismatch"
+	hostKeyStatusAdded    = "added"
+)
+
+// CheckSshCommands registers a sub-tree of commands
+func CheckSshCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check SSH connectivity and host keys of hosts in the inventory",
+		Long: fmt.Sprintf(`Check SSH connectivity and host keys of hosts in the inventory.
+Reads the same inventory as the parent command, connects to every host concurrently
+and reports reachability, the authentication method succeeded and the host key fingerprint.
+Authentication uses the IdentityFile of each host (or the keys provided via --%s/--%s/--%s) and keys from ssh-agent.
+Hosts with ProxyJump are connected through the jump host when it is defined in the inventory, otherwise skipped.
+With --%s, host keys of hosts passed the check are added into the known_hosts file.`,
+			flagSshKeyPathRoot, flagSshKeyPathUser, flagSshKeyPathPerUser,
+			flagAddKnownHosts,
+		),
+		Args: cobra.NoArgs,
+		Run:  checkSshHosts,
+	}
+
+	cmd.Flags().Duration(
+		flagSshCheckTimeout,
+		10*time.Second,
+		"timeout of connecting and authenticating to each host",
+	)
+
+	cmd.Flags().Int(
+		flagSshCheckConcurrency,
+		10,
+		"number of hosts to be checked at the same time",
+	)
+
+	cmd.Flags().String(
+		flagKnownHostsFilePath,
+		defaultKnownHostsFilePath(),
+		"known_hosts file to verify host keys",
+	)
+
+	cmd.Flags().Bool(
+		flagAddKnownHosts,
+		false,
+		"add host keys of hosts passed the check into the known_hosts file, if not known yet",
+	)
+
+	return cmd
+}
+
+func checkSshHosts(cmd *cobra.Command, _ []string) {
+	timeout, _ := cmd.Flags().GetDuration(flagSshCheckTimeout)
+	if timeout <= 0 {
+		panic(fmt.Errorf("bad value for flag --%s", flagSshCheckTimeout))
+	}
+
+	concurrency, _ := cmd.Flags().GetInt(flagSshCheckConcurrency)
+	concurrency = libutils.MaxInt(1, concurrency)
+
+	knownHostsFilePath, _ := cmd.Flags().GetString(flagKnownHostsFilePath)
+	addKnownHosts, _ := cmd.Flags().GetBool(flagAddKnownHosts)
+	if addKnownHosts && libutils.IsBlank(knownHostsFilePath) {
+		panic(fmt.Errorf("flag --%s is required when --%s is provided", flagKnownHostsFilePath, flagAddKnownHosts))
+	}
+
+	inventory := readSshInventoryFromFlags()
+
+	checker := &sshChecker{
+		inventory: inventory,
+		timeout:   timeout,
+	}
+
+	if !libutils.IsBlank(sshKeyPathRoot) || !libutils.IsBlank(sshKeyPathUser) || len(sshKeyPathPerUser) > 0 {
+		checker.identityFiles = readSshIdentityFilesFromFlags()
+	}
+
+	if !libutils.IsBlank(knownHostsFilePath) && isFileExists(knownHostsFilePath) {
+		callback, err := knownhosts.New(knownHostsFilePath)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to read known_hosts file %s", knownHostsFilePath)))
+		}
+		checker.knownHosts = callback
+	}
+
+	var closeAgent func()
+	checker.agent, closeAgent = connectSshAgent()
+	defer closeAgent()
+
+	results := checker.checkAll(concurrency)
+
+	if addKnownHosts {
+		added, err := addToKnownHosts(knownHostsFilePath, results)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to add host keys into known_hosts file %s", knownHostsFilePath)))
+		}
+		if added > 0 {
+			fmt.Printf("Added %d host keys into %s\n", added, knownHostsFilePath)
+		}
+	}
+
+	printSshCheckResults(results)
+
+	for _, result := range results {
+		if result.status != sshCheckStatusOk && result.status != sshCheckStatusSkipped {
+			os.Exit(1)
+		}
+	}
+}
+
+// connectSshAgent connects to ssh-agent via SSH_AUTH_SOCK, returns nil agent if not available
+func connectSshAgent() (agent.Agent, func()) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if len(socket) < 1 {
+		return nil, func() {}
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		fmt.Println("WARN: failed to connect to ssh-agent:", err)
+		return nil, func() {}
+	}
+
+	return agent.NewClient(conn), func() {
+		_ = conn.Close()
+	}
+}
+
+// defaultKnownHostsFilePath returns ~/.ssh/known_hosts, or empty if home directory could not be detected
+func defaultKnownHostsFilePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(homeDir, ".ssh", "known_hosts")
+}
+
+// sshChecker checks connectivity of hosts in the inventory
+type sshChecker struct {
+	inventory     *sshInventory
+	identityFiles sshIdentityFiles
+	// knownHosts verifies host keys, nil if known_hosts file does not exist
+	knownHosts ssh.HostKeyCallback
+	// strictHostKey rejects hosts which host key is not known
+	strictHostKey bool
+	// agent provides keys from ssh-agent, nil if not available
+	agent   agent.Agent
+	timeout time.Duration
+}
+
+// sshCheckResult is the result of checking a host
+type sshCheckResult struct {
+	host    string
+	address string
+	status  string
+	// authMethod is the authentication method succeeded
+	authMethod    string
+	hostKey       ssh.PublicKey
+	hostKeyStatus string
+	err           error
+}
+
+// checkAll checks all hosts in the inventory concurrently, results are in the same order as the inventory
+func (c *sshChecker) checkAll(concurrency int) []sshCheckResult {
+	results := make([]sshCheckResult, len(c.inventory.hosts))
+
+	semaphore := make(chan struct{}, libutils.MaxInt(1, concurrency))
+	var wg sync.WaitGroup
+	for i, host := range c.inventory.hosts {
+		wg.Add(1)
+		go func(i int, host sshInventoryHost) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+			}()
+			results[i] = c.check(host)
+		}(i, host)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// check connects and authenticates to the host
+func (c *sshChecker) check(host sshInventoryHost) sshCheckResult {
+	client, closeClient, result := c.open(host)
+	if client == nil {
+		return result
+	}
+	closeClient()
+
+	result.status = sshCheckStatusOk
+	return result
+}
+
+// open connects and authenticates to the host, through the jump host if needed.
+// When failed, the returned client is nil and the reason is recorded into the result.
+func (c *sshChecker) open(host sshInventoryHost) (client *ssh.Client, closeClient func(), result sshCheckResult) {
+	options := host.effectiveOptions()
+	result = sshCheckResult{
+		host:    host.Host,
+		address: sshAddress(host.HostName, options.Port),
+	}
+
+	var jumpClient *ssh.Client
+	if len(options.ProxyJump) > 0 && !strings.EqualFold(options.ProxyJump, "none") {
+		jumpHost, found := c.findHost(options.ProxyJump)
+		if !found {
+			result.status = sshCheckStatusSkipped
+			result.err = fmt.Errorf("jump host %s is not defined in the inventory", options.ProxyJump)
+			return
+		}
+		if jumpOptions := jumpHost.effectiveOptions(); len(jumpOptions.ProxyJump) > 0 && !strings.EqualFold(jumpOptions.ProxyJump, "none") {
+			result.status = sshCheckStatusSkipped
+			result.err = fmt.Errorf("multiple hops ProxyJump via %s is not supported", jumpHost.Host)
+			return
+		}
+
+		jumpResult := sshCheckResult{
+			host:    jumpHost.Host,
+			address: sshAddress(jumpHost.HostName, jumpHost.effectiveOptions().Port),
+		}
+		var err error
+		jumpClient, err = c.connect(jumpHost, nil, &jumpResult)
+		if err != nil {
+			result.status = jumpResult.status
+			result.err = errors.Wrap(err, fmt.Sprintf("jump host %s", jumpHost.Host))
+			return
+		}
+	}
+
+	var err error
+	client, err = c.connect(host, jumpClient, &result)
+	if err != nil {
+		if jumpClient != nil {
+			_ = jumpClient.Close()
+		}
+		result.err = err
+		return nil, nil, result
+	}
+
+	closeClient = func() {
+		_ = client.Close()
+		if jumpClient != nil {
+			_ = jumpClient.Close()
+		}
+	}
+
+	return
+}
+
+// connect opens SSH connection to the host, directly or through the jump host if provided.
+// Status, auth method and host key are recorded into the result.
+func (c *sshChecker) connect(host sshInventoryHost, jumpClient *ssh.Client, result *sshCheckResult) (*ssh.Client, error) {
+	options := host.effectiveOptions()
+
+	signers, err := c.signersFor(host, func(authMethod string) {
+		result.authMethod = authMethod
+	})
+	if err != nil {
+		result.status = sshCheckStatusError
+		return nil, err
+	}
+	if len(signers) < 1 {
+		result.status = sshCheckStatusError
+		return nil, fmt.Errorf("no usable key to authenticate")
+	}
+
+	config := &ssh.ClientConfig{
+		User: options.User,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signers...),
+		},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			result.hostKey = key
+			if c.knownHosts == nil {
+				result.hostKeyStatus = hostKeyStatusUnknown
+				if c.strictHostKey {
+					return fmt.Errorf("host key of %s is unknown", hostname)
+				}
+				return nil
+			}
+
+			err := c.knownHosts(hostname, remote, key)
+			if err == nil {
+				result.hostKeyStatus = hostKeyStatusKnown
+				return nil
+			}
+
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) {
+				if len(keyErr.Want) > 0 {
+					result.hostKeyStatus = hostKeyStatusMismatch
+					return err
+				}
+				result.hostKeyStatus = hostKeyStatusUnknown
+				if c.strictHostKey {
+					return err
+				}
+				return nil
+			}
+
+			return err
+		},
+		Timeout: c.timeout,
+	}
+
+	var conn net.Conn
+	if jumpClient != nil {
+		conn, err = jumpClient.Dial("tcp", result.address)
+	} else {
+		conn, err = net.DialTimeout("tcp", result.address, c.timeout)
+	}
+	if err != nil {
+		result.status = sshCheckStatusUnreachable
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, result.address, config)
+	if err != nil {
+		_ = conn.Close()
+		result.authMethod = ""
+		if result.hostKeyStatus == hostKeyStatusMismatch {
+			result.status = sshCheckStatusHostKeyMismatch
+		} else if result.hostKeyStatus == hostKeyStatusUnknown && c.strictHostKey {
+			result.status = sshCheckStatusError
+		} else if result.hostKey != nil {
+			result.status = sshCheckStatusAuthFailed
+		} else {
+			result.status = sshCheckStatusError
+		}
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// signersFor returns keys to authenticate to the host: its identity file first, then keys from ssh-agent.
+// The callback is invoked with description of the key used to sign the authentication request.
+func (c *sshChecker) signersFor(host sshInventoryHost, onSign func(authMethod string)) ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+
+	options := host.effectiveOptions()
+	identityFile := options.IdentityFile
+	if len(identityFile) < 1 {
+		identityFile = c.identityFiles.identityFileFor(options.User)
+	}
+
+	if len(identityFile) > 0 {
+		identityFile = expandHomeDir(identityFile)
+		bz, err := os.ReadFile(identityFile)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to read identity file %s", identityFile))
+		}
+
+		signer, err := ssh.ParsePrivateKey(bz)
+		if err != nil {
+			var passphraseMissingErr *ssh.PassphraseMissingError
+			if !errors.As(err, &passphraseMissingErr) || c.agent == nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to parse identity file %s", identityFile))
+			}
+			// encrypted key, expecting it was added into ssh-agent
+		} else {
+			signers = append(signers, &recordingSigner{
+				Signer: signer,
+				source: fmt.Sprintf("publickey %s", identityFile),
+				onSign: onSign,
+			})
+		}
+	}
+
+	if c.agent != nil {
+		agentKeys, err := c.agent.List()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list keys of ssh-agent")
+		}
+		agentSigners, err := c.agent.Signers()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get signers from ssh-agent")
+		}
+		for i, signer := range agentSigners {
+			source := "publickey from ssh-agent"
+			if i < len(agentKeys) && len(agentKeys[i].Comment) > 0 {
+				source = fmt.Sprintf("publickey %s from ssh-agent", agentKeys[i].Comment)
+			}
+			signers = append(signers, &recordingSigner{
+				Signer: signer,
+				source: source,
+				onSign: onSign,
+			})
+		}
+	}
+
+	return signers, nil
+}
+
+// findHost finds host by name in the inventory
+func (c *sshChecker) findHost(name string) (sshInventoryHost, bool) {
+	for _, host := range c.inventory.hosts {
+		if strings.EqualFold(host.Host, name) {
+			return host, true
+		}
+	}
+	return sshInventoryHost{}, false
+}
+
+// recordingSigner records the key used to sign the authentication request,
+// the key is only used for signing after being accepted by the server.
+type recordingSigner struct {
+	ssh.Signer
+	source string
+	onSign func(authMethod string)
+}
+
+func (s *recordingSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	s.onSign(s.source)
+	return s.Signer.Sign(rand, data)
+}
+
+// sshAddress returns host:port, default port is 22
+func sshAddress(hostName string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(hostName, strconv.Itoa(port))
+}
+
+// expandHomeDir expands leading ~/ into home directory
+func expandHomeDir(file string) string {
+	if !strings.HasPrefix(file, "~/") {
+		return file
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return file
+	}
+	return path.Join(homeDir, file[2:])
+}
+
+// addToKnownHosts appends host keys of the hosts passed the check but not known yet, into the known_hosts file.
+// Returns number of added keys.
+func addToKnownHosts(knownHostsFilePath string, results []sshCheckResult) (int, error) {
+	var lines []string
+	added := make(map[string]bool)
+	for i, result := range results {
+		if result.status != sshCheckStatusOk || result.hostKeyStatus != hostKeyStatusUnknown || result.hostKey == nil {
+			continue
+		}
+
+		address := knownhosts.Normalize(result.address)
+		if !added[address] {
+			added[address] = true
+			lines = append(lines, knownhosts.Line([]string{address}, result.hostKey))
+		}
+		results[i].hostKeyStatus = hostKeyStatusAdded
+	}
+
+	if len(lines) < 1 {
+		return 0, nil
+	}
+
+	file, err := os.OpenFile(knownHostsFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if _, err := file.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return 0, err
+	}
+
+	return len(lines), nil
+}
+
+func printSshCheckResults(results []sshCheckResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "HOST\tADDRESS\tSTATUS\tAUTH\tHOST KEY\tFINGERPRINT\tERROR")
+	for _, result := range results {
+		var fingerprint, errMsg string
+		if result.hostKey != nil {
+			fingerprint = fmt.Sprintf("%s %s", result.hostKey.Type(), ssh.FingerprintSHA256(result.hostKey))
+		}
+		if result.err != nil {
+			errMsg = result.err.Error()
+		}
+		_, _ = fmt.Fprintf(
+			writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.host, result.address, result.status,
+			emptyAsDash(result.authMethod), emptyAsDash(result.hostKeyStatus), emptyAsDash(fingerprint), emptyAsDash(errMsg),
+		)
+	}
+	_ = writer.Flush()
+}
+
+func emptyAsDash(str string) string {
+	if len(str) < 1 {
+		return "-"
+	}
+	return str
+}
+
+// runSshCommand runs the command on the remote host, returns combined output
+func runSshCommand(client *ssh.Client, command string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open session")
+	}
+	defer func() {
+		_ = session.Close()
+	}()
+
+	output, err := session.CombinedOutput(command)
+	return strings.TrimSpace(string(output)), err
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testSshServer is an in-process SSH server accepts only the authorized key.
+// When home is provided, keys are authorized by home/.ssh/authorized_keys
+// and exec requests are executed by sh with HOME set to it.
+type testSshServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+}
+
+func newTestSshServer(t *testing.T, authorizedKey ssh.PublicKey) *testSshServer {
+	return startTestSshServer(t, func(key ssh.PublicKey) bool {
+		return bytes.Equal(key.Marshal(), authorizedKey.Marshal())
+	}, "")
+}
+
+func newTestSshServerWithHome(t *testing.T, home string) *testSshServer {
+	return startTestSshServer(t, func(key ssh.PublicKey) bool {
+		bz, err := os.ReadFile(path.Join(home, ".ssh", "authorized_keys"))
+		if err != nil {
+			return false
+		}
+		for len(bz) > 0 {
+			authorizedKey, _, _, rest, err := ssh.ParseAuthorizedKey(bz)
+			if err != nil {
+				return false
+			}
+			if bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return true
+			}
+			bz = rest
+		}
+		return false
+	}, home)
+}
+
+func startTestSshServer(t *testing.T, authorize func(key ssh.PublicKey) bool, home string) *testSshServer {
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorize(key) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSshConn(conn, config, home)
+		}
+	}()
+
+	return &testSshServer{
+		listener: listener,
+		hostKey:  hostKey,
+	}
+}
+
+// serveTestSshConn serves a connection, supports direct-tcpip channels (used by ProxyJump)
+// and session channels with exec requests when home is provided
+func serveTestSshConn(conn net.Conn, config *ssh.ServerConfig, home string) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "session" && len(home) > 0 {
+			channel, channelRequests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go serveTestSshSession(channel, channelRequests, home)
+			continue
+		}
+
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "not supported")
+			continue
+		}
+
+		var payload struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			_ = target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			_, _ = io.Copy(target, channel)
+			_ = target.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(channel, target)
+			_ = channel.Close()
+		}()
+	}
+}
+
+// serveTestSshSession executes the command of exec request by sh, with HOME set to home
+func serveTestSshSession(channel ssh.Channel, requests <-chan *ssh.Request, home string) {
+	defer func() {
+		_ = channel.Close()
+	}()
+
+	for request := range requests {
+		if request.Type != "exec" {
+			_ = request.Reply(false, nil)
+			continue
+		}
+
+		var payload struct {
+			Command string
+		}
+		if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+			_ = request.Reply(false, nil)
+			continue
+		}
+		_ = request.Reply(true, nil)
+
+		command := exec.Command("sh", "-c", payload.Command)
+		command.Env = []string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}
+		command.Stdout = channel
+		command.Stderr = channel.Stderr()
+
+		var exitStatus uint32
+		if err := command.Run(); err != nil {
+			exitStatus = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitStatus = uint32(exitErr.ExitCode())
+			}
+		}
+
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{exitStatus}))
+		return
+	}
+}
+
+func (s *testSshServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// writeTestSshKey generates a new key and writes the private key into a file
+func writeTestSshKey(t *testing.T, dir, name string) (string, ssh.PublicKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bz, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := path.Join(dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: bz}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file, sshPublicKey
+}
+
+func Test_sshChecker(t *testing.T) {
+	dir := t.TempDir()
+	authorizedKeyFile, authorizedKey := writeTestSshKey(t, dir, "id_authorized")
+	otherKeyFile, _ := writeTestSshKey(t, dir, "id_other")
+
+	server := newTestSshServer(t, authorizedKey)
+
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closedListener.Addr().(*net.TCPAddr).Port
+	_ = closedListener.Close()
+
+	inventory := &sshInventory{
+		hosts: []sshInventoryHost{
+			{
+				Host:     "ok",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User: "ubuntu",
+					Port: server.port(),
+				},
+			},
+			{
+				Host:     "bad-key",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User:         "ubuntu",
+					Port:         server.port(),
+					IdentityFile: otherKeyFile,
+				},
+			},
+			{
+				Host:     "unreachable",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User: "ubuntu",
+					Port: closedPort,
+				},
+			},
+			{
+				Host:     "behind-jump",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User:      "ubuntu",
+					Port:      server.port(),
+					ProxyJump: "ok",
+				},
+			},
+			{
+				Host:     "unknown-jump",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User:      "ubuntu",
+					Port:      server.port(),
+					ProxyJump: "not-in-inventory",
+				},
+			},
+		},
+	}
+
+	newChecker := func(knownHosts ssh.HostKeyCallback) *sshChecker {
+		return &sshChecker{
+			inventory: inventory,
+			identityFiles: sshIdentityFiles{
+				root:     authorizedKeyFile,
+				fallback: authorizedKeyFile,
+			},
+			knownHosts: knownHosts,
+			timeout:    5 * time.Second,
+		}
+	}
+
+	results := newChecker(nil).checkAll(2)
+
+	wantStatuses := []string{
+		sshCheckStatusOk,
+		sshCheckStatusAuthFailed,
+		sshCheckStatusUnreachable,
+		sshCheckStatusOk,
+		sshCheckStatusSkipped,
+	}
+	for i, result := range results {
+		if result.status != wantStatuses[i] {
+			t.Errorf("host %s status = %s, want %s, err: %v", result.host, result.status, wantStatuses[i], result.err)
+		}
+	}
+
+	okResult := results[0]
+	if okResult.authMethod != "publickey "+authorizedKeyFile {
+		t.Errorf("auth method = %s", okResult.authMethod)
+	}
+	if okResult.hostKeyStatus != hostKeyStatusUnknown {
+		t.Errorf("host key status = %s, want %s", okResult.hostKeyStatus, hostKeyStatusUnknown)
+	}
+	if okResult.hostKey == nil || ssh.FingerprintSHA256(okResult.hostKey) != ssh.FingerprintSHA256(server.hostKey.PublicKey()) {
+		t.Errorf("host key fingerprint does not match")
+	}
+	if results[1].authMethod != "" {
+		t.Errorf("auth method of failed host must be empty, got %s", results[1].authMethod)
+	}
+
+	t.Run("add to known_hosts then verify", func(t *testing.T) {
+		knownHostsFile := path.Join(dir, "known_hosts")
+
+		added, err := addToKnownHosts(knownHostsFile, results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// host "ok" and "behind-jump" share the same address
+		if added != 1 {
+			t.Errorf("added = %d, want 1", added)
+		}
+
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := newChecker(callback).checkAll(2)
+		if results[0].status != sshCheckStatusOk || results[0].hostKeyStatus != hostKeyStatusKnown {
+			t.Errorf("status = %s, host key status = %s", results[0].status, results[0].hostKeyStatus)
+		}
+
+		added, err = addToKnownHosts(knownHostsFile, results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != 0 {
+			t.Errorf("added = %d, want 0", added)
+		}
+	})
+
+	t.Run("host key mismatch", func(t *testing.T) {
+		_, otherHostKey := writeTestSshKey(t, dir, "id_other_host")
+		knownHostsFile := path.Join(dir, "known_hosts_mismatch")
+		line := knownhosts.Line([]string{knownhosts.Normalize(sshAddress("127.0.0.1", server.port()))}, otherHostKey)
+		if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := newChecker(callback).checkAll(2)
+		if results[0].status != sshCheckStatusHostKeyMismatch {
+			t.Errorf("status = %s, want %s", results[0].status, sshCheckStatusHostKeyMismatch)
+		}
+		if results[3].status != sshCheckStatusHostKeyMismatch {
+			t.Errorf("status of host behind mismatched jump host = %s, want %s", results[3].status, sshCheckStatusHostKeyMismatch)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sshConfigBlock is a Host/Match block of SSH config file,
+// options before the first block are put into a block with empty keyword.
+type sshConfigBlock struct {
+	keyword string
+	// patterns are host patterns of Host block, or criteria of Match block
+	patterns []string
+	// line is 1-based line number of the block declaration
+	line    int
+	options []sshConfigOption
+}
+
+// sshConfigOption is an option within SSH config file, eg: HostName, User, IdentityFile
+type sshConfigOption struct {
+	key  string
+	args []string
+	line int
+}
+
+// value returns arguments of the option joined by space
+func (o sshConfigOption) value() string {
+	return strings.Join(o.args, " ")
+}
+
+// getOption returns arguments of the first option with the key (case-insensitive), SSH uses the first obtained value
+func (b sshConfigBlock) getOption(key string) (args []string, found bool) {
+	for _, option := range b.options {
+		if strings.EqualFold(option.key, key) {
+			return option.args, true
+		}
+	}
+	return nil, false
+}
+
+// isHost returns true if this is a Host block
+func (b sshConfigBlock) isHost() bool {
+	return strings.EqualFold(b.keyword, "Host")
+}
+
+// matchesAnyHost returns true if the block may be applied to any of the hosts.
+// For Match block, only 'all', 'host' and 'originalhost' criteria are evaluated, other criteria are assumed to match.
+func (b sshConfigBlock) matchesAnyHost(hosts []string) bool {
+	for _, host := range hosts {
+		if b.isHost() {
+			if matchSshHostPatterns(b.patterns, host) {
+				return true
+			}
+			continue
+		}
+
+		if b.matchCriteria(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCriteria evaluates criteria of Match block against the host, criteria those could not be evaluated are assumed to match
+func (b sshConfigBlock) matchCriteria(host string) bool {
+	for i := 0; i < len(b.patterns); i++ {
+		criterion := strings.ToLower(b.patterns[i])
+		switch criterion {
+		case "all", "canonical", "final":
+			continue
+		case "host", "originalhost":
+			if i+1 >= len(b.patterns) {
+				return true
+			}
+			i++
+			if !matchSshHostPatterns(strings.Split(b.patterns[i], ","), host) {
+				return false
+			}
+		default:
+			// criteria with argument: exec, user, localuser... and negated ones
+			i++
+		}
+	}
+	return true
+}
+
+// parseSshConfig parses content of SSH config file into blocks
+func parseSshConfig(content string) ([]sshConfigBlock, error) {
+	blocks := []sshConfigBlock{{}}
+
+	for idx, rawLine := range strings.Split(content, "\n") {
+		lineNumber := idx + 1
+
+		key, args, err := parseSshConfigLine(rawLine)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err.Error())
+		}
+
+		if len(key) < 1 {
+			continue
+		}
+
+		if strings.EqualFold(key, "Host") || strings.EqualFold(key, "Match") {
+			if len(args) < 1 {
+				return nil, fmt.Errorf("line %d: missing argument of %s", lineNumber, key)
+			}
+
+			blocks = append(blocks, sshConfigBlock{
+				keyword:  key,
+				patterns: args,
+				line:     lineNumber,
+			})
+			continue
+		}
+
+		last := &blocks[len(blocks)-1]
+		last.options = append(last.options, sshConfigOption{
+			key:  key,
+			args: args,
+			line: lineNumber,
+		})
+	}
+
+	if len(blocks[0].options) < 1 {
+		blocks = blocks[1:]
+	}
+
+	return blocks, nil
+}
+
+// parseSshConfigLine parses a line of SSH config file, key is empty for blank and comment lines.
+// Format is 'Key value' or 'Key=value', arguments can be double-quoted, trailing comment is dropped.
+func parseSshConfigLine(line string) (key string, args []string, err error) {
+	line = strings.TrimSpace(line)
+	if len(line) < 1 || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	keyEnd := strings.IndexAny(line, " \t=")
+	if keyEnd < 0 {
+		return "", nil, fmt.Errorf("missing argument of %s", line)
+	}
+
+	key = line[:keyEnd]
+	rest := strings.TrimLeft(line[keyEnd:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+
+	args, err = splitSshConfigArgs(rest)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(args) < 1 {
+		return "", nil, fmt.Errorf("missing argument of %s", key)
+	}
+
+	return key, args, nil
+}
+
+// splitSshConfigArgs splits arguments by whitespaces, respecting double quotes, stops at comment
+func splitSshConfigArgs(input string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var inQuote, hasCurrent bool
+
+	for _, r := range input {
+		switch {
+		case inQuote:
+			if r == '"' {
+				inQuote = false
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"':
+			inQuote = true
+			hasCurrent = true
+		case r == ' ' || r == '\t':
+			if hasCurrent {
+				args = append(args, current.String())
+				current.Reset()
+				hasCurrent = false
+			}
+		case r == '#' && !hasCurrent:
+			return args, nil
+		default:
+			current.WriteRune(r)
+			hasCurrent = true
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	if hasCurrent {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
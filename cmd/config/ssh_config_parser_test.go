@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSshConfig(t *testing.T) {
+	content := `# global
+ServerAliveInterval 60
+
+Host bastion # jump host
+    HostName 10.0.0.1
+    User root
+    IdentityFile "/home/user/.ssh/id with space"
+
+Host=web-1 web-2
+    HostName=10.0.0.2
+    ProxyJump   bastion
+
+Match host *.internal
+    User ubuntu
+`
+
+	got, err := parseSshConfig(content)
+	if err != nil {
+		t.Fatalf("parseSshConfig() error = %v", err)
+	}
+
+	want := []sshConfigBlock{
+		{
+			options: []sshConfigOption{
+				{key: "ServerAliveInterval", args: []string{"60"}, line: 2},
+			},
+		},
+		{
+			keyword:  "Host",
+			patterns: []string{"bastion"},
+			line:     4,
+			options: []sshConfigOption{
+				{key: "HostName", args: []string{"10.0.0.1"}, line: 5},
+				{key: "User", args: []string{"root"}, line: 6},
+				{key: "IdentityFile", args: []string{"/home/user/.ssh/id with space"}, line: 7},
+			},
+		},
+		{
+			keyword:  "Host",
+			patterns: []string{"web-1", "web-2"},
+			line:     9,
+			options: []sshConfigOption{
+				{key: "HostName", args: []string{"10.0.0.2"}, line: 10},
+				{key: "ProxyJump", args: []string{"bastion"}, line: 11},
+			},
+		},
+		{
+			keyword:  "Match",
+			patterns: []string{"host", "*.internal"},
+			line:     13,
+			options: []sshConfigOption{
+				{key: "User", args: []string{"ubuntu"}, line: 14},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSshConfig() =\n%v\nwant\n%v", got, want)
+	}
+
+	if args, found := got[1].getOption("hostname"); !found || !reflect.DeepEqual(args, []string{"10.0.0.1"}) {
+		t.Errorf("getOption() = %v, %v", args, found)
+	}
+}
+
+func Test_parseSshConfig_error(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "missing host pattern",
+			content: "Host\n",
+		},
+		{
+			name:    "missing argument",
+			content: "Host a\n    HostName\n",
+		},
+		{
+			name:    "unterminated quote",
+			content: "Host a\n    IdentityFile \"/tmp/key\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSshConfig(tt.content); err == nil {
+				t.Errorf("parseSshConfig() expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,553 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	flagImportFrom             = "from"
+	flagImportKnownHosts       = "known-hosts"
+	flagImportDefaultUser      = "default-user"
+	flagImportInventoryFile    = "inventory-file"
+	flagImportInventoryFormat  = "inventory-format"
+	flagForceOverrideInventory = "force"
+)
+
+// maxSshConfigIncludeDepth is the maximum depth of nested Include directives, same as ssh
+const maxSshConfigIncludeDepth = 16
+
+// ImportSshCommands registers a sub-tree of commands
+func ImportSshCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import inventory from existing SSH config and known_hosts files",
+		Long: fmt.Sprintf(`Import inventory from existing SSH config and known_hosts files.
+Every specific host (not a pattern) of Host blocks in the SSH config file (including files provided by Include directives) becomes a host of the inventory,
+options HostName, User, Port, ProxyJump, IdentityFile and LocalForward are resolved by ssh first-match semantic, including wildcard blocks.
+Hosts of the known_hosts file (--%s) which are not defined in the SSH config file are also imported, hashed entries are skipped.
+Duplicated hosts and hosts pointing to the same address are reported.
+The inventory is written to stdout, or the file provided via --%s.`,
+			flagImportKnownHosts, flagImportInventoryFile,
+		),
+		Args: cobra.NoArgs,
+		Run:  importSshInventory,
+	}
+
+	cmd.Flags().String(
+		flagImportFrom,
+		path.Join(defaultSshKeyDir(), "config"),
+		"SSH config file to import from",
+	)
+
+	cmd.Flags().String(
+		flagImportKnownHosts,
+		"",
+		"known_hosts file to import from, eg: ~/.ssh/known_hosts",
+	)
+
+	cmd.Flags().String(
+		flagImportDefaultUser,
+		currentUserName(),
+		"user of hosts which User is not defined",
+	)
+
+	cmd.Flags().String(
+		flagImportInventoryFile,
+		"",
+		"output inventory file, default is stdout",
+	)
+
+	cmd.Flags().String(
+		flagImportInventoryFormat,
+		inventoryFormatAuto,
+		fmt.Sprintf("format of the output inventory: %s (by extension, %s for stdout), %s, %s, %s", inventoryFormatAuto, inventoryFormatYaml, inventoryFormatYaml, inventoryFormatTsv, inventoryFormatCsv),
+	)
+
+	cmd.Flags().Bool(
+		flagForceOverrideInventory,
+		false,
+		"override output inventory file if exists",
+	)
+
+	return cmd
+}
+
+func importSshInventory(cmd *cobra.Command, _ []string) {
+	from, _ := cmd.Flags().GetString(flagImportFrom)
+	knownHostsFile, _ := cmd.Flags().GetString(flagImportKnownHosts)
+	defaultUser, _ := cmd.Flags().GetString(flagImportDefaultUser)
+	outputFile, _ := cmd.Flags().GetString(flagImportInventoryFile)
+	outputFormat, _ := cmd.Flags().GetString(flagImportInventoryFormat)
+	force, _ := cmd.Flags().GetBool(flagForceOverrideInventory)
+
+	if libutils.IsBlank(from) && libutils.IsBlank(knownHostsFile) {
+		panic(fmt.Errorf("at least one of flags --%s and --%s is required", flagImportFrom, flagImportKnownHosts))
+	}
+
+	if libutils.IsBlank(defaultUser) {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagImportDefaultUser))
+	}
+
+	if outputFormat == inventoryFormatAuto || len(outputFormat) < 1 {
+		if libutils.IsBlank(outputFile) {
+			outputFormat = inventoryFormatYaml
+		} else {
+			outputFormat = detectInventoryFormat(outputFile)
+		}
+	}
+
+	if !libutils.IsBlank(outputFile) && isFileExists(outputFile) && !force {
+		panic(fmt.Errorf("output inventory file %s is already exists, supply --%s to override", outputFile, flagForceOverrideInventory))
+	}
+
+	importer := newSshInventoryImporter(defaultUser)
+
+	if !libutils.IsBlank(from) {
+		blocks, err := loadSshConfigFile(from)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to load SSH config file %s", from)))
+		}
+		importer.importSshConfig(blocks)
+	}
+
+	if !libutils.IsBlank(knownHostsFile) {
+		bz, err := os.ReadFile(knownHostsFile)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to read known_hosts file %s", knownHostsFile)))
+		}
+		importer.importKnownHosts(string(bz), knownHostsFile)
+	}
+
+	importer.reportAddressConflicts()
+
+	for _, report := range importer.reports {
+		libutils.PrintlnStdErr(report)
+	}
+
+	if err := importer.inventory.validate(); err != nil {
+		panic(errors.Wrap(err, "imported inventory is invalid"))
+	}
+
+	var buffer bytes.Buffer
+	if err := writeSshInventory(&buffer, importer.inventory, outputFormat); err != nil {
+		panic(errors.Wrap(err, "failed to write inventory"))
+	}
+
+	if libutils.IsBlank(outputFile) {
+		fmt.Print(buffer.String())
+		return
+	}
+
+	if err := os.WriteFile(outputFile, buffer.Bytes(), 0o644); err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to write inventory file %s", outputFile)))
+	}
+
+	libutils.PrintlnStdErr("Imported", len(importer.inventory.hosts), "hosts into", outputFile)
+}
+
+// currentUserName returns name of the current OS user, ssh uses it when User is not defined
+func currentUserName() string {
+	currentUser, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return currentUser.Username
+}
+
+// sshConfigSourceBlock is a block of SSH config with the file it belongs to
+type sshConfigSourceBlock struct {
+	sshConfigBlock
+	file string
+	// continuation is true if the block continues the previous declaration of the same Host, split by Include directive
+	continuation bool
+}
+
+// source returns file:line of the block
+func (b sshConfigSourceBlock) source() string {
+	return fmt.Sprintf("%s:%d", b.file, b.line)
+}
+
+// loadSshConfigFile parses the SSH config file, Include directives are expanded.
+// Relative paths of Include directive are relative to the directory of the root config file, like ~/.ssh for user config.
+func loadSshConfigFile(file string) ([]sshConfigSourceBlock, error) {
+	return loadSshConfigFileWithDepth(file, filepath.Dir(file), 0)
+}
+
+func loadSshConfigFileWithDepth(file, baseDir string, depth int) ([]sshConfigSourceBlock, error) {
+	if depth > maxSshConfigIncludeDepth {
+		return nil, fmt.Errorf("too many nested Include directives at %s", file)
+	}
+
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := parseSshConfig(string(bz))
+	if err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+
+	var result []sshConfigSourceBlock
+	for _, block := range blocks {
+		// options are split at each Include directive, options after the Include still belong to this block
+		current := sshConfigSourceBlock{
+			sshConfigBlock: sshConfigBlock{
+				keyword:  block.keyword,
+				patterns: block.patterns,
+				line:     block.line,
+			},
+			file: file,
+		}
+
+		for _, option := range block.options {
+			if !strings.EqualFold(option.key, "Include") {
+				current.options = append(current.options, option)
+				continue
+			}
+
+			result = append(result, current)
+			current.options = nil
+			current.continuation = true
+
+			for _, pattern := range option.args {
+				pattern = expandHomeDir(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+
+				includedFiles, err := filepath.Glob(pattern)
+				if err != nil {
+					return nil, errors.Wrap(err, fmt.Sprintf("bad Include pattern at %s:%d", file, option.line))
+				}
+
+				for _, includedFile := range includedFiles {
+					includedBlocks, err := loadSshConfigFileWithDepth(includedFile, baseDir, depth+1)
+					if err != nil {
+						return nil, err
+					}
+
+					for _, includedBlock := range includedBlocks {
+						if len(includedBlock.keyword) < 1 {
+							// options before the first block of included file are conditional on the including block
+							includedBlock.keyword = block.keyword
+							includedBlock.patterns = block.patterns
+							includedBlock.continuation = true
+						}
+						result = append(result, includedBlock)
+					}
+				}
+			}
+		}
+
+		result = append(result, current)
+	}
+
+	return result, nil
+}
+
+// sshInventoryImporter builds inventory from SSH config and known_hosts
+type sshInventoryImporter struct {
+	inventory   *sshInventory
+	defaultUser string
+	hostTracker sshHostTracker
+	// reports are duplicates, conflicts and skipped entries found while importing
+	reports []string
+}
+
+func newSshInventoryImporter(defaultUser string) *sshInventoryImporter {
+	return &sshInventoryImporter{
+		inventory:   &sshInventory{},
+		defaultUser: defaultUser,
+		hostTracker: newSshHostTracker(),
+	}
+}
+
+func (im *sshInventoryImporter) report(format string, a ...any) {
+	im.reports = append(im.reports, fmt.Sprintf(format, a...))
+}
+
+// importSshConfig imports every specific host of Host blocks, options are resolved by ssh first-match semantic
+func (im *sshInventoryImporter) importSshConfig(blocks []sshConfigSourceBlock) {
+	var hasMatchBlock bool
+	for _, block := range blocks {
+		if len(block.keyword) > 0 && !block.isHost() {
+			hasMatchBlock = true
+			continue
+		}
+		if !block.isHost() || block.continuation {
+			continue
+		}
+
+		for _, pattern := range block.patterns {
+			if !isSshHostAlias(pattern) {
+				continue
+			}
+
+			if previousSource, duplicated := im.hostTracker.track(pattern, block.source()); duplicated {
+				im.report("Duplicated host %s at %s, already defined at %s, options are merged by ssh first-match semantic", pattern, block.source(), previousSource)
+				continue
+			}
+
+			im.inventory.hosts = append(im.inventory.hosts, im.resolveSshConfigHost(pattern, blocks, block.source()))
+		}
+	}
+
+	if hasMatchBlock {
+		im.report("Match blocks are not supported and were ignored")
+	}
+}
+
+// resolveSshConfigHost resolves options of the host from all blocks matching it, ssh uses the first obtained value of each option
+func (im *sshInventoryImporter) resolveSshConfigHost(alias string, blocks []sshConfigSourceBlock, source string) sshInventoryHost {
+	host := sshInventoryHost{
+		Host:   alias,
+		source: source,
+	}
+
+	found := make(map[string]bool)
+	for _, block := range blocks {
+		if len(block.keyword) > 0 && !(block.isHost() && matchSshHostPatterns(block.patterns, alias)) {
+			continue
+		}
+
+		for _, option := range block.options {
+			key := strings.ToLower(option.key)
+			if key == "localforward" {
+				host.LocalForward = append(host.LocalForward, option.value())
+				continue
+			}
+
+			if found[key] {
+				continue
+			}
+
+			switch key {
+			case "hostname":
+				host.HostName = option.value()
+			case "user":
+				host.User = option.value()
+			case "port":
+				port, err := strconv.Atoi(option.value())
+				if err != nil {
+					im.report("Bad Port \"%s\" of host %s at %s:%d was ignored", option.value(), alias, block.file, option.line)
+					continue
+				}
+				host.Port = port
+			case "proxyjump":
+				host.ProxyJump = option.value()
+			case "identityfile":
+				host.IdentityFile = option.value()
+			default:
+				continue
+			}
+
+			found[key] = true
+		}
+	}
+
+	if len(host.HostName) < 1 {
+		host.HostName = alias
+	}
+	if len(host.User) < 1 {
+		host.User = im.defaultUser
+	}
+	if host.Port == 22 {
+		host.Port = 0
+	}
+
+	return host
+}
+
+// importKnownHosts imports hosts of known_hosts which are not imported yet, hashed entries are skipped
+func (im *sshInventoryImporter) importKnownHosts(content, file string) {
+	importedAddresses := make(map[string]bool)
+	for _, host := range im.inventory.hosts {
+		importedAddresses[sshAddress(strings.ToLower(host.HostName), host.Port)] = true
+	}
+
+	var hashedEntries int
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 1 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			im.report("Malformed entry at %s:%d was ignored", file, lineNumber)
+			continue
+		}
+
+		if strings.HasPrefix(fields[0], "|") {
+			hashedEntries++
+			continue
+		}
+
+		for _, name := range strings.Split(fields[0], ",") {
+			hostName, port, ok := parseKnownHostsName(name)
+			if !ok {
+				continue
+			}
+
+			address := sshAddress(strings.ToLower(hostName), port)
+			if importedAddresses[address] {
+				continue
+			}
+			importedAddresses[address] = true
+
+			alias := hostName
+			if port != 0 {
+				alias = fmt.Sprintf("%s-%d", hostName, port)
+			}
+
+			source := fmt.Sprintf("%s:%d", file, lineNumber)
+			if previousSource, duplicated := im.hostTracker.track(alias, source); duplicated {
+				im.report("Host %s at %s conflicts with the host defined at %s, skipped", alias, source, previousSource)
+				continue
+			}
+
+			im.inventory.hosts = append(im.inventory.hosts, sshInventoryHost{
+				Host:     alias,
+				HostName: hostName,
+				sshHostOptions: sshHostOptions{
+					User: im.defaultUser,
+					Port: port,
+				},
+				source: source,
+			})
+		}
+	}
+
+	if hashedEntries > 0 {
+		im.report("Skipped %d hashed entries of %s, host names could not be recovered", hashedEntries, file)
+	}
+}
+
+// parseKnownHostsName parses a host name of known_hosts, format 'host' or '[host]:port'.
+// Port is zero for the default port, patterns and negated names are not accepted.
+func parseKnownHostsName(name string) (hostName string, port int, ok bool) {
+	if len(name) < 1 || strings.ContainsAny(name, "*?!") {
+		return "", 0, false
+	}
+
+	if !strings.HasPrefix(name, "[") {
+		return name, 0, isSshHostAlias(name)
+	}
+
+	host, portStr, err := net.SplitHostPort(name)
+	if err != nil {
+		return "", 0, false
+	}
+	host = strings.Trim(host, "[]")
+
+	port, err = strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, false
+	}
+	if port == 22 {
+		port = 0
+	}
+
+	return host, port, isSshHostAlias(host)
+}
+
+// reportAddressConflicts reports hosts pointing to the same address
+func (im *sshInventoryImporter) reportAddressConflicts() {
+	var addresses []string
+	hostsByAddress := make(map[string][]string)
+	for _, host := range im.inventory.hosts {
+		address := sshAddress(strings.ToLower(host.HostName), host.Port)
+		if _, found := hostsByAddress[address]; !found {
+			addresses = append(addresses, address)
+		}
+		hostsByAddress[address] = append(hostsByAddress[address], host.Host)
+	}
+
+	for _, address := range addresses {
+		if hosts := hostsByAddress[address]; len(hosts) > 1 {
+			im.report("Hosts %s point to the same address %s", strings.Join(hosts, ", "), address)
+		}
+	}
+}
+
+// writeSshInventory writes the inventory in the format, TSV & CSV are written with header row
+func writeSshInventory(w io.Writer, inventory *sshInventory, format string) error {
+	switch format {
+	case inventoryFormatYaml:
+		var raw yamlSshInventory
+		for _, host := range inventory.hosts {
+			raw.Hosts = append(raw.Hosts, sshInventoryHostRaw{
+				Host:           host.Host,
+				HostName:       host.HostName,
+				Comment:        host.Comment,
+				Tags:           host.Tags,
+				sshHostOptions: host.sshHostOptions,
+			})
+		}
+
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(raw); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case inventoryFormatTsv, inventoryFormatCsv:
+		columns := []string{
+			inventoryColumnHost, inventoryColumnHostName, inventoryColumnUser, inventoryColumnPort, inventoryColumnProxyJump,
+			inventoryColumnIdentityFile, inventoryColumnLocalForward, inventoryColumnTags, inventoryColumnComment,
+		}
+		records := [][]string{columns}
+		for _, host := range inventory.hosts {
+			var port string
+			if host.Port > 0 {
+				port = strconv.Itoa(host.Port)
+			}
+			records = append(records, []string{
+				host.Host, host.HostName, host.User, port, host.ProxyJump,
+				host.IdentityFile, strings.Join(host.LocalForward, listValueSeparator), strings.Join(host.Tags, listValueSeparator), host.Comment,
+			})
+		}
+
+		if format == inventoryFormatCsv {
+			writer := csv.NewWriter(w)
+			if err := writer.WriteAll(records); err != nil {
+				return err
+			}
+			return writer.Error()
+		}
+
+		for _, record := range records {
+			for _, cell := range record {
+				if strings.ContainsAny(cell, "\t\n") {
+					return fmt.Errorf("value \"%s\" could not be written into TSV", cell)
+				}
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(record, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("not supported inventory format %s", format)
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_sshInventoryImporter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(path.Join(dir, "conf.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"config": `
+Host *
+    ServerAliveInterval 30
+
+Host bastion
+    HostName 1.2.3.4
+    User root
+    Include conf.d/*.conf
+    IdentityFile ~/.ssh/id_bastion
+
+Host prod-web-1 prod-web-2
+    HostName 10.0.0.1
+    ProxyJump bastion
+    LocalForward 8080 localhost:80
+
+Host prod-*
+    User ubuntu
+    Port 2222
+    LocalForward 9090 localhost:90
+
+Host bastion
+    User other
+
+Match host foo
+    User x
+`,
+		"conf.d/a.conf": `
+Port 2200
+
+Host inc-host
+    HostName 5.6.7.8
+`,
+		"known_hosts": `
+# comment
+1.2.3.4 ssh-ed25519 AAAA
+[1.2.3.4]:2200 ssh-ed25519 AAAA
+[9.9.9.9]:2022,9.9.9.10 ssh-ed25519 AAAA
+9.9.9.10 ssh-rsa AAAA
+|1|abc=|def= ssh-ed25519 AAAA
+@cert-authority *.example.com ssh-ed25519 AAAA
+*.example.com ssh-ed25519 AAAA
+inc-host ssh-ed25519 AAAA
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blocks, err := loadSshConfigFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importer := newSshInventoryImporter("me")
+	importer.importSshConfig(blocks)
+	importer.importKnownHosts(files["known_hosts"], "known_hosts")
+	importer.reportAddressConflicts()
+
+	for i := range importer.inventory.hosts {
+		importer.inventory.hosts[i].source = ""
+	}
+
+	wantHosts := []sshInventoryHost{
+		{
+			Host:     "bastion",
+			HostName: "1.2.3.4",
+			sshHostOptions: sshHostOptions{
+				User:         "root",
+				Port:         2200,
+				IdentityFile: "~/.ssh/id_bastion",
+			},
+		},
+		{
+			Host:           "inc-host",
+			HostName:       "5.6.7.8",
+			sshHostOptions: sshHostOptions{User: "me"},
+		},
+		{
+			Host:     "prod-web-1",
+			HostName: "10.0.0.1",
+			sshHostOptions: sshHostOptions{
+				User:         "ubuntu",
+				Port:         2222,
+				ProxyJump:    "bastion",
+				LocalForward: []string{"8080 localhost:80", "9090 localhost:90"},
+			},
+		},
+		{
+			Host:     "prod-web-2",
+			HostName: "10.0.0.1",
+			sshHostOptions: sshHostOptions{
+				User:         "ubuntu",
+				Port:         2222,
+				ProxyJump:    "bastion",
+				LocalForward: []string{"8080 localhost:80", "9090 localhost:90"},
+			},
+		},
+		{
+			Host:           "1.2.3.4",
+			HostName:       "1.2.3.4",
+			sshHostOptions: sshHostOptions{User: "me"},
+		},
+		{
+			Host:           "9.9.9.9-2022",
+			HostName:       "9.9.9.9",
+			sshHostOptions: sshHostOptions{User: "me", Port: 2022},
+		},
+		{
+			Host:           "9.9.9.10",
+			HostName:       "9.9.9.10",
+			sshHostOptions: sshHostOptions{User: "me"},
+		},
+	}
+	if !reflect.DeepEqual(importer.inventory.hosts, wantHosts) {
+		t.Errorf("imported hosts =\n%+v\nwant\n%+v", importer.inventory.hosts, wantHosts)
+	}
+
+	wantReports := []string{
+		"Duplicated host bastion at " + path.Join(dir, "config") + ":21",
+		"Match blocks are not supported",
+		"Host inc-host at known_hosts:10 conflicts with the host defined at " + path.Join(dir, "conf.d/a.conf") + ":4",
+		"Skipped 1 hashed entries",
+		"Hosts prod-web-1, prod-web-2 point to the same address 10.0.0.1:2222",
+	}
+	if len(importer.reports) != len(wantReports) {
+		t.Fatalf("reports =\n%s", strings.Join(importer.reports, "\n"))
+	}
+	for i, report := range importer.reports {
+		if !strings.HasPrefix(report, wantReports[i]) {
+			t.Errorf("report #%d = %s, want prefix %s", i, report, wantReports[i])
+		}
+	}
+
+	if err := importer.inventory.validate(); err != nil {
+		t.Errorf("imported inventory is invalid: %v", err)
+	}
+}
+
+func Test_writeSshInventory(t *testing.T) {
+	inventory := &sshInventory{
+		hosts: []sshInventoryHost{
+			{
+				Host:     "web-1",
+				HostName: "10.0.0.1",
+				Comment:  "main, web",
+				Tags:     []string{"prod", "web"},
+				sshHostOptions: sshHostOptions{
+					User:         "ubuntu",
+					Port:         2222,
+					ProxyJump:    "bastion",
+					IdentityFile: "/keys/my key",
+					LocalForward: []string{"8080 localhost:80", "9090 localhost:90"},
+				},
+			},
+			{
+				Host:           "bastion",
+				HostName:       "1.2.3.4",
+				sshHostOptions: sshHostOptions{User: "root"},
+			},
+		},
+	}
+
+	for _, format := range []string{inventoryFormatYaml, inventoryFormatCsv, inventoryFormatTsv} {
+		t.Run(format, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := writeSshInventory(&buffer, inventory, format); err != nil {
+				t.Fatal(err)
+			}
+
+			file := path.Join(t.TempDir(), "inventory")
+			if err := os.WriteFile(file, buffer.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readSshInventory(file, format)
+			if err != nil {
+				t.Fatalf("failed to read written inventory: %v\n%s", err, buffer.String())
+			}
+
+			for i := range got.hosts {
+				got.hosts[i].source = ""
+				got.hosts[i].headComments = nil
+			}
+
+			if !reflect.DeepEqual(got.hosts, inventory.hosts) {
+				t.Errorf("read hosts =\n%+v\nwant\n%+v\ncontent:\n%s", got.hosts, inventory.hosts, buffer.String())
+			}
+		})
+	}
+}
+
+func Test_parseKnownHostsName(t *testing.T) {
+	tests := []struct {
+		name         string
+		wantHostName string
+		wantPort     int
+		wantOk       bool
+	}{
+		{name: "github.com", wantHostName: "github.com", wantOk: true},
+		{name: "[1.2.3.4]:2222", wantHostName: "1.2.3.4", wantPort: 2222, wantOk: true},
+		{name: "[1.2.3.4]:22", wantHostName: "1.2.3.4", wantOk: true},
+		{name: "[::1]:2222", wantHostName: "::1", wantPort: 2222, wantOk: true},
+		{name: "*.example.com", wantOk: false},
+		{name: "!bad.example.com", wantOk: false},
+		{name: "[1.2.3.4]:abc", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHostName, gotPort, gotOk := parseKnownHostsName(tt.name)
+			if gotOk != tt.wantOk {
+				t.Fatalf("parseKnownHostsName() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+			if gotOk && (gotHostName != tt.wantHostName || gotPort != tt.wantPort) {
+				t.Errorf("parseKnownHostsName() = %s, %d, want %s, %d", gotHostName, gotPort, tt.wantHostName, tt.wantPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,683 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	inventoryFormatAuto = "auto"
+	inventoryFormatTsv  = "tsv"
+	inventoryFormatCsv  = "csv"
+	inventoryFormatYaml = "yaml"
+)
+
+// supported columns of TSV/CSV inventory with header row
+const (
+	inventoryColumnHost         = "host"
+	inventoryColumnHostName     = "hostname"
+	inventoryColumnUser         = "user"
+	inventoryColumnPort         = "port"
+	inventoryColumnProxyJump    = "proxyjump"
+	inventoryColumnIdentityFile = "identityfile"
+	inventoryColumnLocalForward = "localforward"
+	inventoryColumnGroup        = "group"
+	inventoryColumnTags         = "tags"
+	inventoryColumnComment      = "comment"
+)
+
+var supportedInventoryColumns = []string{
+	inventoryColumnHost, inventoryColumnHostName, inventoryColumnUser, inventoryColumnPort, inventoryColumnProxyJump,
+	inventoryColumnIdentityFile, inventoryColumnLocalForward, inventoryColumnGroup, inventoryColumnTags, inventoryColumnComment,
+}
+
+// listValueSeparator separates multiple values (LocalForward, tags) within a TSV/CSV cell
+const listValueSeparator = ";"
+
+var regexInventoryColumnNameIgnoredChars = regexp.MustCompile(`[\s_-]+`)
+
+// sshInventory is the list of hosts to generate SSH config
+type sshInventory struct {
+	groups []sshInventoryGroup
+	hosts  []sshInventoryHost
+	// tailComments are comment lines after the last host
+	tailComments []string
+}
+
+// sshInventoryGroup is a named group of hosts, defaults are applied to hosts of the group.
+// When Pattern is provided, defaults are emitted once as a wildcard Host block instead of being repeated in each host.
+type sshInventoryGroup struct {
+	Name     string                `yaml:"name"`
+	Pattern  string                `yaml:"pattern,omitempty"`
+	Tags     []string              `yaml:"tags,omitempty"`
+	Defaults sshHostOptions        `yaml:"defaults,omitempty"`
+	Hosts    []sshInventoryHostRaw `yaml:"hosts"`
+}
+
+// patterns returns the host patterns of the wildcard block of this group
+func (g sshInventoryGroup) patterns() []string {
+	return splitSshHostPatterns(g.Pattern)
+}
+
+// sshHostOptions are optional options of a host, can be provided as defaults of a group
+type sshHostOptions struct {
+	User         string   `yaml:"user,omitempty"`
+	Port         int      `yaml:"port,omitempty"`
+	ProxyJump    string   `yaml:"proxyjump,omitempty"`
+	IdentityFile string   `yaml:"identityfile,omitempty"`
+	LocalForward []string `yaml:"localforward,omitempty"`
+}
+
+// sshInventoryHostRaw is a host as defined in YAML inventory
+type sshInventoryHostRaw struct {
+	Host           string   `yaml:"host"`
+	HostName       string   `yaml:"hostname"`
+	Comment        string   `yaml:"comment,omitempty"`
+	Tags           []string `yaml:"tags,omitempty"`
+	sshHostOptions `yaml:",inline"`
+}
+
+// sshInventoryHost is a host defined in the inventory
+type sshInventoryHost struct {
+	Host     string
+	HostName string
+	Group    string
+	Comment  string
+	Tags     []string
+	sshHostOptions
+
+	// inherited are options provided by the wildcard block of the group, not written into the host block
+	inherited sshHostOptions
+	// source describes where the host is defined, used in error messages
+	source string
+	// headComments are comment lines right before this host
+	headComments []string
+}
+
+// yamlSshInventory is the root of YAML inventory
+type yamlSshInventory struct {
+	Defaults sshHostOptions        `yaml:"defaults,omitempty"`
+	Groups   []sshInventoryGroup   `yaml:"groups,omitempty"`
+	Hosts    []sshInventoryHostRaw `yaml:"hosts,omitempty"`
+}
+
+// detectInventoryFormat detects format of inventory file by its extension
+func detectInventoryFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return inventoryFormatYaml
+	case ".csv":
+		return inventoryFormatCsv
+	default:
+		return inventoryFormatTsv
+	}
+}
+
+// readSshInventory reads and validates the inventory file
+func readSshInventory(file string, format string) (*sshInventory, error) {
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read %s", file))
+	}
+
+	if len(strings.TrimSpace(string(bz))) < 1 {
+		return nil, fmt.Errorf("input inventory %s is empty", file)
+	}
+
+	if format == inventoryFormatAuto || len(format) < 1 {
+		format = detectInventoryFormat(file)
+	}
+
+	var inventory *sshInventory
+	switch format {
+	case inventoryFormatTsv:
+		inventory, err = parseTsvInventory(string(bz))
+	case inventoryFormatCsv:
+		inventory, err = parseCsvInventory(bz)
+	case inventoryFormatYaml:
+		inventory, err = parseYamlInventory(bz)
+	default:
+		return nil, fmt.Errorf("not supported inventory format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := inventory.validate(); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// parseTsvInventory parses TSV inventory, with header row or legacy format without header
+func parseTsvInventory(content string) (*sshInventory, error) {
+	lines := strings.Split(content, "\n")
+
+	var records [][]string
+	var lineNumbers []int
+	var rawLines []string
+	for idx, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if libutils.IsBlank(line) {
+			continue
+		}
+		records = append(records, strings.Split(line, "\t"))
+		lineNumbers = append(lineNumbers, idx+1)
+		rawLines = append(rawLines, strings.TrimSpace(line))
+	}
+
+	if headerIdx := findInventoryHeader(records); headerIdx >= 0 {
+		return parseDelimitedInventory(records, lineNumbers, headerIdx)
+	}
+
+	return parseLegacyTsvInventory(rawLines)
+}
+
+// parseLegacyTsvInventory parses TSV inventory without header, each line format: Host<tab>HostName<tab>User(<tab># Optional comment)
+func parseLegacyTsvInventory(tsvLines []string) (*sshInventory, error) {
+	inventory := &sshInventory{}
+
+	var comments []string
+	for _, line := range tsvLines {
+		if strings.HasPrefix(line, "#") {
+			fmt.Println("Skipped comment line:", line)
+			comments = append(comments, line)
+			continue
+		}
+		if libutils.IsBlank(line) {
+			continue
+		}
+		spl := strings.SplitN(
+			regexReplaceContinousSpace.ReplaceAllString(strings.Replace(line, "\t", " ", -1), " "),
+			" ", 3,
+		)
+		if len(spl) != 3 {
+			return nil, fmt.Errorf("in input tsv file, each line must maintains format: %s", tsvLineFormat)
+		}
+
+		host := strings.TrimSpace(spl[0])
+		hostName := strings.TrimSpace(spl[1])
+		user := strings.TrimSpace(spl[2])
+
+		var comment string
+
+		commentSymbolIdx := strings.Index(user, "#")
+		if commentSymbolIdx == 0 {
+			return nil, fmt.Errorf("comment symbol '#' could not be exists at first character")
+		} else if commentSymbolIdx > 0 {
+			spl = strings.Split(user, "#")
+			user = strings.TrimSpace(spl[0])
+			comment = strings.TrimSpace(spl[1])
+		}
+
+		inventory.hosts = append(inventory.hosts, sshInventoryHost{
+			Host:     host,
+			HostName: hostName,
+			Comment:  comment,
+			sshHostOptions: sshHostOptions{
+				User: user,
+			},
+			source:       fmt.Sprintf("line: %s", line),
+			headComments: comments,
+		})
+		comments = nil
+	}
+
+	inventory.tailComments = comments
+	return inventory, nil
+}
+
+// parseCsvInventory parses CSV inventory, header row is required
+func parseCsvInventory(bz []byte) (*sshInventory, error) {
+	reader := csv.NewReader(bytes.NewReader(bz))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	var lineNumbers []int
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "failed to parse CSV")
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lineNumbers = append(lineNumbers, line)
+	}
+
+	headerIdx := findInventoryHeader(records)
+	if headerIdx < 0 {
+		return nil, fmt.Errorf("header row is required for CSV inventory, supported columns: %s", strings.Join(supportedInventoryColumns, ", "))
+	}
+
+	return parseDelimitedInventory(records, lineNumbers, headerIdx)
+}
+
+// findInventoryHeader returns index of the header row (first non-comment row), -1 if not found.
+// Header row must contain columns host & hostname and all columns must be supported.
+func findInventoryHeader(records [][]string) int {
+	for idx, record := range records {
+		if len(record) < 1 || strings.HasPrefix(strings.TrimSpace(record[0]), "#") {
+			continue
+		}
+
+		columns := make(map[string]bool)
+		for _, cell := range record {
+			column := normalizeInventoryColumnName(cell)
+			if len(column) < 1 {
+				continue
+			}
+			if !isSupportedInventoryColumn(column) {
+				return -1
+			}
+			columns[column] = true
+		}
+
+		if columns[inventoryColumnHost] && columns[inventoryColumnHostName] {
+			return idx
+		}
+
+		return -1
+	}
+
+	return -1
+}
+
+func normalizeInventoryColumnName(name string) string {
+	return strings.ToLower(regexInventoryColumnNameIgnoredChars.ReplaceAllString(strings.TrimSpace(name), ""))
+}
+
+func isSupportedInventoryColumn(column string) bool {
+	for _, supportedColumn := range supportedInventoryColumns {
+		if column == supportedColumn {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDelimitedInventory parses TSV/CSV records, columns are mapped by the header row
+func parseDelimitedInventory(records [][]string, lineNumbers []int, headerIdx int) (*sshInventory, error) {
+	columnIndexes := make(map[string]int)
+	for idx, cell := range records[headerIdx] {
+		column := normalizeInventoryColumnName(cell)
+		if len(column) < 1 {
+			continue
+		}
+		if _, found := columnIndexes[column]; found {
+			return nil, fmt.Errorf("duplicated column %s in header row", column)
+		}
+		columnIndexes[column] = idx
+	}
+
+	inventory := &sshInventory{}
+
+	var comments []string
+	for recordIdx, record := range records {
+		if recordIdx == headerIdx {
+			continue
+		}
+
+		if len(record) > 0 && strings.HasPrefix(strings.TrimSpace(record[0]), "#") {
+			comments = append(comments, strings.TrimSpace(strings.Join(record, " ")))
+			continue
+		}
+
+		if recordIdx < headerIdx {
+			continue
+		}
+
+		get := func(column string) string {
+			idx, found := columnIndexes[column]
+			if !found || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+
+		host := sshInventoryHost{
+			Host:     get(inventoryColumnHost),
+			HostName: get(inventoryColumnHostName),
+			Group:    get(inventoryColumnGroup),
+			Comment:  get(inventoryColumnComment),
+			sshHostOptions: sshHostOptions{
+				User:         get(inventoryColumnUser),
+				ProxyJump:    get(inventoryColumnProxyJump),
+				IdentityFile: get(inventoryColumnIdentityFile),
+			},
+			source:       fmt.Sprintf("line %d", lineNumbers[recordIdx]),
+			headComments: comments,
+		}
+		comments = nil
+
+		if port := get(inventoryColumnPort); len(port) > 0 {
+			var err error
+			host.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("bad port \"%s\" at %s", port, host.source)
+			}
+		}
+
+		host.LocalForward = splitListValue(get(inventoryColumnLocalForward))
+		host.Tags = splitListValue(get(inventoryColumnTags))
+
+		inventory.hosts = append(inventory.hosts, host)
+	}
+
+	inventory.tailComments = comments
+	return inventory, nil
+}
+
+// splitListValue splits multiple values within a TSV/CSV cell
+func splitListValue(cell string) []string {
+	var values []string
+	for _, value := range strings.Split(cell, listValueSeparator) {
+		if value = strings.TrimSpace(value); len(value) > 0 {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseYamlInventory parses YAML inventory
+func parseYamlInventory(bz []byte) (*sshInventory, error) {
+	var raw yamlSshInventory
+	decoder := yaml.NewDecoder(bytes.NewReader(bz))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse YAML inventory")
+	}
+
+	inventory := &sshInventory{}
+
+	toHost := func(rawHost sshInventoryHostRaw, group *sshInventoryGroup) sshInventoryHost {
+		host := sshInventoryHost{
+			Host:     rawHost.Host,
+			HostName: rawHost.HostName,
+			Comment:  rawHost.Comment,
+			Tags:     rawHost.Tags,
+			source:   fmt.Sprintf("host \"%s\"", rawHost.Host),
+		}
+
+		if group == nil {
+			host.sshHostOptions = rawHost.sshHostOptions.withDefaults(raw.Defaults)
+			return host
+		}
+
+		host.Group = group.Name
+		host.Tags = mergeTags(group.Tags, rawHost.Tags)
+		host.source = fmt.Sprintf("host \"%s\" of group %s", rawHost.Host, group.Name)
+		if len(group.Pattern) > 0 {
+			// options of the group are provided by the wildcard block
+			host.sshHostOptions = rawHost.sshHostOptions
+			host.inherited = group.Defaults
+		} else {
+			host.sshHostOptions = rawHost.sshHostOptions.withDefaults(group.Defaults)
+		}
+
+		return host
+	}
+
+	uniqueGroups := make(map[string]bool)
+	for _, group := range raw.Groups {
+		if len(strings.TrimSpace(group.Name)) < 1 {
+			return nil, fmt.Errorf("group name must not be empty")
+		}
+		if uniqueGroups[group.Name] {
+			return nil, fmt.Errorf("group %s is not unique", group.Name)
+		}
+		uniqueGroups[group.Name] = true
+
+		group.Pattern = strings.TrimSpace(group.Pattern)
+		group.Defaults = group.Defaults.withDefaults(raw.Defaults)
+		if len(group.Pattern) > 0 {
+			if err := validateSshHostPatterns(group.patterns()); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("bad pattern of group %s", group.Name))
+			}
+		}
+
+		inventory.groups = append(inventory.groups, group)
+		for _, rawHost := range group.Hosts {
+			inventory.hosts = append(inventory.hosts, toHost(rawHost, &group))
+		}
+	}
+
+	for _, rawHost := range raw.Hosts {
+		inventory.hosts = append(inventory.hosts, toHost(rawHost, nil))
+	}
+
+	return inventory, nil
+}
+
+// mergeTags returns distinct tags of both lists, order is kept
+func mergeTags(tags1, tags2 []string) []string {
+	var merged []string
+	unique := make(map[string]bool)
+	for _, tag := range append(append([]string{}, tags1...), tags2...) {
+		if unique[tag] {
+			continue
+		}
+		unique[tag] = true
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// effectiveOptions returns options applied to the host by ssh, including the ones provided by the wildcard block
+func (h sshInventoryHost) effectiveOptions() sshHostOptions {
+	return h.sshHostOptions.withDefaults(h.inherited)
+}
+
+// hasAnyTag returns true if the host has at least one of the tags
+func (h sshInventoryHost) hasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, hostTag := range h.Tags {
+			if strings.EqualFold(tag, hostTag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withDefaults returns options, empty values are filled by the defaults
+func (o sshHostOptions) withDefaults(defaults sshHostOptions) sshHostOptions {
+	if len(o.User) < 1 {
+		o.User = defaults.User
+	}
+	if o.Port == 0 {
+		o.Port = defaults.Port
+	}
+	if len(o.ProxyJump) < 1 {
+		o.ProxyJump = defaults.ProxyJump
+	}
+	if len(o.IdentityFile) < 1 {
+		o.IdentityFile = defaults.IdentityFile
+	}
+	if len(o.LocalForward) < 1 {
+		o.LocalForward = defaults.LocalForward
+	}
+	return o
+}
+
+// validate checks mandatory fields and uniqueness of hosts
+func (inv *sshInventory) validate() error {
+	if len(inv.hosts) < 1 {
+		return fmt.Errorf("no host was defined in the inventory")
+	}
+
+	hostTracker := newSshHostTracker()
+	for _, host := range inv.hosts {
+		if len(host.Host) < 1 {
+			return fmt.Errorf("SSH config host must not be empty at %s", host.source)
+		}
+		if _, duplicated := hostTracker.track(host.Host, host.source); duplicated {
+			return fmt.Errorf("SSH config host does not unique at %s", host.source)
+		}
+		if !isSshHostAlias(host.Host) {
+			return fmt.Errorf("SSH config host must not contain whitespace or pattern characters at %s", host.source)
+		}
+		if len(host.HostName) < 1 {
+			return fmt.Errorf("SSH config host name must not be empty at %s", host.source)
+		}
+		options := host.effectiveOptions()
+		if len(options.User) < 1 {
+			return fmt.Errorf("SSH config user must not be empty at %s", host.source)
+		}
+		if options.Port < 0 || options.Port > 65535 {
+			return fmt.Errorf("SSH config port %d is invalid at %s", options.Port, host.source)
+		}
+		for _, tag := range host.Tags {
+			if strings.ContainsAny(tag, " \t"+listValueSeparator) {
+				return fmt.Errorf("SSH config tag \"%s\" must not contain whitespace or '%s' at %s", tag, listValueSeparator, host.source)
+			}
+		}
+	}
+
+	return inv.validateWildcardGroups()
+}
+
+// validateWildcardGroups ensures wildcard block of each group matches all hosts of the group
+// and never shadows hosts of other groups, because wildcard blocks are written after all host blocks
+// and ssh applies the first obtained value of each option.
+func (inv *sshInventory) validateWildcardGroups() error {
+	for _, group := range inv.groups {
+		if len(group.Pattern) < 1 {
+			continue
+		}
+
+		patterns := group.patterns()
+		for _, host := range inv.hosts {
+			matched := matchSshHostPatterns(patterns, host.Host)
+			if host.Group == group.Name && !matched {
+				return fmt.Errorf("pattern '%s' of group %s does not match %s so the group defaults would not be applied", group.Pattern, group.Name, host.source)
+			}
+			if host.Group != group.Name && matched {
+				return fmt.Errorf("pattern '%s' of group %s shadows %s which does not belong to the group", group.Pattern, group.Name, host.source)
+			}
+		}
+	}
+
+	return nil
+}
+
+// findHandWrittenHostsShadowedByGroups returns warnings for hand-written hosts placed after the managed section,
+// those match wildcard block of any group so options of the group take precedence over the hand-written ones.
+func (inv *sshInventory) findHandWrittenHostsShadowedByGroups(handWrittenAfter string) ([]string, error) {
+	blocks, err := parseSshConfig(handWrittenAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make(map[string]bool)
+	for _, host := range inv.hostNames() {
+		managed[strings.ToLower(host)] = true
+	}
+
+	var warnings []string
+	for _, block := range blocks {
+		if !block.isHost() {
+			continue
+		}
+
+		for _, host := range block.patterns {
+			if strings.ContainsAny(host, "*?!") || managed[strings.ToLower(host)] {
+				continue
+			}
+
+			for _, group := range inv.groups {
+				if len(group.Pattern) > 0 && matchSshHostPatterns(group.patterns(), host) {
+					warnings = append(warnings, fmt.Sprintf("hand-written host %s is placed after the managed section and matches pattern '%s' of group %s, options of the group take precedence", host, group.Pattern, group.Name))
+				}
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// filterByTags returns a new inventory contains only hosts having at least one of the tags.
+// Wildcard blocks of groups without any remaining host are removed.
+func (inv *sshInventory) filterByTags(tags []string) *sshInventory {
+	if len(tags) < 1 {
+		return inv
+	}
+
+	filtered := &sshInventory{
+		tailComments: inv.tailComments,
+	}
+
+	remainingGroups := make(map[string]bool)
+	for _, host := range inv.hosts {
+		if !host.hasAnyTag(tags) {
+			continue
+		}
+		filtered.hosts = append(filtered.hosts, host)
+		remainingGroups[host.Group] = true
+	}
+
+	for _, group := range inv.groups {
+		if remainingGroups[group.Name] {
+			filtered.groups = append(filtered.groups, group)
+		}
+	}
+
+	return filtered
+}
+
+// sshHostTracker tracks uniqueness of hosts, host is case-insensitive
+type sshHostTracker map[string]string
+
+func newSshHostTracker() sshHostTracker {
+	return make(sshHostTracker)
+}
+
+// track records the host, returns source of the previous definition if the host was already tracked
+func (t sshHostTracker) track(host, source string) (previousSource string, duplicated bool) {
+	normalizedHost := strings.ToLower(host)
+	if previousSource, found := t[normalizedHost]; found {
+		return previousSource, true
+	}
+	t[normalizedHost] = source
+	return "", false
+}
+
+// isSshHostAlias returns true if the host can be used as a specific host of Host keyword,
+// it must not contain whitespace or pattern characters
+func isSshHostAlias(host string) bool {
+	return len(host) > 0 && !strings.ContainsAny(host, " \t*?!")
+}
+
+// hostNames returns name of all hosts
+func (inv *sshInventory) hostNames() []string {
+	hosts := make([]string, len(inv.hosts))
+	for i, host := range inv.hosts {
+		hosts[i] = host.Host
+	}
+	return hosts
+}
+
+// requireDefaultIdentityFiles returns true if any host does not define its own identity file
+func (inv *sshInventory) requireDefaultIdentityFiles() bool {
+	for _, host := range inv.hosts {
+		if len(host.effectiveOptions().IdentityFile) < 1 {
+			return true
+		}
+	}
+	return false
+}
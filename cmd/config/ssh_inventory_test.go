@@ -0,0 +1,522 @@
+package config
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func Test_readSshInventory(t *testing.T) {
+	tests := []struct {
+		name      string
+		fileName  string
+		content   string
+		wantHosts []sshInventoryHost
+		wantErr   bool
+	}{
+		{
+			name:     "legacy TSV",
+			fileName: "input.tsv",
+			content:  "# web\nweb-1\t10.0.0.1\tubuntu # main\ndb-1  10.0.0.2 root\n",
+			wantHosts: []sshInventoryHost{
+				{
+					Host:           "web-1",
+					HostName:       "10.0.0.1",
+					Comment:        "main",
+					sshHostOptions: sshHostOptions{User: "ubuntu"},
+				},
+				{
+					Host:           "db-1",
+					HostName:       "10.0.0.2",
+					sshHostOptions: sshHostOptions{User: "root"},
+				},
+			},
+		},
+		{
+			name:     "TSV with header, columns in any order",
+			fileName: "input.txt",
+			content:  "HostName\tHost\tUser\tPort\tLocal Forward\tcomment\n10.0.0.1\tweb-1\tubuntu\t2222\t8080 localhost:80;9090 localhost:90\tmain\n10.0.0.2\tdb-1\troot\t\t\t\n",
+			wantHosts: []sshInventoryHost{
+				{
+					Host:     "web-1",
+					HostName: "10.0.0.1",
+					Comment:  "main",
+					sshHostOptions: sshHostOptions{
+						User:         "ubuntu",
+						Port:         2222,
+						LocalForward: []string{"8080 localhost:80", "9090 localhost:90"},
+					},
+				},
+				{
+					Host:           "db-1",
+					HostName:       "10.0.0.2",
+					sshHostOptions: sshHostOptions{User: "root"},
+				},
+			},
+		},
+		{
+			name:     "CSV",
+			fileName: "input.csv",
+			content:  "host,hostname,user,proxy_jump,identity_file,group\nweb-1,10.0.0.1,ubuntu,bastion,\"/home/me/.ssh/id key\",prod\n",
+			wantHosts: []sshInventoryHost{
+				{
+					Host:     "web-1",
+					HostName: "10.0.0.1",
+					Group:    "prod",
+					sshHostOptions: sshHostOptions{
+						User:         "ubuntu",
+						ProxyJump:    "bastion",
+						IdentityFile: "/home/me/.ssh/id key",
+					},
+				},
+			},
+		},
+		{
+			name:     "CSV without header",
+			fileName: "input.csv",
+			content:  "web-1,10.0.0.1,ubuntu\n",
+			wantErr:  true,
+		},
+		{
+			name:     "YAML with group defaults",
+			fileName: "input.yaml",
+			content: `
+defaults:
+  user: ubuntu
+groups:
+  - name: prod
+    defaults:
+      proxyjump: bastion
+      port: 2222
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+      - host: prod-db-1
+        hostname: 10.0.0.2
+        user: postgres
+        proxyjump: other-bastion
+hosts:
+  - host: bastion
+    hostname: 1.2.3.4
+    user: root
+    comment: jump host
+`,
+			wantHosts: []sshInventoryHost{
+				{
+					Host:     "prod-web-1",
+					HostName: "10.0.0.1",
+					Group:    "prod",
+					sshHostOptions: sshHostOptions{
+						User:      "ubuntu",
+						Port:      2222,
+						ProxyJump: "bastion",
+					},
+				},
+				{
+					Host:     "prod-db-1",
+					HostName: "10.0.0.2",
+					Group:    "prod",
+					sshHostOptions: sshHostOptions{
+						User:      "postgres",
+						Port:      2222,
+						ProxyJump: "other-bastion",
+					},
+				},
+				{
+					Host:           "bastion",
+					HostName:       "1.2.3.4",
+					Comment:        "jump host",
+					sshHostOptions: sshHostOptions{User: "root"},
+				},
+			},
+		},
+		{
+			name:     "YAML wildcard group shadows host of other group",
+			fileName: "input.yaml",
+			content: `
+groups:
+  - name: prod
+    pattern: prod-*
+    defaults:
+      user: ubuntu
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+hosts:
+  - host: prod-bastion
+    hostname: 1.2.3.4
+    user: root
+`,
+			wantErr: true,
+		},
+		{
+			name:     "YAML wildcard group with negated pattern",
+			fileName: "input.yaml",
+			content: `
+groups:
+  - name: prod
+    pattern: prod-* !prod-bastion
+    defaults:
+      user: ubuntu
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+hosts:
+  - host: prod-bastion
+    hostname: 1.2.3.4
+    user: root
+`,
+			wantHosts: []sshInventoryHost{
+				{
+					Host:      "prod-web-1",
+					HostName:  "10.0.0.1",
+					Group:     "prod",
+					inherited: sshHostOptions{User: "ubuntu"},
+				},
+				{
+					Host:           "prod-bastion",
+					HostName:       "1.2.3.4",
+					sshHostOptions: sshHostOptions{User: "root"},
+				},
+			},
+		},
+		{
+			name:     "YAML wildcard group does not match its host",
+			fileName: "input.yaml",
+			content: `
+groups:
+  - name: prod
+    pattern: prod-*
+    defaults:
+      user: ubuntu
+    hosts:
+      - host: web-1
+        hostname: 10.0.0.1
+`,
+			wantErr: true,
+		},
+		{
+			name:     "YAML wildcard group without user",
+			fileName: "input.yaml",
+			content: `
+groups:
+  - name: prod
+    pattern: prod-*
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+`,
+			wantErr: true,
+		},
+		{
+			name:     "YAML unknown field",
+			fileName: "input.yml",
+			content:  "hosts:\n  - host: a\n    hostname: b\n    user: c\n    unknown: d\n",
+			wantErr:  true,
+		},
+		{
+			name:     "duplicated host",
+			fileName: "input.tsv",
+			content:  "web-1\t10.0.0.1\tubuntu\nWEB-1\t10.0.0.2\tubuntu\n",
+			wantErr:  true,
+		},
+		{
+			name:     "missing user",
+			fileName: "input.csv",
+			content:  "host,hostname\nweb-1,10.0.0.1\n",
+			wantErr:  true,
+		},
+		{
+			name:     "bad port",
+			fileName: "input.csv",
+			content:  "host,hostname,user,port\nweb-1,10.0.0.1,ubuntu,ssh\n",
+			wantErr:  true,
+		},
+		{
+			name:     "pattern is not allowed as host",
+			fileName: "input.csv",
+			content:  "host,hostname,user\nweb-*,10.0.0.1,ubuntu\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := path.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readSshInventory(file, inventoryFormatAuto)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readSshInventory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// ignore fields used for error messages & output comments
+			for i := range got.hosts {
+				got.hosts[i].source = ""
+				got.hosts[i].headComments = nil
+			}
+
+			if !reflect.DeepEqual(got.hosts, tt.wantHosts) {
+				t.Errorf("readSshInventory() hosts =\n%+v\nwant\n%+v", got.hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func Test_buildSshConfigContent(t *testing.T) {
+	identityFiles := sshIdentityFiles{
+		root:     "/keys/root",
+		fallback: "/keys/user",
+		perUser: map[string]string{
+			"special": "/keys/special",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "legacy TSV output is kept",
+			content: "# group A\nweb-1\t10.0.0.1\tubuntu # main\ndb-1\t10.0.0.2\troot\nsp-1\t10.0.0.3\tspecial\n# end\n",
+			want: `# Generated by House Keeper
+
+# group A
+
+Host web-1 # main
+    HostName 10.0.0.1
+    User ubuntu
+    IdentityFile /keys/user
+
+Host db-1
+    HostName 10.0.0.2
+    User root
+    IdentityFile /keys/root
+
+Host sp-1
+    HostName 10.0.0.3
+    User special
+    IdentityFile /keys/special
+
+# end
+`,
+		},
+		{
+			name:    "optional columns",
+			content: "host\thostname\tuser\tport\tproxyjump\tidentityfile\tlocalforward\nweb-1\t10.0.0.1\tubuntu\t2222\tbastion\t/keys/my key\t8080 localhost:80;9090 localhost:90\n",
+			want: `# Generated by House Keeper
+
+Host web-1
+    HostName 10.0.0.1
+    User ubuntu
+    Port 2222
+    ProxyJump bastion
+    IdentityFile "/keys/my key"
+    LocalForward 8080 localhost:80
+    LocalForward 9090 localhost:90
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := path.Join(t.TempDir(), "input.tsv")
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			inventory, err := readSshInventory(file, inventoryFormatAuto)
+			if err != nil {
+				t.Fatalf("readSshInventory() error = %v", err)
+			}
+
+			if got := buildSshConfigContent(inventory, identityFiles); got != tt.want {
+				t.Errorf("buildSshConfigContent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildSshConfigContent_wildcardGroups(t *testing.T) {
+	identityFiles := sshIdentityFiles{
+		root:     "/keys/root",
+		fallback: "/keys/user",
+	}
+
+	content := `
+defaults:
+  port: 22
+groups:
+  - name: prod
+    pattern: prod-*
+    tags: [prod]
+    defaults:
+      user: ubuntu
+      proxyjump: bastion
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+        tags: [web]
+      - host: prod-db-1
+        hostname: 10.0.0.2
+        user: root
+  - name: staging
+    pattern: stg-*
+    tags: [staging]
+    defaults:
+      user: ubuntu
+      identityfile: /keys/staging
+    hosts:
+      - host: stg-web-1
+        hostname: 10.1.0.1
+        tags: [web]
+hosts:
+  - host: bastion
+    hostname: 1.2.3.4
+    user: root
+    tags: [prod]
+`
+
+	file := path.Join(t.TempDir(), "input.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := readSshInventory(file, inventoryFormatAuto)
+	if err != nil {
+		t.Fatalf("readSshInventory() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{
+			name: "all hosts",
+			want: `# Generated by House Keeper
+
+Host prod-web-1
+    HostName 10.0.0.1
+    IdentityFile /keys/user
+
+Host prod-db-1
+    HostName 10.0.0.2
+    User root
+    IdentityFile /keys/root
+
+Host stg-web-1
+    HostName 10.1.0.1
+
+Host bastion
+    HostName 1.2.3.4
+    User root
+    Port 22
+    IdentityFile /keys/root
+
+# Defaults of group prod
+Host prod-*
+    User ubuntu
+    Port 22
+    ProxyJump bastion
+
+# Defaults of group staging
+Host stg-*
+    User ubuntu
+    Port 22
+    IdentityFile /keys/staging
+`,
+		},
+		{
+			name: "filter by tags",
+			tags: []string{"web"},
+			want: `# Generated by House Keeper
+
+Host prod-web-1
+    HostName 10.0.0.1
+    IdentityFile /keys/user
+
+Host stg-web-1
+    HostName 10.1.0.1
+
+# Defaults of group prod
+Host prod-*
+    User ubuntu
+    Port 22
+    ProxyJump bastion
+
+# Defaults of group staging
+Host stg-*
+    User ubuntu
+    Port 22
+    IdentityFile /keys/staging
+`,
+		},
+		{
+			name: "filter by group tag",
+			tags: []string{"staging"},
+			want: `# Generated by House Keeper
+
+Host stg-web-1
+    HostName 10.1.0.1
+
+# Defaults of group staging
+Host stg-*
+    User ubuntu
+    Port 22
+    IdentityFile /keys/staging
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSshConfigContent(inventory.filterByTags(tt.tags), identityFiles); got != tt.want {
+				t.Errorf("buildSshConfigContent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sshInventory_findHandWrittenHostsShadowedByGroups(t *testing.T) {
+	content := `
+defaults:
+  user: ubuntu
+groups:
+  - name: prod
+    pattern: prod-* !prod-legacy
+    hosts:
+      - host: prod-web-1
+        hostname: 10.0.0.1
+hosts:
+  - host: bastion
+    hostname: 1.2.3.4
+`
+
+	file := path.Join(t.TempDir(), "input.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := readSshInventory(file, inventoryFormatAuto)
+	if err != nil {
+		t.Fatalf("readSshInventory() error = %v", err)
+	}
+
+	handWrittenAfter := "\nHost prod-mine prod-web-1 prod-legacy\n    User me\n\nHost prod-*\n    Port 2222\n\nMatch host prod-other\n    User me\n"
+
+	warnings, err := inventory.findHandWrittenHostsShadowedByGroups(handWrittenAfter)
+	if err != nil {
+		t.Fatalf("findHandWrittenHostsShadowedByGroups() error = %v", err)
+	}
+
+	want := []string{"hand-written host prod-mine is placed after the managed section and matches pattern 'prod-* !prod-legacy' of group prod, options of the group take precedence"}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("findHandWrittenHostsShadowedByGroups() = %q, want %q", warnings, want)
+	}
+}
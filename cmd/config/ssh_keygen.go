@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	flagSshKeyDir         = "key-dir"
+	flagAskPassphrase     = "ask-passphrase"
+	flagForceOverrideKeys = "force"
+)
+
+// roles of SSH key, mapping to flags used to generate SSH config
+const (
+	sshKeyRoleRoot    = "root"
+	sshKeyRoleDefault = "default"
+)
+
+// KeygenSshCommands registers a sub-tree of commands
+func KeygenSshCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen [role1] [role2]...",
+		Short: "Generate ed25519 SSH key for each user role",
+		Long: fmt.Sprintf(`Generate ed25519 SSH key for each user role, using ssh-keygen.
+Key files are named id_ed25519_<role>_<yyyyMMdd> within the key directory,
+private key is set to mode 0600, public key 0644 and the key directory 0700.
+Role '%s' is the key for root user (--%s), role '%s' is the fallback key for non-root users (--%s),
+other roles are the user names (--%s).`,
+			sshKeyRoleRoot, flagSshKeyPathRoot, sshKeyRoleDefault, flagSshKeyPathUser, flagSshKeyPathPerUser,
+		),
+		Args: cobra.MinimumNArgs(1),
+		Run:  generateSshKeys,
+	}
+
+	cmd.Flags().String(
+		flagSshKeyDir,
+		defaultSshKeyDir(),
+		"directory to store the generated keys",
+	)
+
+	cmd.Flags().Bool(
+		flagAskPassphrase,
+		false,
+		"prompt for passphrase of each key (by ssh-keygen), default no passphrase",
+	)
+
+	cmd.Flags().Bool(
+		flagForceOverrideKeys,
+		false,
+		"override key files if exists",
+	)
+
+	return cmd
+}
+
+func generateSshKeys(cmd *cobra.Command, roles []string) {
+	keyDir, _ := cmd.Flags().GetString(flagSshKeyDir)
+	if libutils.IsBlank(keyDir) {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagSshKeyDir))
+	}
+
+	askPassphrase, _ := cmd.Flags().GetBool(flagAskPassphrase)
+	force, _ := cmd.Flags().GetBool(flagForceOverrideKeys)
+
+	uniqueRoles := make(map[string]bool)
+	for _, role := range roles {
+		if !regexSshUserName.MatchString(role) {
+			panic(fmt.Errorf("role \"%s\" is malformed, must be a valid user name", role))
+		}
+		if uniqueRoles[role] {
+			panic(fmt.Errorf("role \"%s\" is duplicated", role))
+		}
+		uniqueRoles[role] = true
+	}
+
+	if !utils.HasBinaryName("ssh-keygen") {
+		panic(fmt.Errorf("ssh-keygen is required to generate SSH keys"))
+	}
+
+	if err := ensureSshKeyDir(keyDir); err != nil {
+		panic(err)
+	}
+
+	date := time.Now().Format("20060102")
+	keyFiles := make(map[string]string)
+	for _, role := range roles {
+		keyFile := path.Join(keyDir, fmt.Sprintf("id_ed25519_%s_%s", role, date))
+		if isFileExists(keyFile) || isFileExists(keyFile+".pub") {
+			if !force {
+				panic(fmt.Errorf("key file %s is already exists, supply --%s to override", keyFile, flagForceOverrideKeys))
+			}
+			_ = os.Remove(keyFile)
+			_ = os.Remove(keyFile + ".pub")
+		}
+
+		if err := generateSshKey(keyFile, fmt.Sprintf("hkd-%s-%s", role, date), askPassphrase); err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to generate key for role %s", role)))
+		}
+
+		publicKey, err := readSshPublicKey(keyFile)
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("Generated key for role %s: %s (%s)\n", role, keyFile, ssh.FingerprintSHA256(publicKey))
+		keyFiles[role] = keyFile
+	}
+
+	fmt.Println("Flags to use the generated keys:", buildSshKeyFlags(roles, keyFiles))
+}
+
+// generateSshKey generates ed25519 key pair using ssh-keygen then fixes file permissions
+func generateSshKey(keyFile, comment string, askPassphrase bool) error {
+	args := []string{"-q", "-t", "ed25519", "-C", comment, "-f", keyFile}
+	if !askPassphrase {
+		args = append(args, "-N", "")
+	}
+
+	if ec := utils.LaunchAppWithDirectStd("ssh-keygen", args, nil); ec != 0 {
+		return fmt.Errorf("ssh-keygen exited with code %d", ec)
+	}
+
+	if err := os.Chmod(keyFile, 0o600); err != nil {
+		return errors.Wrap(err, "failed to set permission of private key")
+	}
+
+	if err := os.Chmod(keyFile+".pub", 0o644); err != nil {
+		return errors.Wrap(err, "failed to set permission of public key")
+	}
+
+	return nil
+}
+
+// ensureSshKeyDir creates the directory if not exists, and restricts its permission to 0700
+func ensureSshKeyDir(keyDir string) error {
+	fi, err := os.Stat(keyDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Wrap(err, fmt.Sprintf("failed to check key directory %s", keyDir))
+		}
+		if err := os.MkdirAll(keyDir, 0o700); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to create key directory %s", keyDir))
+		}
+		return nil
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("key directory %s is not a directory", keyDir)
+	}
+
+	if fi.Mode().Perm()&0o077 != 0 {
+		fmt.Printf("Restricting permission of key directory %s from %s to 0700\n", keyDir, fi.Mode().Perm())
+		if err := os.Chmod(keyDir, 0o700); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to set permission of key directory %s", keyDir))
+		}
+	}
+
+	return nil
+}
+
+// defaultSshKeyDir returns ~/.ssh, or empty if home directory could not be detected
+func defaultSshKeyDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(homeDir, ".ssh")
+}
+
+// readSshPublicKey reads public key of the private key file, from the .pub file if exists
+func readSshPublicKey(privateKeyFile string) (ssh.PublicKey, error) {
+	if bz, err := os.ReadFile(privateKeyFile + ".pub"); err == nil {
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey(bz)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to parse public key %s.pub", privateKeyFile))
+		}
+		return publicKey, nil
+	}
+
+	bz, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read private key %s", privateKeyFile))
+	}
+
+	signer, err := ssh.ParsePrivateKey(bz)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse private key %s, provide the public key file %s.pub", privateKeyFile, privateKeyFile))
+	}
+
+	return signer.PublicKey(), nil
+}
+
+// buildSshKeyFlags builds flags to use the keys of roles when generating SSH config
+func buildSshKeyFlags(roles []string, keyFiles map[string]string) string {
+	var flags, perUser []string
+	for _, role := range roles {
+		switch role {
+		case sshKeyRoleRoot:
+			flags = append(flags, fmt.Sprintf("--%s %s", flagSshKeyPathRoot, keyFiles[role]))
+		case sshKeyRoleDefault:
+			flags = append(flags, fmt.Sprintf("--%s %s", flagSshKeyPathUser, keyFiles[role]))
+		default:
+			perUser = append(perUser, role, keyFiles[role])
+		}
+	}
+	if len(perUser) > 0 {
+		flags = append(flags, fmt.Sprintf("--%s %s", flagSshKeyPathPerUser, strings.Join(perUser, ",")))
+	}
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	sshManagedSectionBeginMarker = "# BEGIN hkd managed hosts"
+	sshManagedSectionEndMarker   = "# END hkd managed hosts"
+)
+
+// mergeManagedSshConfig replaces the managed section (between marker comments) of the existing SSH config content
+// by the generated content, content outside the managed section is kept untouched.
+// If the managed section does not exist, it is inserted before the first hand-written block which matches any managed host,
+// because ssh uses the first obtained value of each option, otherwise it is appended to the end.
+// Returns the merged content and the content before and after the managed section.
+func mergeManagedSshConfig(existing, generated string, managedHosts []string) (merged, handWrittenBefore, handWrittenAfter string, err error) {
+	lines := strings.Split(existing, "\n")
+
+	beginIdx, endIdx := -1, -1
+	for idx, line := range lines {
+		switch strings.TrimSpace(line) {
+		case sshManagedSectionBeginMarker:
+			if beginIdx >= 0 {
+				return "", "", "", fmt.Errorf("duplicated marker '%s' at line %d", sshManagedSectionBeginMarker, idx+1)
+			}
+			beginIdx = idx
+		case sshManagedSectionEndMarker:
+			if endIdx >= 0 {
+				return "", "", "", fmt.Errorf("duplicated marker '%s' at line %d", sshManagedSectionEndMarker, idx+1)
+			}
+			endIdx = idx
+		}
+	}
+
+	managedSection := sshManagedSectionBeginMarker + "\n" + strings.TrimSuffix(generated, "\n") + "\n" + sshManagedSectionEndMarker
+
+	if beginIdx < 0 && endIdx < 0 {
+		insertIdx, err := findManagedSectionInsertIndex(existing, managedHosts)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if insertIdx < 0 {
+			trimmed := strings.TrimRight(existing, "\n")
+			if len(trimmed) < 1 {
+				return managedSection + "\n", existing, "", nil
+			}
+			return trimmed + "\n\n" + managedSection + "\n", existing, "", nil
+		}
+
+		handWrittenBefore = strings.Join(lines[:insertIdx], "\n")
+		handWrittenAfter = strings.Join(lines[insertIdx:], "\n")
+		trimmed := strings.TrimRight(handWrittenBefore, "\n")
+		if len(trimmed) < 1 {
+			return managedSection + "\n\n" + handWrittenAfter, handWrittenBefore, handWrittenAfter, nil
+		}
+		return trimmed + "\n\n" + managedSection + "\n\n" + handWrittenAfter, handWrittenBefore, handWrittenAfter, nil
+	}
+
+	if beginIdx < 0 || endIdx < 0 {
+		return "", "", "", fmt.Errorf("found only one of the markers '%s' and '%s'", sshManagedSectionBeginMarker, sshManagedSectionEndMarker)
+	}
+
+	if endIdx < beginIdx {
+		return "", "", "", fmt.Errorf("marker '%s' must be placed after '%s'", sshManagedSectionEndMarker, sshManagedSectionBeginMarker)
+	}
+
+	before := lines[:beginIdx]
+	after := lines[endIdx+1:]
+
+	mergedLines := append(append(append([]string{}, before...), managedSection), after...)
+
+	return strings.Join(mergedLines, "\n"), strings.Join(before, "\n"), strings.Join(after, "\n"), nil
+}
+
+// findManagedSectionInsertIndex returns index of the line to insert the managed section at,
+// that is the declaration (and the comments right above) of the first Host/Match block which matches any managed host.
+// Returns -1 if no block matches.
+func findManagedSectionInsertIndex(existing string, managedHosts []string) (int, error) {
+	blocks, err := parseSshConfig(existing)
+	if err != nil {
+		return -1, err
+	}
+
+	lines := strings.Split(existing, "\n")
+	for _, block := range blocks {
+		if len(block.keyword) < 1 || !block.matchesAnyHost(managedHosts) {
+			continue
+		}
+
+		idx := block.line - 1
+		for idx > 0 && strings.HasPrefix(strings.TrimSpace(lines[idx-1]), "#") {
+			idx--
+		}
+		return idx, nil
+	}
+
+	return -1, nil
+}
+
+// findShadowingHandWrittenBlocks returns warnings for hand-written Host/Match blocks placed before the managed section,
+// those match managed hosts by pattern so their options take precedence over the managed ones.
+// Exact host names are reported by findDuplicatedHandWrittenHosts.
+func findShadowingHandWrittenBlocks(handWrittenBefore string, managedHosts []string) ([]string, error) {
+	blocks, err := parseSshConfig(handWrittenBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, block := range blocks {
+		if len(block.keyword) < 1 {
+			continue
+		}
+
+		var shadowedHosts []string
+		for _, host := range managedHosts {
+			if block.isHost() && containsFold(block.patterns, host) {
+				continue
+			}
+			if block.matchesAnyHost([]string{host}) {
+				shadowedHosts = append(shadowedHosts, host)
+			}
+		}
+
+		if len(shadowedHosts) > 0 {
+			warnings = append(warnings, fmt.Sprintf("hand-written entry '%s %s' at line %d is placed before the managed section, its options take precedence over the managed hosts: %s", block.keyword, strings.Join(block.patterns, " "), block.line, strings.Join(shadowedHosts, ", ")))
+		}
+	}
+
+	return warnings, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDuplicatedHandWrittenHosts returns warnings for hosts which are defined by both hand-written content and managed section
+func findDuplicatedHandWrittenHosts(handWritten string, managedHosts []string) ([]string, error) {
+	blocks, err := parseSshConfig(handWritten)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make(map[string]bool)
+	for _, host := range managedHosts {
+		managed[strings.ToLower(host)] = true
+	}
+
+	var warnings []string
+	for _, block := range blocks {
+		if !block.isHost() {
+			continue
+		}
+
+		for _, pattern := range block.patterns {
+			if managed[strings.ToLower(pattern)] {
+				warnings = append(warnings, fmt.Sprintf("host %s is managed but also defined by hand-written entry '%s %s', ssh uses the first obtained value of each option", pattern, block.keyword, strings.Join(block.patterns, " ")))
+			}
+		}
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_mergeManagedSshConfig(t *testing.T) {
+	const generated = "# Generated by House Keeper\n\nHost a\n    HostName 1.1.1.1\n"
+	const managedSection = "# BEGIN hkd managed hosts\n# Generated by House Keeper\n\nHost a\n    HostName 1.1.1.1\n# END hkd managed hosts"
+
+	tests := []struct {
+		name       string
+		existing   string
+		wantMerged string
+		wantBefore string
+		wantAfter  string
+		wantErr    bool
+	}{
+		{
+			name:       "empty file",
+			existing:   "",
+			wantMerged: managedSection + "\n",
+			wantBefore: "",
+		},
+		{
+			name:       "append to hand-written content",
+			existing:   "Host mine\n    User me\n",
+			wantMerged: "Host mine\n    User me\n\n" + managedSection + "\n",
+			wantBefore: "Host mine\n    User me\n",
+		},
+		{
+			name:       "insert before hand-written wildcard block with its comments",
+			existing:   "Host mine\n    User me\n\n# defaults\nHost *\n    User root\n    IdentityFile ~/.ssh/id_root\n",
+			wantMerged: "Host mine\n    User me\n\n" + managedSection + "\n\n# defaults\nHost *\n    User root\n    IdentityFile ~/.ssh/id_root\n",
+			wantBefore: "Host mine\n    User me\n",
+			wantAfter:  "# defaults\nHost *\n    User root\n    IdentityFile ~/.ssh/id_root\n",
+		},
+		{
+			name:       "insert before hand-written Match all block",
+			existing:   "Match all\n    Port 2222\n",
+			wantMerged: managedSection + "\n\nMatch all\n    Port 2222\n",
+			wantBefore: "",
+			wantAfter:  "Match all\n    Port 2222\n",
+		},
+		{
+			name:       "append after hand-written Match block of other hosts",
+			existing:   "Match host b,c\n    Port 2222\n",
+			wantMerged: "Match host b,c\n    Port 2222\n\n" + managedSection + "\n",
+			wantBefore: "Match host b,c\n    Port 2222\n",
+		},
+		{
+			name:       "replace existing managed section, keep content around",
+			existing:   "Host mine\n    User me\n\n# BEGIN hkd managed hosts\nHost old\n# END hkd managed hosts\n\nHost *\n    ServerAliveInterval 60\n",
+			wantMerged: "Host mine\n    User me\n\n" + managedSection + "\n\nHost *\n    ServerAliveInterval 60\n",
+			wantBefore: "Host mine\n    User me\n",
+			wantAfter:  "\nHost *\n    ServerAliveInterval 60\n",
+		},
+		{
+			name:     "missing end marker",
+			existing: "# BEGIN hkd managed hosts\nHost old\n",
+			wantErr:  true,
+		},
+		{
+			name:     "markers in wrong order",
+			existing: "# END hkd managed hosts\n# BEGIN hkd managed hosts\n",
+			wantErr:  true,
+		},
+		{
+			name:     "duplicated markers",
+			existing: "# BEGIN hkd managed hosts\n# END hkd managed hosts\n# BEGIN hkd managed hosts\n# END hkd managed hosts\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMerged, gotBefore, gotAfter, err := mergeManagedSshConfig(tt.existing, generated, []string{"a"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("mergeManagedSshConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotMerged != tt.wantMerged {
+				t.Errorf("mergeManagedSshConfig() merged =\n%q\nwant\n%q", gotMerged, tt.wantMerged)
+			}
+			if gotBefore != tt.wantBefore {
+				t.Errorf("mergeManagedSshConfig() before =\n%q\nwant\n%q", gotBefore, tt.wantBefore)
+			}
+			if gotAfter != tt.wantAfter {
+				t.Errorf("mergeManagedSshConfig() after =\n%q\nwant\n%q", gotAfter, tt.wantAfter)
+			}
+
+			// merging again must not change anything
+			mergedAgain, _, _, err := mergeManagedSshConfig(gotMerged, generated, []string{"a"})
+			if err != nil || mergedAgain != gotMerged {
+				t.Errorf("merging again changed the content:\n%q", mergedAgain)
+			}
+		})
+	}
+}
+
+func Test_findShadowingHandWrittenBlocks(t *testing.T) {
+	handWrittenBefore := "Host web-1\n    User me\n\nHost web-*\n    User root\n\nHost db-*\n    User postgres\n\nMatch host web-2,web-3 exec \"true\"\n    Port 2222\n"
+
+	warnings, err := findShadowingHandWrittenBlocks(handWrittenBefore, []string{"web-1", "web-2"})
+	if err != nil {
+		t.Fatalf("findShadowingHandWrittenBlocks() error = %v", err)
+	}
+
+	want := []string{
+		"hand-written entry 'Host web-*' at line 4 is placed before the managed section, its options take precedence over the managed hosts: web-1, web-2",
+		"hand-written entry 'Match host web-2,web-3 exec true' at line 10 is placed before the managed section, its options take precedence over the managed hosts: web-2",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("findShadowingHandWrittenBlocks() =\n%q\nwant\n%q", warnings, want)
+	}
+}
+
+func Test_findDuplicatedHandWrittenHosts(t *testing.T) {
+	handWritten := "Host mine web-1\n    User me\n\nHost *\n    ServerAliveInterval 60\n"
+
+	warnings, err := findDuplicatedHandWrittenHosts(handWritten, []string{"WEB-1", "web-2"})
+	if err != nil {
+		t.Fatalf("findDuplicatedHandWrittenHosts() error = %v", err)
+	}
+
+	want := []string{"host web-1 is managed but also defined by hand-written entry 'Host mine web-1', ssh uses the first obtained value of each option"}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("findDuplicatedHandWrittenHosts() = %v, want %v", warnings, want)
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitSshHostPatterns splits whitespace separated host patterns, like argument of Host keyword
+func splitSshHostPatterns(patterns string) []string {
+	return strings.Fields(patterns)
+}
+
+// validateSshHostPatterns checks the patterns can be used as argument of Host keyword
+func validateSshHostPatterns(patterns []string) error {
+	if len(patterns) < 1 {
+		return fmt.Errorf("host pattern must not be empty")
+	}
+
+	var anyPositive bool
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			pattern = pattern[1:]
+		} else {
+			anyPositive = true
+		}
+
+		if len(pattern) < 1 {
+			return fmt.Errorf("host pattern must not be empty")
+		}
+		if strings.ContainsAny(pattern, "!,\"") {
+			return fmt.Errorf("host pattern %s contains not supported character", pattern)
+		}
+	}
+
+	if !anyPositive {
+		return fmt.Errorf("host patterns '%s' contain only negated patterns so never match any host", strings.Join(patterns, " "))
+	}
+
+	return nil
+}
+
+// matchSshHostPatterns reports whether the host matches the pattern list, following ssh semantic:
+// a negated pattern matches prevents the whole list from matching, otherwise any pattern matches is a match.
+func matchSshHostPatterns(patterns []string, host string) bool {
+	var matched bool
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if matchSshHostPattern(pattern[1:], host) {
+				return false
+			}
+			continue
+		}
+
+		if matchSshHostPattern(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchSshHostPattern reports whether the host matches the glob pattern, case-insensitive.
+// '*' matches zero or more characters and '?' matches exactly one character.
+func matchSshHostPattern(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+
+	// position to resume when a later mismatch happens after a '*'
+	starIdx, matchIdx := -1, 0
+	p, h := 0, 0
+	for h < len(host) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == host[h]) {
+			p++
+			h++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			starIdx = p
+			matchIdx = h
+			p++
+		} else if starIdx >= 0 {
+			p = starIdx + 1
+			matchIdx++
+			h = matchIdx
+		} else {
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
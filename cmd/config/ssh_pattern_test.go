@@ -0,0 +1,53 @@
+package config
+
+import "testing"
+
+func Test_matchSshHostPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{pattern: "prod-*", host: "prod-web-1", want: true},
+		{pattern: "prod-*", host: "prod-", want: true},
+		{pattern: "prod-*", host: "staging-web-1", want: false},
+		{pattern: "PROD-*", host: "prod-web-1", want: true},
+		{pattern: "*-web-*", host: "prod-web-1", want: true},
+		{pattern: "*-web-*", host: "prod-db-1", want: false},
+		{pattern: "web-?", host: "web-1", want: true},
+		{pattern: "web-?", host: "web-10", want: false},
+		{pattern: "*", host: "anything", want: true},
+		{pattern: "web-1", host: "web-1", want: true},
+		{pattern: "web-1", host: "web-10", want: false},
+		{pattern: "a*b*c", host: "aXbYbZc", want: true},
+		{pattern: "a*b*c", host: "aXbYbZ", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.host, func(t *testing.T) {
+			if got := matchSshHostPattern(tt.pattern, tt.host); got != tt.want {
+				t.Errorf("matchSshHostPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchSshHostPatterns(t *testing.T) {
+	tests := []struct {
+		patterns string
+		host     string
+		want     bool
+	}{
+		{patterns: "prod-* staging-*", host: "staging-web-1", want: true},
+		{patterns: "prod-* !prod-bastion", host: "prod-web-1", want: true},
+		{patterns: "prod-* !prod-bastion", host: "prod-bastion", want: false},
+		{patterns: "!prod-bastion prod-*", host: "prod-bastion", want: false},
+		{patterns: "!prod-bastion", host: "prod-web-1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.patterns+" "+tt.host, func(t *testing.T) {
+			if got := matchSshHostPatterns(splitSshHostPatterns(tt.patterns), tt.host); got != tt.want {
+				t.Errorf("matchSshHostPatterns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	flagNewSshKeyPathRoot    = "new-key-root"
+	flagNewSshKeyPathUser    = "new-key-user"
+	flagNewSshKeyPathPerUser = "new-key-per-user"
+)
+
+// RotateSshCommands registers a sub-tree of commands
+func RotateSshCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate SSH keys of hosts in the inventory",
+		Long: fmt.Sprintf(`Rotate SSH keys of hosts in the inventory.
+Current keys are provided via --%s/--%s/--%s, new keys via --%s/--%s/--%s (keys not provided are not rotated).
+1. Deploy the new public key into ~/.ssh/authorized_keys of every host, using the current key.
+2. Verify login with the new key. If any host failed, stop here and nothing is removed.
+3. Remove the old public key from ~/.ssh/authorized_keys of every host.
+4. Regenerate the output SSH config file to use the new keys (respecting --%s, --%s and --%s).
+Host keys must be known (see 'check --%s'), hosts defining their own IdentityFile in the inventory are skipped.`,
+			flagSshKeyPathRoot, flagSshKeyPathUser, flagSshKeyPathPerUser,
+			flagNewSshKeyPathRoot, flagNewSshKeyPathUser, flagNewSshKeyPathPerUser,
+			flagOverrideSshConfigOutputFile, flagMergeSshConfigOutputFile, flagDiffSshConfigOutputFile,
+			flagAddKnownHosts,
+		),
+		Args: cobra.NoArgs,
+		Run:  rotateSshKeys,
+	}
+
+	cmd.Flags().String(
+		flagNewSshKeyPathRoot,
+		"",
+		"new SSH key file path to be used for root users",
+	)
+
+	cmd.Flags().String(
+		flagNewSshKeyPathUser,
+		"",
+		"new SSH key file path to be used for non-root users (fallback)",
+	)
+
+	cmd.Flags().StringSlice(
+		flagNewSshKeyPathPerUser,
+		[]string{},
+		"new SSH key file path to be used for each non-root user (format: user1,path1,user2,path2)",
+	)
+
+	cmd.Flags().Duration(
+		flagSshCheckTimeout,
+		10*time.Second,
+		"timeout of connecting and authenticating to each host",
+	)
+
+	cmd.Flags().String(
+		flagKnownHostsFilePath,
+		defaultKnownHostsFilePath(),
+		"known_hosts file to verify host keys",
+	)
+
+	return cmd
+}
+
+func rotateSshKeys(cmd *cobra.Command, _ []string) {
+	timeout, _ := cmd.Flags().GetDuration(flagSshCheckTimeout)
+	if timeout <= 0 {
+		panic(fmt.Errorf("bad value for flag --%s", flagSshCheckTimeout))
+	}
+
+	knownHostsFilePath, _ := cmd.Flags().GetString(flagKnownHostsFilePath)
+	if libutils.IsBlank(knownHostsFilePath) || !isFileExists(knownHostsFilePath) {
+		panic(fmt.Errorf("known_hosts file is required to verify host keys, provide via --%s", flagKnownHostsFilePath))
+	}
+	knownHostsCallback, err := knownhosts.New(knownHostsFilePath)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to read known_hosts file %s", knownHostsFilePath)))
+	}
+
+	inventory := readSshInventoryFromFlags()
+
+	validateSshConfigOutputFlags()
+
+	oldIdentityFiles := readSshIdentityFilesFromFlags()
+	newIdentityFiles := readNewSshIdentityFilesFromFlags(cmd, oldIdentityFiles)
+
+	rotations, err := planSshKeyRotations(inventory, oldIdentityFiles, newIdentityFiles)
+	if err != nil {
+		panic(err)
+	}
+
+	sshAgent, closeAgent := connectSshAgent()
+	defer closeAgent()
+
+	if err := checkNewKeysUsable(rotations, sshAgent); err != nil {
+		panic(err)
+	}
+
+	rotator := &sshKeyRotator{
+		oldKeyChecker: &sshChecker{
+			inventory:     inventory,
+			identityFiles: oldIdentityFiles,
+			knownHosts:    knownHostsCallback,
+			strictHostKey: true,
+			agent:         sshAgent,
+			timeout:       timeout,
+		},
+		// only the new keys are used, to make sure login with the new keys works.
+		// ssh-agent is restricted to the new keys, for the encrypted ones.
+		newKeyChecker: &sshChecker{
+			inventory:     inventory,
+			identityFiles: newIdentityFiles,
+			knownHosts:    knownHostsCallback,
+			strictHostKey: true,
+			agent:         newRestrictedSshAgent(sshAgent, newKeysOf(rotations)),
+			timeout:       timeout,
+		},
+	}
+
+	if !rotator.deployAndVerify(rotations) {
+		printSshKeyRotations(rotations)
+		fmt.Println("Rotation stopped, old keys were not removed and SSH config file was not updated")
+		os.Exit(1)
+	}
+
+	removedAll := rotator.removeOldKeys(rotations)
+
+	writeSshConfigFile(buildSshConfigContent(inventory, newIdentityFiles), inventory)
+
+	printSshKeyRotations(rotations)
+
+	if !removedAll {
+		fmt.Println("Failed to remove old keys from some hosts, please remove them manually")
+		os.Exit(1)
+	}
+}
+
+// readNewSshIdentityFilesFromFlags reads new SSH key file paths provided via flags,
+// keys which are not provided are kept as the current keys.
+func readNewSshIdentityFilesFromFlags(cmd *cobra.Command, current sshIdentityFiles) sshIdentityFiles {
+	newKeyPathRoot, _ := cmd.Flags().GetString(flagNewSshKeyPathRoot)
+	newKeyPathUser, _ := cmd.Flags().GetString(flagNewSshKeyPathUser)
+	newKeyPathPerUser, _ := cmd.Flags().GetStringSlice(flagNewSshKeyPathPerUser)
+
+	if libutils.IsBlank(newKeyPathRoot) && libutils.IsBlank(newKeyPathUser) && len(newKeyPathPerUser) < 1 {
+		panic(fmt.Errorf("at least one of flags --%s, --%s, --%s is required", flagNewSshKeyPathRoot, flagNewSshKeyPathUser, flagNewSshKeyPathPerUser))
+	}
+
+	identityFiles := sshIdentityFiles{
+		root:     current.root,
+		fallback: current.fallback,
+		perUser:  make(map[string]string),
+	}
+	for user, keyPath := range current.perUser {
+		identityFiles.perUser[user] = keyPath
+	}
+
+	if !libutils.IsBlank(newKeyPathRoot) {
+		if !isFileExists(newKeyPathRoot) {
+			panic(fmt.Errorf("new SSH key (for root user) could not be found: %s", newKeyPathRoot))
+		}
+		identityFiles.root = newKeyPathRoot
+	}
+
+	if !libutils.IsBlank(newKeyPathUser) {
+		if !isFileExists(newKeyPathUser) {
+			panic(fmt.Errorf("new SSH key (fallback for non-root user) could not be found: %s", newKeyPathUser))
+		}
+		identityFiles.fallback = newKeyPathUser
+	}
+
+	for user, keyPath := range readSshKeyPathPerUser(newKeyPathPerUser, flagNewSshKeyPathPerUser) {
+		identityFiles.perUser[user] = keyPath
+	}
+
+	return identityFiles
+}
+
+// sshKeyRotation is the rotation of SSH key on a host
+type sshKeyRotation struct {
+	host       sshInventoryHost
+	oldKeyFile string
+	newKeyFile string
+	oldKey     ssh.PublicKey
+	newKey     ssh.PublicKey
+
+	// skipReason is the reason this host is not rotated
+	skipReason string
+	// progress is the last step completed
+	progress string
+	err      error
+}
+
+// steps of the rotation
+const (
+	sshKeyRotationDeployed = "deployed"
+	sshKeyRotationVerified = "verified"
+	sshKeyRotationRotated  = "rotated"
+)
+
+// planSshKeyRotations resolves the old and new keys of each host.
+// Hosts connected directly come first so jump hosts are rotated before hosts behind them.
+func planSshKeyRotations(inventory *sshInventory, oldIdentityFiles, newIdentityFiles sshIdentityFiles) ([]*sshKeyRotation, error) {
+	publicKeys := make(map[string]ssh.PublicKey)
+	readPublicKey := func(keyFile string) (ssh.PublicKey, error) {
+		if publicKey, found := publicKeys[keyFile]; found {
+			return publicKey, nil
+		}
+		publicKey, err := readSshPublicKey(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys[keyFile] = publicKey
+		return publicKey, nil
+	}
+
+	var rotations []*sshKeyRotation
+	for _, host := range inventory.hosts {
+		options := host.effectiveOptions()
+		rotation := &sshKeyRotation{
+			host:       host,
+			oldKeyFile: oldIdentityFiles.identityFileFor(options.User),
+			newKeyFile: newIdentityFiles.identityFileFor(options.User),
+		}
+		rotations = append(rotations, rotation)
+
+		if len(options.IdentityFile) > 0 {
+			rotation.skipReason = "IdentityFile is defined in the inventory"
+			continue
+		}
+
+		if rotation.oldKeyFile == rotation.newKeyFile {
+			rotation.skipReason = fmt.Sprintf("no new key provided for user %s", options.User)
+			continue
+		}
+
+		var err error
+		if rotation.oldKey, err = readPublicKey(rotation.oldKeyFile); err != nil {
+			return nil, err
+		}
+		if rotation.newKey, err = readPublicKey(rotation.newKeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(rotations, func(i, j int) bool {
+		return !rotations[i].usesProxyJump() && rotations[j].usesProxyJump()
+	})
+
+	return rotations, nil
+}
+
+func (r *sshKeyRotation) usesProxyJump() bool {
+	proxyJump := r.host.effectiveOptions().ProxyJump
+	return len(proxyJump) > 0 && !strings.EqualFold(proxyJump, "none")
+}
+
+// newKeysOf returns the new keys of the rotations those are not skipped
+func newKeysOf(rotations []*sshKeyRotation) []ssh.PublicKey {
+	var keys []ssh.PublicKey
+	for _, rotation := range rotations {
+		if len(rotation.skipReason) < 1 && rotation.newKey != nil {
+			keys = append(keys, rotation.newKey)
+		}
+	}
+	return keys
+}
+
+// checkNewKeysUsable ensures the new keys can be used to login: not encrypted, or added into ssh-agent
+func checkNewKeysUsable(rotations []*sshKeyRotation, sshAgent agent.Agent) error {
+	checked := make(map[string]bool)
+	for _, rotation := range rotations {
+		if len(rotation.skipReason) > 0 || checked[rotation.newKeyFile] {
+			continue
+		}
+		checked[rotation.newKeyFile] = true
+
+		newKeyFile := expandHomeDir(rotation.newKeyFile)
+		bz, err := os.ReadFile(newKeyFile)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to read new key %s", newKeyFile))
+		}
+
+		_, err = ssh.ParsePrivateKey(bz)
+		if err == nil {
+			continue
+		}
+
+		var passphraseMissingErr *ssh.PassphraseMissingError
+		if !errors.As(err, &passphraseMissingErr) {
+			return errors.Wrap(err, fmt.Sprintf("failed to parse new key %s", newKeyFile))
+		}
+
+		if sshAgent == nil {
+			return fmt.Errorf("new key %s is encrypted, ssh-agent is required to login with it, start ssh-agent then run: ssh-add %s", newKeyFile, newKeyFile)
+		}
+
+		agentKeys, err := sshAgent.List()
+		if err != nil {
+			return errors.Wrap(err, "failed to list keys of ssh-agent")
+		}
+
+		var added bool
+		for _, agentKey := range agentKeys {
+			if bytes.Equal(agentKey.Blob, rotation.newKey.Marshal()) {
+				added = true
+				break
+			}
+		}
+		if !added {
+			return fmt.Errorf("new key %s is encrypted and was not added into ssh-agent, run: ssh-add %s", newKeyFile, newKeyFile)
+		}
+	}
+
+	return nil
+}
+
+// restrictedSshAgent exposes only the allowed keys of ssh-agent
+type restrictedSshAgent struct {
+	agent.Agent
+	// allowedKeys are the allowed public keys, in wire format
+	allowedKeys map[string]bool
+}
+
+// newRestrictedSshAgent returns ssh-agent which exposes only the provided keys, nil if ssh-agent is not available
+func newRestrictedSshAgent(sshAgent agent.Agent, keys []ssh.PublicKey) agent.Agent {
+	if sshAgent == nil {
+		return nil
+	}
+
+	allowedKeys := make(map[string]bool)
+	for _, key := range keys {
+		allowedKeys[string(key.Marshal())] = true
+	}
+
+	return &restrictedSshAgent{
+		Agent:       sshAgent,
+		allowedKeys: allowedKeys,
+	}
+}
+
+func (a *restrictedSshAgent) List() ([]*agent.Key, error) {
+	keys, err := a.Agent.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []*agent.Key
+	for _, key := range keys {
+		if a.allowedKeys[string(key.Blob)] {
+			allowed = append(allowed, key)
+		}
+	}
+	return allowed, nil
+}
+
+func (a *restrictedSshAgent) Signers() ([]ssh.Signer, error) {
+	signers, err := a.Agent.Signers()
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed []ssh.Signer
+	for _, signer := range signers {
+		if a.allowedKeys[string(signer.PublicKey().Marshal())] {
+			allowed = append(allowed, signer)
+		}
+	}
+	return allowed, nil
+}
+
+// sshKeyRotator deploys new keys and removes old keys on hosts
+type sshKeyRotator struct {
+	oldKeyChecker *sshChecker
+	newKeyChecker *sshChecker
+}
+
+// deployAndVerify deploys the new key into each host then verifies login with the new key.
+// Returns false if any host failed.
+func (r *sshKeyRotator) deployAndVerify(rotations []*sshKeyRotation) bool {
+	success := true
+	for _, rotation := range rotations {
+		if len(rotation.skipReason) > 0 {
+			continue
+		}
+
+		if err := r.deploy(rotation); err != nil {
+			rotation.err = errors.Wrap(err, "failed to deploy new key")
+			fmt.Printf("[%s] %v\n", rotation.host.Host, rotation.err)
+			success = false
+			continue
+		}
+		rotation.progress = sshKeyRotationDeployed
+		fmt.Printf("[%s] deployed new key %s\n", rotation.host.Host, rotation.newKeyFile)
+
+		client, closeClient, result := r.newKeyChecker.open(rotation.host)
+		if client == nil {
+			rotation.err = errors.Wrap(result.err, "failed to login with new key")
+			fmt.Printf("[%s] %v\n", rotation.host.Host, rotation.err)
+			success = false
+			continue
+		}
+		closeClient()
+		rotation.progress = sshKeyRotationVerified
+		fmt.Printf("[%s] verified login with new key\n", rotation.host.Host)
+	}
+	return success
+}
+
+// deploy adds the new key into authorized_keys, using the old key.
+// When login with the old key failed, the new key is used in case it was deployed before.
+func (r *sshKeyRotator) deploy(rotation *sshKeyRotation) error {
+	client, closeClient, result := r.oldKeyChecker.open(rotation.host)
+	if client == nil {
+		if result.status != sshCheckStatusAuthFailed {
+			return result.err
+		}
+
+		// the new key might be deployed before, eg: multiple hosts sharing the same account
+		client, closeClient, _ = r.newKeyChecker.open(rotation.host)
+		if client == nil {
+			return errors.Wrap(result.err, "failed to login with both old and new keys")
+		}
+	}
+	defer closeClient()
+
+	comment := fmt.Sprintf("hkd-%s", filepath.Base(rotation.newKeyFile))
+	output, err := runSshCommand(client, buildDeployAuthorizedKeyCommand(rotation.newKey, comment))
+	if err != nil {
+		return errors.Wrap(err, output)
+	}
+
+	return nil
+}
+
+// removeOldKeys removes the old key from authorized_keys of each host, using the new key.
+// Returns false if any host failed.
+func (r *sshKeyRotator) removeOldKeys(rotations []*sshKeyRotation) bool {
+	success := true
+	for _, rotation := range rotations {
+		if len(rotation.skipReason) > 0 {
+			continue
+		}
+
+		if authorizedKeyBody(rotation.oldKey) == authorizedKeyBody(rotation.newKey) {
+			rotation.progress = sshKeyRotationRotated
+			continue
+		}
+
+		client, closeClient, result := r.newKeyChecker.open(rotation.host)
+		if client == nil {
+			rotation.err = errors.Wrap(result.err, "failed to remove old key")
+			fmt.Printf("[%s] %v\n", rotation.host.Host, rotation.err)
+			success = false
+			continue
+		}
+
+		output, err := runSshCommand(client, buildRemoveAuthorizedKeyCommand(rotation.oldKey))
+		closeClient()
+		if err != nil {
+			rotation.err = errors.Wrap(errors.Wrap(err, output), "failed to remove old key")
+			fmt.Printf("[%s] %v\n", rotation.host.Host, rotation.err)
+			success = false
+			continue
+		}
+
+		rotation.progress = sshKeyRotationRotated
+		fmt.Printf("[%s] removed old key %s\n", rotation.host.Host, rotation.oldKeyFile)
+	}
+	return success
+}
+
+// authorizedKeyBody returns the key in authorized_keys format, without comment
+func authorizedKeyBody(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// buildDeployAuthorizedKeyCommand builds shell command to add the key into authorized_keys if not exists.
+// A new line is appended first if the file does not end with one, so the key is not glued onto the last key.
+func buildDeployAuthorizedKeyCommand(key ssh.PublicKey, comment string) string {
+	keyBody := authorizedKeyBody(key)
+	return fmt.Sprintf(
+		`umask 077 && mkdir -p ~/.ssh && f=~/.ssh/authorized_keys && touch "$f" && (grep -qF %s "$f" || { if [ -s "$f" ] && [ "$(tail -c1 "$f")" != "" ]; then echo >> "$f"; fi; echo %s >> "$f"; })`,
+		utils.ShellQuote(keyBody), utils.ShellQuote(keyBody+" "+comment),
+	)
+}
+
+// buildRemoveAuthorizedKeyCommand builds shell command to remove the key from authorized_keys.
+// The file is rewritten in place to keep its permission, and kept untouched if grep failed.
+func buildRemoveAuthorizedKeyCommand(key ssh.PublicKey) string {
+	return fmt.Sprintf(
+		`f=~/.ssh/authorized_keys; [ -f "$f" ] || exit 0; grep -vF %s "$f" > "$f.hkd-tmp"; if [ $? -le 1 ]; then cat "$f.hkd-tmp" > "$f"; rc=$?; else rc=1; fi; rm -f "$f.hkd-tmp"; exit $rc`,
+		utils.ShellQuote(authorizedKeyBody(key)),
+	)
+}
+
+func printSshKeyRotations(rotations []*sshKeyRotation) {
+	fmt.Println()
+	for _, rotation := range rotations {
+		switch {
+		case len(rotation.skipReason) > 0:
+			fmt.Printf("%s: skipped, %s\n", rotation.host.Host, rotation.skipReason)
+		case rotation.err != nil:
+			fmt.Printf("%s: failed after step '%s', %v\n", rotation.host.Host, emptyAsDash(rotation.progress), rotation.err)
+		default:
+			fmt.Printf("%s: %s, %s => %s\n", rotation.host.Host, rotation.progress, rotation.oldKeyFile, rotation.newKeyFile)
+		}
+	}
+}
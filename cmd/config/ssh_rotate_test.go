@@ -0,0 +1,283 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_sshKeyRotator(t *testing.T) {
+	dir := t.TempDir()
+	oldKeyFile, oldKey := writeTestSshKey(t, dir, "id_old")
+	newKeyFile, newKey := writeTestSshKey(t, dir, "id_new")
+	otherKeyFile, otherKey := writeTestSshKey(t, dir, "id_other")
+
+	home := path.Join(dir, "home")
+	if err := os.MkdirAll(path.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	authorizedKeysFile := path.Join(home, ".ssh", "authorized_keys")
+	// no trailing new line, the new key must not be glued onto the last key
+	authorizedKeys := authorizedKeyBody(oldKey) + " old\n" + authorizedKeyBody(otherKey) + " other"
+	if err := os.WriteFile(authorizedKeysFile, []byte(authorizedKeys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestSshServerWithHome(t, home)
+
+	knownHostsFile := path.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(sshAddress("127.0.0.1", server.port()))}, server.hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	knownHostsCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inventory := &sshInventory{
+		hosts: []sshInventoryHost{
+			{
+				Host:     "behind-jump",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User:      "ubuntu",
+					Port:      server.port(),
+					ProxyJump: "web",
+				},
+			},
+			{
+				Host:     "web",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User: "ubuntu",
+					Port: server.port(),
+				},
+			},
+			{
+				Host:     "own-key",
+				HostName: "127.0.0.1",
+				sshHostOptions: sshHostOptions{
+					User:         "deployer",
+					Port:         server.port(),
+					IdentityFile: otherKeyFile,
+				},
+			},
+		},
+	}
+
+	oldIdentityFiles := sshIdentityFiles{root: oldKeyFile, fallback: oldKeyFile}
+	newIdentityFiles := sshIdentityFiles{root: oldKeyFile, fallback: newKeyFile}
+
+	rotations, err := planSshKeyRotations(inventory, oldIdentityFiles, newIdentityFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotations[0].host.Host != "web" || rotations[2].host.Host != "behind-jump" {
+		t.Fatalf("jump host must be rotated first, got %s, %s, %s", rotations[0].host.Host, rotations[1].host.Host, rotations[2].host.Host)
+	}
+	if len(rotations[1].skipReason) < 1 {
+		t.Errorf("host defines its own IdentityFile must be skipped")
+	}
+
+	newChecker := func(identityFiles sshIdentityFiles) *sshChecker {
+		return &sshChecker{
+			inventory:     inventory,
+			identityFiles: identityFiles,
+			knownHosts:    knownHostsCallback,
+			strictHostKey: true,
+			timeout:       5 * time.Second,
+		}
+	}
+
+	rotator := &sshKeyRotator{
+		oldKeyChecker: newChecker(oldIdentityFiles),
+		newKeyChecker: newChecker(newIdentityFiles),
+	}
+
+	if !rotator.deployAndVerify(rotations) {
+		for _, rotation := range rotations {
+			t.Logf("%s: %v", rotation.host.Host, rotation.err)
+		}
+		t.Fatal("deployAndVerify() failed")
+	}
+
+	bz, err := os.ReadFile(authorizedKeysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(bz), authorizedKeyBody(newKey)) != 1 {
+		t.Errorf("new key must be deployed exactly once, authorized_keys:\n%s", string(bz))
+	}
+	if !strings.Contains(string(bz), authorizedKeyBody(otherKey)+" other\n"+authorizedKeyBody(newKey)) {
+		t.Errorf("new key must be deployed on a new line, authorized_keys:\n%s", string(bz))
+	}
+
+	if !rotator.removeOldKeys(rotations) {
+		for _, rotation := range rotations {
+			t.Logf("%s: %v", rotation.host.Host, rotation.err)
+		}
+		t.Fatal("removeOldKeys() failed")
+	}
+
+	bz, err = os.ReadFile(authorizedKeysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bz), authorizedKeyBody(oldKey)) {
+		t.Errorf("old key must be removed, authorized_keys:\n%s", string(bz))
+	}
+	if !strings.Contains(string(bz), authorizedKeyBody(otherKey)+" other") {
+		t.Errorf("other keys must be kept, authorized_keys:\n%s", string(bz))
+	}
+
+	fi, err := os.Stat(authorizedKeysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("permission of authorized_keys must be kept, got %s", fi.Mode().Perm())
+	}
+
+	for _, rotation := range rotations {
+		if len(rotation.skipReason) < 1 && rotation.progress != sshKeyRotationRotated {
+			t.Errorf("host %s progress = %s, want %s", rotation.host.Host, rotation.progress, sshKeyRotationRotated)
+		}
+	}
+
+	// login with old key is no longer possible
+	if _, _, result := newChecker(oldIdentityFiles).open(inventory.hosts[1]); result.status != sshCheckStatusAuthFailed {
+		t.Errorf("status of login with old key = %s, want %s", result.status, sshCheckStatusAuthFailed)
+	}
+}
+
+func Test_checkNewKeysUsable(t *testing.T) {
+	dir := t.TempDir()
+	plainKeyFile, plainKey := writeTestSshKey(t, dir, "id_plain")
+	encryptedKeyFile, encryptedKey := writeTestSshKey(t, dir, "id_encrypted")
+
+	bz, err := os.ReadFile(encryptedKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawKey, err := ssh.ParseRawPrivateKey(bz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// marks the key as encrypted
+	encryptedPem := pem.EncodeToMemory(&pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00000000000000000000000000000000"},
+		Bytes:   []byte("encrypted"),
+	})
+	if err := os.WriteFile(encryptedKeyFile, encryptedPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyAgent := agent.NewKeyring()
+	loadedAgent := agent.NewKeyring()
+	if err := loadedAgent.Add(agent.AddedKey{PrivateKey: rawKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	plainRotation := &sshKeyRotation{newKeyFile: plainKeyFile, newKey: plainKey}
+	encryptedRotation := &sshKeyRotation{newKeyFile: encryptedKeyFile, newKey: encryptedKey}
+	skippedRotation := &sshKeyRotation{newKeyFile: encryptedKeyFile, newKey: encryptedKey, skipReason: "skipped"}
+
+	tests := []struct {
+		name      string
+		rotations []*sshKeyRotation
+		sshAgent  agent.Agent
+		wantErr   string
+	}{
+		{
+			name:      "plain key without ssh-agent",
+			rotations: []*sshKeyRotation{plainRotation},
+		},
+		{
+			name:      "skipped encrypted key",
+			rotations: []*sshKeyRotation{plainRotation, skippedRotation},
+		},
+		{
+			name:      "encrypted key without ssh-agent",
+			rotations: []*sshKeyRotation{plainRotation, encryptedRotation},
+			wantErr:   "ssh-agent is required",
+		},
+		{
+			name:      "encrypted key not added into ssh-agent",
+			rotations: []*sshKeyRotation{encryptedRotation},
+			sshAgent:  emptyAgent,
+			wantErr:   "was not added into ssh-agent",
+		},
+		{
+			name:      "encrypted key added into ssh-agent",
+			rotations: []*sshKeyRotation{encryptedRotation},
+			sshAgent:  loadedAgent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkNewKeysUsable(tt.rotations, tt.sshAgent)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkNewKeysUsable() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("checkNewKeysUsable() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_restrictedSshAgent(t *testing.T) {
+	keyring := agent.NewKeyring()
+	var publicKeys []ssh.PublicKey
+	for i := 0; i < 2; i++ {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keyring.Add(agent.AddedKey{PrivateKey: privateKey}); err != nil {
+			t.Fatal(err)
+		}
+		sshPublicKey, err := ssh.NewPublicKey(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKeys = append(publicKeys, sshPublicKey)
+	}
+
+	if newRestrictedSshAgent(nil, publicKeys) != nil {
+		t.Errorf("newRestrictedSshAgent() must return nil when ssh-agent is not available")
+	}
+
+	restricted := newRestrictedSshAgent(keyring, publicKeys[1:])
+
+	keys, err := restricted.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !bytes.Equal(keys[0].Blob, publicKeys[1].Marshal()) {
+		t.Errorf("List() = %v, want only the allowed key", keys)
+	}
+
+	signers, err := restricted.Signers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signers) != 1 || !bytes.Equal(signers[0].PublicKey().Marshal(), publicKeys[1].Marshal()) {
+		t.Errorf("Signers() = %v, want only the allowed key", signers)
+	}
+}
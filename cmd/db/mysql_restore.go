@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// MySqlRestoreCommands registers a sub-tree of commands
+func MySqlRestoreCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mysql_restore [file_name]",
+		Short: "Restore DB using SQL backup file (MySQL/MariaDB)",
+		Long: fmt.Sprintf(`Restore DB using SQL backup file (MySQL/MariaDB), the file is fed into mysql client.
+Default port and username are %d and %s, flag --%s is required.
+Password is passed to mysql via a temporary option file (--defaults-extra-file) instead of command line.`, defaultMySqlPort, defaultMySqlUserName, flagDbName),
+		Args: cobra.ExactArgs(1),
+		Run:  restoreMySqlDatabase,
+	}
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password of the user which will be used to restore the database, %s", secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the mysql utility",
+	)
+
+	return cmd
+}
+
+func restoreMySqlDatabase(cmd *cobra.Command, args []string) {
+	inputFilePath := strings.TrimSpace(args[0])
+	if len(inputFilePath) < 1 {
+		panic("bad input backup file")
+	}
+
+	_, err := os.Stat(inputFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			panic("bad input backup file, file does not exists")
+		}
+
+		panic(errors.Wrap(err, fmt.Sprintf("problem when checking input file %s", inputFilePath)))
+	}
+
+	conn := readMySqlConnection(cmd)
+
+	toolName := readToolName(cmd, "mysql")
+
+	password := readMySqlPassword(cmd, conn.userName)
+
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to open input file %s", inputFilePath)))
+	}
+
+	optionFile := createMySqlDefaultsExtraFile(password)
+
+	// --defaults-extra-file must be the first argument
+	restoreArgs := []string{fmt.Sprintf("--defaults-extra-file=%s", optionFile)}
+	restoreArgs = append(restoreArgs, conn.buildConnArgs()...)
+	restoreArgs = append(restoreArgs, conn.dbName)
+
+	fmt.Println("Input file:", inputFilePath)
+	fmt.Println("Restore arguments:\n", toolName, strings.Join(restoreArgs, " "), "<", inputFilePath)
+	fmt.Println("Begin restore", inputFilePath, "at", utils.NowStr())
+
+	ec := utils.LaunchAppWithSetup(toolName, restoreArgs, func(launchCmd *exec.Cmd) {
+		launchCmd.Stdin = inputFile
+		launchCmd.Stdout = os.Stdout
+		launchCmd.Stderr = os.Stderr
+	})
+
+	_ = inputFile.Close()
+	_ = os.Remove(optionFile)
+
+	if ec != 0 {
+		fmt.Println("Failed to restore", inputFilePath, "at", utils.NowStr())
+		os.Exit(ec)
+	}
+
+	fmt.Println("Finished restore", inputFilePath, "at", utils.NowStr())
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const (
+	defaultMySqlPort     = 3306
+	defaultMySqlUserName = "root"
+)
+
+// mySqlConnection holds information to connect to MySQL/MariaDB server, read from the persistent flags
+type mySqlConnection struct {
+	host     string
+	port     uint16
+	userName string
+	dbName   string
+}
+
+// readMySqlConnection reads connection information from the persistent flags,
+// default values of port and username which are defined for PostgreSQL are replaced by MySQL's ones.
+func readMySqlConnection(cmd *cobra.Command) mySqlConnection {
+	var conn mySqlConnection
+
+	conn.host, _ = cmd.Flags().GetString(flagHost)
+	conn.host = strings.TrimSpace(conn.host)
+
+	if cmd.Flags().Changed(flagPort) {
+		conn.port, _ = cmd.Flags().GetUint16(flagPort)
+		if conn.port == 0 {
+			panic(fmt.Errorf("missing value for mandatory flag --%s", flagPort))
+		}
+	} else {
+		conn.port = defaultMySqlPort
+	}
+
+	if cmd.Flags().Changed(flagUsername) {
+		conn.userName, _ = cmd.Flags().GetString(flagUsername)
+		conn.userName = strings.TrimSpace(conn.userName)
+	} else {
+		conn.userName = defaultMySqlUserName
+	}
+
+	if !cmd.Flags().Changed(flagDbName) {
+		panic(fmt.Errorf("flag --%s is required", flagDbName))
+	}
+	conn.dbName, _ = cmd.Flags().GetString(flagDbName)
+	conn.dbName = strings.TrimSpace(conn.dbName)
+	if len(conn.dbName) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagDbName))
+	}
+
+	return conn
+}
+
+// buildConnArgs builds arguments --host/--port/--user for MySQL utilities
+func (c mySqlConnection) buildConnArgs() []string {
+	connArgs := make([]string, 0)
+	if len(c.host) > 0 {
+		connArgs = append(connArgs, fmt.Sprintf("--host=%s", c.host))
+	}
+	if c.port > 0 {
+		connArgs = append(connArgs, fmt.Sprintf("--port=%d", c.port))
+	}
+	if len(c.userName) > 0 {
+		connArgs = append(connArgs, fmt.Sprintf("--user=%s", c.userName))
+	}
+	return connArgs
+}
+
+// readMySqlPassword reads password from password file (or secret source) provided via flag, or environment variable MYSQL_PWD
+func readMySqlPassword(cmd *cobra.Command, userName string) string {
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	if len(passwordFile) > 0 {
+		return secrets.ReadSecret(passwordFile)
+	}
+
+	password := strings.TrimSpace(os.Getenv(constants.ENV_MYSQL_PASSWORD))
+	if len(password) < 1 {
+		panic(fmt.Errorf("missing password for user %s, either environment variable %s or flag --%s is required", userName, constants.ENV_MYSQL_PASSWORD, flagPasswordFile))
+	}
+
+	return password
+}
+
+// createMySqlDefaultsExtraFile writes password into a temporary option file which is only readable by owner,
+// so password will not be exposed via command line. Caller is responsible to remove the file.
+func createMySqlDefaultsExtraFile(password string) string {
+	file, err := os.CreateTemp("", fmt.Sprintf("%s-mysql-*.cnf", constants.BINARY_NAME))
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create temporary MySQL option file"))
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if err := file.Chmod(constants.RECOMMENDED_FILE_PERMISSION); err != nil {
+		_ = os.Remove(file.Name())
+		panic(errors.Wrap(err, "failed to set permission of temporary MySQL option file"))
+	}
+
+	if _, err := file.WriteString(buildMySqlOptionFileContent(password)); err != nil {
+		_ = os.Remove(file.Name())
+		panic(errors.Wrap(err, "failed to write temporary MySQL option file"))
+	}
+
+	return file.Name()
+}
+
+func buildMySqlOptionFileContent(password string) string {
+	escaped := strings.ReplaceAll(password, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	return fmt.Sprintf("[client]\npassword=\"%s\"\n", escaped)
+}
@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func Test_buildMySqlOptionFileContent(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{
+			password: "1234567",
+			want:     "[client]\npassword=\"1234567\"\n",
+		},
+		{
+			password: `pa"ss`,
+			want:     "[client]\npassword=\"pa\\\"ss\"\n",
+		},
+		{
+			password: `pa\ss#word`,
+			want:     "[client]\npassword=\"pa\\\\ss#word\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := buildMySqlOptionFileContent(tt.password); got != tt.want {
+				t.Errorf("buildMySqlOptionFileContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// MySqlDumpCommands registers a sub-tree of commands
+func MySqlDumpCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mysqldump",
+		Short: "Backup DB (MySQL/MariaDB)",
+		Long: fmt.Sprintf(`Backup DB (MySQL/MariaDB) using mysqldump.
+Default port and username are %d and %s, flag --%s is required.
+Password is passed to mysqldump via a temporary option file (--defaults-extra-file) instead of command line.`, defaultMySqlPort, defaultMySqlUserName, flagDbName),
+		Args: cobra.NoArgs,
+		Run:  backupMySqlDatabase,
+	}
+
+	cmd.PersistentFlags().String(
+		flagOutputFile,
+		dailyFileName("db", "sql"),
+		"specify name of the output backup file, file name only, default has layout: db-yyyy-MM-dd.sql",
+	)
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password of the user which will be used to backup the database, %s", secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the mysqldump utility",
+	)
+
+	return cmd
+}
+
+func backupMySqlDatabase(cmd *cobra.Command, _ []string) {
+	outputFileName := readOutputFileName(cmd)
+	outputFilePath := resolveOutputFilePath(cmd, outputFileName)
+
+	conn := readMySqlConnection(cmd)
+
+	toolName := readToolName(cmd, "mysqldump")
+
+	password := readMySqlPassword(cmd, conn.userName)
+
+	optionFile := createMySqlDefaultsExtraFile(password)
+
+	// --defaults-extra-file must be the first argument
+	dumpArgs := []string{fmt.Sprintf("--defaults-extra-file=%s", optionFile)}
+	dumpArgs = append(dumpArgs, conn.buildConnArgs()...)
+	dumpArgs = append(dumpArgs, "--single-transaction", "--routines", "--triggers")
+	dumpArgs = append(dumpArgs, fmt.Sprintf("--result-file=%s", outputFilePath))
+	dumpArgs = append(dumpArgs, conn.dbName)
+
+	fmt.Println("Output file:", outputFilePath)
+	fmt.Println("Dump arguments:\n", toolName, strings.Join(dumpArgs, " "))
+	fmt.Println("Begin dump", outputFileName, "at", utils.NowStr())
+
+	ec := utils.LaunchApp(toolName, dumpArgs, nil, false)
+
+	_ = os.Remove(optionFile)
+
+	if ec != 0 {
+		fmt.Println("Failed to dump", outputFileName, "at", utils.NowStr())
+		os.Exit(ec)
+	}
+
+	fmt.Println("Finished dump", outputFileName, "at", utils.NowStr())
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/spf13/cobra"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+//goland:noinspection SpellCheckingInspection
+var supportedPgSslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+var regexPgConnInfoPassword = regexp.MustCompile(`(^|\s)password\s*=\s*('(\\.|[^'])*'|\S+)`)
+
+// pgConnection holds information to connect to PostgreSQL server, read from the persistent flags
+type pgConnection struct {
+	host     string
+	port     uint16
+	userName string
+	// dbName is database name, or a libpq connection URI/connection string
+	dbName      string
+	service     string
+	serviceFile string
+	sslMode     string
+	sslRootCert string
+
+	// explicitFlags tracks flags host/port/username which were provided explicitly
+	explicitFlags map[string]bool
+}
+
+// readPgConnection reads connection information from the persistent flags
+func readPgConnection(cmd *cobra.Command) pgConnection {
+	conn := pgConnection{
+		explicitFlags: make(map[string]bool),
+	}
+
+	conn.host, _ = cmd.Flags().GetString(flagHost)
+	conn.host = strings.TrimSpace(conn.host)
+
+	conn.port, _ = cmd.Flags().GetUint16(flagPort)
+
+	conn.userName, _ = cmd.Flags().GetString(flagUsername)
+	conn.userName = strings.TrimSpace(conn.userName)
+
+	conn.dbName, _ = cmd.Flags().GetString(flagDbName)
+	conn.dbName = strings.TrimSpace(conn.dbName)
+
+	conn.service, _ = cmd.Flags().GetString(flagService)
+	conn.service = strings.TrimSpace(conn.service)
+
+	conn.serviceFile, _ = cmd.Flags().GetString(flagServiceFile)
+	conn.serviceFile = strings.TrimSpace(conn.serviceFile)
+
+	conn.sslMode, _ = cmd.Flags().GetString(flagSslMode)
+	conn.sslMode = strings.TrimSpace(conn.sslMode)
+
+	conn.sslRootCert, _ = cmd.Flags().GetString(flagSslRootCert)
+	conn.sslRootCert = strings.TrimSpace(conn.sslRootCert)
+
+	for _, flag := range []string{flagHost, flagPort, flagUsername} {
+		conn.explicitFlags[flag] = cmd.Flags().Changed(flag)
+	}
+
+	if conn.useDefaultConnFlags() && conn.port == 0 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagPort))
+	}
+
+	if len(conn.serviceFile) > 0 {
+		if exists, _ := utils.IsFileAndExists(conn.serviceFile); !exists {
+			panic(fmt.Errorf("service file provided by flag --%s does not exists: %s", flagServiceFile, conn.serviceFile))
+		}
+	}
+
+	if len(conn.sslMode) > 0 {
+		var supported bool
+		for _, sslMode := range supportedPgSslModes {
+			if sslMode == conn.sslMode {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			panic(fmt.Errorf("not supported value \"%s\" for flag --%s, supported values: %s", conn.sslMode, flagSslMode, strings.Join(supportedPgSslModes, ", ")))
+		}
+	}
+
+	if len(conn.sslRootCert) > 0 {
+		if exists, _ := utils.IsFileAndExists(conn.sslRootCert); !exists {
+			panic(fmt.Errorf("SSL root certificate file provided by flag --%s does not exists: %s", flagSslRootCert, conn.sslRootCert))
+		}
+	}
+
+	return conn
+}
+
+// isConnString returns true if database name is a libpq connection URI or connection string
+func (c pgConnection) isConnString() bool {
+	return isPgConnString(c.dbName)
+}
+
+// useDefaultConnFlags returns true if default values of flags host/port/username should be used,
+// when connection URI or service is used, only explicitly provided flags are used.
+func (c pgConnection) useDefaultConnFlags() bool {
+	return !c.isConnString() && len(c.service) < 1
+}
+
+// buildConnArgs builds arguments --host/--port/--username for PostgreSQL utilities
+func (c pgConnection) buildConnArgs() []string {
+	useDefault := c.useDefaultConnFlags()
+
+	connArgs := make([]string, 0)
+	if len(c.host) > 0 && (useDefault || c.explicitFlags[flagHost]) {
+		connArgs = append(connArgs, fmt.Sprintf("--host=%s", c.host))
+	}
+	if c.port > 0 && (useDefault || c.explicitFlags[flagPort]) {
+		connArgs = append(connArgs, fmt.Sprintf("--port=%d", c.port))
+	}
+	if len(c.userName) > 0 && (useDefault || c.explicitFlags[flagUsername]) {
+		connArgs = append(connArgs, fmt.Sprintf("--username=%s", c.userName))
+	}
+	return connArgs
+}
+
+// buildEnvVars builds environment variables for PostgreSQL utilities.
+// The full environment of current process is kept, password read from password file or secret source (if any) is overlaid.
+// When password file is not provided, password must be available via either
+// environment variable PGPASSWORD, connection URI, service or ~/.pgpass file.
+func (c pgConnection) buildEnvVars(passwordFile string) []string {
+	var overlay []string
+
+	if len(passwordFile) > 0 {
+		pgPassword := secrets.ReadSecret(passwordFile)
+		overlay = append(overlay, fmt.Sprintf("%s=%s", constants.ENV_PG_PASSWORD, pgPassword))
+	} else if !c.hasPasswordSource() {
+		panic(fmt.Errorf("missing password for user %s, either environment variable %s or flag --%s or ~/.pgpass file or flag --%s is required", c.userName, constants.ENV_PG_PASSWORD, flagPasswordFile, flagService))
+	}
+
+	if len(c.service) > 0 {
+		overlay = append(overlay, fmt.Sprintf("%s=%s", constants.ENV_PG_SERVICE, c.service))
+	}
+	if len(c.serviceFile) > 0 {
+		overlay = append(overlay, fmt.Sprintf("%s=%s", constants.ENV_PG_SERVICEFILE, c.serviceFile))
+	}
+	if len(c.sslMode) > 0 {
+		overlay = append(overlay, fmt.Sprintf("%s=%s", constants.ENV_PG_SSLMODE, c.sslMode))
+	}
+	if len(c.sslRootCert) > 0 {
+		overlay = append(overlay, fmt.Sprintf("%s=%s", constants.ENV_PG_SSLROOTCERT, c.sslRootCert))
+	}
+
+	return utils.OverlayEnvVars(os.Environ(), overlay...)
+}
+
+// hasPasswordSource returns true if password can be provided to libpq without password file
+func (c pgConnection) hasPasswordSource() bool {
+	if len(strings.TrimSpace(os.Getenv(constants.ENV_PG_PASSWORD))) > 0 {
+		return true
+	}
+
+	if hasPasswordInPgConnString(c.dbName) {
+		return true
+	}
+
+	if len(c.service) > 0 || len(strings.TrimSpace(os.Getenv(constants.ENV_PG_SERVICE))) > 0 {
+		// password might be provided by the service file
+		return true
+	}
+
+	pgPassFile := findPgPassFile()
+	if len(pgPassFile) > 0 {
+		fip, err := os.Stat(pgPassFile)
+		if err == nil {
+			fipPerm := fip.Mode().Perm()
+			if errPerm := utils.ValidatePasswordFileMode(fipPerm); errPerm != nil {
+				fmt.Printf("WARN: incorrect permission '%o' of password file %s: %s, it might be ignored by libpq\n", fipPerm, pgPassFile, errPerm)
+				fmt.Printf("Suggest setting permission to '%o'\n", constants.RECOMMENDED_FILE_PERMISSION)
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// findPgPassFile returns path of the password file used by libpq, empty if not exists
+func findPgPassFile() string {
+	pgPassFile := strings.TrimSpace(os.Getenv(constants.ENV_PG_PASSFILE))
+	if len(pgPassFile) < 1 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		pgPassFile = path.Join(home, ".pgpass")
+	}
+
+	if exists, _ := utils.IsFileAndExists(pgPassFile); exists {
+		return pgPassFile
+	}
+
+	return ""
+}
+
+// isPgConnString returns true if the input is a libpq connection URI or connection string (key=value)
+func isPgConnString(dbName string) bool {
+	return strings.HasPrefix(dbName, "postgresql://") || strings.HasPrefix(dbName, "postgres://") || strings.Contains(dbName, "=")
+}
+
+// hasPasswordInPgConnString returns true if password is included in the libpq connection URI or connection string
+func hasPasswordInPgConnString(connString string) bool {
+	if !isPgConnString(connString) {
+		return false
+	}
+
+	if u, err := url.Parse(connString); err == nil && (u.Scheme == "postgresql" || u.Scheme == "postgres") {
+		if _, set := u.User.Password(); set {
+			return true
+		}
+		return len(u.Query().Get("password")) > 0
+	}
+
+	return regexPgConnInfoPassword.MatchString(connString)
+}
+
+// redactPgConnString masks password within libpq connection URI or connection string, for printing purpose
+func redactPgConnString(connString string) string {
+	if !isPgConnString(connString) {
+		return connString
+	}
+
+	if u, err := url.Parse(connString); err == nil && (u.Scheme == "postgresql" || u.Scheme == "postgres") {
+		if len(u.Query().Get("password")) > 0 {
+			query := u.Query()
+			query.Set("password", "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+
+	return regexPgConnInfoPassword.ReplaceAllString(connString, "${1}password=xxxxx")
+}
+
+// redactPgArgs masks password within connection URI/string of the arguments, for printing purpose
+func redactPgArgs(args []string) []string {
+	const dbNameArgPrefix = "--dbname="
+
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, dbNameArgPrefix) {
+			redacted[i] = dbNameArgPrefix + redactPgConnString(strings.TrimPrefix(arg, dbNameArgPrefix))
+		} else if !strings.HasPrefix(arg, "-") {
+			redacted[i] = redactPgConnString(arg)
+		} else {
+			redacted[i] = arg
+		}
+	}
+	return redacted
+}
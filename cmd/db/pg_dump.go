@@ -2,15 +2,25 @@ package db
 
 import (
 	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
-	"time"
+)
+
+// remotePartialFileSuffix is suffix of the file which archive is streamed into, before renamed to the output file
+const remotePartialFileSuffix = ".partial"
+
+const (
+	flagRemoteDest         = "remote-dest"
+	flagRemotePasswordFile = "remote-password-file"
+	flagNoRemotePassword   = "no-remote-password"
+	flagPassphrase         = "passphrase"
+	flagPipeCommand        = "pipe-command"
 )
 
 // PgDumpCommands registers a sub-tree of commands
@@ -18,20 +28,32 @@ func PgDumpCommands() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "pg_dump",
 		Short: "Backup DB (PostgreSQL)",
-		Args:  cobra.NoArgs,
-		Run:   backupPgDatabase,
+		Long: fmt.Sprintf(`Backup DB (PostgreSQL) using pg_dump.
+- Dump into working directory:
+> %s db pg_dump --working-directory /mnt/md0/backup --dbname my_db
+- Stream the archive to remote server over ssh while it is being produced, no local disk needed:
+> %s db pg_dump --dbname my_db --%s backup@192.168.0.2:/mnt/md0/backup
+- Stream the archive into a local command:
+> %s db pg_dump --dbname my_db --%s 'gzip > /mnt/md0/backup/db.dump.gz'
+
+Note:
+- When streaming to remote server, password to access remote server is read from flag --%s or environment variable %s or %s, similar to '%s files rsync'.
+- You must connect to that remote server at least one time before to perform host key verification (one time action).
+`, constants.BINARY_NAME, constants.BINARY_NAME, flagRemoteDest, constants.BINARY_NAME, flagPipeCommand, flagRemotePasswordFile, constants.ENV_RSYNC_PASSWORD, constants.ENV_SSHPASS, constants.BINARY_NAME),
+		Args: cobra.NoArgs,
+		Run:  backupPgDatabase,
 	}
 
 	cmd.PersistentFlags().String(
 		flagOutputFile,
-		fmt.Sprintf("db-%s.dump", time.Now().Format("2006-01-02")),
+		dailyFileName("db", "dump"),
 		"specify name of the output backup file, file name only, default has layout: db-yyyy-MM-dd.dump",
 	)
 
 	cmd.PersistentFlags().String(
 		flagPasswordFile,
 		"",
-		"file path which store password of the user which will be used to backup the database",
+		fmt.Sprintf("file path which store password of the user which will be used to backup the database, %s", secrets.SourceUsage),
 	)
 
 	cmd.PersistentFlags().String(
@@ -46,129 +68,194 @@ func PgDumpCommands() *cobra.Command {
 		"specify schema to backup",
 	)
 
+	cmd.PersistentFlags().String(
+		flagRemoteDest,
+		"",
+		"stream the archive directly to remote directory over ssh instead of writing local file, format: user@host:/path/to/dir",
+	)
+
+	cmd.PersistentFlags().String(
+		flagRemotePasswordFile,
+		"",
+		fmt.Sprintf("file path which store password to access remote server, used with --%s, %s", flagRemoteDest, secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagNoRemotePassword,
+		false,
+		fmt.Sprintf("connect remote server without password, used with --%s", flagRemoteDest),
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagPassphrase,
+		false,
+		"by default sshpass passes password. If you are authenticating using passphrase, supply this flag to indicate",
+	)
+
+	cmd.PersistentFlags().String(
+		flagPipeCommand,
+		"",
+		"stream the archive into stdin of the specified command (executed by /bin/bash) instead of writing local file",
+	)
+
 	return cmd
 }
 
 func backupPgDatabase(cmd *cobra.Command, _ []string) {
-	outputFileName, _ := cmd.Flags().GetString(flagOutputFile)
-	outputFileName = strings.TrimSpace(outputFileName)
-	if len(outputFileName) < 1 {
-		panic(fmt.Errorf("missing value for mandatory flag --%s", flagOutputFile))
-	}
-
-	dir, outputFileName := path.Split(outputFileName)
-	if len(dir) > 0 {
-		panic("output file name must be file name alone, can not contains directory part")
-	}
+	outputFileName := readOutputFileName(cmd)
 
-	workingDir := utils.ReadFlagWorkingDir(cmd)
+	remoteDest, _ := cmd.Flags().GetString(flagRemoteDest)
+	remoteDest = strings.TrimSpace(remoteDest)
 
-	outputFilePath, err := filepath.Abs(path.Join(workingDir, outputFileName))
-	if err != nil {
-		panic(errors.Wrap(err, "failed to convert into absolute path"))
-	}
+	pipeCommand, _ := cmd.Flags().GetString(flagPipeCommand)
+	pipeCommand = strings.TrimSpace(pipeCommand)
 
-	_, err = os.Stat(outputFilePath)
-	if err == nil {
-		panic(fmt.Errorf("output file already exists: %s", outputFilePath))
-	} else {
-		if os.IsNotExist(err) {
-			// ok
-		} else {
-			panic(errors.Wrap(err, fmt.Sprintf("problem when checking file %s", outputFilePath)))
-		}
+	if len(remoteDest) > 0 && len(pipeCommand) > 0 {
+		panic(fmt.Errorf("flags --%s and --%s can not be used together", flagRemoteDest, flagPipeCommand))
 	}
 
-	host, _ := cmd.Flags().GetString(flagHost)
-	host = strings.TrimSpace(host)
+	isStreaming := len(remoteDest) > 0 || len(pipeCommand) > 0
 
-	port, _ := cmd.Flags().GetUint16(flagPort)
-	if port == 0 {
-		panic(fmt.Errorf("missing value for mandatory flag --%s", flagPort))
+	var outputFilePath string
+	if !isStreaming {
+		outputFilePath = resolveOutputFilePath(cmd, outputFileName)
 	}
 
-	dbName, _ := cmd.Flags().GetString(flagDbName)
-	dbName = strings.TrimSpace(dbName)
+	conn := readPgConnection(cmd)
+
+	dbName := conn.dbName
 	if len(dbName) < 1 {
 		panic(fmt.Errorf("missing value for mandatory flag --%s", flagDbName))
 	}
 
-	userName, _ := cmd.Flags().GetString(flagUsername)
-	userName = strings.TrimSpace(userName)
-
 	schema, _ := cmd.Flags().GetString(flagSchema)
 	schema = strings.TrimSpace(schema)
 
-	toolName := "pg_dump"
-	customToolName, _ := cmd.Flags().GetString(flagToolFile)
-	customToolName = strings.TrimSpace(customToolName)
-	if len(customToolName) > 0 {
-		_, err = os.Stat(customToolName)
-		if os.IsNotExist(err) {
-			panic(fmt.Errorf("custom pg_dump file path does not exists: %s", customToolName))
-		}
+	toolName := readToolName(cmd, "pg_dump")
+
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	envVars := conn.buildEnvVars(passwordFile)
 
-		toolName = customToolName
+	dumpArgs := conn.buildConnArgs()
+	if len(schema) > 0 {
+		dumpArgs = append(dumpArgs, fmt.Sprintf("--schema=%s", schema))
 	}
+	dumpArgs = append(dumpArgs, "-Fc")
+	if !isStreaming {
+		dumpArgs = append(dumpArgs, fmt.Sprintf("--file=%s", outputFilePath))
+	}
+	dumpArgs = append(dumpArgs, dbName)
 
-	var envVars []string
+	if len(remoteDest) > 0 {
+		streamPgDumpToRemote(cmd, toolName, dumpArgs, envVars, remoteDest, outputFileName)
+		return
+	}
 
-	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
-	if len(passwordFile) < 1 {
-		if len(strings.TrimSpace(os.Getenv(constants.ENV_PG_PASSWORD))) < 1 {
-			panic(fmt.Errorf("missing password for user %s, either environment variable %s or flag --%s is required", userName, constants.ENV_PG_PASSWORD, flagPasswordFile))
-		}
+	if len(pipeCommand) > 0 {
+		streamPgDumpToPipeCommand(toolName, dumpArgs, envVars, pipeCommand, outputFileName)
+		return
+	}
 
-		envVars = os.Environ()
-	} else {
-		fip, err := os.Stat(passwordFile)
-		if os.IsNotExist(err) {
-			panic(fmt.Errorf("supplied password file does not exists %s", passwordFile))
-		}
+	fmt.Println("Output file:", outputFilePath)
+	fmt.Println("Dump arguments:\n", toolName, strings.Join(redactPgArgs(dumpArgs), " "))
+	fmt.Println("Begin dump", outputFileName, "at", utils.NowStr())
 
-		bz, err := os.ReadFile(passwordFile)
-		if err != nil {
-			panic(errors.Wrap(err, fmt.Sprintf("failed to read password file %s", passwordFile)))
-		}
+	ec := utils.LaunchApp(toolName, dumpArgs, envVars, false)
+	if ec != 0 {
+		fmt.Println("Failed to dump", outputFileName, "at", utils.NowStr())
+		os.Exit(ec)
+	}
 
-		fipPerm := fip.Mode().Perm()
-		errPerm := utils.ValidatePasswordFileMode(fipPerm)
-		if errPerm != nil {
-			fmt.Printf("Incorrect permission '%o' of password file: %s\n", fipPerm, errPerm)
-			fmt.Printf("Suggest setting permission to '%o'\n", constants.RECOMMENDED_FILE_PERMISSION)
-			os.Exit(1)
+	fmt.Println("Finished dump", outputFileName, "at", utils.NowStr())
+}
+
+// streamPgDumpToRemote streams output of pg_dump to the remote server over ssh,
+// password handling is the same as rsync command.
+// The archive is streamed into a partial file, which is renamed to the output file only after success.
+func streamPgDumpToRemote(cmd *cobra.Command, toolName string, dumpArgs, dumpEnvVars []string, remoteDest, outputFileName string) {
+	sshTarget, remoteFilePath := parseRemoteDest(remoteDest, outputFileName)
+	remotePartialFilePath := remoteFilePath + remotePartialFileSuffix
+	streamCommand, finalizeCommand, cleanupCommand := buildRemoteDumpCommands(remoteFilePath, remotePartialFilePath)
+
+	consumerName := "ssh"
+	consumerArgs := []string{sshTarget}
+	var consumerEnvVars []string
+
+	noRemotePassword, _ := cmd.Flags().GetBool(flagNoRemotePassword)
+	if !noRemotePassword {
+		if !utils.HasToolSshPass() {
+			panic(fmt.Errorf("sshpass is required to pass password to ssh, otherwise supply flag --%s", flagNoRemotePassword))
 		}
 
-		pgPassword := strings.TrimSpace(string(bz))
-		if len(pgPassword) < 1 {
-			panic(fmt.Errorf("password file is empty: %s", passwordFile))
+		passphraseMode, _ := cmd.Flags().GetBool(flagPassphrase)
+
+		remotePasswordFile, _ := cmd.Flags().GetString(flagRemotePasswordFile)
+		remotePasswordFile = strings.TrimSpace(remotePasswordFile)
+		var sshPassFile string
+		if len(remotePasswordFile) > 0 {
+			passwordSource := secrets.ParseSource(remotePasswordFile)
+			password := passwordSource.Read()
+			if passwordSource.IsFile() {
+				fmt.Println("Using sshpass to passing password file")
+				sshPassFile = passwordSource.Value
+			} else {
+				fmt.Println("Using sshpass to passing password from", passwordSource.Kind, "source via environment variable", constants.ENV_SSHPASS)
+				consumerEnvVars = utils.OverlayEnvVars(os.Environ(), fmt.Sprintf("%s=%s", constants.ENV_SSHPASS, password))
+			}
+		} else {
+			password, _, _ := utils.ReadRemotePasswordFromEnv()
+			if len(password) < 1 {
+				panic(fmt.Errorf("missing password for remote server, either environment variable %s or %s or flag --%s is required", constants.ENV_RSYNC_PASSWORD, constants.ENV_SSHPASS, flagRemotePasswordFile))
+			}
+			fmt.Println("Using sshpass to passing password via environment variable", constants.ENV_SSHPASS)
+			consumerEnvVars = utils.OverlayEnvVars(os.Environ(), fmt.Sprintf("%s=%s", constants.ENV_SSHPASS, password))
 		}
 
-		envVars = append(envVars, fmt.Sprintf("%s=%s", constants.ENV_PG_PASSWORD, pgPassword))
+		consumerName = "sshpass"
+		consumerArgs = append(append(utils.BuildSshPassArgs(sshPassFile, passphraseMode), "ssh"), consumerArgs...)
 	}
 
-	dumpArgs := make([]string, 0)
-	if len(host) > 0 {
-		dumpArgs = append(dumpArgs, fmt.Sprintf("--host=%s", host))
-	}
-	if port > 0 {
-		dumpArgs = append(dumpArgs, fmt.Sprintf("--port=%d", port))
+	remoteCommandArgs := func(remoteCommand string) []string {
+		return append(append([]string{}, consumerArgs...), remoteCommand)
 	}
-	if len(schema) > 0 {
-		dumpArgs = append(dumpArgs, fmt.Sprintf("--schema=%s", schema))
+
+	fmt.Println("Remote output file:", fmt.Sprintf("%s:%s", sshTarget, remoteFilePath))
+
+	ec := launchPgDumpPiped(toolName, dumpArgs, dumpEnvVars, consumerName, remoteCommandArgs(streamCommand), consumerEnvVars, outputFileName)
+	if ec == 0 {
+		ec = utils.LaunchApp(consumerName, remoteCommandArgs(finalizeCommand), consumerEnvVars, true)
+		if ec != 0 {
+			libutils.PrintlnStdErr("ERR: failed to rename the remote partial file, the complete archive was left at", fmt.Sprintf("%s:%s", sshTarget, remotePartialFilePath))
+		}
+	} else if utils.LaunchApp(consumerName, remoteCommandArgs(cleanupCommand), consumerEnvVars, true) != 0 {
+		libutils.PrintlnStdErr("ERR: failed to remove the remote partial file, the incomplete archive was left at", fmt.Sprintf("%s:%s", sshTarget, remotePartialFilePath))
 	}
-	dumpArgs = append(dumpArgs, "-Fc")
-	if len(userName) > 0 {
-		dumpArgs = append(dumpArgs, fmt.Sprintf("--username=%s", userName))
+
+	if ec != 0 {
+		fmt.Println("Failed to dump", outputFileName, "at", utils.NowStr())
+		os.Exit(ec)
 	}
-	dumpArgs = append(dumpArgs, fmt.Sprintf("--file=%s", outputFilePath))
-	dumpArgs = append(dumpArgs, dbName)
 
-	fmt.Println("Output file:", outputFilePath)
-	fmt.Println("Dump arguments:\n", toolName, strings.Join(dumpArgs, " "))
-	fmt.Println("Begin dump", outputFileName, "at", utils.NowStr())
+	fmt.Println("Finished dump", outputFileName, "at", utils.NowStr())
+}
 
-	ec := utils.LaunchApp(toolName, dumpArgs, envVars, false)
+// buildRemoteDumpCommands builds shell commands to be executed on remote server:
+// stream stdin into the partial file, rename the partial file to the output file after success, remove the partial file after failure.
+// Existing output file is never overridden.
+func buildRemoteDumpCommands(remoteFilePath, remotePartialFilePath string) (streamCommand, finalizeCommand, cleanupCommand string) {
+	filePath := utils.ShellQuote(remoteFilePath)
+	partialFilePath := utils.ShellQuote(remotePartialFilePath)
+
+	streamCommand = fmt.Sprintf("test ! -e %s && cat > %s", filePath, partialFilePath)
+	finalizeCommand = fmt.Sprintf("test ! -e %s && mv %s %s", filePath, partialFilePath, filePath)
+	cleanupCommand = fmt.Sprintf("rm -f %s", partialFilePath)
+	return
+}
+
+// streamPgDumpToPipeCommand streams output of pg_dump into stdin of the provided command
+func streamPgDumpToPipeCommand(toolName string, dumpArgs, dumpEnvVars []string, pipeCommand, outputFileName string) {
+	fmt.Println("Pipe command:", pipeCommand)
+	ec := launchPgDumpPiped(toolName, dumpArgs, dumpEnvVars, "/bin/bash", []string{"-c", pipeCommand}, nil, outputFileName)
 	if ec != 0 {
 		fmt.Println("Failed to dump", outputFileName, "at", utils.NowStr())
 		os.Exit(ec)
@@ -176,3 +263,33 @@ func backupPgDatabase(cmd *cobra.Command, _ []string) {
 
 	fmt.Println("Finished dump", outputFileName, "at", utils.NowStr())
 }
+
+// launchPgDumpPiped launches pg_dump and pipes the output into the consumer, returns non-zero exit code if any failed
+func launchPgDumpPiped(toolName string, dumpArgs, dumpEnvVars []string, consumerName string, consumerArgs, consumerEnvVars []string, outputFileName string) int {
+	fmt.Println("Dump arguments:\n", toolName, strings.Join(redactPgArgs(dumpArgs), " "))
+	fmt.Println("Begin dump", outputFileName, "at", utils.NowStr())
+
+	dumpEc, consumerEc := utils.LaunchAppsPiped(toolName, dumpArgs, dumpEnvVars, consumerName, consumerArgs, consumerEnvVars)
+	return libutils.MaxInt(dumpEc, consumerEc)
+}
+
+// parseRemoteDest parses remote destination in format user@host:/path/to/dir,
+// returns the ssh target and the full path of the output file on remote server.
+func parseRemoteDest(remoteDest, outputFileName string) (sshTarget, remoteFilePath string) {
+	spl := strings.SplitN(remoteDest, ":", 2)
+	if len(spl) != 2 {
+		panic(fmt.Errorf("remote destination supplied by flag --%s must satisfy the format: user@host:/path/to/dir", flagRemoteDest))
+	}
+
+	sshTarget = strings.TrimSpace(spl[0])
+	remoteDir := strings.TrimSpace(spl[1])
+	if len(sshTarget) < 1 || strings.HasPrefix(sshTarget, "-") {
+		panic(fmt.Errorf("bad remote host supplied by flag --%s: %s", flagRemoteDest, remoteDest))
+	}
+	if len(remoteDir) < 1 {
+		panic(fmt.Errorf("missing remote directory in value supplied by flag --%s: %s", flagRemoteDest, remoteDest))
+	}
+
+	remoteFilePath = path.Join(remoteDir, outputFileName)
+	return
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func Test_buildRemoteDumpCommands(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "my db.dump")
+	partialFilePath := filePath + remotePartialFileSuffix
+
+	streamCommand, finalizeCommand, cleanupCommand := buildRemoteDumpCommands(filePath, partialFilePath)
+
+	run := func(command, stdin string) error {
+		c := exec.Command("sh", "-c", command)
+		c.Stdin = strings.NewReader(stdin)
+		return c.Run()
+	}
+
+	// failed dump, the partial file is removed and does not block the retry
+	if err := run(streamCommand, "truncated"); err != nil {
+		t.Fatalf("stream command failed: %v", err)
+	}
+	if err := run(cleanupCommand, ""); err != nil {
+		t.Fatalf("cleanup command failed: %v", err)
+	}
+	if _, err := os.Stat(partialFilePath); !os.IsNotExist(err) {
+		t.Fatalf("partial file must be removed, got err = %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Fatalf("output file must not be created, got err = %v", err)
+	}
+
+	// successful dump
+	if err := run(streamCommand, "archive"); err != nil {
+		t.Fatalf("stream command failed: %v", err)
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Fatalf("output file must not be created before finalized, got err = %v", err)
+	}
+	if err := run(finalizeCommand, ""); err != nil {
+		t.Fatalf("finalize command failed: %v", err)
+	}
+	bz, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bz) != "archive" {
+		t.Errorf("output file content = %s, want archive", string(bz))
+	}
+
+	// existing output file is never overridden
+	if err := run(streamCommand, "another"); err == nil {
+		t.Errorf("stream command must fail when output file exists")
+	}
+	if err := os.WriteFile(partialFilePath, []byte("another"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(finalizeCommand, ""); err == nil {
+		t.Errorf("finalize command must fail when output file exists")
+	}
+	bz, err = os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bz) != "archive" {
+		t.Errorf("output file must not be overridden, got %s", string(bz))
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const (
+	flagGlobalsOnly     = "globals-only"
+	flagNoRolePasswords = "no-role-passwords"
+)
+
+// PgDumpAllCommands registers a sub-tree of commands
+func PgDumpAllCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pg_dumpall",
+		Short: "Backup globals (roles, tablespaces, grants) or the entire cluster (PostgreSQL)",
+		Args:  cobra.NoArgs,
+		Run:   backupPgCluster,
+	}
+
+	cmd.PersistentFlags().String(
+		flagOutputFile,
+		"",
+		fmt.Sprintf("specify name of the output backup file, file name only, default has layout: globals-yyyy-MM-dd.sql when --%s, otherwise cluster-yyyy-MM-dd.sql", flagGlobalsOnly),
+	)
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password of the user which will be used to backup the database cluster, %s", secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the pg_dumpall utility",
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagGlobalsOnly,
+		false,
+		"dump only global objects (roles, tablespaces and grants), no databases",
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagNoRolePasswords,
+		false,
+		"do not dump passwords for roles, allow dumping by non-superuser",
+	)
+
+	return cmd
+}
+
+func backupPgCluster(cmd *cobra.Command, _ []string) {
+	globalsOnly, _ := cmd.Flags().GetBool(flagGlobalsOnly)
+
+	if !cmd.Flags().Changed(flagOutputFile) {
+		var defaultOutputFileName string
+		if globalsOnly {
+			defaultOutputFileName = dailyFileName("globals", "sql")
+		} else {
+			defaultOutputFileName = dailyFileName("cluster", "sql")
+		}
+		_ = cmd.Flags().Set(flagOutputFile, defaultOutputFileName)
+	}
+
+	outputFileName := readOutputFileName(cmd)
+	outputFilePath := resolveOutputFilePath(cmd, outputFileName)
+
+	conn := readPgConnection(cmd)
+
+	toolName := readToolName(cmd, "pg_dumpall")
+
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	envVars := conn.buildEnvVars(passwordFile)
+
+	dumpArgs := conn.buildConnArgs()
+	if cmd.Flags().Changed(flagDbName) {
+		// database to connect to for dumping global objects, or connection URI
+		if len(conn.dbName) > 0 {
+			dumpArgs = append(dumpArgs, fmt.Sprintf("--dbname=%s", conn.dbName))
+		}
+	}
+	if globalsOnly {
+		dumpArgs = append(dumpArgs, "--globals-only")
+	}
+	noRolePasswords, _ := cmd.Flags().GetBool(flagNoRolePasswords)
+	if noRolePasswords {
+		dumpArgs = append(dumpArgs, "--no-role-passwords")
+	}
+	dumpArgs = append(dumpArgs, fmt.Sprintf("--file=%s", outputFilePath))
+
+	fmt.Println("Output file:", outputFilePath)
+	fmt.Println("Dump arguments:\n", toolName, strings.Join(redactPgArgs(dumpArgs), " "))
+	fmt.Println("Begin dump", outputFileName, "at", utils.NowStr())
+
+	ec := utils.LaunchApp(toolName, dumpArgs, envVars, false)
+	if ec != 0 {
+		fmt.Println("Failed to dump", outputFileName, "at", utils.NowStr())
+		os.Exit(ec)
+	}
+
+	fmt.Println("Finished dump", outputFileName, "at", utils.NowStr())
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ephemeralPgPort     = 5432
+	ephemeralPgUserName = "postgres"
+)
+
+// ephemeralPgServer is a local PostgreSQL server initialized in a temporary directory,
+// it only listens on a unix socket within the same directory, authentication is not required.
+type ephemeralPgServer struct {
+	baseDir string
+	dataDir string
+	pgCtl   string
+}
+
+// startEphemeralPgServer initializes and starts a PostgreSQL server in a temporary directory.
+// The initdb and pg_ctl utilities next to the pg_* utility are preferred.
+func startEphemeralPgServer(pgToolName string) (*ephemeralPgServer, error) {
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("PostgreSQL server can not be started by root, please run as a non-root user")
+	}
+
+	initDb, err := findPgServerTool(pgToolName, "initdb")
+	if err != nil {
+		return nil, err
+	}
+
+	pgCtl, err := findPgServerTool(pgToolName, "pg_ctl")
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir, err := os.MkdirTemp("", "hkd-pg-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary directory")
+	}
+
+	server := &ephemeralPgServer{
+		baseDir: baseDir,
+		dataDir: path.Join(baseDir, "data"),
+		pgCtl:   pgCtl,
+	}
+
+	fmt.Println("Initializing temporary PostgreSQL server at", server.dataDir)
+	initDbArgs := []string{
+		fmt.Sprintf("--pgdata=%s", server.dataDir),
+		fmt.Sprintf("--username=%s", ephemeralPgUserName),
+		"--auth=trust",
+		"--encoding=UTF8",
+		"--no-sync",
+	}
+	if ec := utils.LaunchApp(initDb, initDbArgs, nil, false); ec != 0 {
+		server.cleanup()
+		return nil, fmt.Errorf("failed to initialize PostgreSQL data directory, exit code %d", ec)
+	}
+
+	// TCP is disabled, the port only determines name of the socket file
+	serverOptions := fmt.Sprintf("-c listen_addresses='' -p %d -k %s", ephemeralPgPort, utils.ShellQuote(baseDir))
+	startArgs := []string{
+		fmt.Sprintf("--pgdata=%s", server.dataDir),
+		fmt.Sprintf("--log=%s", path.Join(baseDir, "server.log")),
+		"--wait",
+		"-o", serverOptions,
+		"start",
+	}
+	if ec := utils.LaunchApp(pgCtl, startArgs, nil, false); ec != 0 {
+		server.cleanup()
+		return nil, fmt.Errorf("failed to start PostgreSQL server, exit code %d", ec)
+	}
+
+	fmt.Println("Started temporary PostgreSQL server at", utils.NowStr())
+	return server, nil
+}
+
+// buildConnArgs builds arguments --host/--port/--username to connect to the server
+func (s *ephemeralPgServer) buildConnArgs() []string {
+	return []string{
+		fmt.Sprintf("--host=%s", s.baseDir),
+		fmt.Sprintf("--port=%d", ephemeralPgPort),
+		fmt.Sprintf("--username=%s", ephemeralPgUserName),
+	}
+}
+
+// stop stops the server and removes the temporary directory
+func (s *ephemeralPgServer) stop() {
+	stopArgs := []string{
+		fmt.Sprintf("--pgdata=%s", s.dataDir),
+		"--mode=fast",
+		"--wait",
+		"stop",
+	}
+	if ec := utils.LaunchApp(s.pgCtl, stopArgs, nil, false); ec != 0 {
+		fmt.Println("Failed to stop temporary PostgreSQL server, data directory is kept at", s.dataDir)
+		return
+	}
+
+	s.cleanup()
+	fmt.Println("Stopped temporary PostgreSQL server at", utils.NowStr())
+}
+
+func (s *ephemeralPgServer) cleanup() {
+	if err := os.RemoveAll(s.baseDir); err != nil {
+		fmt.Println("Failed to remove temporary directory", s.baseDir, err)
+	}
+}
+
+// findPgServerTool finds PostgreSQL server utility like initdb, pg_ctl.
+// Lookup order: same directory as the provided pg_* utility, PATH, then /usr/lib/postgresql/<version>/bin (Debian/Ubuntu).
+func findPgServerTool(pgToolName string, toolName string) (string, error) {
+	if strings.Contains(pgToolName, "/") {
+		sibling := path.Join(path.Dir(pgToolName), toolName)
+		if exists, _ := utils.IsFileAndExists(sibling); exists {
+			return sibling, nil
+		}
+	}
+
+	if found, err := exec.LookPath(toolName); err == nil {
+		return found, nil
+	}
+
+	candidates, _ := filepath.Glob(path.Join("/usr/lib/postgresql", "*", "bin", toolName))
+	if len(candidates) > 0 {
+		// prefer the latest major version
+		sort.Slice(candidates, func(i, j int) bool {
+			return pgVersionOfLibPath(candidates[i]) > pgVersionOfLibPath(candidates[j])
+		})
+		return candidates[0], nil
+	}
+
+	return "", fmt.Errorf("%s could not be found, PostgreSQL server package is required", toolName)
+}
+
+// pgVersionOfLibPath extracts the major version from path like /usr/lib/postgresql/<version>/bin/initdb
+func pgVersionOfLibPath(toolPath string) float64 {
+	version := path.Base(path.Dir(path.Dir(toolPath)))
+	v, err := strconv.ParseFloat(version, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func Test_pgVersionOfLibPath(t *testing.T) {
+	tests := []struct {
+		toolPath string
+		want     float64
+	}{
+		{
+			toolPath: "/usr/lib/postgresql/16/bin/initdb",
+			want:     16,
+		},
+		{
+			toolPath: "/usr/lib/postgresql/9.6/bin/initdb",
+			want:     9.6,
+		},
+		{
+			toolPath: "/usr/lib/postgresql/unknown/bin/initdb",
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.toolPath, func(t *testing.T) {
+			if got := pgVersionOfLibPath(tt.toolPath); got != tt.want {
+				t.Errorf("pgVersionOfLibPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,19 +2,31 @@ package db
 
 import (
 	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"os"
 	"strings"
+	"time"
 )
 
 //goland:noinspection SpellCheckingInspection
 const (
-	flagNoPubSub  = "no-pubsub"
-	flagDataOnly  = "data-only"
-	flagSuperUser = "superuser"
+	flagNoPubSub      = "no-pubsub"
+	flagDataOnly      = "data-only"
+	flagSuperUser     = "superuser"
+	flagCreateDb      = "create-db"
+	flagDropExisting  = "drop-existing"
+	flagConfirmDrop   = "confirm-drop"
+	flagRestoreAs     = "restore-as"
+	flagMaintenanceDb = "maintenance-db"
+)
+
+const (
+	restoringDbNameSuffix = "_hkd_restoring"
+	oldDbNameSuffix       = "_hkd_old_"
 )
 
 // PgRestoreCommands registers a sub-tree of commands
@@ -22,14 +34,37 @@ func PgRestoreCommands() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "pg_restore [file_name]",
 		Short: "Restore DB using backup file (PostgreSQL)",
-		Args:  cobra.ExactArgs(1),
-		Run:   restorePgDatabase,
+		Long: fmt.Sprintf(`Restore DB using backup file (PostgreSQL).
+- Restore data into existing database (default mode):
+> %s db pg_restore db.dump --dbname example --superuser postgres
+- Restore into a fresh database:
+> %s db pg_restore db.dump --dbname example --%s
+- Re-create the database then restore:
+> %s db pg_restore db.dump --dbname example --%s --%s
+- Refresh a database without downtime, dump is restored into a temporary database then names are swapped atomically:
+> %s db pg_restore db.dump --%s staging --%s
+
+Note:
+- When --%s or --%s is used, full restore is performed (--%s=false) unless specified explicitly.
+- Dropping database requires typing the database name to confirm, or supply it in advance via flag --%s.
+- Without --%s, the previous database of --%s is kept with name suffixed by '%s<time>'.
+`,
+			constants.BINARY_NAME,
+			constants.BINARY_NAME, flagCreateDb,
+			constants.BINARY_NAME, flagCreateDb, flagDropExisting,
+			constants.BINARY_NAME, flagRestoreAs, flagDropExisting,
+			flagCreateDb, flagRestoreAs, flagDataOnly,
+			flagConfirmDrop,
+			flagDropExisting, flagRestoreAs, oldDbNameSuffix,
+		),
+		Args: cobra.ExactArgs(1),
+		Run:  restorePgDatabase,
 	}
 
 	cmd.PersistentFlags().String(
 		flagPasswordFile,
 		"",
-		"file path which store password of the user which will be used to backup the database",
+		fmt.Sprintf("file path which store password of the user which will be used to backup the database, %s", secrets.SourceUsage),
 	)
 
 	cmd.PersistentFlags().String(
@@ -56,47 +91,97 @@ func PgRestoreCommands() *cobra.Command {
 		"do not output commands to restore publications/subscriptions, even if the archive contains them.",
 	)
 
+	cmd.PersistentFlags().Bool(
+		flagCreateDb,
+		false,
+		"create the database before restoring",
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagDropExisting,
+		false,
+		fmt.Sprintf("drop the existing database, used with --%s or --%s, requires typing the database name to confirm", flagCreateDb, flagRestoreAs),
+	)
+
+	cmd.PersistentFlags().String(
+		flagConfirmDrop,
+		"",
+		fmt.Sprintf("provide the name of the database to be dropped in advance to skip typed confirmation of --%s", flagDropExisting),
+	)
+
+	cmd.PersistentFlags().String(
+		flagRestoreAs,
+		"",
+		"restore into a temporary database then atomically rename it to the specified name, the existing database with the same name will be renamed",
+	)
+
+	cmd.PersistentFlags().String(
+		flagMaintenanceDb,
+		"postgres",
+		"database to connect to when creating/dropping/renaming databases",
+	)
+
 	return cmd
 }
 
 func restorePgDatabase(cmd *cobra.Command, args []string) {
-	inputFilePath := args[0]
-	inputFilePath = strings.TrimSpace(inputFilePath)
-	if len(inputFilePath) < 1 {
-		panic("bad input backup file")
-	}
+	inputFilePath := readInputBackupFile(args)
 
-	// workingDir := utils.ReadFlagWorkingDir(cmd)
+	conn := readPgConnection(cmd)
 
-	_, err := os.Stat(inputFilePath)
-	if err == nil {
-		// ok
-	} else {
-		if os.IsNotExist(err) {
-			panic("bad input backup file, file does not exists")
-		} else {
-			panic(errors.Wrap(err, fmt.Sprintf("problem when checking input file %s", inputFilePath)))
-		}
+	dbName := conn.dbName
+	if len(dbName) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagDbName))
 	}
 
-	host, _ := cmd.Flags().GetString(flagHost)
-	host = strings.TrimSpace(host)
+	createDb, _ := cmd.Flags().GetBool(flagCreateDb)
+	dropExisting, _ := cmd.Flags().GetBool(flagDropExisting)
+	confirmDrop, _ := cmd.Flags().GetString(flagConfirmDrop)
+	confirmDrop = strings.TrimSpace(confirmDrop)
+
+	restoreAs, _ := cmd.Flags().GetString(flagRestoreAs)
+	restoreAs = strings.TrimSpace(restoreAs)
 
-	port, _ := cmd.Flags().GetUint16(flagPort)
-	if port == 0 {
-		panic(fmt.Errorf("missing value for mandatory flag --%s", flagPort))
+	maintenanceDb, _ := cmd.Flags().GetString(flagMaintenanceDb)
+	maintenanceDb = strings.TrimSpace(maintenanceDb)
+
+	if len(restoreAs) > 0 {
+		if createDb {
+			panic(fmt.Errorf("flag --%s is not allowed when --%s is used, database is always created", flagCreateDb, flagRestoreAs))
+		}
+		if cmd.Flags().Changed(flagDbName) {
+			panic(fmt.Errorf("flag --%s is not allowed when --%s is used", flagDbName, flagRestoreAs))
+		}
+		if len(restoreAs)+len(oldDbNameSuffix)+len("20060102150405") > maxPgIdentifierLength {
+			panic(fmt.Errorf("database name provided by flag --%s is too long", flagRestoreAs))
+		}
+	} else if dropExisting && !createDb {
+		panic(fmt.Errorf("flag --%s requires either --%s or --%s", flagDropExisting, flagCreateDb, flagRestoreAs))
 	}
 
-	dbName, _ := cmd.Flags().GetString(flagDbName)
-	dbName = strings.TrimSpace(dbName)
-	if len(dbName) < 1 {
-		panic(fmt.Errorf("missing value for mandatory flag --%s", flagDbName))
+	if len(confirmDrop) > 0 && !dropExisting {
+		panic(fmt.Errorf("flag --%s requires --%s", flagConfirmDrop, flagDropExisting))
 	}
 
-	userName, _ := cmd.Flags().GetString(flagUsername)
-	userName = strings.TrimSpace(userName)
+	isFreshDb := createDb || len(restoreAs) > 0
+	if isFreshDb {
+		if conn.isConnString() {
+			panic(fmt.Errorf("connection URI is not supported when restoring into a fresh database, use --%s/--%s/--%s or --%s instead", flagHost, flagPort, flagUsername, flagService))
+		}
+		if err := validateMaintenanceDb(maintenanceDb, dbName, restoreAs, createDb); err != nil {
+			panic(err)
+		}
+	}
 
 	dataOnly, _ := cmd.Flags().GetBool(flagDataOnly)
+	if isFreshDb {
+		if !cmd.Flags().Changed(flagDataOnly) {
+			// fresh database does not have schema, full restore is required
+			dataOnly = false
+		} else if dataOnly {
+			panic(fmt.Errorf("flag --%s=true is not allowed when restoring into a fresh database", flagDataOnly))
+		}
+	}
 
 	superUser, _ := cmd.Flags().GetString(flagSuperUser)
 	superUser = strings.TrimSpace(superUser)
@@ -116,92 +201,204 @@ func restorePgDatabase(cmd *cobra.Command, args []string) {
 		panic(fmt.Sprintf("not yet supported flag --%s", flagSchema))
 	}
 
-	toolName := "pg_restore"
-	customToolName, _ := cmd.Flags().GetString(flagToolFile)
-	customToolName = strings.TrimSpace(customToolName)
-	if len(customToolName) > 0 {
-		_, err = os.Stat(customToolName)
-		if os.IsNotExist(err) {
-			panic(fmt.Errorf("custom pg_restore file path does not exists: %s", customToolName))
-		}
+	toolName := readToolName(cmd, "pg_restore")
+
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	envVars := conn.buildEnvVars(passwordFile)
+
+	connArgs := conn.buildConnArgs()
 
-		toolName = customToolName
+	noPubSub, _ := cmd.Flags().GetBool(flagNoPubSub)
+	restoreOptions := pgRestoreOptions{
+		connArgs:      connArgs,
+		inputFilePath: inputFilePath,
+		noPubSub:      noPubSub,
+		dataOnly:      dataOnly,
+		superUser:     superUser,
 	}
 
-	var envVars []string
+	launchRestore := func(targetDbName string) bool {
+		return launchPgRestore(toolName, restoreOptions, targetDbName, envVars)
+	}
 
-	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
-	if len(passwordFile) < 1 {
-		if len(strings.TrimSpace(os.Getenv(constants.ENV_PG_PASSWORD))) < 1 {
-			panic(fmt.Errorf("missing password for user %s, either environment variable %s or flag --%s is required", userName, constants.ENV_PG_PASSWORD, flagPasswordFile))
+	if !isFreshDb {
+		if !launchRestore(dbName) {
+			os.Exit(1)
 		}
+		return
+	}
 
-		envVars = os.Environ()
-	} else {
-		fip, err := os.Stat(passwordFile)
-		if os.IsNotExist(err) {
-			panic(fmt.Errorf("supplied password file does not exists %s", passwordFile))
-		}
+	psql := newPsqlRunner(toolName, connArgs, maintenanceDb, envVars)
+
+	if createDb {
+		if psql.isDatabaseExists(dbName) {
+			if !dropExisting {
+				panic(fmt.Errorf("database %s already exists, supply flag --%s to drop it", dbName, flagDropExisting))
+			}
 
-		bz, err := os.ReadFile(passwordFile)
-		if err != nil {
-			panic(errors.Wrap(err, fmt.Sprintf("failed to read password file %s", passwordFile)))
+			confirmDropDatabase(dbName, confirmDrop)
+			fmt.Println("Dropping database", dbName)
+			psql.dropDatabase(dbName)
 		}
 
-		fipPerm := fip.Mode().Perm()
-		errPerm := utils.ValidatePasswordFileMode(fipPerm)
-		if errPerm != nil {
-			fmt.Printf("Incorrect permission '%o' of password file: %s\n", fipPerm, errPerm)
-			fmt.Printf("Suggest setting permission to '%o'\n", constants.RECOMMENDED_FILE_PERMISSION)
+		fmt.Println("Creating database", dbName)
+		psql.createDatabase(dbName)
+
+		if !launchRestore(dbName) {
 			os.Exit(1)
 		}
+		return
+	}
+
+	restoringDbName := restoreAs + restoringDbNameSuffix
+	if psql.isDatabaseExists(restoringDbName) {
+		panic(fmt.Errorf("temporary database %s already exists, probably left from a previous failed restore, drop it manually before retrying", restoringDbName))
+	}
+
+	isTargetExists := psql.isDatabaseExists(restoreAs)
+	if isTargetExists && dropExisting {
+		// confirm before the long-running restore
+		confirmDropDatabase(restoreAs, confirmDrop)
+	}
+
+	fmt.Println("Creating temporary database", restoringDbName)
+	psql.createDatabase(restoringDbName)
+
+	if !launchRestore(restoringDbName) {
+		fmt.Println("Dropping temporary database", restoringDbName)
+		psql.dropDatabase(restoringDbName)
+		os.Exit(1)
+	}
+
+	oldDbName := restoreAs + oldDbNameSuffix + time.Now().Format("20060102150405")
+
+	var swapSql string
+	if isTargetExists {
+		swapSql = fmt.Sprintf(
+			"%s; %s; BEGIN; ALTER DATABASE %s RENAME TO %s; ALTER DATABASE %s RENAME TO %s; COMMIT;",
+			buildTerminateConnectionsSql(restoreAs),
+			buildTerminateConnectionsSql(restoringDbName),
+			quotePgIdentifier(restoreAs), quotePgIdentifier(oldDbName),
+			quotePgIdentifier(restoringDbName), quotePgIdentifier(restoreAs),
+		)
+	} else {
+		swapSql = fmt.Sprintf(
+			"%s; ALTER DATABASE %s RENAME TO %s;",
+			buildTerminateConnectionsSql(restoringDbName),
+			quotePgIdentifier(restoringDbName), quotePgIdentifier(restoreAs),
+		)
+	}
+
+	// new connections may be established right after being terminated, so retry a few times
+	const maxSwapAttempts = 5
+	for attempt := 1; ; attempt++ {
+		if psql.tryExec(swapSql) {
+			break
+		}
 
-		pgPassword := strings.TrimSpace(string(bz))
-		if len(pgPassword) < 1 {
-			panic(fmt.Errorf("password file is empty: %s", passwordFile))
+		if attempt >= maxSwapAttempts {
+			fmt.Println("Failed to rename database at", utils.NowStr())
+			fmt.Println("Restored data is kept in database", restoringDbName)
+			os.Exit(1)
 		}
 
-		envVars = append(envVars, fmt.Sprintf("%s=%s", constants.ENV_PG_PASSWORD, pgPassword))
+		fmt.Println("Retry renaming database in 1 second...")
+		time.Sleep(time.Second)
 	}
 
-	restoreArgs := make([]string, 0)
-	if len(host) > 0 {
-		restoreArgs = append(restoreArgs, fmt.Sprintf("--host=%s", host))
+	fmt.Println("Restored database is now available as", restoreAs)
+
+	if !isTargetExists {
+		return
 	}
-	if port > 0 {
-		restoreArgs = append(restoreArgs, fmt.Sprintf("--port=%d", port))
+
+	if dropExisting {
+		fmt.Println("Dropping previous database", oldDbName)
+		psql.dropDatabase(oldDbName)
+	} else {
+		fmt.Println("Previous database is kept as", oldDbName)
 	}
-	if len(userName) > 0 {
-		restoreArgs = append(restoreArgs, fmt.Sprintf("--username=%s", userName))
+}
+
+// readInputBackupFile reads the backup file path from the first argument, the file must exist
+func readInputBackupFile(args []string) string {
+	inputFilePath := args[0]
+	inputFilePath = strings.TrimSpace(inputFilePath)
+	if len(inputFilePath) < 1 {
+		panic("bad input backup file")
+	}
+
+	_, err := os.Stat(inputFilePath)
+	if err == nil {
+		// ok
+	} else {
+		if os.IsNotExist(err) {
+			panic("bad input backup file, file does not exists")
+		} else {
+			panic(errors.Wrap(err, fmt.Sprintf("problem when checking input file %s", inputFilePath)))
+		}
 	}
-	restoreArgs = append(restoreArgs, fmt.Sprintf("--dbname=%s", dbName))
+
+	return inputFilePath
+}
+
+// pgRestoreOptions holds the options used to build arguments for pg_restore
+type pgRestoreOptions struct {
+	connArgs      []string
+	inputFilePath string
+	noPubSub      bool
+	dataOnly      bool
+	superUser     string
+}
+
+func (o pgRestoreOptions) buildArgs(targetDbName string) []string {
+	restoreArgs := append([]string{}, o.connArgs...)
+	restoreArgs = append(restoreArgs, fmt.Sprintf("--dbname=%s", targetDbName))
 	restoreArgs = append(restoreArgs, "--single-transaction")
-	noPubSub, _ := cmd.Flags().GetBool(flagNoPubSub)
-	if noPubSub {
+	if o.noPubSub {
 		restoreArgs = append(restoreArgs, "--no-publications")
 		restoreArgs = append(restoreArgs, "--no-subscriptions")
 	}
 	restoreArgs = append(restoreArgs, "--no-owner")
-	if dataOnly {
+	if o.dataOnly {
 		restoreArgs = append(restoreArgs, "--data-only")
 		restoreArgs = append(restoreArgs, "--disable-triggers")
-		if len(superUser) > 0 {
-			restoreArgs = append(restoreArgs, fmt.Sprintf("--superuser=%s", superUser))
+		if len(o.superUser) > 0 {
+			restoreArgs = append(restoreArgs, fmt.Sprintf("--superuser=%s", o.superUser))
 		} else {
 			panic("require superuser")
 		}
 	}
-	restoreArgs = append(restoreArgs, inputFilePath)
+	restoreArgs = append(restoreArgs, o.inputFilePath)
+	return restoreArgs
+}
+
+// launchPgRestore restores the backup file into the target database using pg_restore, returns true if success
+func launchPgRestore(toolName string, opts pgRestoreOptions, targetDbName string, envVars []string) bool {
+	restoreArgs := opts.buildArgs(targetDbName)
 
-	fmt.Println("Input file:", inputFilePath)
-	fmt.Println("Restore arguments:\n", toolName, strings.Join(restoreArgs, " "))
-	fmt.Println("Begin restore", inputFilePath, "at", utils.NowStr())
+	fmt.Println("Input file:", opts.inputFilePath)
+	fmt.Println("Restore arguments:\n", toolName, strings.Join(redactPgArgs(restoreArgs), " "))
+	fmt.Println("Begin restore", opts.inputFilePath, "at", utils.NowStr())
 
 	ec := utils.LaunchApp(toolName, restoreArgs, envVars, false)
 	if ec != 0 {
-		fmt.Println("Failed to restore", inputFilePath, "at", utils.NowStr())
-		os.Exit(ec)
+		fmt.Println("Failed to restore", opts.inputFilePath, "at", utils.NowStr())
+		return false
 	}
 
-	fmt.Println("Finished restore", inputFilePath, "at", utils.NowStr())
+	fmt.Println("Finished restore", opts.inputFilePath, "at", utils.NowStr())
+	return true
+}
+
+// validateMaintenanceDb validates the maintenance database is different from the database to be created.
+// With --restore-as, --dbname is not used as the target so it is not compared.
+func validateMaintenanceDb(maintenanceDb, dbName, restoreAs string, createDb bool) error {
+	if len(maintenanceDb) < 1 {
+		return fmt.Errorf("missing value for mandatory flag --%s", flagMaintenanceDb)
+	}
+	if (createDb && maintenanceDb == dbName) || maintenanceDb == restoreAs {
+		return fmt.Errorf("maintenance database provided by flag --%s must be different from the database to be restored", flagMaintenanceDb)
+	}
+	return nil
 }
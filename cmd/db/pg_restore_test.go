@@ -0,0 +1,103 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_pgRestoreOptions_buildArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		options pgRestoreOptions
+		want    []string
+	}{
+		{
+			name: "full restore",
+			options: pgRestoreOptions{
+				connArgs:      []string{"--host=localhost"},
+				inputFilePath: "db.dump",
+				noPubSub:      true,
+			},
+			want: []string{"--host=localhost", "--dbname=drill", "--single-transaction", "--no-publications", "--no-subscriptions", "--no-owner", "db.dump"},
+		},
+		{
+			name: "data only",
+			options: pgRestoreOptions{
+				inputFilePath: "db.dump",
+				dataOnly:      true,
+				superUser:     "postgres",
+			},
+			want: []string{"--dbname=drill", "--single-transaction", "--no-owner", "--data-only", "--disable-triggers", "--superuser=postgres", "db.dump"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.buildArgs("drill"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateMaintenanceDb(t *testing.T) {
+	// default values of flags
+	root := Commands()
+	restoreCmd, _, err := root.Find([]string{"pg_restore"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreCmd.ParseFlags([]string{"--" + flagRestoreAs, "staging"}); err != nil {
+		t.Fatal(err)
+	}
+	defaultDbName, _ := restoreCmd.Flags().GetString(flagDbName)
+	defaultMaintenanceDb, _ := restoreCmd.Flags().GetString(flagMaintenanceDb)
+
+	tests := []struct {
+		name          string
+		maintenanceDb string
+		dbName        string
+		restoreAs     string
+		createDb      bool
+		wantErr       bool
+	}{
+		{
+			name:          "restore-as with default flags",
+			maintenanceDb: defaultMaintenanceDb,
+			dbName:        defaultDbName,
+			restoreAs:     "staging",
+		},
+		{
+			name:          "restore-as the maintenance database",
+			maintenanceDb: defaultMaintenanceDb,
+			dbName:        defaultDbName,
+			restoreAs:     defaultMaintenanceDb,
+			wantErr:       true,
+		},
+		{
+			name:          "create-db",
+			maintenanceDb: defaultMaintenanceDb,
+			dbName:        "staging",
+			createDb:      true,
+		},
+		{
+			name:          "create-db the maintenance database",
+			maintenanceDb: defaultMaintenanceDb,
+			dbName:        defaultMaintenanceDb,
+			createDb:      true,
+			wantErr:       true,
+		},
+		{
+			name:     "missing maintenance database",
+			dbName:   "staging",
+			createDb: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMaintenanceDb(tt.maintenanceDb, tt.dbName, tt.restoreAs, tt.createDb); (err != nil) != tt.wantErr {
+				t.Errorf("validateMaintenanceDb() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxPgIdentifierLength is the maximum length of identifiers in PostgreSQL (NAMEDATALEN - 1)
+const maxPgIdentifierLength = 63
+
+// psqlRunner executes administrative SQL statements using psql
+type psqlRunner struct {
+	toolName string
+	connArgs []string
+	dbName   string
+	envVars  []string
+}
+
+// newPsqlRunner creates a psqlRunner which connects to the given database.
+// If the pg_* utility is a custom tool file, psql at the same directory is preferred.
+func newPsqlRunner(pgToolName string, connArgs []string, dbName string, envVars []string) psqlRunner {
+	toolName := "psql"
+	if strings.Contains(pgToolName, "/") {
+		siblingPsql := path.Join(path.Dir(pgToolName), "psql")
+		if exists, _ := utils.IsFileAndExists(siblingPsql); exists {
+			toolName = siblingPsql
+		}
+	}
+
+	return psqlRunner{
+		toolName: toolName,
+		connArgs: connArgs,
+		dbName:   dbName,
+		envVars:  envVars,
+	}
+}
+
+func (r psqlRunner) buildArgs(sql string) []string {
+	args := append([]string{}, r.connArgs...)
+	args = append(args, fmt.Sprintf("--dbname=%s", r.dbName))
+	args = append(args, "--no-psqlrc", "--set=ON_ERROR_STOP=1", "--quiet")
+	return append(args, "--command", sql)
+}
+
+// tryExec executes SQL and returns true if success
+func (r psqlRunner) tryExec(sql string) bool {
+	fmt.Println("Executing SQL:", sql)
+	return utils.LaunchApp(r.toolName, r.buildArgs(sql), r.envVars, false) == 0
+}
+
+// exec executes SQL, program exits if failed
+func (r psqlRunner) exec(sql string) {
+	if !r.tryExec(sql) {
+		fmt.Println("Failed to execute SQL at", utils.NowStr())
+		os.Exit(1)
+	}
+}
+
+// queryLines executes SQL and returns the output rows, columns are separated by tab.
+// Program exits if failed.
+func (r psqlRunner) queryLines(sql string) []string {
+	lines, ok := r.tryQueryLines(sql)
+	if !ok {
+		fmt.Println("Failed to execute SQL:", sql)
+		os.Exit(1)
+	}
+	return lines
+}
+
+// tryQueryLines executes SQL and returns the output rows, columns are separated by tab.
+// The second returned value is false if the execution failed.
+func (r psqlRunner) tryQueryLines(sql string) ([]string, bool) {
+	args := append(r.buildArgs(sql), "--no-align", "--tuples-only", "--field-separator=\t")
+	output, ec := utils.LaunchAppAndCaptureOutput(r.toolName, args, r.envVars)
+	if ec != 0 {
+		return nil, false
+	}
+
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, true
+}
+
+func (r psqlRunner) isDatabaseExists(dbName string) bool {
+	return len(r.queryLines(fmt.Sprintf("SELECT 1 FROM pg_database WHERE datname = %s", quotePgLiteral(dbName)))) > 0
+}
+
+func (r psqlRunner) createDatabase(dbName string) {
+	r.exec(fmt.Sprintf("CREATE DATABASE %s", quotePgIdentifier(dbName)))
+}
+
+func (r psqlRunner) dropDatabase(dbName string) {
+	r.terminateConnections(dbName)
+	r.exec(fmt.Sprintf("DROP DATABASE %s", quotePgIdentifier(dbName)))
+}
+
+// terminateConnections terminates all other connections to the database
+func (r psqlRunner) terminateConnections(dbName string) {
+	r.exec(buildTerminateConnectionsSql(dbName))
+}
+
+func buildTerminateConnectionsSql(dbName string) string {
+	return fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = %s AND pid <> pg_backend_pid()", quotePgLiteral(dbName))
+}
+
+// quotePgIdentifier quotes identifier to be used in SQL statement
+func quotePgIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// quotePgLiteral quotes string literal to be used in SQL statement
+func quotePgLiteral(literal string) string {
+	return "'" + strings.ReplaceAll(literal, "'", "''") + "'"
+}
+
+// confirmDropDatabase requires user to type the database name to confirm dropping it,
+// confirmation can be provided in advance via flag.
+func confirmDropDatabase(dbName, providedConfirmation string) {
+	if len(providedConfirmation) > 0 {
+		if providedConfirmation != dbName {
+			fmt.Printf("Aborted! Confirmation '%s' provided via flag --%s does not match database name '%s'\n", providedConfirmation, flagConfirmDrop, dbName)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("Database '%s' will be DROPPED, type the database name to confirm:\n", dbName)
+
+	reader := bufio.NewReader(os.Stdin)
+	text, _ := reader.ReadString('\n')
+	text = strings.TrimSpace(text)
+
+	if text != dbName {
+		fmt.Printf("Aborted! '%s' does not match database name '%s'\n", text, dbName)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import "testing"
+
+func Test_quotePgIdentifier(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       string
+	}{
+		{
+			identifier: "example",
+			want:       `"example"`,
+		},
+		{
+			identifier: "Example DB",
+			want:       `"Example DB"`,
+		},
+		{
+			identifier: `ex"ample`,
+			want:       `"ex""ample"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			if got := quotePgIdentifier(tt.identifier); got != tt.want {
+				t.Errorf("quotePgIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_quotePgLiteral(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    string
+	}{
+		{
+			literal: "example",
+			want:    "'example'",
+		},
+		{
+			literal: "it's",
+			want:    "'it''s'",
+		},
+		{
+			literal: "'; DROP DATABASE postgres; --",
+			want:    "'''; DROP DATABASE postgres; --'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			if got := quotePgLiteral(tt.literal); got != tt.want {
+				t.Errorf("quotePgLiteral() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const (
+	flagStopOnError = "stop-on-error"
+)
+
+// PsqlRestoreCommands registers a sub-tree of commands
+func PsqlRestoreCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "psql_restore [file_name]",
+		Short: "Restore globals or the entire cluster from plain SQL file produced by pg_dumpall (PostgreSQL)",
+		Long: `Restore globals or the entire cluster from plain SQL file produced by pg_dumpall (PostgreSQL).
+The SQL file is applied using psql, connected to the database provided via flag --dbname (default: postgres).
+
+Note: by default, errors like "role already exists" are printed and skipped, supply --stop-on-error to stop at the first error.`,
+		Args: cobra.ExactArgs(1),
+		Run:  restorePgCluster,
+	}
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password of the user which will be used to restore, %s", secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the psql utility",
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagStopOnError,
+		false,
+		"stop at the first error",
+	)
+
+	return cmd
+}
+
+func restorePgCluster(cmd *cobra.Command, args []string) {
+	inputFilePath := strings.TrimSpace(args[0])
+	if len(inputFilePath) < 1 {
+		panic("bad input SQL file")
+	}
+
+	_, err := os.Stat(inputFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			panic("bad input SQL file, file does not exists")
+		}
+
+		panic(errors.Wrap(err, fmt.Sprintf("problem when checking input file %s", inputFilePath)))
+	}
+
+	conn := readPgConnection(cmd)
+
+	if len(conn.dbName) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagDbName))
+	}
+
+	toolName := readToolName(cmd, "psql")
+
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	envVars := conn.buildEnvVars(passwordFile)
+
+	restoreArgs := conn.buildConnArgs()
+	restoreArgs = append(restoreArgs, fmt.Sprintf("--dbname=%s", conn.dbName))
+	restoreArgs = append(restoreArgs, "--no-psqlrc")
+	stopOnError, _ := cmd.Flags().GetBool(flagStopOnError)
+	if stopOnError {
+		restoreArgs = append(restoreArgs, "--set=ON_ERROR_STOP=1")
+	}
+	restoreArgs = append(restoreArgs, fmt.Sprintf("--file=%s", inputFilePath))
+
+	fmt.Println("Input file:", inputFilePath)
+	fmt.Println("Restore arguments:\n", toolName, strings.Join(redactPgArgs(restoreArgs), " "))
+	fmt.Println("Begin restore", inputFilePath, "at", utils.NowStr())
+
+	ec := utils.LaunchApp(toolName, restoreArgs, envVars, false)
+	if ec != 0 {
+		fmt.Println("Failed to restore", inputFilePath, "at", utils.NowStr())
+		os.Exit(ec)
+	}
+
+	fmt.Println("Finished restore", inputFilePath, "at", utils.NowStr())
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flagRdbFile         = "rdb-file"
+	flagSnapshotTimeout = "timeout"
+
+	defaultRedisPort = 6379
+)
+
+var regexRedisErrorReply = regexp.MustCompile(`^(ERR|NOAUTH|WRONGPASS|NOPERM|LOADING|MISCONF|BUSY|READONLY)\b`)
+
+// RedisSnapshotCommands registers a sub-tree of commands
+func RedisSnapshotCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "redis-snapshot",
+		Short: "Backup Redis by triggering BGSAVE and copying the RDB file",
+		Long: fmt.Sprintf(`Backup Redis by triggering BGSAVE, waiting for it to finish and copying the RDB file into working directory.
+Default port is %d, flag --%s is only used if specified explicitly (ACL user).
+The RDB file location is queried using CONFIG GET dir/dbfilename, or provided via flag --%s, it must be accessible from this machine.`, defaultRedisPort, flagUsername, flagRdbFile),
+		Args: cobra.NoArgs,
+		Run:  snapshotRedis,
+	}
+
+	cmd.PersistentFlags().String(
+		flagOutputFile,
+		dailyFileName("redis", "rdb"),
+		"specify name of the output backup file, file name only, default has layout: redis-yyyy-MM-dd.rdb",
+	)
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password to connect to Redis, environment variable %s is used if not provided, %s", constants.ENV_REDIS_PASSWORD, secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the redis-cli utility",
+	)
+
+	cmd.PersistentFlags().String(
+		flagRdbFile,
+		"",
+		"path of the RDB file produced by Redis, default is queried from Redis server",
+	)
+
+	cmd.PersistentFlags().Duration(
+		flagSnapshotTimeout,
+		30*time.Minute,
+		"maximum time to wait for BGSAVE to finish",
+	)
+
+	return cmd
+}
+
+func snapshotRedis(cmd *cobra.Command, _ []string) {
+	outputFileName := readOutputFileName(cmd)
+	outputFilePath := resolveOutputFilePath(cmd, outputFileName)
+
+	host, _ := cmd.Flags().GetString(flagHost)
+	host = strings.TrimSpace(host)
+
+	port := uint16(defaultRedisPort)
+	if cmd.Flags().Changed(flagPort) {
+		port, _ = cmd.Flags().GetUint16(flagPort)
+		if port == 0 {
+			panic(fmt.Errorf("missing value for mandatory flag --%s", flagPort))
+		}
+	}
+
+	var userName string
+	if cmd.Flags().Changed(flagUsername) {
+		userName, _ = cmd.Flags().GetString(flagUsername)
+		userName = strings.TrimSpace(userName)
+	}
+
+	timeout, _ := cmd.Flags().GetDuration(flagSnapshotTimeout)
+	if timeout <= 0 {
+		panic(fmt.Errorf("bad value for flag --%s", flagSnapshotTimeout))
+	}
+
+	toolName := readToolName(cmd, "redis-cli")
+
+	envVars := os.Environ()
+	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+	if len(passwordFile) > 0 {
+		password := secrets.ReadSecret(passwordFile)
+		envVars = utils.OverlayEnvVars(envVars, fmt.Sprintf("%s=%s", constants.ENV_REDIS_PASSWORD, password))
+	}
+
+	connArgs := []string{"-h", host, "-p", fmt.Sprintf("%d", port), "--no-auth-warning"}
+	if len(userName) > 0 {
+		connArgs = append(connArgs, "--user", userName)
+	}
+
+	redisCli := func(args ...string) string {
+		output, ec := utils.LaunchAppAndCaptureOutput(toolName, append(append([]string{}, connArgs...), args...), envVars)
+		output = strings.TrimSpace(output)
+		if ec != 0 {
+			fmt.Println("Failed to execute Redis command", strings.Join(args, " "), "at", utils.NowStr())
+			os.Exit(ec)
+		}
+		if regexRedisErrorReply.MatchString(output) {
+			panic(fmt.Errorf("redis command %s returns error: %s", strings.Join(args, " "), output))
+		}
+		return output
+	}
+
+	readLastSave := func() int64 {
+		output := redisCli("LASTSAVE")
+		lastSave, err := strconv.ParseInt(output, 10, 64)
+		if err != nil {
+			panic(errors.Wrap(err, fmt.Sprintf("failed to parse output of LASTSAVE: %s", output)))
+		}
+		return lastSave
+	}
+
+	rdbFilePath, _ := cmd.Flags().GetString(flagRdbFile)
+	rdbFilePath = strings.TrimSpace(rdbFilePath)
+	if len(rdbFilePath) < 1 {
+		dir := parseRedisConfigGetReply(redisCli("CONFIG", "GET", "dir"))
+		dbFileName := parseRedisConfigGetReply(redisCli("CONFIG", "GET", "dbfilename"))
+		if len(dir) < 1 || len(dbFileName) < 1 {
+			panic(fmt.Errorf("failed to query RDB file location from Redis server, provide it via flag --%s", flagRdbFile))
+		}
+		rdbFilePath = path.Join(dir, dbFileName)
+	}
+
+	fmt.Println("RDB file:", rdbFilePath)
+	fmt.Println("Output file:", outputFilePath)
+	fmt.Println("Begin snapshot", outputFileName, "at", utils.NowStr())
+
+	lastSaveBefore := readLastSave()
+
+	fmt.Println("Triggering BGSAVE:", redisCli("BGSAVE"))
+
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(time.Second)
+
+		persistence := parseRedisInfo(redisCli("INFO", "persistence"))
+		finished, err := checkRedisBgsaveFinished(persistence, readLastSave(), lastSaveBefore)
+		if err != nil {
+			fmt.Println(err.Error(), "at", utils.NowStr())
+			os.Exit(1)
+		}
+		if finished {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			fmt.Println("Timed out waiting for BGSAVE to finish at", utils.NowStr())
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println("BGSAVE finished at", utils.NowStr())
+
+	copyFile(rdbFilePath, outputFilePath)
+
+	fmt.Println("Finished snapshot", outputFileName, "at", utils.NowStr())
+}
+
+// checkRedisBgsaveFinished checks whether BGSAVE finished, based on output of INFO persistence and LASTSAVE.
+// LASTSAVE only advances after a successful save, so failure is detected via rdb_last_bgsave_status.
+func checkRedisBgsaveFinished(persistence map[string]string, lastSave, lastSaveBefore int64) (finished bool, err error) {
+	if persistence["rdb_bgsave_in_progress"] != "0" {
+		return false, nil
+	}
+
+	status := persistence["rdb_last_bgsave_status"]
+	if status == "err" {
+		return false, fmt.Errorf("BGSAVE failed with status %s, check logs of Redis server for details", status)
+	}
+
+	if lastSave > lastSaveBefore {
+		if status != "ok" {
+			return false, fmt.Errorf("BGSAVE finished with status %s", status)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// parseRedisConfigGetReply parses raw reply of CONFIG GET, which has 2 lines: key and value
+func parseRedisConfigGetReply(reply string) string {
+	lines := strings.Split(strings.TrimSpace(reply), "\n")
+	if len(lines) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(lines[1])
+}
+
+// parseRedisInfo parses raw reply of INFO command, lines of key:value
+func parseRedisInfo(reply string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spl := strings.SplitN(line, ":", 2)
+		if len(spl) != 2 {
+			continue
+		}
+		info[spl[0]] = spl[1]
+	}
+	return info
+}
+
+// copyFile copies source file into the destination file which must not exist,
+// the destination file is only readable by owner.
+func copyFile(srcFilePath, destFilePath string) {
+	srcFile, err := os.Open(srcFilePath)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to open file %s, make sure it is accessible from this machine", srcFilePath)))
+	}
+	defer func() {
+		_ = srcFile.Close()
+	}()
+
+	destFile, err := os.OpenFile(destFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, constants.RECOMMENDED_FILE_PERMISSION)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to create output file %s", destFilePath)))
+	}
+
+	written, err := io.Copy(destFile, srcFile)
+	if err == nil {
+		err = destFile.Sync()
+	}
+	if errClose := destFile.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(destFilePath)
+		panic(errors.Wrap(err, fmt.Sprintf("failed to copy %s to %s", srcFilePath, destFilePath)))
+	}
+
+	fmt.Printf("Copied %d bytes from %s to %s\n", written, srcFilePath, destFilePath)
+}
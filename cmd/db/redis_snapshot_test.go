@@ -0,0 +1,107 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseRedisConfigGetReply(t *testing.T) {
+	//goland:noinspection SpellCheckingInspection
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{
+			reply: "dir\n/var/lib/redis\n",
+			want:  "/var/lib/redis",
+		},
+		{
+			reply: "dbfilename\r\ndump.rdb",
+			want:  "dump.rdb",
+		},
+		{
+			reply: "",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			if got := parseRedisConfigGetReply(tt.reply); got != tt.want {
+				t.Errorf("parseRedisConfigGetReply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseRedisInfo(t *testing.T) {
+	reply := "# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:1\r\nrdb_last_bgsave_status:ok\r\n\r\n"
+	want := map[string]string{
+		"loading":                "0",
+		"rdb_bgsave_in_progress": "1",
+		"rdb_last_bgsave_status": "ok",
+	}
+	if got := parseRedisInfo(reply); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRedisInfo() = %v, want %v", got, want)
+	}
+}
+
+func Test_checkRedisBgsaveFinished(t *testing.T) {
+	tests := []struct {
+		name         string
+		persistence  map[string]string
+		lastSave     int64
+		wantFinished bool
+		wantErr      bool
+	}{
+		{
+			name: "in progress",
+			persistence: map[string]string{
+				"rdb_bgsave_in_progress": "1",
+				"rdb_last_bgsave_status": "ok",
+			},
+			lastSave: 100,
+		},
+		{
+			name: "finished successfully",
+			persistence: map[string]string{
+				"rdb_bgsave_in_progress": "0",
+				"rdb_last_bgsave_status": "ok",
+			},
+			lastSave:     101,
+			wantFinished: true,
+		},
+		{
+			name: "failed, LASTSAVE is not advanced",
+			persistence: map[string]string{
+				"rdb_bgsave_in_progress": "0",
+				"rdb_last_bgsave_status": "err",
+			},
+			lastSave: 100,
+			wantErr:  true,
+		},
+		{
+			name: "not started yet",
+			persistence: map[string]string{
+				"rdb_bgsave_in_progress": "0",
+				"rdb_last_bgsave_status": "ok",
+			},
+			lastSave: 100,
+		},
+		{
+			name:     "missing info",
+			lastSave: 101,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finished, err := checkRedisBgsaveFinished(tt.persistence, tt.lastSave, 100)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkRedisBgsaveFinished() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if finished != tt.wantFinished {
+				t.Errorf("checkRedisBgsaveFinished() finished = %v, want %v", finished, tt.wantFinished)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package db
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	flagSanitySql       = "sanity-sql"
+	flagEphemeralServer = "ephemeral-server"
+)
+
+const drillDbNamePrefix = "hkd_drill_"
+
+// RestoreDrillCommands registers a sub-tree of commands
+func RestoreDrillCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore-drill [file_name]",
+		Short: "Validate a backup file (PostgreSQL) by restoring it into a throwaway database",
+		Long: fmt.Sprintf(`Validate a backup file (PostgreSQL) by restoring it into a throwaway database.
+The backup is fully restored (not data-only) into a newly created database, then sanity queries are executed,
+a report is printed and the database is dropped.
+
+By default, row count of every table is reported, and the drill fails if no table was restored.
+Custom sanity queries can be provided via --%s, a query fails if it returns error or the first value is false.
+> %s db restore-drill db.dump --%s 'SELECT count(*) > 0 FROM users'
+- Restore into a temporary local PostgreSQL server (requires initdb & pg_ctl, can not run as root):
+> %s db restore-drill db.dump --%s
+`,
+			flagSanitySql,
+			constants.BINARY_NAME, flagSanitySql,
+			constants.BINARY_NAME, flagEphemeralServer,
+		),
+		Args: cobra.ExactArgs(1),
+		Run:  drillPgRestore,
+	}
+
+	cmd.PersistentFlags().String(
+		flagPasswordFile,
+		"",
+		fmt.Sprintf("file path which store password of the user which will be used to create and restore the database, %s", secrets.SourceUsage),
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the pg_restore utility",
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagNoPubSub,
+		true,
+		"do not output commands to restore publications/subscriptions, even if the archive contains them.",
+	)
+
+	cmd.PersistentFlags().String(
+		flagMaintenanceDb,
+		"postgres",
+		"database to connect to when creating/dropping the throwaway database",
+	)
+
+	cmd.PersistentFlags().StringArray(
+		flagSanitySql,
+		make([]string, 0),
+		fmt.Sprintf("custom sanity query to be executed after restored, can be repeated multiple times, eg: --%s 'SELECT count(*) > 0 FROM users'", flagSanitySql),
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagEphemeralServer,
+		false,
+		"restore into a temporary PostgreSQL server started in a temporary directory, instead of the server provided by connection flags",
+	)
+
+	return cmd
+}
+
+// drillReport holds result of a restore drill
+type drillReport struct {
+	inputFilePath   string
+	drillDbName     string
+	restored        bool
+	restoreDuration time.Duration
+	tableRowCounts  [][2]string
+	tableCountErr   string
+	sanityResults   []drillSanityResult
+}
+
+// drillSanityResult holds result of a sanity query
+type drillSanityResult struct {
+	sql    string
+	output []string
+	passed bool
+}
+
+func (r drillReport) isPassed() bool {
+	if !r.restored || len(r.tableCountErr) > 0 {
+		return false
+	}
+	for _, result := range r.sanityResults {
+		if !result.passed {
+			return false
+		}
+	}
+	return true
+}
+
+func drillPgRestore(cmd *cobra.Command, args []string) {
+	inputFilePath := readInputBackupFile(args)
+
+	if cmd.Flags().Changed(flagDbName) {
+		panic(fmt.Errorf("flag --%s is not allowed, the throwaway database is created with name prefixed by '%s'", flagDbName, drillDbNamePrefix))
+	}
+
+	useEphemeralServer, _ := cmd.Flags().GetBool(flagEphemeralServer)
+	if useEphemeralServer {
+		for _, flag := range []string{flagHost, flagPort, flagUsername, flagService, flagServiceFile, flagPasswordFile, flagMaintenanceDb} {
+			if cmd.Flags().Changed(flag) {
+				panic(fmt.Errorf("flag --%s is not allowed when --%s is used", flag, flagEphemeralServer))
+			}
+		}
+	}
+
+	maintenanceDb, _ := cmd.Flags().GetString(flagMaintenanceDb)
+	maintenanceDb = strings.TrimSpace(maintenanceDb)
+	if len(maintenanceDb) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagMaintenanceDb))
+	}
+
+	var sanitySqlList []string
+	rawSanitySqlList, _ := cmd.Flags().GetStringArray(flagSanitySql)
+	for _, sanitySql := range rawSanitySqlList {
+		sanitySql = strings.TrimSpace(sanitySql)
+		if len(sanitySql) > 0 {
+			sanitySqlList = append(sanitySqlList, sanitySql)
+		}
+	}
+
+	toolName := readToolName(cmd, "pg_restore")
+
+	var server *ephemeralPgServer
+	var connArgs, envVars []string
+	if useEphemeralServer {
+		var err error
+		server, err = startEphemeralPgServer(toolName)
+		libutils.PanicIfErr(err, "failed to start temporary PostgreSQL server")
+
+		connArgs = server.buildConnArgs()
+		envVars = os.Environ()
+	} else {
+		conn := readPgConnection(cmd)
+		if conn.isConnString() {
+			panic(fmt.Errorf("connection URI is not supported, use --%s/--%s/--%s or --%s instead", flagHost, flagPort, flagUsername, flagService))
+		}
+
+		passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
+		envVars = conn.buildEnvVars(passwordFile)
+		connArgs = conn.buildConnArgs()
+	}
+
+	noPubSub, _ := cmd.Flags().GetBool(flagNoPubSub)
+	report := func() drillReport {
+		if server != nil {
+			defer server.stop()
+		}
+
+		return runPgRestoreDrill(toolName, pgRestoreOptions{
+			connArgs:      connArgs,
+			inputFilePath: inputFilePath,
+			noPubSub:      noPubSub,
+			dataOnly:      false,
+		}, maintenanceDb, sanitySqlList, envVars)
+	}()
+
+	report.print()
+
+	if !report.isPassed() {
+		os.Exit(1)
+	}
+}
+
+// runPgRestoreDrill creates a throwaway database, restores into it, runs sanity queries then drops the database
+func runPgRestoreDrill(toolName string, opts pgRestoreOptions, maintenanceDb string, sanitySqlList []string, envVars []string) drillReport {
+	report := drillReport{
+		inputFilePath: opts.inputFilePath,
+		drillDbName:   drillDbNamePrefix + time.Now().Format("20060102150405"),
+	}
+
+	maintenancePsql := newPsqlRunner(toolName, opts.connArgs, maintenanceDb, envVars)
+
+	fmt.Println("Creating throwaway database", report.drillDbName)
+	if !maintenancePsql.tryExec(fmt.Sprintf("CREATE DATABASE %s", quotePgIdentifier(report.drillDbName))) {
+		panic(fmt.Errorf("failed to create database %s", report.drillDbName))
+	}
+
+	defer func() {
+		fmt.Println("Dropping throwaway database", report.drillDbName)
+		if !maintenancePsql.tryExec(fmt.Sprintf("DROP DATABASE %s", quotePgIdentifier(report.drillDbName))) {
+			fmt.Printf("Failed to drop database %s, please drop it manually\n", report.drillDbName)
+		}
+	}()
+
+	restoreStartTime := time.Now()
+	report.restored = launchPgRestore(toolName, opts, report.drillDbName, envVars)
+	report.restoreDuration = time.Since(restoreStartTime)
+	if !report.restored {
+		return report
+	}
+
+	drillPsql := newPsqlRunner(toolName, opts.connArgs, report.drillDbName, envVars)
+
+	tables, ok := drillPsql.tryQueryLines("SELECT quote_ident(schemaname) || '.' || quote_ident(tablename) FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema') ORDER BY 1")
+	if !ok {
+		report.tableCountErr = "failed to list tables"
+	} else if len(tables) < 1 {
+		report.tableCountErr = "no table was restored"
+	} else {
+		for _, table := range tables {
+			lines, ok := drillPsql.tryQueryLines(fmt.Sprintf("SELECT count(*) FROM %s", table))
+			if !ok || len(lines) != 1 {
+				report.tableCountErr = fmt.Sprintf("failed to count rows of table %s", table)
+				break
+			}
+			report.tableRowCounts = append(report.tableRowCounts, [2]string{table, lines[0]})
+		}
+	}
+
+	for _, sanitySql := range sanitySqlList {
+		fmt.Println("Executing sanity query:", sanitySql)
+		lines, ok := drillPsql.tryQueryLines(sanitySql)
+		report.sanityResults = append(report.sanityResults, drillSanityResult{
+			sql:    sanitySql,
+			output: lines,
+			passed: ok && isSanityOutputPassed(lines),
+		})
+	}
+
+	return report
+}
+
+// isSanityOutputPassed returns false if the first value of the query output is false
+func isSanityOutputPassed(lines []string) bool {
+	if len(lines) < 1 {
+		return true
+	}
+
+	firstValue := strings.TrimSpace(strings.Split(lines[0], "\t")[0])
+	switch strings.ToLower(firstValue) {
+	case "f", "false":
+		return false
+	default:
+		return true
+	}
+}
+
+func (r drillReport) print() {
+	passOrFail := func(passed bool) string {
+		if passed {
+			return "PASS"
+		}
+		return "FAIL"
+	}
+
+	fmt.Println()
+	fmt.Println("===== Restore drill report =====")
+	fmt.Println("Backup file:", r.inputFilePath)
+	fmt.Println("Database:", r.drillDbName)
+	fmt.Printf("Restore: %s (took %s)\n", passOrFail(r.restored), r.restoreDuration.Round(time.Millisecond))
+
+	if r.restored {
+		if len(r.tableRowCounts) > 0 {
+			fmt.Println("Row counts:")
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, tableRowCount := range r.tableRowCounts {
+				_, _ = fmt.Fprintf(writer, "  %s\t%s\n", tableRowCount[0], tableRowCount[1])
+			}
+			_ = writer.Flush()
+		}
+		if len(r.tableCountErr) > 0 {
+			fmt.Println("Row counts: FAIL,", r.tableCountErr)
+		}
+
+		if len(r.sanityResults) > 0 {
+			fmt.Println("Sanity queries:")
+			for _, result := range r.sanityResults {
+				fmt.Printf("  [%s] %s\n", passOrFail(result.passed), result.sql)
+				for _, line := range result.output {
+					fmt.Println("    ", line)
+				}
+			}
+		}
+	}
+
+	fmt.Println("Result:", passOrFail(r.isPassed()))
+}
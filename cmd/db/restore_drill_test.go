@@ -0,0 +1,93 @@
+package db
+
+import "testing"
+
+func Test_isSanityOutputPassed(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  bool
+	}{
+		{
+			name:  "no output",
+			lines: nil,
+			want:  true,
+		},
+		{
+			name:  "true",
+			lines: []string{"t"},
+			want:  true,
+		},
+		{
+			name:  "false",
+			lines: []string{"f"},
+			want:  false,
+		},
+		{
+			name:  "false in upper case, multiple columns",
+			lines: []string{"FALSE\t123"},
+			want:  false,
+		},
+		{
+			name:  "only the first value is evaluated",
+			lines: []string{"123\tf", "f"},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSanityOutputPassed(tt.lines); got != tt.want {
+				t.Errorf("isSanityOutputPassed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_drillReport_isPassed(t *testing.T) {
+	tests := []struct {
+		name   string
+		report drillReport
+		want   bool
+	}{
+		{
+			name: "passed",
+			report: drillReport{
+				restored:       true,
+				tableRowCounts: [][2]string{{"public.users", "10"}},
+				sanityResults:  []drillSanityResult{{passed: true}},
+			},
+			want: true,
+		},
+		{
+			name: "restore failed",
+			report: drillReport{
+				restored: false,
+			},
+			want: false,
+		},
+		{
+			name: "no table",
+			report: drillReport{
+				restored:      true,
+				tableCountErr: "no table was restored",
+			},
+			want: false,
+		},
+		{
+			name: "sanity query failed",
+			report: drillReport{
+				restored:       true,
+				tableRowCounts: [][2]string{{"public.users", "10"}},
+				sanityResults:  []drillSanityResult{{passed: true}, {passed: false}},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.isPassed(); got != tt.want {
+				t.Errorf("isPassed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,15 @@
 package db
 
 import (
+	"fmt"
 	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 const (
@@ -15,6 +22,10 @@ const (
 	flagUsername     = "username"
 	flagSchema       = "schema"
 	flagPasswordFile = "password-file"
+	flagService      = "service"
+	flagServiceFile  = "service-file"
+	flagSslMode      = "sslmode"
+	flagSslRootCert  = "sslrootcert"
 )
 
 // Commands registers a sub-tree of commands
@@ -27,6 +38,14 @@ func Commands() *cobra.Command {
 	cmd.AddCommand(
 		PgDumpCommands(),
 		PgRestoreCommands(),
+		PgDumpAllCommands(),
+		PsqlRestoreCommands(),
+		MySqlDumpCommands(),
+		MySqlRestoreCommands(),
+		RedisSnapshotCommands(),
+		SqliteBackupCommands(),
+		ScheduleCommands(),
+		RestoreDrillCommands(),
 	)
 
 	utils.AddFlagWorkingDir(cmd)
@@ -46,7 +65,7 @@ func Commands() *cobra.Command {
 	cmd.PersistentFlags().String(
 		flagDbName,
 		"postgres",
-		"database name",
+		"database name, also accepts libpq connection URI (postgresql://user@host:port/db?sslmode=require)",
 	)
 
 	cmd.PersistentFlags().String(
@@ -55,5 +74,91 @@ func Commands() *cobra.Command {
 		"username to be used to connect to database",
 	)
 
+	cmd.PersistentFlags().String(
+		flagService,
+		"",
+		"(PostgreSQL) connection service name defined in the connection service file, when provided, host/port/username flags are only used if specified explicitly",
+	)
+
+	cmd.PersistentFlags().String(
+		flagServiceFile,
+		"",
+		"(PostgreSQL) custom connection service file, default is ~/.pg_service.conf",
+	)
+
+	//goland:noinspection SpellCheckingInspection
+	cmd.PersistentFlags().String(
+		flagSslMode,
+		"",
+		fmt.Sprintf("(PostgreSQL) SSL mode: %s", strings.Join(supportedPgSslModes, ", ")),
+	)
+
+	//goland:noinspection SpellCheckingInspection
+	cmd.PersistentFlags().String(
+		flagSslRootCert,
+		"",
+		"(PostgreSQL) file path of SSL certificate authority (CA) certificate(s) used to verify the server certificate",
+	)
+
 	return cmd
 }
+
+// dailyFileName returns file name with layout: prefix-yyyy-MM-dd.ext
+func dailyFileName(prefix, ext string) string {
+	return fmt.Sprintf("%s-%s.%s", prefix, time.Now().Format("2006-01-02"), ext)
+}
+
+// readOutputFileName reads output file name from flag, it must be file name alone
+func readOutputFileName(cmd *cobra.Command) string {
+	outputFileName, _ := cmd.Flags().GetString(flagOutputFile)
+	outputFileName = strings.TrimSpace(outputFileName)
+	if len(outputFileName) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagOutputFile))
+	}
+
+	dir, outputFileName := path.Split(outputFileName)
+	if len(dir) > 0 {
+		panic("output file name must be file name alone, can not contains directory part")
+	}
+
+	return outputFileName
+}
+
+// resolveOutputFilePath returns absolute path of the output file within working directory, the file must not exist
+func resolveOutputFilePath(cmd *cobra.Command, outputFileName string) string {
+	workingDir := utils.ReadFlagWorkingDir(cmd)
+
+	outputFilePath, err := filepath.Abs(path.Join(workingDir, outputFileName))
+	if err != nil {
+		panic(errors.Wrap(err, "failed to convert into absolute path"))
+	}
+
+	_, err = os.Stat(outputFilePath)
+	if err == nil {
+		panic(fmt.Errorf("output file already exists: %s", outputFilePath))
+	} else {
+		if os.IsNotExist(err) {
+			// ok
+		} else {
+			panic(errors.Wrap(err, fmt.Sprintf("problem when checking file %s", outputFilePath)))
+		}
+	}
+
+	return outputFilePath
+}
+
+// readToolName returns the custom tool file path if provided via flag, otherwise the default tool name
+func readToolName(cmd *cobra.Command, defaultToolName string) string {
+	customToolName, _ := cmd.Flags().GetString(flagToolFile)
+	customToolName = strings.TrimSpace(customToolName)
+	if len(customToolName) > 0 {
+		_, err := os.Stat(customToolName)
+		if os.IsNotExist(err) {
+			panic(fmt.Errorf("custom %s file path does not exists: %s", defaultToolName, customToolName))
+		}
+
+		return customToolName
+	}
+
+	return defaultToolName
+}
@@ -0,0 +1,330 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	flagScheduleConfigFile = "config"
+	flagScheduleStatusFile = "status-file"
+)
+
+// ScheduleCommands registers a sub-tree of commands
+func ScheduleCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Run scheduled backup jobs as a daemon",
+		Long: fmt.Sprintf(`Run scheduled backup jobs as a daemon.
+Each job executes this binary with the provided arguments on a cron schedule, then applies retention policy.
+Status of the jobs is written into the status file, which can be queried using '%s db schedule status'.
+
+Sample config file (~/%s):
+concurrency: 2
+jobs:
+  - name: main-db
+    schedule: "0 3 * * *"
+    timeout: 2h
+    args: ["db", "pg_dump", "--dbname", "main", "--working-directory", "/mnt/md0/backup", "--password-file", "/home/backup/.pg_password"]
+    env:
+      PGSSLMODE: require
+    retention:
+      working_directory: /mnt/md0/backup
+      contains: [".dump"]
+      keep: 7
+`, constants.BINARY_NAME, constants.SCHEDULE_CONFIG_FILE_NAME),
+		Args: cobra.NoArgs,
+		Run:  runScheduleDaemon,
+	}
+
+	cmd.AddCommand(ScheduleStatusCommands())
+
+	cmd.PersistentFlags().String(
+		flagScheduleConfigFile,
+		defaultHomeFilePath(constants.SCHEDULE_CONFIG_FILE_NAME),
+		"schedule config file (YAML)",
+	)
+
+	cmd.PersistentFlags().String(
+		flagScheduleStatusFile,
+		defaultHomeFilePath(constants.SCHEDULE_STATUS_FILE_NAME),
+		"status file written by the daemon",
+	)
+
+	return cmd
+}
+
+// ScheduleStatusCommands registers a sub-tree of commands
+func ScheduleStatusCommands() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show status of the scheduled backup jobs, exit with non-zero code if daemon is not running or any job failed",
+		Args:  cobra.NoArgs,
+		Run:   showScheduleStatus,
+	}
+}
+
+func runScheduleDaemon(cmd *cobra.Command, _ []string) {
+	configFile, _ := cmd.Flags().GetString(flagScheduleConfigFile)
+	configFile = strings.TrimSpace(configFile)
+	if len(configFile) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagScheduleConfigFile))
+	}
+
+	statusFile, _ := cmd.Flags().GetString(flagScheduleStatusFile)
+	statusFile = strings.TrimSpace(statusFile)
+	if len(statusFile) < 1 {
+		panic(fmt.Errorf("missing value for mandatory flag --%s", flagScheduleStatusFile))
+	}
+
+	config, err := loadScheduleConfig(configFile)
+	libutils.PanicIfErr(err, "failed to load schedule config")
+
+	executable, err := os.Executable()
+	libutils.PanicIfErr(err, "failed to get path of the current executable")
+
+	tracker := newScheduleStatusTracker(statusFile, config)
+	slots := make(chan struct{}, config.Concurrency)
+	outputMu := &sync.Mutex{}
+
+	scheduler := cron.New()
+	entryIds := make(map[string]cron.EntryID)
+
+	for _, job := range config.Jobs {
+		job := job
+		var entryId cron.EntryID
+		entryId, err = scheduler.AddFunc(job.Schedule, func() {
+			runScheduledJob(executable, job, tracker, slots, outputMu)
+			tracker.setNextRun(job.Name, scheduler.Entry(entryId).Next)
+		})
+		libutils.PanicIfErr(err, fmt.Sprintf("failed to schedule job %s", job.Name))
+		entryIds[job.Name] = entryId
+	}
+
+	fmt.Println("Starting scheduler with", len(config.Jobs), "jobs, concurrency", config.Concurrency, "at", utils.NowStr())
+	fmt.Println("Status file:", statusFile)
+
+	scheduler.Start()
+
+	for _, job := range config.Jobs {
+		nextRun := scheduler.Entry(entryIds[job.Name]).Next
+		tracker.setNextRun(job.Name, nextRun)
+		fmt.Println("Job", job.Name, "next run at", utils.FormatTime(nextRun))
+	}
+
+	chanSignal := make(chan os.Signal, 1)
+	signal.Notify(chanSignal, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-chanSignal
+
+	fmt.Printf("Received signal %s, waiting for running jobs to finish at %s\n", sig, utils.NowStr())
+	<-scheduler.Stop().Done()
+	tracker.markStopped()
+	fmt.Println("Stopped scheduler at", utils.NowStr())
+}
+
+func runScheduledJob(executable string, job scheduleJob, tracker *scheduleStatusTracker, slots chan struct{}, outputMu *sync.Mutex) {
+	if !tracker.tryMarkRunning(job.Name) {
+		fmt.Println("Skipped job", job.Name, "because previous run is still in progress at", utils.NowStr())
+		return
+	}
+
+	slots <- struct{}{}
+	defer func() {
+		<-slots
+	}()
+
+	tracker.markStarted(job.Name)
+	fmt.Println("Begin job", job.Name, "at", utils.NowStr())
+
+	var envVars []string
+	for key, value := range job.Env {
+		envVars = append(envVars, fmt.Sprintf("%s=%s", key, value))
+	}
+	envVars = utils.OverlayEnvVars(os.Environ(), envVars...)
+
+	ec := launchScheduledProcess(executable, job.Args, envVars, job, outputMu)
+	if ec != 0 {
+		fmt.Println("Failed job", job.Name, "with exit code", ec, "at", utils.NowStr())
+		tracker.markFinished(job.Name, ec, "")
+		return
+	}
+
+	var errMsg string
+	if job.Retention != nil {
+		fmt.Println("Applying retention of job", job.Name, "keep", job.Retention.Keep, "newest files at", utils.NowStr())
+		if retentionEc := launchScheduledProcess(executable, job.Retention.buildArgs(), envVars, job, outputMu); retentionEc != 0 {
+			errMsg = fmt.Sprintf("retention failed with exit code %d", retentionEc)
+			fmt.Println("Failed retention of job", job.Name, "at", utils.NowStr())
+		}
+	}
+
+	tracker.markFinished(job.Name, 0, errMsg)
+	fmt.Println("Finished job", job.Name, "at", utils.NowStr())
+}
+
+func launchScheduledProcess(executable string, args []string, envVars []string, job scheduleJob, outputMu *sync.Mutex) int {
+	ctx := context.Background()
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	prefix := fmt.Sprintf("[%s] ", job.Name)
+	stdout := newLinePrefixWriter(os.Stdout, prefix, outputMu)
+	stderr := newLinePrefixWriter(os.Stderr, prefix, outputMu)
+	defer stdout.flush()
+	defer stderr.flush()
+
+	launchCmd := exec.CommandContext(ctx, executable, args...)
+	launchCmd.Env = envVars
+	launchCmd.Stdout = stdout
+	launchCmd.Stderr = stderr
+
+	if err := launchCmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			libutils.PrintlnStdErr("job", job.Name, "timed out after", job.Timeout)
+		} else {
+			libutils.PrintlnStdErr("problem when running job", job.Name, err)
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
+		return 1
+	}
+
+	return 0
+}
+
+func showScheduleStatus(cmd *cobra.Command, _ []string) {
+	statusFile, _ := cmd.Flags().GetString(flagScheduleStatusFile)
+	statusFile = strings.TrimSpace(statusFile)
+
+	status, err := readScheduleStatus(statusFile)
+	if err != nil {
+		libutils.PrintlnStdErr("ERR:", err.Error())
+		os.Exit(1)
+	}
+
+	var anyError bool
+
+	if status.isDaemonAlive() {
+		fmt.Printf("Daemon: running (pid %d) since %s\n", status.Pid, utils.FormatTime(status.StartedAt))
+	} else {
+		fmt.Printf("Daemon: NOT running (last pid %d)\n", status.Pid)
+		anyError = true
+	}
+	fmt.Println("Updated at:", utils.FormatTime(status.UpdatedAt))
+
+	formatOptionalTime := func(t *time.Time) string {
+		if t == nil || t.IsZero() {
+			return "-"
+		}
+		return utils.FormatTime(*t)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "JOB\tSCHEDULE\tSTATE\tLAST START\tLAST FINISH\tEXIT\tOK/FAIL\tNEXT RUN")
+	for _, job := range status.Jobs {
+		var state string
+		if job.Running {
+			state = "running"
+		} else if job.LastFinish == nil {
+			state = "pending"
+		} else if job.isLastRunFailed() {
+			state = "FAILED"
+			anyError = true
+		} else {
+			state = "ok"
+		}
+
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%d\t%d/%d\t%s\n",
+			job.Name, job.Schedule, state,
+			formatOptionalTime(job.LastStart), formatOptionalTime(job.LastFinish),
+			job.LastExitCode, job.SuccessCount, job.FailureCount,
+			formatOptionalTime(job.NextRun),
+		)
+	}
+	_ = writer.Flush()
+
+	for _, job := range status.Jobs {
+		if len(job.LastError) > 0 {
+			fmt.Printf("Job %s: %s\n", job.Name, job.LastError)
+		}
+	}
+
+	if anyError {
+		os.Exit(1)
+	}
+}
+
+// defaultHomeFilePath returns path of the file within home directory, or the file name alone if home directory is not available
+func defaultHomeFilePath(fileName string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fileName
+	}
+	return path.Join(home, fileName)
+}
+
+// linePrefixWriter prefixes each line written by jobs, so outputs of concurrent jobs can be distinguished
+type linePrefixWriter struct {
+	out    io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buffer bytes.Buffer
+}
+
+func newLinePrefixWriter(out io.Writer, prefix string, mu *sync.Mutex) *linePrefixWriter {
+	return &linePrefixWriter{
+		out:    out,
+		prefix: prefix,
+		mu:     mu,
+	}
+}
+
+func (w *linePrefixWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+
+	for {
+		line, err := w.buffer.ReadBytes('\n')
+		if err != nil {
+			// incomplete line, keep for the next write
+			w.buffer.Reset()
+			w.buffer.Write(line)
+			break
+		}
+		w.writeLine(line)
+	}
+
+	return len(p), nil
+}
+
+func (w *linePrefixWriter) flush() {
+	if w.buffer.Len() > 0 {
+		w.writeLine(append(w.buffer.Bytes(), '\n'))
+		w.buffer.Reset()
+	}
+}
+
+func (w *linePrefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, _ = w.out.Write(append([]byte(w.prefix), line...))
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var regexScheduleJobName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.-]*$`)
+
+// scheduleConfig is the configuration of the backup scheduler daemon
+type scheduleConfig struct {
+	// Concurrency is the maximum number of jobs running at the same time
+	Concurrency int           `yaml:"concurrency"`
+	Jobs        []scheduleJob `yaml:"jobs"`
+}
+
+// scheduleJob is a backup job, which executes this binary with the provided arguments
+type scheduleJob struct {
+	Name string `yaml:"name"`
+	// Schedule is a standard cron expression (5 fields) or descriptor like @daily
+	Schedule string `yaml:"schedule"`
+	// Args are arguments passed to this binary, eg: ["db", "pg_dump", "--dbname", "my_db"]
+	Args      []string           `yaml:"args"`
+	Env       map[string]string  `yaml:"env"`
+	Timeout   time.Duration      `yaml:"timeout"`
+	Retention *scheduleRetention `yaml:"retention"`
+}
+
+// scheduleRetention removes old backup files after the job succeeded,
+// newest files which satisfy the filters are kept.
+type scheduleRetention struct {
+	WorkingDirectory string   `yaml:"working_directory"`
+	Contains         []string `yaml:"contains"`
+	Regex            string   `yaml:"regex"`
+	Keep             int      `yaml:"keep"`
+}
+
+// loadScheduleConfig reads and validates the scheduler configuration file
+func loadScheduleConfig(configFile string) (*scheduleConfig, error) {
+	bz, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read schedule config file %s", configFile))
+	}
+
+	var config scheduleConfig
+	if err := yaml.Unmarshal(bz, &config); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse schedule config file %s", configFile))
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid schedule config file %s", configFile))
+	}
+
+	return &config, nil
+}
+
+func (c *scheduleConfig) validate() error {
+	if c.Concurrency == 0 {
+		c.Concurrency = 1
+	} else if c.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+
+	if len(c.Jobs) < 1 {
+		return fmt.Errorf("no job was defined")
+	}
+
+	uniqueNames := make(map[string]bool)
+	for i, job := range c.Jobs {
+		if !regexScheduleJobName.MatchString(job.Name) {
+			return fmt.Errorf("job #%d: malformed name \"%s\"", i+1, job.Name)
+		}
+		if uniqueNames[job.Name] {
+			return fmt.Errorf("job %s: name is not unique", job.Name)
+		}
+		uniqueNames[job.Name] = true
+
+		if _, err := cron.ParseStandard(job.Schedule); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("job %s: bad schedule \"%s\"", job.Name, job.Schedule))
+		}
+
+		if len(job.Args) < 1 {
+			return fmt.Errorf("job %s: missing args", job.Name)
+		}
+		if len(job.Args) > 1 && job.Args[0] == "db" && job.Args[1] == "schedule" {
+			return fmt.Errorf("job %s: scheduling the scheduler is not allowed", job.Name)
+		}
+
+		if job.Timeout < 0 {
+			return fmt.Errorf("job %s: timeout must not be negative", job.Name)
+		}
+
+		if job.Retention != nil {
+			if err := job.Retention.validate(); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("job %s", job.Name))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r scheduleRetention) validate() error {
+	if len(strings.TrimSpace(r.WorkingDirectory)) < 1 {
+		return fmt.Errorf("retention: missing working_directory")
+	}
+	if r.Keep < 1 {
+		return fmt.Errorf("retention: keep must be at least 1")
+	}
+	if len(r.Contains) < 1 && len(r.Regex) < 1 {
+		// prevent deleting unrelated files within the directory
+		return fmt.Errorf("retention: at least one filter contains/regex is required")
+	}
+	if len(r.Regex) > 0 {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return errors.Wrap(err, "retention: bad regex")
+		}
+	}
+	return nil
+}
+
+// buildArgs builds arguments for command 'files list' to delete old backup files
+func (r scheduleRetention) buildArgs() []string {
+	args := []string{
+		"files", "list",
+		"--working-directory", r.WorkingDirectory,
+		"--order-by", "date",
+		"--desc",
+		"--skip", fmt.Sprintf("%d", r.Keep),
+		"--delete",
+	}
+	for _, contains := range r.Contains {
+		args = append(args, "--contains", contains)
+	}
+	if len(r.Regex) > 0 {
+		args = append(args, "--regex", r.Regex)
+	}
+	return args
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_scheduleConfig_validate(t *testing.T) {
+	validJob := func() scheduleJob {
+		return scheduleJob{
+			Name:     "main-db",
+			Schedule: "0 3 * * *",
+			Args:     []string{"db", "pg_dump", "--dbname", "main"},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		config          scheduleConfig
+		wantErr         bool
+		wantConcurrency int
+	}{
+		{
+			name: "valid, default concurrency",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{validJob()},
+			},
+			wantErr:         false,
+			wantConcurrency: 1,
+		},
+		{
+			name: "valid, descriptor schedule",
+			config: scheduleConfig{
+				Concurrency: 3,
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Schedule = "@daily"
+					return job
+				}()},
+			},
+			wantErr:         false,
+			wantConcurrency: 3,
+		},
+		{
+			name:    "no job",
+			config:  scheduleConfig{},
+			wantErr: true,
+		},
+		{
+			name: "negative concurrency",
+			config: scheduleConfig{
+				Concurrency: -1,
+				Jobs:        []scheduleJob{validJob()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated job name",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{validJob(), validJob()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed job name",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Name = "main db"
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bad schedule",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Schedule = "0 3 * *"
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing args",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Args = nil
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scheduling the scheduler",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Args = []string{"db", "schedule"}
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retention without filter",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Retention = &scheduleRetention{
+						WorkingDirectory: "/tmp/backup",
+						Keep:             3,
+					}
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retention keep nothing",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Retention = &scheduleRetention{
+						WorkingDirectory: "/tmp/backup",
+						Contains:         []string{".dump"},
+					}
+					return job
+				}()},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid retention",
+			config: scheduleConfig{
+				Jobs: []scheduleJob{func() scheduleJob {
+					job := validJob()
+					job.Retention = &scheduleRetention{
+						WorkingDirectory: "/tmp/backup",
+						Regex:            `^db-.*\.dump$`,
+						Keep:             3,
+					}
+					return job
+				}()},
+			},
+			wantErr:         false,
+			wantConcurrency: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && tt.config.Concurrency != tt.wantConcurrency {
+				t.Errorf("validate() concurrency = %d, want %d", tt.config.Concurrency, tt.wantConcurrency)
+			}
+		})
+	}
+}
+
+func Test_scheduleRetention_buildArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention scheduleRetention
+		want      []string
+	}{
+		{
+			name: "contains",
+			retention: scheduleRetention{
+				WorkingDirectory: "/tmp/backup",
+				Contains:         []string{".dump", "db-"},
+				Keep:             7,
+			},
+			want: []string{"files", "list", "--working-directory", "/tmp/backup", "--order-by", "date", "--desc", "--skip", "7", "--delete", "--contains", ".dump", "--contains", "db-"},
+		},
+		{
+			name: "regex",
+			retention: scheduleRetention{
+				WorkingDirectory: "/tmp/backup",
+				Regex:            `\.sql$`,
+				Keep:             1,
+			},
+			want: []string{"files", "list", "--working-directory", "/tmp/backup", "--order-by", "date", "--desc", "--skip", "1", "--delete", "--regex", `\.sql$`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retention.buildArgs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// scheduleStatus is the content of the status file written by the scheduler daemon
+type scheduleStatus struct {
+	Pid       int                  `json:"pid"`
+	StartedAt time.Time            `json:"started_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Stopped   bool                 `json:"stopped"`
+	Jobs      []*scheduleJobStatus `json:"jobs"`
+}
+
+type scheduleJobStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	LastStart    *time.Time `json:"last_start,omitempty"`
+	LastFinish   *time.Time `json:"last_finish,omitempty"`
+	LastExitCode int        `json:"last_exit_code"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	SuccessCount int        `json:"success_count"`
+	FailureCount int        `json:"failure_count"`
+}
+
+// isLastRunFailed returns true if the job has been executed and the last run failed
+func (s scheduleJobStatus) isLastRunFailed() bool {
+	return s.LastFinish != nil && (s.LastExitCode != 0 || len(s.LastError) > 0)
+}
+
+// isDaemonAlive returns true if the process which wrote the status is still alive
+func (s scheduleStatus) isDaemonAlive() bool {
+	if s.Stopped || s.Pid < 1 {
+		return false
+	}
+	process, err := os.FindProcess(s.Pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// scheduleStatusTracker tracks status of jobs and persists into the status file
+type scheduleStatusTracker struct {
+	mu         sync.Mutex
+	statusFile string
+	status     scheduleStatus
+	jobs       map[string]*scheduleJobStatus
+}
+
+func newScheduleStatusTracker(statusFile string, config *scheduleConfig) *scheduleStatusTracker {
+	now := time.Now()
+	tracker := &scheduleStatusTracker{
+		statusFile: statusFile,
+		status: scheduleStatus{
+			Pid:       os.Getpid(),
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+		jobs: make(map[string]*scheduleJobStatus),
+	}
+
+	for _, job := range config.Jobs {
+		jobStatus := &scheduleJobStatus{
+			Name:     job.Name,
+			Schedule: job.Schedule,
+		}
+		tracker.status.Jobs = append(tracker.status.Jobs, jobStatus)
+		tracker.jobs[job.Name] = jobStatus
+	}
+
+	return tracker
+}
+
+// tryMarkRunning marks the job as running, returns false if the job is already running
+func (t *scheduleStatusTracker) tryMarkRunning(jobName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	jobStatus := t.jobs[jobName]
+	if jobStatus.Running {
+		return false
+	}
+
+	jobStatus.Running = true
+	return true
+}
+
+func (t *scheduleStatusTracker) markStarted(jobName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.jobs[jobName].LastStart = &now
+	t.persist()
+}
+
+func (t *scheduleStatusTracker) markFinished(jobName string, exitCode int, errMsg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	jobStatus := t.jobs[jobName]
+	jobStatus.Running = false
+	jobStatus.LastFinish = &now
+	jobStatus.LastExitCode = exitCode
+	jobStatus.LastError = errMsg
+	if exitCode == 0 && len(errMsg) < 1 {
+		jobStatus.SuccessCount++
+	} else {
+		jobStatus.FailureCount++
+	}
+	t.persist()
+}
+
+func (t *scheduleStatusTracker) setNextRun(jobName string, nextRun time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if nextRun.IsZero() {
+		t.jobs[jobName].NextRun = nil
+	} else {
+		t.jobs[jobName].NextRun = &nextRun
+	}
+	t.persist()
+}
+
+func (t *scheduleStatusTracker) markStopped() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.Stopped = true
+	t.persist()
+}
+
+// persist writes status into the status file, must be called while holding the lock
+func (t *scheduleStatusTracker) persist() {
+	t.status.UpdatedAt = time.Now()
+
+	if err := writeScheduleStatus(t.statusFile, t.status); err != nil {
+		fmt.Println("ERR: failed to write status file:", err.Error())
+	}
+}
+
+// writeScheduleStatus writes the status file atomically
+func writeScheduleStatus(statusFile string, status scheduleStatus) error {
+	bz, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal status")
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(statusFile), filepath.Base(statusFile)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary status file")
+	}
+
+	_, err = tmpFile.Write(bz)
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), statusFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return errors.Wrap(err, fmt.Sprintf("failed to write status file %s", statusFile))
+	}
+
+	return nil
+}
+
+// readScheduleStatus reads the status file written by the scheduler daemon
+func readScheduleStatus(statusFile string) (*scheduleStatus, error) {
+	bz, err := os.ReadFile(statusFile)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read status file %s", statusFile))
+	}
+
+	var status scheduleStatus
+	if err := json.Unmarshal(bz, &status); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse status file %s", statusFile))
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	flagBusyTimeout = "busy-timeout"
+)
+
+// SqliteBackupCommands registers a sub-tree of commands
+func SqliteBackupCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sqlite-backup [db_file]",
+		Short: "Backup SQLite database using online backup API",
+		Long: `Backup SQLite database into working directory using the online backup API (sqlite3 '.backup' command),
+so the copy is consistent even if the database is being written by other processes.
+The output file is checked using 'PRAGMA integrity_check' after backup.`,
+		Args: cobra.ExactArgs(1),
+		Run:  backupSqliteDatabase,
+	}
+
+	cmd.PersistentFlags().String(
+		flagOutputFile,
+		dailyFileName("db", "sqlite"),
+		"specify name of the output backup file, file name only, default has layout: db-yyyy-MM-dd.sqlite",
+	)
+
+	cmd.PersistentFlags().String(
+		flagToolFile,
+		"",
+		"custom file path for the sqlite3 utility",
+	)
+
+	cmd.PersistentFlags().Uint(
+		flagBusyTimeout,
+		10_000,
+		"milliseconds to wait when the database is locked",
+	)
+
+	return cmd
+}
+
+func backupSqliteDatabase(cmd *cobra.Command, args []string) {
+	inputFilePath := strings.TrimSpace(args[0])
+	if len(inputFilePath) < 1 {
+		panic("bad input database file")
+	}
+
+	exists, err := utils.IsFileAndExists(inputFilePath)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("problem when checking input file %s", inputFilePath)))
+	}
+	if !exists {
+		panic("bad input database file, file does not exists")
+	}
+
+	outputFileName := readOutputFileName(cmd)
+	outputFilePath := resolveOutputFilePath(cmd, outputFileName)
+	if strings.Contains(outputFilePath, "'") {
+		panic("output file path must not contain single quote")
+	}
+
+	busyTimeout, _ := cmd.Flags().GetUint(flagBusyTimeout)
+
+	toolName := readToolName(cmd, "sqlite3")
+
+	// commands are fed via stdin so file paths are not interpreted by shell
+	backupScript := fmt.Sprintf(".timeout %d\n.backup '%s'\n", busyTimeout, outputFilePath)
+
+	fmt.Println("Input file:", inputFilePath)
+	fmt.Println("Output file:", outputFilePath)
+	fmt.Println("Begin backup", outputFileName, "at", utils.NowStr())
+
+	ec := utils.LaunchAppWithSetup(toolName, []string{"-batch", "-bail", inputFilePath}, func(launchCmd *exec.Cmd) {
+		launchCmd.Stdin = strings.NewReader(backupScript)
+		launchCmd.Stdout = os.Stdout
+		launchCmd.Stderr = os.Stderr
+	})
+	if ec != 0 {
+		fmt.Println("Failed to backup", outputFileName, "at", utils.NowStr())
+		os.Exit(ec)
+	}
+
+	output, ec := utils.LaunchAppAndCaptureOutput(toolName, []string{"-batch", "-readonly", outputFilePath, "PRAGMA integrity_check;"}, nil)
+	output = strings.TrimSpace(output)
+	if ec != 0 || output != "ok" {
+		fmt.Println("Integrity check failed for", outputFilePath, ":", output)
+		os.Exit(1)
+	}
+
+	fmt.Println("Finished backup", outputFileName, "at", utils.NowStr())
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/EscanBE/go-ienumerable/goe"
 	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
 	"github.com/EscanBE/house-keeper/cmd/utils"
 	"github.com/EscanBE/house-keeper/constants"
 	"github.com/pkg/errors"
@@ -83,7 +84,7 @@ Note:
 	cmd.PersistentFlags().String(
 		flagPasswordFile,
 		"",
-		"file path which store password to access remote server",
+		fmt.Sprintf("file path which store password to access remote server, %s", secrets.SourceUsage),
 	)
 
 	cmd.PersistentFlags().String(
@@ -239,67 +240,36 @@ func remoteTransferFile(cmd *cobra.Command, args []string) {
 
 	passwordFile, _ := cmd.Flags().GetString(flagPasswordFile)
 	if len(passwordFile) > 0 {
-		fip, err := os.Stat(passwordFile)
-		if os.IsNotExist(err) {
-			panic(fmt.Errorf("supplied password file does not exists: %s", passwordFile))
-		}
-
-		bz, err := os.ReadFile(passwordFile)
-		if err != nil {
-			panic(errors.Wrap(err, fmt.Sprintf("failed to read password file: %s", passwordFile)))
-		}
-		password := strings.TrimSpace(string(bz))
-		if len(password) < 1 {
-			panic(fmt.Errorf("password file is empty: %s", passwordFile))
-		}
-
-		fipPerm := fip.Mode().Perm()
-		errPerm := utils.ValidatePasswordFileMode(fipPerm)
-		if errPerm != nil {
-			fmt.Printf("Incorrect permission '%o' of password file: %s\n", fipPerm, errPerm)
-			fmt.Printf("Suggest setting permission to '%o'\n", constants.RECOMMENDED_FILE_PERMISSION)
-			os.Exit(1)
-		}
+		passwordSource := secrets.ParseSource(passwordFile)
+		password := passwordSource.Read()
 
 		if utils.HasToolSshPass() {
-			fmt.Println("Using sshpass to passing password file")
-
-			var cmdArgs []string
-			if sshPassPhrase {
-				//goland:noinspection SpellCheckingInspection
-				cmdArgs = []string{"-P", "assphrase", "-f", passwordFile}
+			cmdArgs := make([]string, 0)
+			var sshPassEnvVars []string
+			if passwordSource.IsFile() {
+				fmt.Println("Using sshpass to passing password file")
+				cmdArgs = append(cmdArgs, utils.BuildSshPassArgs(passwordSource.Value, sshPassPhrase)...)
 			} else {
-				cmdArgs = []string{"-f", passwordFile}
+				fmt.Println("Using sshpass to passing password from", passwordSource.Kind, "source via environment variable", constants.ENV_SSHPASS)
+				cmdArgs = append(cmdArgs, utils.BuildSshPassArgs("", sshPassPhrase)...)
+				sshPassEnvVars = []string{fmt.Sprintf("%s=%s", constants.ENV_SSHPASS, password)}
 			}
 			cmdArgs = append(cmdArgs, toolName)
 			cmdArgs = append(cmdArgs, options...)
 			cmdArgs = append(cmdArgs, "--rsh", "ssh", src, dest)
 
-			launchApp("sshpass", cmdArgs, nil, directStd)
+			launchApp("sshpass", cmdArgs, sshPassEnvVars, directStd)
 			return
 		}
 
-		fmt.Println("Using environment variable", constants.ENV_RSYNC_PASSWORD, "to passing password from password file to rsync")
+		fmt.Println("Using environment variable", constants.ENV_RSYNC_PASSWORD, "to passing password from", passwordSource.Kind, "source to rsync")
 		fmt.Println("**WARNING: if remote machine does not have rsync service running, password prompt still appears")
 		launchApp(toolName, append(options, "--rsh", "ssh", src, dest), []string{fmt.Sprintf("%s=%s", constants.ENV_RSYNC_PASSWORD, password)}, directStd)
 		return
 	}
 
-	rsyncPassword := strings.TrimSpace(os.Getenv(constants.ENV_RSYNC_PASSWORD))
-	sshPassword := strings.TrimSpace(os.Getenv(constants.ENV_SSHPASS))
-
-	var password string
-	if len(rsyncPassword) > 0 && len(sshPassword) > 0 {
-		if rsyncPassword != sshPassword {
-			panic(fmt.Errorf("both environment variables %s and %s are set but mis-match, consider remove one to take the rest", constants.ENV_RSYNC_PASSWORD, constants.ENV_SSHPASS))
-		}
-
-		password = rsyncPassword
-	} else if len(rsyncPassword) > 0 {
-		password = rsyncPassword
-	} else if len(sshPassword) > 0 {
-		password = sshPassword
-	} else {
+	password, rsyncPassword, sshPassword := utils.ReadRemotePasswordFromEnv()
+	if len(password) < 1 {
 		panic(fmt.Errorf("missing password for remote server, either environment variable %s or %s or flag --%s is required", constants.ENV_RSYNC_PASSWORD, constants.ENV_SSHPASS, flagPasswordFile))
 	}
 
@@ -309,13 +279,8 @@ func remoteTransferFile(cmd *cobra.Command, args []string) {
 		}
 		fmt.Println("Using sshpass to passing password via environment variable", constants.ENV_SSHPASS)
 
-		var cmdArgs []string
-		if sshPassPhrase {
-			//goland:noinspection SpellCheckingInspection
-			cmdArgs = []string{"-P", "assphrase", "-e", toolName}
-		} else {
-			cmdArgs = []string{"-e", toolName}
-		}
+		cmdArgs := utils.BuildSshPassArgs("", sshPassPhrase)
+		cmdArgs = append(cmdArgs, toolName)
 		cmdArgs = append(cmdArgs, options...)
 		cmdArgs = append(cmdArgs, "--rsh", "ssh", src, dest)
 
@@ -0,0 +1,252 @@
+package gen
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	flagCronSchedule = "schedule"
+	flagCronName     = "name"
+	flagCronLogFile  = "log-file"
+	flagCronHkdPath  = "hkd-path"
+)
+
+const cronDir = "/etc/cron.d"
+
+// descriptors supported by system cron
+var cronDescriptors = []string{"@reboot", "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+// field of system cron: numbers or names, lists, ranges and steps. '?' and 'TZ=' prefix are not supported.
+var regexCronField = regexp.MustCompile(`^[\da-zA-Z*,/-]+$`)
+
+// flags of hkd commands those are checked when validating the command,
+// besides utils.FlagWorkingDir which defaults to the current directory, that is the home directory when executed by cron
+const (
+	// flagDelete deletes the listed files, filters are required to prevent deleting unrelated files
+	flagDelete   = "delete"
+	flagContains = "contains"
+	flagRegex    = "regex"
+)
+
+// GenerateCronCommands registers a sub-tree of commands
+func GenerateCronCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cron -- [hkd_command...]",
+		Short: "Generate cron entry to execute hkd command periodically",
+		Long: fmt.Sprintf(`Generate cron entry to execute hkd command periodically, eg:
+hkd gen cron --%s '0 3 * * *' -- db pg_dump --dbname main --working-directory /mnt/md0/backup --password-file /home/backup/.pg_password
+hkd gen cron --%s '0 4 * * *' -- files list --working-directory /mnt/md0/backup --contains .dump --order-by date --desc --skip 7 --delete
+The hkd command is validated, and is called using absolute path of the current binary.`, flagCronSchedule, flagCronSchedule),
+		Args: cobra.MinimumNArgs(1),
+		Run:  generateCron,
+	}
+
+	cmd.Flags().String(flagCronSchedule, "", fmt.Sprintf("standard cron expression (5 fields) or descriptor: %s", strings.Join(cronDescriptors, ", ")))
+	cmd.Flags().String(flagUser, "", "user to execute the command, default is the user invoked sudo or current user")
+	cmd.Flags().String(flagCronName, "", fmt.Sprintf("name of the file within %s, default is hkd-<command>, eg: hkd-db-pg_dump", cronDir))
+	cmd.Flags().String(flagCronLogFile, "", "append output of the command into the log file, instead of sending via mail by cron")
+	cmd.Flags().String(flagCronHkdPath, "", "absolute path of hkd binary, default is path of the current binary")
+
+	addOutputFlags(cmd, fmt.Sprintf("into %s/<name>", cronDir))
+
+	return cmd
+}
+
+// cronEntryConfig holds the information to build cron entry
+type cronEntryConfig struct {
+	schedule string
+	user     string
+	hkdPath  string
+	args     []string
+	logFile  string
+}
+
+func generateCron(cmd *cobra.Command, args []string) {
+	targetCmd, err := validateHkdCommand(cmd.Root(), args)
+	if err != nil {
+		panic(errors.Wrap(err, "invalid hkd command"))
+	}
+
+	cfg := cronEntryConfig{
+		args: args,
+	}
+
+	cfg.schedule, _ = cmd.Flags().GetString(flagCronSchedule)
+	cfg.user = readUserFromFlags(cmd).Username
+	cfg.logFile, _ = cmd.Flags().GetString(flagCronLogFile)
+
+	cfg.hkdPath, _ = cmd.Flags().GetString(flagCronHkdPath)
+	if len(strings.TrimSpace(cfg.hkdPath)) < 1 {
+		cfg.hkdPath, err = currentExecutablePath()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	content, err := buildCronEntry(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	name, _ := cmd.Flags().GetString(flagCronName)
+	name = strings.TrimSpace(name)
+	if len(name) < 1 {
+		// eg: 'hkd db pg_dump' => 'hkd-db-pg_dump'
+		name = strings.ReplaceAll(strings.ReplaceAll(targetCmd.CommandPath(), " ", "-"), ".", "_")
+	}
+	if !regexIncludedConfigFileName.MatchString(name) {
+		panic(fmt.Errorf("malformed name \"%s\", files within %s those name contains '.' are ignored", name, cronDir))
+	}
+
+	readGeneratedOutputFromFlags(cmd, path.Join(cronDir, name)).emit(content, 0o644)
+}
+
+// validateHkdCommand validates the hkd command: command exists, flags and arguments are accepted.
+// Returns the command to be executed.
+func validateHkdCommand(root *cobra.Command, args []string) (*cobra.Command, error) {
+	targetCmd, flagsAndArgs, err := root.Find(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetCmd == root || !targetCmd.Runnable() {
+		return nil, fmt.Errorf("\"%s\" is not an executable command", strings.Join(args, " "))
+	}
+
+	if err := targetCmd.ParseFlags(flagsAndArgs); err != nil {
+		return nil, errors.Wrap(err, targetCmd.CommandPath())
+	}
+
+	if err := targetCmd.ValidateArgs(targetCmd.Flags().Args()); err != nil {
+		return nil, errors.Wrap(err, targetCmd.CommandPath())
+	}
+
+	if targetCmd.Flags().Lookup(utils.FlagWorkingDir) != nil && !isFlagChanged(targetCmd, utils.FlagWorkingDir) {
+		return nil, fmt.Errorf("--%s of %s must be provided explicitly, cron executes commands within home directory of the user", utils.FlagWorkingDir, targetCmd.CommandPath())
+	}
+
+	if isFlagChanged(targetCmd, flagDelete) && !isFlagChanged(targetCmd, flagContains) && !isFlagChanged(targetCmd, flagRegex) {
+		return nil, fmt.Errorf("--%s of %s requires at least one filter --%s/--%s, to prevent deleting unrelated files", flagDelete, targetCmd.CommandPath(), flagContains, flagRegex)
+	}
+
+	return targetCmd, nil
+}
+
+func isFlagChanged(cmd *cobra.Command, name string) bool {
+	flag := cmd.Flags().Lookup(name)
+	return flag != nil && flag.Changed
+}
+
+// currentExecutablePath returns the absolute path of the current binary, symlinks are resolved
+func currentExecutablePath() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get path of the current binary")
+	}
+
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve path of the current binary")
+	}
+
+	return executable, nil
+}
+
+// validateCronSchedule validates the schedule is accepted by system cron within /etc/cron.d:
+// 5 fields or one of the supported descriptors.
+// Extensions of robfig/cron like '@every' and 'CRON_TZ=' prefix are rejected.
+func validateCronSchedule(schedule string) error {
+	fields := strings.Fields(schedule)
+	if len(fields) < 1 {
+		return fmt.Errorf("schedule is required")
+	}
+
+	if strings.HasPrefix(fields[0], "@") {
+		if len(fields) != 1 {
+			return fmt.Errorf("descriptor must not be followed by other fields")
+		}
+		for _, descriptor := range cronDescriptors {
+			if fields[0] == descriptor {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported descriptor %s, supported: %s", fields[0], strings.Join(cronDescriptors, ", "))
+	}
+
+	if len(fields) != 5 {
+		return fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week) but got %d", len(fields))
+	}
+
+	for _, field := range fields {
+		if !regexCronField.MatchString(field) {
+			return fmt.Errorf("malformed field \"%s\"", field)
+		}
+	}
+
+	// validate ranges of values
+	if _, err := cron.ParseStandard(strings.Join(fields, " ")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// buildCronEntry builds content of the file within /etc/cron.d, contains a single entry
+func buildCronEntry(cfg cronEntryConfig) (string, error) {
+	if err := validateCronSchedule(cfg.schedule); err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("bad schedule \"%s\"", cfg.schedule))
+	}
+
+	if !regexUserName.MatchString(cfg.user) {
+		return "", fmt.Errorf("malformed user name \"%s\"", cfg.user)
+	}
+
+	if !filepath.IsAbs(cfg.hkdPath) {
+		return "", fmt.Errorf("hkd path must be absolute: %s", cfg.hkdPath)
+	}
+
+	if len(cfg.args) < 1 {
+		return "", fmt.Errorf("missing hkd command")
+	}
+
+	if len(cfg.logFile) > 0 && !filepath.IsAbs(cfg.logFile) {
+		return "", fmt.Errorf("log file must be absolute: %s", cfg.logFile)
+	}
+
+	words := append([]string{cfg.hkdPath}, cfg.args...)
+	for _, word := range words {
+		if strings.ContainsAny(word, "\n\r") {
+			return "", fmt.Errorf("command must not contain new line")
+		}
+	}
+
+	quotedWords := make([]string, len(words))
+	for i, word := range words {
+		quotedWords[i] = utils.ShellQuote(word)
+	}
+	command := strings.Join(quotedWords, " ")
+	if len(cfg.logFile) > 0 {
+		command += fmt.Sprintf(" >> %s 2>&1", utils.ShellQuote(cfg.logFile))
+	}
+
+	// '%' is converted into new line by cron, unless escaped
+	command = strings.ReplaceAll(command, "%", `\%`)
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by House Keeper\n")
+	sb.WriteString("SHELL=/bin/sh\n")
+	sb.WriteString("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\n")
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s %s %s\n", strings.TrimSpace(cfg.schedule), cfg.user, command))
+
+	return sb.String(), nil
+}
@@ -0,0 +1,251 @@
+package gen
+
+import (
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"strings"
+	"testing"
+)
+
+// newTestRootCommand returns a command tree similar to hkd
+func newTestRootCommand() *cobra.Command {
+	root := &cobra.Command{Use: "hkd"}
+
+	files := &cobra.Command{Use: "files", Aliases: []string{"f"}}
+	list := &cobra.Command{Use: "list", Args: cobra.NoArgs, Run: func(*cobra.Command, []string) {}}
+	list.PersistentFlags().String(utils.FlagWorkingDir, "/current/dir", "")
+	list.PersistentFlags().StringArray(flagContains, nil, "")
+	list.PersistentFlags().String(flagRegex, "", "")
+	list.PersistentFlags().Bool(flagDelete, false, "")
+	list.PersistentFlags().Int("skip", 0, "")
+	files.AddCommand(list)
+
+	version := &cobra.Command{Use: "version", Args: cobra.NoArgs, Run: func(*cobra.Command, []string) {}}
+
+	root.AddCommand(files, version)
+	return root
+}
+
+func Test_validateHkdCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			args:     []string{"files", "list", "--working-directory", "/mnt/md0/backup", "--contains", ".dump", "--skip", "7", "--delete"},
+			wantPath: "hkd files list",
+		},
+		{
+			name:     "alias",
+			args:     []string{"f", "list", "--working-directory=/mnt/md0/backup"},
+			wantPath: "hkd files list",
+		},
+		{
+			name:     "no flag",
+			args:     []string{"version"},
+			wantPath: "hkd version",
+		},
+		{
+			name:    "not executable",
+			args:    []string{"files"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"backup"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"files", "list", "--working-directory", "/mnt", "--recursive"},
+			wantErr: true,
+		},
+		{
+			name:    "bad flag value",
+			args:    []string{"files", "list", "--working-directory", "/mnt", "--skip", "seven"},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected argument",
+			args:    []string{"version", "now"},
+			wantErr: true,
+		},
+		{
+			name:    "implicit working directory",
+			args:    []string{"files", "list", "--contains", ".dump"},
+			wantErr: true,
+		},
+		{
+			name:    "delete without filter",
+			args:    []string{"files", "list", "--working-directory", "/mnt/md0/backup", "--delete"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateHkdCommand(newTestRootCommand(), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateHkdCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.CommandPath() != tt.wantPath {
+				t.Errorf("validateHkdCommand() = %s, want %s", got.CommandPath(), tt.wantPath)
+			}
+		})
+	}
+}
+
+func Test_buildCronEntry(t *testing.T) {
+	validCfg := func() cronEntryConfig {
+		return cronEntryConfig{
+			schedule: "0 3 * * *",
+			user:     "backup",
+			hkdPath:  "/usr/local/bin/hkd",
+			args:     []string{"db", "pg_dump", "--dbname", "main", "--working-directory", "/mnt/md0/backup"},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(cfg *cronEntryConfig)
+		wantErr   bool
+		wantEntry string
+	}{
+		{
+			name:      "valid",
+			wantEntry: "0 3 * * * backup /usr/local/bin/hkd db pg_dump --dbname main --working-directory /mnt/md0/backup",
+		},
+		{
+			name: "descriptor, log file, quoting and percent sign",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "@daily"
+				cfg.args = []string{"db", "pg_dump", "--working-directory", "/mnt/my backup", "--output-file", "db-%d.dump"}
+				cfg.logFile = "/var/log/hkd/backup.log"
+			},
+			wantEntry: `@daily backup /usr/local/bin/hkd db pg_dump --working-directory '/mnt/my backup' --output-file db-\%d.dump >> /var/log/hkd/backup.log 2>&1`,
+		},
+		{
+			name: "bad schedule",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "0 25 * * *"
+			},
+			wantErr: true,
+		},
+		{
+			name: "reboot descriptor",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "@reboot"
+			},
+			wantEntry: "@reboot backup /usr/local/bin/hkd db pg_dump --dbname main --working-directory /mnt/md0/backup",
+		},
+		{
+			name: "lists, ranges, steps and names",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "*/15 1,3 1-7 JAN-JUN mon-fri"
+			},
+			wantEntry: "*/15 1,3 1-7 JAN-JUN mon-fri backup /usr/local/bin/hkd db pg_dump --dbname main --working-directory /mnt/md0/backup",
+		},
+		{
+			name: "every descriptor is not supported by system cron",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "@every 1h"
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown descriptor",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "@fortnightly"
+			},
+			wantErr: true,
+		},
+		{
+			name: "CRON_TZ prefix is not supported by system cron",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "CRON_TZ=Asia/Tokyo 0 3 * * *"
+			},
+			wantErr: true,
+		},
+		{
+			name: "TZ prefix is not supported by system cron",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "TZ=UTC 0 3 * * *"
+			},
+			wantErr: true,
+		},
+		{
+			name: "question mark is not supported by system cron",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "0 3 ? * *"
+			},
+			wantErr: true,
+		},
+		{
+			name: "6 fields",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = "0 0 3 * * *"
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty schedule",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.schedule = " "
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative hkd path",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.hkdPath = "hkd"
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative log file",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.logFile = "backup.log"
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed user",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.user = "backup /bin/sh"
+			},
+			wantErr: true,
+		},
+		{
+			name: "new line",
+			modify: func(cfg *cronEntryConfig) {
+				cfg.args = append(cfg.args, "\n* * * * * root reboot")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validCfg()
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+
+			got, err := buildCronEntry(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildCronEntry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			lines := strings.Split(strings.TrimSpace(got), "\n")
+			if gotEntry := lines[len(lines)-1]; gotEntry != tt.wantEntry {
+				t.Errorf("buildCronEntry() entry = %s, want %s", gotEntry, tt.wantEntry)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package gen
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	flagLogrotateName         = "name"
+	flagLogrotateFrequency    = "frequency"
+	flagLogrotateRotate       = "rotate"
+	flagLogrotateMaxSize      = "max-size"
+	flagLogrotateNoCompress   = "no-compress"
+	flagLogrotateCopyTruncate = "copy-truncate"
+	flagLogrotateSu           = "su"
+)
+
+const logrotateDir = "/etc/logrotate.d"
+
+var logrotateFrequencies = []string{"hourly", "daily", "weekly", "monthly", "yearly"}
+
+var regexLogrotateSize = regexp.MustCompile(`^\d+[kMG]?$`)
+
+// GenerateLogrotateCommands registers a sub-tree of commands
+func GenerateLogrotateCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logrotate [log_file...]",
+		Short: "Generate logrotate config for log files",
+		Long: `Generate logrotate config for log files, glob patterns are accepted, eg: /var/log/evmosd/*.log.
+The output is validated using 'logrotate --debug' when available.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  generateLogrotate,
+	}
+
+	cmd.Flags().String(flagLogrotateName, "", fmt.Sprintf("name of the file within %s, default is name of directory of the first log file", logrotateDir))
+	cmd.Flags().String(flagLogrotateFrequency, "daily", fmt.Sprintf("rotation frequency: %s", strings.Join(logrotateFrequencies, ", ")))
+	cmd.Flags().Int(flagLogrotateRotate, 7, "number of rotated files to keep")
+	cmd.Flags().String(flagLogrotateMaxSize, "", "rotate when log file grows bigger than the size, even before the time interval, eg: 100M")
+	cmd.Flags().Bool(flagLogrotateNoCompress, false, "do not compress rotated files")
+	cmd.Flags().Bool(flagLogrotateCopyTruncate, true, "truncate the original log file after creating a copy, for processes those keep the log file opened")
+	cmd.Flags().String(flagLogrotateSu, "", "rotate files as the user (and group), format: user[:group], required when the log directory is writable by non-root user")
+
+	addOutputFlags(cmd, fmt.Sprintf("into %s/<name>", logrotateDir))
+
+	return cmd
+}
+
+// logrotateConfig holds the information to build logrotate config
+type logrotateConfig struct {
+	logFiles     []string
+	frequency    string
+	rotate       int
+	maxSize      string
+	compress     bool
+	copyTruncate bool
+	suUser       string
+	suGroup      string
+}
+
+func generateLogrotate(cmd *cobra.Command, args []string) {
+	cfg := logrotateConfig{
+		logFiles: args,
+	}
+
+	cfg.frequency, _ = cmd.Flags().GetString(flagLogrotateFrequency)
+	cfg.rotate, _ = cmd.Flags().GetInt(flagLogrotateRotate)
+	cfg.maxSize, _ = cmd.Flags().GetString(flagLogrotateMaxSize)
+	noCompress, _ := cmd.Flags().GetBool(flagLogrotateNoCompress)
+	cfg.compress = !noCompress
+	cfg.copyTruncate, _ = cmd.Flags().GetBool(flagLogrotateCopyTruncate)
+
+	su, _ := cmd.Flags().GetString(flagLogrotateSu)
+	if su = strings.TrimSpace(su); len(su) > 0 {
+		spl := strings.SplitN(su, ":", 2)
+		cfg.suUser = spl[0]
+		cfg.suGroup = spl[0]
+		if len(spl) > 1 {
+			cfg.suGroup = spl[1]
+		}
+	}
+
+	content, err := buildLogrotateConfig(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	name, _ := cmd.Flags().GetString(flagLogrotateName)
+	name = strings.TrimSpace(name)
+	if len(name) < 1 {
+		name = strings.ReplaceAll(filepath.Base(filepath.Dir(cfg.logFiles[0])), ".", "_")
+	}
+	if !regexIncludedConfigFileName.MatchString(name) {
+		panic(fmt.Errorf("malformed name \"%s\", files within %s those name contains '.' are ignored", name, logrotateDir))
+	}
+
+	output := readGeneratedOutputFromFlags(cmd, path.Join(logrotateDir, name))
+
+	if err := validateLogrotateConfig(content); err != nil {
+		if len(output.filePath) > 0 && !output.diff {
+			panic(errors.Wrap(err, "refused to write invalid logrotate config"))
+		}
+		libutils.PrintlnStdErr("WARN:", err.Error())
+	}
+
+	output.emit(content, 0o644)
+}
+
+// buildLogrotateConfig builds content of the logrotate config
+func buildLogrotateConfig(cfg logrotateConfig) (string, error) {
+	if len(cfg.logFiles) < 1 {
+		return "", fmt.Errorf("at least one log file is required")
+	}
+
+	quotedLogFiles := make([]string, len(cfg.logFiles))
+	for i, logFile := range cfg.logFiles {
+		if !filepath.IsAbs(logFile) {
+			return "", fmt.Errorf("log file must be absolute: %s", logFile)
+		}
+		if strings.ContainsAny(logFile, "\"'{}\n\r#") {
+			return "", fmt.Errorf("log file must not contain quotes, braces, '#' or new line: %s", logFile)
+		}
+		if strings.ContainsAny(logFile, " \t") {
+			quotedLogFiles[i] = fmt.Sprintf("\"%s\"", logFile)
+		} else {
+			quotedLogFiles[i] = logFile
+		}
+	}
+
+	var isValidFrequency bool
+	for _, frequency := range logrotateFrequencies {
+		if cfg.frequency == frequency {
+			isValidFrequency = true
+			break
+		}
+	}
+	if !isValidFrequency {
+		return "", fmt.Errorf("unknown frequency \"%s\", supported: %s", cfg.frequency, strings.Join(logrotateFrequencies, ", "))
+	}
+
+	if cfg.rotate < 0 {
+		return "", fmt.Errorf("number of rotated files to keep must not be negative")
+	}
+
+	if len(cfg.maxSize) > 0 && !regexLogrotateSize.MatchString(cfg.maxSize) {
+		return "", fmt.Errorf("malformed size \"%s\", eg: 100k, 100M, 1G", cfg.maxSize)
+	}
+
+	if len(cfg.suUser) > 0 || len(cfg.suGroup) > 0 {
+		if !regexUserName.MatchString(cfg.suUser) {
+			return "", fmt.Errorf("malformed su user \"%s\"", cfg.suUser)
+		}
+		if !regexUserName.MatchString(cfg.suGroup) {
+			return "", fmt.Errorf("malformed su group \"%s\"", cfg.suGroup)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by House Keeper\n")
+	sb.WriteString(fmt.Sprintf("%s {\n", strings.Join(quotedLogFiles, " ")))
+	sb.WriteString(fmt.Sprintf("    %s\n", cfg.frequency))
+	sb.WriteString(fmt.Sprintf("    rotate %d\n", cfg.rotate))
+	if len(cfg.maxSize) > 0 {
+		sb.WriteString(fmt.Sprintf("    maxsize %s\n", cfg.maxSize))
+	}
+	sb.WriteString("    missingok\n")
+	sb.WriteString("    notifempty\n")
+	if cfg.compress {
+		sb.WriteString("    compress\n")
+		sb.WriteString("    delaycompress\n")
+	}
+	if cfg.copyTruncate {
+		sb.WriteString("    copytruncate\n")
+	}
+	if len(cfg.suUser) > 0 {
+		sb.WriteString(fmt.Sprintf("    su %s %s\n", cfg.suUser, cfg.suGroup))
+	}
+	sb.WriteString("}\n")
+
+	return sb.String(), nil
+}
+
+// validateLogrotateConfig validates the logrotate config using 'logrotate --debug', nothing is rotated.
+// Validation is skipped with a warning if logrotate is not available.
+func validateLogrotateConfig(content string) error {
+	logrotatePath, err := exec.LookPath("logrotate")
+	if err != nil {
+		libutils.PrintlnStdErr("WARN: logrotate could not be found, the output was not validated by logrotate")
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "hkd-logrotate-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary directory for validation")
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+
+	configFile := path.Join(tmpDir, "config")
+	if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+		return errors.Wrap(err, "failed to write temporary file for validation")
+	}
+
+	output, err := exec.Command(logrotatePath, "--debug", "--state", path.Join(tmpDir, "state"), configFile).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("logrotate config is invalid: %s", strings.ReplaceAll(strings.TrimSpace(string(output)), configFile, "<output>"))
+	}
+
+	return nil
+}
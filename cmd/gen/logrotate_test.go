@@ -0,0 +1,129 @@
+package gen
+
+import (
+	"testing"
+)
+
+func Test_buildLogrotateConfig(t *testing.T) {
+	validCfg := func() logrotateConfig {
+		return logrotateConfig{
+			logFiles:     []string{"/var/log/evmosd/*.log"},
+			frequency:    "daily",
+			rotate:       7,
+			compress:     true,
+			copyTruncate: true,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *logrotateConfig)
+		wantErr bool
+		want    string
+	}{
+		{
+			name: "default",
+			want: `# Generated by House Keeper
+/var/log/evmosd/*.log {
+    daily
+    rotate 7
+    missingok
+    notifempty
+    compress
+    delaycompress
+    copytruncate
+}
+`,
+		},
+		{
+			name: "multiple files, max size, su, no compress",
+			modify: func(cfg *logrotateConfig) {
+				cfg.logFiles = append(cfg.logFiles, "/var/log/my app/app.log")
+				cfg.frequency = "weekly"
+				cfg.rotate = 4
+				cfg.maxSize = "100M"
+				cfg.compress = false
+				cfg.copyTruncate = false
+				cfg.suUser = "validator"
+				cfg.suGroup = "adm"
+			},
+			want: `# Generated by House Keeper
+/var/log/evmosd/*.log "/var/log/my app/app.log" {
+    weekly
+    rotate 4
+    maxsize 100M
+    missingok
+    notifempty
+    su validator adm
+}
+`,
+		},
+		{
+			name: "relative log file",
+			modify: func(cfg *logrotateConfig) {
+				cfg.logFiles = []string{"logs/*.log"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "log file contains brace",
+			modify: func(cfg *logrotateConfig) {
+				cfg.logFiles = []string{"/var/log/a.log {\n}"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown frequency",
+			modify: func(cfg *logrotateConfig) {
+				cfg.frequency = "minutely"
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative rotate",
+			modify: func(cfg *logrotateConfig) {
+				cfg.rotate = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed size",
+			modify: func(cfg *logrotateConfig) {
+				cfg.maxSize = "100MB"
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed su group",
+			modify: func(cfg *logrotateConfig) {
+				cfg.suUser = "validator"
+				cfg.suGroup = "adm wheel"
+			},
+			wantErr: true,
+		},
+		{
+			name: "no log file",
+			modify: func(cfg *logrotateConfig) {
+				cfg.logFiles = nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validCfg()
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+
+			got, err := buildLogrotateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildLogrotateConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("buildLogrotateConfig() got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
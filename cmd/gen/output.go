@@ -0,0 +1,101 @@
+package gen
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const (
+	flagOutputFile = "output-file"
+	flagInstall    = "install"
+	flagDiff       = "diff"
+)
+
+// addOutputFlags adds flags to write the generated content into a file, to install it or to print the diff
+func addOutputFlags(cmd *cobra.Command, installDescription string) {
+	cmd.Flags().String(flagOutputFile, "", "write the generated content into the file instead of printing to stdout")
+	cmd.Flags().Bool(flagInstall, false, fmt.Sprintf("install the generated content, %s. Requires root privilege", installDescription))
+	cmd.Flags().Bool(flagDiff, false, fmt.Sprintf("print diff between the existing file (--%s or the installed one) and the generated content, without writing", flagOutputFile))
+}
+
+// generatedOutput describes where the generated content goes
+type generatedOutput struct {
+	filePath string // empty means stdout
+	install  bool
+	diff     bool
+}
+
+// readGeneratedOutputFromFlags reads the output flags, installPath is the path the content will be installed into
+func readGeneratedOutputFromFlags(cmd *cobra.Command, installPath string) generatedOutput {
+	outputFile, _ := cmd.Flags().GetString(flagOutputFile)
+	install, _ := cmd.Flags().GetBool(flagInstall)
+	diff, _ := cmd.Flags().GetBool(flagDiff)
+
+	outputFile = strings.TrimSpace(outputFile)
+	if install && len(outputFile) > 0 {
+		panic(fmt.Errorf("flags --%s and --%s can not be used together", flagInstall, flagOutputFile))
+	}
+	if install && diff {
+		panic(fmt.Errorf("flags --%s and --%s can not be used together", flagInstall, flagDiff))
+	}
+
+	output := generatedOutput{
+		filePath: outputFile,
+		install:  install,
+		diff:     diff,
+	}
+
+	if install || (diff && len(outputFile) < 1) {
+		output.filePath = installPath
+	}
+
+	return output
+}
+
+// emit prints the content to stdout, or prints the diff against the existing file,
+// or writes the content into the file atomically.
+// Returns true if the file was written.
+func (o generatedOutput) emit(content string, mode os.FileMode) (written bool) {
+	if len(o.filePath) < 1 {
+		fmt.Print(content)
+		return false
+	}
+
+	var existingContent string
+	bz, err := os.ReadFile(o.filePath)
+	if err == nil {
+		existingContent = string(bz)
+	} else if !os.IsNotExist(err) {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to read existing file %s", o.filePath)))
+	}
+
+	if o.diff {
+		diff := utils.UnifiedDiff(o.filePath, o.filePath, existingContent, content)
+		if len(diff) < 1 {
+			fmt.Println("No changes")
+		} else {
+			fmt.Print(diff)
+		}
+		return false
+	}
+
+	if o.install && os.Geteuid() != 0 {
+		panic(fmt.Errorf("--%s requires root privilege, run with sudo", flagInstall))
+	}
+
+	if err == nil && existingContent == content {
+		fmt.Println("No changes to output file:", o.filePath)
+		return false
+	}
+
+	if err := utils.WriteFileAtomically(o.filePath, []byte(content), mode); err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Output file:", o.filePath)
+	return true
+}
@@ -1,9 +1,25 @@
 package gen
 
 import (
+	"fmt"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
 )
 
+const (
+	flagUser         = "user"
+	flagRegistryFile = "registry-file"
+)
+
+var regexUserName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z\d_.-]*$`)
+
+// files within /etc/sudoers.d, /etc/logrotate.d and /etc/cron.d are ignored if the name contains '.'
+var regexIncludedConfigFileName = regexp.MustCompile(`^[a-zA-Z\d_-]+$`)
+
 // Commands registers a sub-tree of commands
 func Commands() *cobra.Command {
 	cmd := &cobra.Command{
@@ -14,7 +30,34 @@ func Commands() *cobra.Command {
 
 	cmd.AddCommand(
 		GenerateVisudoCommands(),
+		GenerateSystemdCommands(),
+		GenerateLogrotateCommands(),
+		GenerateCronCommands(),
 	)
 
 	return cmd
 }
+
+// readUserFromFlags returns the user provided via flag --user,
+// or the user invoked sudo, or the current user
+func readUserFromFlags(cmd *cobra.Command) *user.User {
+	userName, _ := cmd.Flags().GetString(flagUser)
+	userName = strings.TrimSpace(userName)
+	if len(userName) < 1 {
+		userName = strings.TrimSpace(os.Getenv("SUDO_USER"))
+	}
+
+	if len(userName) < 1 {
+		u, err := user.Current()
+		if err != nil {
+			panic(errors.Wrap(err, "failed to get current user"))
+		}
+		return u
+	}
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to lookup user %s", userName)))
+	}
+	return u
+}
@@ -0,0 +1,346 @@
+package gen
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	flagSystemdTemplate      = "template"
+	flagSystemdService       = "service"
+	flagSystemdDescription   = "description"
+	flagSystemdHome          = "home"
+	flagSystemdWorkingDir    = "working-directory"
+	flagSystemdArgs          = "args"
+	flagSystemdRestart       = "restart"
+	flagSystemdRestartSec    = "restart-sec"
+	flagSystemdLimitNoFile   = "limit-nofile"
+	flagSystemdHardening     = "hardening"
+	flagSystemdReadWritePath = "read-write-path"
+)
+
+// templates of systemd service unit
+const (
+	systemdTemplateCosmos = "cosmos"
+	systemdTemplateDaemon = "daemon"
+)
+
+// levels of sandboxing options
+const (
+	systemdHardeningNone   = "none"
+	systemdHardeningBasic  = "basic"
+	systemdHardeningStrict = "strict"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+var systemdRestartPolicies = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+var regexSystemdServiceName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.@-]*$`)
+
+// GenerateSystemdCommands registers a sub-tree of commands
+func GenerateSystemdCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "systemd [binary]",
+		Short: "Generate hardened systemd service unit for the binary",
+		Long: fmt.Sprintf(`Generate hardened systemd service unit for the binary.
+Templates:
+- %s: Cosmos node, started by '<binary> start --home <home>'
+- %s: plain daemon, started by '<binary> <args>'
+Default template is %s for nodes declared in the node registry those support reset action, otherwise %s.`,
+			systemdTemplateCosmos, systemdTemplateDaemon, systemdTemplateCosmos, systemdTemplateDaemon),
+		Args: cobra.ExactArgs(1),
+		Run:  generateSystemd,
+	}
+
+	cmd.Flags().String(flagSystemdTemplate, "", fmt.Sprintf("template of the unit: %s or %s", systemdTemplateCosmos, systemdTemplateDaemon))
+	cmd.Flags().String(flagSystemdService, "", "service name, default is service name in the node registry or the binary name")
+	cmd.Flags().String(flagSystemdDescription, "", "description of the unit")
+	cmd.Flags().String(flagUser, "", "user to run the service, default is the user invoked sudo or current user")
+	cmd.Flags().String(flagSystemdHome, "", fmt.Sprintf("(%s) home directory of the node, default is the one in the node registry or ~/.<binary> of the user", systemdTemplateCosmos))
+	cmd.Flags().String(flagSystemdWorkingDir, "", fmt.Sprintf("(%s) working directory of the daemon, default is home directory of the user", systemdTemplateDaemon))
+	cmd.Flags().String(flagSystemdArgs, "", "additional arguments to be passed to the binary, eg: \"--x-crisis-skip-assert-invariants\"")
+	cmd.Flags().String(flagSystemdRestart, "on-failure", fmt.Sprintf("restart policy: %s", strings.Join(systemdRestartPolicies, ", ")))
+	cmd.Flags().Duration(flagSystemdRestartSec, 3*time.Second, "time to sleep before restarting the service")
+	cmd.Flags().Uint(flagSystemdLimitNoFile, 65535, "maximum number of open files")
+	cmd.Flags().String(flagSystemdHardening, systemdHardeningBasic, fmt.Sprintf("sandboxing options: %s, %s (NoNewPrivileges, PrivateTmp, ProtectSystem=full...) or %s (ProtectSystem=strict, ProtectHome=read-only... only home/working directory and --%s are writable)", systemdHardeningNone, systemdHardeningBasic, systemdHardeningStrict, flagSystemdReadWritePath))
+	cmd.Flags().StringSlice(flagSystemdReadWritePath, nil, fmt.Sprintf("(%s hardening) additional writable paths", systemdHardeningStrict))
+	cmd.Flags().String(flagRegistryFile, node.DefaultRegistryFilePath(), "node registry file")
+
+	addOutputFlags(cmd, fmt.Sprintf("into %s/<service>.service then reload systemd", systemdUnitDir))
+
+	return cmd
+}
+
+// systemdUnitConfig holds the information to build the systemd service unit
+type systemdUnitConfig struct {
+	template       string
+	description    string
+	user           string
+	binaryPath     string
+	args           []string
+	workingDir     string
+	restart        string
+	restartSec     time.Duration
+	limitNoFile    uint
+	hardening      string
+	readWritePaths []string
+}
+
+func generateSystemd(cmd *cobra.Command, args []string) {
+	binary := strings.TrimSpace(args[0])
+
+	registryFile, _ := cmd.Flags().GetString(flagRegistryFile)
+	nodes, err := node.LoadRegistry(registryFile)
+	if err != nil {
+		panic(err)
+	}
+
+	registryNode := node.Node{Binary: filepath.Base(binary)}
+	for _, n := range nodes {
+		if n.Binary == registryNode.Binary {
+			registryNode = n
+			break
+		}
+	}
+
+	serviceName, _ := cmd.Flags().GetString(flagSystemdService)
+	serviceName = strings.TrimSuffix(strings.TrimSpace(serviceName), ".service")
+	if len(serviceName) < 1 {
+		serviceName = registryNode.ServiceName()
+	}
+	if !regexSystemdServiceName.MatchString(serviceName) {
+		panic(fmt.Errorf("malformed service name \"%s\"", serviceName))
+	}
+
+	cfg := systemdUnitConfig{}
+
+	cfg.template, _ = cmd.Flags().GetString(flagSystemdTemplate)
+	if len(cfg.template) < 1 {
+		if registryNode.HasAction(node.ActionReset) {
+			cfg.template = systemdTemplateCosmos
+		} else {
+			cfg.template = systemdTemplateDaemon
+		}
+	}
+
+	cfg.binaryPath, err = resolveBinaryPath(binary)
+	if err != nil {
+		panic(err)
+	}
+
+	serviceUser := readUserFromFlags(cmd)
+	cfg.user = serviceUser.Username
+
+	if cfg.template == systemdTemplateCosmos {
+		cfg.workingDir, _ = cmd.Flags().GetString(flagSystemdHome)
+		if len(strings.TrimSpace(cfg.workingDir)) < 1 {
+			cfg.workingDir = registryNode.HomeDirOf(serviceUser.HomeDir)
+		}
+	} else {
+		cfg.workingDir, _ = cmd.Flags().GetString(flagSystemdWorkingDir)
+		if len(strings.TrimSpace(cfg.workingDir)) < 1 {
+			cfg.workingDir = serviceUser.HomeDir
+		}
+	}
+	cfg.workingDir = strings.TrimSpace(cfg.workingDir)
+
+	cfg.description, _ = cmd.Flags().GetString(flagSystemdDescription)
+	if len(strings.TrimSpace(cfg.description)) < 1 {
+		if cfg.template == systemdTemplateCosmos {
+			cfg.description = fmt.Sprintf("%s node", registryNode.Binary)
+		} else {
+			cfg.description = fmt.Sprintf("%s daemon", registryNode.Binary)
+		}
+	}
+
+	extraArgs, _ := cmd.Flags().GetString(flagSystemdArgs)
+	cfg.args, err = utils.SplitShellWords(extraArgs)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to parse --%s", flagSystemdArgs)))
+	}
+
+	cfg.restart, _ = cmd.Flags().GetString(flagSystemdRestart)
+	cfg.restartSec, _ = cmd.Flags().GetDuration(flagSystemdRestartSec)
+	cfg.limitNoFile, _ = cmd.Flags().GetUint(flagSystemdLimitNoFile)
+	cfg.hardening, _ = cmd.Flags().GetString(flagSystemdHardening)
+	cfg.readWritePaths, _ = cmd.Flags().GetStringSlice(flagSystemdReadWritePath)
+
+	content, err := buildSystemdUnit(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	output := readGeneratedOutputFromFlags(cmd, path.Join(systemdUnitDir, serviceName+".service"))
+	if !output.emit(content, 0o644) || !output.install {
+		return
+	}
+
+	if ec := utils.LaunchAppWithDirectStd("systemctl", []string{"daemon-reload"}, nil); ec != 0 {
+		os.Exit(ec)
+	}
+
+	fmt.Printf("Service %s was installed, to enable and start it:\n", serviceName)
+	fmt.Printf("hkd svc enable %s --now\n", serviceName)
+}
+
+// resolveBinaryPath returns the absolute path of the binary, binary name is looked up within PATH
+func resolveBinaryPath(binary string) (string, error) {
+	if strings.Contains(binary, "/") {
+		absPath, err := filepath.Abs(binary)
+		if err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("failed to resolve absolute path of %s", binary))
+		}
+		if _, err := os.Stat(absPath); err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("binary %s does not exist", absPath))
+		}
+		return absPath, nil
+	}
+
+	binaryPath, err := exec.LookPath(binary)
+	if err != nil {
+		return "", fmt.Errorf("binary %s could not be found within PATH, provide the absolute path of the binary", binary)
+	}
+
+	return filepath.Abs(binaryPath)
+}
+
+// buildSystemdUnit builds content of the systemd service unit
+func buildSystemdUnit(cfg systemdUnitConfig) (string, error) {
+	if cfg.template != systemdTemplateCosmos && cfg.template != systemdTemplateDaemon {
+		return "", fmt.Errorf("unknown template \"%s\", supported templates: %s, %s", cfg.template, systemdTemplateCosmos, systemdTemplateDaemon)
+	}
+
+	if !filepath.IsAbs(cfg.binaryPath) {
+		return "", fmt.Errorf("binary path must be absolute: %s", cfg.binaryPath)
+	}
+
+	if !filepath.IsAbs(cfg.workingDir) {
+		return "", fmt.Errorf("working directory must be absolute: %s", cfg.workingDir)
+	}
+
+	if len(cfg.user) < 1 || strings.ContainsAny(cfg.user, " \t\n") {
+		return "", fmt.Errorf("malformed user name \"%s\"", cfg.user)
+	}
+
+	if strings.ContainsAny(cfg.description, "\n\r") {
+		return "", fmt.Errorf("description must be single line")
+	}
+
+	var isValidRestartPolicy bool
+	for _, policy := range systemdRestartPolicies {
+		if cfg.restart == policy {
+			isValidRestartPolicy = true
+			break
+		}
+	}
+	if !isValidRestartPolicy {
+		return "", fmt.Errorf("unknown restart policy \"%s\", supported policies: %s", cfg.restart, strings.Join(systemdRestartPolicies, ", "))
+	}
+
+	if cfg.restartSec < 0 || cfg.restartSec%time.Second != 0 {
+		return "", fmt.Errorf("restart delay must be non-negative whole seconds: %s", cfg.restartSec)
+	}
+
+	if cfg.limitNoFile < 1 {
+		return "", fmt.Errorf("limit of open files must be positive")
+	}
+
+	var readWritePaths []string
+	switch cfg.hardening {
+	case systemdHardeningNone, systemdHardeningBasic:
+		if len(cfg.readWritePaths) > 0 {
+			return "", fmt.Errorf("writable paths are only supported by %s hardening", systemdHardeningStrict)
+		}
+	case systemdHardeningStrict:
+		readWritePaths = append([]string{cfg.workingDir}, cfg.readWritePaths...)
+		for _, readWritePath := range readWritePaths {
+			if !filepath.IsAbs(readWritePath) || strings.ContainsAny(readWritePath, " \t\n\"'") {
+				return "", fmt.Errorf("writable path must be absolute and must not contain whitespace or quotes: %s", readWritePath)
+			}
+		}
+	default:
+		return "", fmt.Errorf("unknown hardening \"%s\", supported: %s, %s, %s", cfg.hardening, systemdHardeningNone, systemdHardeningBasic, systemdHardeningStrict)
+	}
+
+	execStart := []string{cfg.binaryPath}
+	if cfg.template == systemdTemplateCosmos {
+		execStart = append(execStart, "start", "--home", cfg.workingDir)
+	}
+	execStart = append(execStart, cfg.args...)
+
+	quotedExecStart := make([]string, len(execStart))
+	for i, word := range execStart {
+		quotedExecStart[i] = quoteSystemdArg(word)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by House Keeper\n")
+	sb.WriteString("[Unit]\n")
+	sb.WriteString(fmt.Sprintf("Description=%s\n", escapeSystemdSpecifiers(cfg.description)))
+	sb.WriteString("After=network-online.target\n")
+	sb.WriteString("Wants=network-online.target\n")
+	sb.WriteString("\n[Service]\n")
+	sb.WriteString("Type=simple\n")
+	sb.WriteString(fmt.Sprintf("User=%s\n", cfg.user))
+	sb.WriteString(fmt.Sprintf("WorkingDirectory=%s\n", escapeSystemdSpecifiers(cfg.workingDir)))
+	sb.WriteString(fmt.Sprintf("ExecStart=%s\n", strings.Join(quotedExecStart, " ")))
+	sb.WriteString(fmt.Sprintf("Restart=%s\n", cfg.restart))
+	sb.WriteString(fmt.Sprintf("RestartSec=%d\n", int64(cfg.restartSec/time.Second)))
+	sb.WriteString(fmt.Sprintf("LimitNOFILE=%d\n", cfg.limitNoFile))
+
+	if cfg.hardening != systemdHardeningNone {
+		sb.WriteString("\n# Sandboxing\n")
+		sb.WriteString("NoNewPrivileges=true\n")
+		sb.WriteString("PrivateTmp=true\n")
+		sb.WriteString("ProtectControlGroups=true\n")
+		sb.WriteString("ProtectKernelModules=true\n")
+		sb.WriteString("ProtectKernelTunables=true\n")
+		sb.WriteString("RestrictSUIDSGID=true\n")
+
+		if cfg.hardening == systemdHardeningStrict {
+			sb.WriteString("ProtectSystem=strict\n")
+			sb.WriteString("ProtectHome=read-only\n")
+			sb.WriteString("PrivateDevices=true\n")
+			sb.WriteString("LockPersonality=true\n")
+			sb.WriteString("RestrictRealtime=true\n")
+			sb.WriteString(fmt.Sprintf("ReadWritePaths=%s\n", escapeSystemdSpecifiers(strings.Join(readWritePaths, " "))))
+		} else {
+			sb.WriteString("ProtectSystem=full\n")
+		}
+	}
+
+	sb.WriteString("\n[Install]\n")
+	sb.WriteString("WantedBy=multi-user.target\n")
+
+	return sb.String(), nil
+}
+
+// escapeSystemdSpecifiers escapes '%' which is used as prefix of specifiers in unit files
+func escapeSystemdSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// quoteSystemdArg quotes argument of ExecStart, so it is passed to the binary as-is
+func quoteSystemdArg(arg string) string {
+	arg = escapeSystemdSpecifiers(arg)
+	arg = strings.ReplaceAll(arg, "$", "$$")
+
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\"'\\;") {
+		return arg
+	}
+
+	arg = strings.ReplaceAll(arg, "\\", "\\\\")
+	arg = strings.ReplaceAll(arg, "\"", "\\\"")
+	return "\"" + arg + "\""
+}
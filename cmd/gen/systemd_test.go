@@ -0,0 +1,195 @@
+package gen
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_buildSystemdUnit(t *testing.T) {
+	validCfg := func() systemdUnitConfig {
+		return systemdUnitConfig{
+			template:    systemdTemplateCosmos,
+			description: "evmosd node",
+			user:        "validator",
+			binaryPath:  "/home/validator/go/bin/evmosd",
+			workingDir:  "/home/validator/.evmosd",
+			restart:     "on-failure",
+			restartSec:  3 * time.Second,
+			limitNoFile: 65535,
+			hardening:   systemdHardeningBasic,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(cfg *systemdUnitConfig)
+		wantErr     bool
+		wantLines   []string
+		unwantLines []string
+	}{
+		{
+			name: "cosmos",
+			wantLines: []string{
+				"Description=evmosd node",
+				"User=validator",
+				"WorkingDirectory=/home/validator/.evmosd",
+				"ExecStart=/home/validator/go/bin/evmosd start --home /home/validator/.evmosd",
+				"Restart=on-failure",
+				"RestartSec=3",
+				"LimitNOFILE=65535",
+				"NoNewPrivileges=true",
+				"ProtectSystem=full",
+				"WantedBy=multi-user.target",
+			},
+			unwantLines: []string{
+				"ProtectHome=read-only",
+			},
+		},
+		{
+			name: "daemon with args",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.template = systemdTemplateDaemon
+				cfg.binaryPath = "/usr/local/bin/crawld"
+				cfg.workingDir = "/home/validator"
+				cfg.args = []string{"--config", "/etc/crawld/my config.yaml", "--rate", "50%", "$HOME"}
+			},
+			wantLines: []string{
+				`ExecStart=/usr/local/bin/crawld --config "/etc/crawld/my config.yaml" --rate 50%% $$HOME`,
+			},
+		},
+		{
+			name: "strict hardening",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.hardening = systemdHardeningStrict
+				cfg.readWritePaths = []string{"/var/log/evmosd"}
+			},
+			wantLines: []string{
+				"ProtectSystem=strict",
+				"ProtectHome=read-only",
+				"ReadWritePaths=/home/validator/.evmosd /var/log/evmosd",
+			},
+			unwantLines: []string{
+				"ProtectSystem=full",
+			},
+		},
+		{
+			name: "no hardening",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.hardening = systemdHardeningNone
+			},
+			unwantLines: []string{
+				"NoNewPrivileges=true",
+				"ProtectSystem=full",
+			},
+		},
+		{
+			name: "writable paths require strict hardening",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.readWritePaths = []string{"/var/log/evmosd"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative writable path",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.hardening = systemdHardeningStrict
+				cfg.readWritePaths = []string{"logs"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown template",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.template = "docker"
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown restart policy",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.restart = "sometimes"
+			},
+			wantErr: true,
+		},
+		{
+			name: "restart delay is not whole seconds",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.restartSec = 1500 * time.Millisecond
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative binary path",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.binaryPath = "evmosd"
+			},
+			wantErr: true,
+		},
+		{
+			name: "multi-line description",
+			modify: func(cfg *systemdUnitConfig) {
+				cfg.description = "evmosd\nExecStartPre=/bin/rm"
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validCfg()
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+
+			got, err := buildSystemdUnit(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildSystemdUnit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			lines := strings.Split(got, "\n")
+			hasLine := func(line string) bool {
+				for _, l := range lines {
+					if l == line {
+						return true
+					}
+				}
+				return false
+			}
+
+			for _, line := range tt.wantLines {
+				if !hasLine(line) {
+					t.Errorf("buildSystemdUnit() missing line %s, got:\n%s", line, got)
+				}
+			}
+			for _, line := range tt.unwantLines {
+				if hasLine(line) {
+					t.Errorf("buildSystemdUnit() unexpected line %s, got:\n%s", line, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_quoteSystemdArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{arg: "start", want: "start"},
+		{arg: "", want: `""`},
+		{arg: "a b", want: `"a b"`},
+		{arg: `say "hi"`, want: `"say \"hi\""`},
+		{arg: `C:\path`, want: `"C:\\path"`},
+		{arg: "100%", want: "100%%"},
+		{arg: "$PATH", want: "$$PATH"},
+		{arg: "a;b", want: `"a;b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			if got := quoteSystemdArg(tt.arg); got != tt.want {
+				t.Errorf("quoteSystemdArg() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,64 +1,311 @@
 package gen
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
 	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"os/user"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
-	"time"
+	"text/template"
 )
 
+const (
+	flagVisudoRoles       = "role"
+	flagVisudoTemplateDir = "template-dir"
+	flagVisudoName        = "name"
+)
+
+// built-in role profiles
+const (
+	sudoersRoleServiceManager = "service-manager"
+	sudoersRoleFirewallReader = "firewall-reader"
+	sudoersRoleReboot         = "reboot"
+)
+
+const sudoersDir = "/etc/sudoers.d"
+
+const sudoersTemplateFileExtension = ".tmpl"
+
+//go:embed templates/sudoers/*.tmpl
+var builtInSudoersTemplates embed.FS
+
+var regexSudoersServiceName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.@-]*$`)
+var regexSudoersRole = regexp.MustCompile(`^[a-z\d][a-z\d_-]*$`)
+
 // GenerateVisudoCommands registers a sub-tree of commands
 func GenerateVisudoCommands() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "visudo [service] [user_name]",
-		Short: "Generate config for user to start/stop read log of service",
-		Args:  cobra.RangeArgs(1, 2),
-		Run:   generateVisudo,
+		Use:   "visudo [service...]",
+		Short: "Generate sudoers config for user, from role profiles",
+		Long: fmt.Sprintf(`Generate sudoers config for user, from role profiles.
+Built-in roles:
+- %s: start/stop/restart/enable/disable/status the services, and read logs via journalctl
+- %s: get current firewall status via ufw
+- %s: restart/shutdown server
+Custom roles can be provided as template files <role>%s within --%s, they override the built-in roles of the same name.
+Services can be specified by service name, or by binary name or alias prefix of nodes declared in the node registry.
+The output is validated using 'visudo -cf'.`,
+			sudoersRoleServiceManager, sudoersRoleFirewallReader, sudoersRoleReboot,
+			sudoersTemplateFileExtension, flagVisudoTemplateDir,
+		),
+		Run: generateVisudo,
 	}
 
+	cmd.Flags().String(flagUser, "", "user to grant permissions to, default is the user invoked sudo or current user")
+	cmd.Flags().StringSlice(flagVisudoRoles, []string{sudoersRoleServiceManager}, "role profiles to be granted")
+	cmd.Flags().String(flagVisudoTemplateDir, "", "directory contains custom role templates")
+	cmd.Flags().String(flagVisudoName, "", fmt.Sprintf("name of the file within %s, default is the user name", sudoersDir))
+	cmd.Flags().String(flagRegistryFile, node.DefaultRegistryFilePath(), "node registry file")
+
+	addOutputFlags(cmd, fmt.Sprintf("into %s/<name> with mode 0440", sudoersDir))
+
 	return cmd
 }
 
-func generateVisudo(_ *cobra.Command, args []string) {
-	service := args[0]
-	var userName string
-	if len(args) < 2 {
-		u, err := user.Current()
-		libutils.PanicIfErr(err, "failed to get current user")
-		userName = u.Username
-	} else {
-		userName = args[1]
-	}
-	fmt.Println("Generating visudo config...")
-	fmt.Println("Username:", userName)
-	fmt.Println("Service :", service)
-	time.Sleep(5 * time.Second)
-
-	template := `# Allow user '$USR' to manage '$SVC' service
-$USR ALL= NOPASSWD: /usr/bin/systemctl start $SVC
-$USR ALL= NOPASSWD: /usr/bin/systemctl stop $SVC
-$USR ALL= NOPASSWD: /usr/bin/systemctl restart $SVC
-$USR ALL= NOPASSWD: /usr/bin/systemctl enable $SVC
-$USR ALL= NOPASSWD: /usr/bin/systemctl disable $SVC
-$USR ALL= NOPASSWD: /usr/bin/systemctl status $SVC
-$USR ALL= NOPASSWD: /usr/bin/journalctl`
-	if userName == "statd" {
-		template += `
-
-# Allow user '$USR' to get current firewall status
-$USR ALL= NOPASSWD: /usr/sbin/ufw status`
-	} else if userName == "bots" {
-		template += `
-
-# Allow user '$USR' to restart/shutdown server
-$USR ALL= NOPASSWD: /usr/sbin/reboot
-$USR ALL= NOPASSWD: /usr/sbin/shutdown now`
-	}
-
-	content := strings.ReplaceAll(template, "$USR", userName)
-	content = strings.ReplaceAll(content, "$SVC", service)
-
-	fmt.Println(content)
+// sudoersTemplateData is the data provided to role templates
+type sudoersTemplateData struct {
+	User     string
+	Services []string
+}
+
+func generateVisudo(cmd *cobra.Command, args []string) {
+	registryFile, _ := cmd.Flags().GetString(flagRegistryFile)
+	nodes, err := node.LoadRegistry(registryFile)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := checkLegacyVisudoArgs(args, cmd.Flags().Changed(flagUser), nodes); err != nil {
+		panic(err)
+	}
+
+	userName := readUserFromFlags(cmd).Username
+	roles, _ := cmd.Flags().GetStringSlice(flagVisudoRoles)
+	templateDir, _ := cmd.Flags().GetString(flagVisudoTemplateDir)
+
+	services, err := resolveSudoersServices(args, nodes)
+	if err != nil {
+		panic(err)
+	}
+
+	content, err := buildSudoers(roles, strings.TrimSpace(templateDir), sudoersTemplateData{
+		User:     userName,
+		Services: services,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	name, _ := cmd.Flags().GetString(flagVisudoName)
+	name = strings.TrimSpace(name)
+	if len(name) < 1 {
+		name = strings.ReplaceAll(userName, ".", "_")
+	}
+	if !regexIncludedConfigFileName.MatchString(name) {
+		panic(fmt.Errorf("malformed name \"%s\", sudo ignores files within %s those name contains '.' or ends with '~'", name, sudoersDir))
+	}
+
+	output := readGeneratedOutputFromFlags(cmd, path.Join(sudoersDir, name))
+
+	if err := validateSudoers(content); err != nil {
+		if len(output.filePath) > 0 && !output.diff {
+			panic(errors.Wrap(err, "refused to write sudoers config without successful validation"))
+		}
+		libutils.PrintlnStdErr("WARN:", err.Error())
+	}
+
+	output.emit(content, 0o440)
+}
+
+// checkLegacyVisudoArgs rejects the legacy syntax 'visudo [service] [user_name]',
+// so the user name is not silently treated as a service while permissions are granted to another user.
+// Two arguments without --user are ambiguous unless the second one is a node of the registry.
+func checkLegacyVisudoArgs(args []string, isUserFlagProvided bool, nodes []node.Node) error {
+	if isUserFlagProvided || len(args) != 2 {
+		return nil
+	}
+
+	second := strings.TrimSpace(args[1])
+	for _, n := range nodes {
+		if n.Binary == second || n.Prefix == second {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"ambiguous arguments \"%s\", legacy syntax 'visudo [service] [user_name]' is no longer supported, arguments are services. Use 'hkd gen visudo %s --%s %s', or provide --%s explicitly if both are services",
+		strings.Join(args, " "), args[0], flagUser, second, flagUser,
+	)
+}
+
+// resolveSudoersServices resolves binary name and alias prefix of nodes into service name
+func resolveSudoersServices(args []string, nodes []node.Node) ([]string, error) {
+	var services []string
+	uniqueServices := make(map[string]bool)
+
+	for _, service := range args {
+		service = strings.TrimSuffix(strings.TrimSpace(service), ".service")
+
+		for _, n := range nodes {
+			if n.Binary == service || n.Prefix == service {
+				service = n.ServiceName()
+				break
+			}
+		}
+
+		if !regexSudoersServiceName.MatchString(service) {
+			return nil, fmt.Errorf("malformed service name \"%s\"", service)
+		}
+
+		if uniqueServices[service] {
+			continue
+		}
+		uniqueServices[service] = true
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// buildSudoers renders templates of the roles, templates within the template directory override the built-in ones
+func buildSudoers(roles []string, templateDir string, data sudoersTemplateData) (string, error) {
+	if !regexUserName.MatchString(data.User) {
+		return "", fmt.Errorf("malformed user name \"%s\"", data.User)
+	}
+
+	if len(roles) < 1 {
+		return "", fmt.Errorf("at least one role is required")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by House Keeper\n")
+
+	renderedRoles := make(map[string]bool)
+	for _, role := range roles {
+		role = strings.TrimSpace(role)
+		if renderedRoles[role] {
+			continue
+		}
+		renderedRoles[role] = true
+
+		if role == sudoersRoleServiceManager && len(data.Services) < 1 {
+			return "", fmt.Errorf("role %s requires at least one service", role)
+		}
+
+		tmpl, err := loadSudoersTemplate(role, templateDir)
+		if err != nil {
+			return "", err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("failed to render template of role %s", role))
+		}
+
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimSpace(buf.String()))
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
+}
+
+// loadSudoersTemplate loads template of the role from the template directory, or the built-in one
+func loadSudoersTemplate(role, templateDir string) (*template.Template, error) {
+	if !regexSudoersRole.MatchString(role) {
+		return nil, fmt.Errorf("malformed role \"%s\"", role)
+	}
+
+	fileName := role + sudoersTemplateFileExtension
+
+	var bz []byte
+	var err error
+	if len(templateDir) > 0 {
+		bz, err = os.ReadFile(path.Join(templateDir, fileName))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to read template of role %s", role))
+		}
+	}
+
+	if len(bz) < 1 {
+		bz, err = builtInSudoersTemplates.ReadFile(path.Join("templates", "sudoers", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("unknown role \"%s\", available roles: %s", role, strings.Join(availableSudoersRoles(templateDir), ", "))
+		}
+	}
+
+	tmpl, err := template.New(role).Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Option("missingkey=error").Parse(string(bz))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse template of role %s", role))
+	}
+
+	return tmpl, nil
+}
+
+// availableSudoersRoles returns the built-in roles and the roles provided within the template directory
+func availableSudoersRoles(templateDir string) []string {
+	uniqueRoles := make(map[string]bool)
+
+	collect := func(entries []os.DirEntry) {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), sudoersTemplateFileExtension) {
+				uniqueRoles[strings.TrimSuffix(entry.Name(), sudoersTemplateFileExtension)] = true
+			}
+		}
+	}
+
+	if entries, err := builtInSudoersTemplates.ReadDir(path.Join("templates", "sudoers")); err == nil {
+		collect(entries)
+	}
+	if len(templateDir) > 0 {
+		if entries, err := os.ReadDir(templateDir); err == nil {
+			collect(entries)
+		}
+	}
+
+	roles := make([]string, 0, len(uniqueRoles))
+	for role := range uniqueRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// validateSudoers validates the sudoers content using 'visudo -cf'
+func validateSudoers(content string) error {
+	visudoPath, err := exec.LookPath("visudo")
+	if err != nil {
+		return fmt.Errorf("visudo could not be found, the output was not validated")
+	}
+
+	tmpFile, err := os.CreateTemp("", "hkd-sudoers-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file for validation")
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+
+	_, err = tmpFile.WriteString(content)
+	_ = tmpFile.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to write temporary file for validation")
+	}
+
+	output, err := exec.Command(visudoPath, "-c", "-f", tmpFile.Name()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sudoers config is invalid: %s", strings.ReplaceAll(strings.TrimSpace(string(output)), tmpFile.Name(), "<output>"))
+	}
+
+	return nil
 }
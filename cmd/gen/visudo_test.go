@@ -0,0 +1,262 @@
+package gen
+
+import (
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_resolveSudoersServices(t *testing.T) {
+	nodes := []node.Node{
+		{Binary: "evmosd", Prefix: "es"},
+		{Binary: "crawld", Prefix: "ec", Service: "evmos-crawler"},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "service names",
+			args: []string{"nginx", "postgresql.service"},
+			want: []string{"nginx", "postgresql"},
+		},
+		{
+			name: "binary name and alias prefix of nodes, duplicated",
+			args: []string{"es", "ec", "evmosd"},
+			want: []string{"evmosd", "evmos-crawler"},
+		},
+		{
+			name:    "malformed",
+			args:    []string{"nginx, /bin/sh"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSudoersServices(tt.args, nodes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveSudoersServices() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveSudoersServices() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_buildSudoers(t *testing.T) {
+	templateDir := t.TempDir()
+	if err := os.WriteFile(path.Join(templateDir, "reboot.tmpl"), []byte("{{.User}} ALL= NOPASSWD: /usr/sbin/reboot\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(templateDir, "docker-reader.tmpl"), []byte("{{.User}} ALL= NOPASSWD: /usr/bin/docker ps\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(templateDir, "broken.tmpl"), []byte("{{.Unknown}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data := sudoersTemplateData{
+		User:     "bots",
+		Services: []string{"evmosd", "nginx"},
+	}
+
+	tests := []struct {
+		name        string
+		roles       []string
+		templateDir string
+		data        sudoersTemplateData
+		wantErr     bool
+		wantLines   []string
+		unwantLines []string
+	}{
+		{
+			name:  "service manager of multiple services",
+			roles: []string{sudoersRoleServiceManager},
+			data:  data,
+			wantLines: []string{
+				"bots ALL= NOPASSWD: /usr/bin/systemctl restart evmosd, /usr/bin/systemctl restart evmosd.service",
+				"bots ALL= NOPASSWD: /usr/bin/systemctl restart nginx, /usr/bin/systemctl restart nginx.service",
+				"bots ALL= NOPASSWD: /usr/bin/journalctl",
+			},
+			unwantLines: []string{
+				"bots ALL= NOPASSWD: /usr/sbin/reboot",
+			},
+		},
+		{
+			name:  "multiple roles",
+			roles: []string{sudoersRoleFirewallReader, sudoersRoleReboot, sudoersRoleReboot},
+			data:  sudoersTemplateData{User: "statd"},
+			wantLines: []string{
+				"statd ALL= NOPASSWD: /usr/sbin/ufw status, /usr/sbin/ufw status verbose, /usr/sbin/ufw status numbered",
+				"statd ALL= NOPASSWD: /usr/sbin/reboot",
+				"statd ALL= NOPASSWD: /usr/sbin/shutdown now",
+			},
+		},
+		{
+			name:        "custom templates override built-in ones",
+			roles:       []string{sudoersRoleReboot, "docker-reader"},
+			templateDir: templateDir,
+			data:        data,
+			wantLines: []string{
+				"bots ALL= NOPASSWD: /usr/sbin/reboot",
+				"bots ALL= NOPASSWD: /usr/bin/docker ps",
+			},
+			unwantLines: []string{
+				"bots ALL= NOPASSWD: /usr/sbin/shutdown now",
+			},
+		},
+		{
+			name:    "service manager requires services",
+			roles:   []string{sudoersRoleServiceManager},
+			data:    sudoersTemplateData{User: "bots"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown role",
+			roles:   []string{"docker-reader"},
+			data:    data,
+			wantErr: true,
+		},
+		{
+			name:    "malformed role",
+			roles:   []string{"../reboot"},
+			data:    data,
+			wantErr: true,
+		},
+		{
+			name:        "broken template",
+			roles:       []string{"broken"},
+			templateDir: templateDir,
+			data:        data,
+			wantErr:     true,
+		},
+		{
+			name:    "malformed user",
+			roles:   []string{sudoersRoleReboot},
+			data:    sudoersTemplateData{User: "bots ALL=(ALL) ALL"},
+			wantErr: true,
+		},
+		{
+			name:    "no role",
+			data:    data,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildSudoers(tt.roles, tt.templateDir, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("buildSudoers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			lines := strings.Split(got, "\n")
+			hasLine := func(line string) bool {
+				for _, l := range lines {
+					if l == line {
+						return true
+					}
+				}
+				return false
+			}
+
+			for _, line := range tt.wantLines {
+				if !hasLine(line) {
+					t.Errorf("buildSudoers() missing line %s, got:\n%s", line, got)
+				}
+			}
+			for _, line := range tt.unwantLines {
+				if hasLine(line) {
+					t.Errorf("buildSudoers() unexpected line %s, got:\n%s", line, got)
+				}
+			}
+
+			if !tt.wantErr && !strings.HasSuffix(got, "\n") {
+				t.Errorf("buildSudoers() output must end with new line")
+			}
+		})
+	}
+}
+
+func Test_validateSudoers(t *testing.T) {
+	if _, err := exec.LookPath("visudo"); err != nil {
+		t.Skip("visudo is not available")
+	}
+
+	content, err := buildSudoers([]string{sudoersRoleServiceManager, sudoersRoleReboot}, "", sudoersTemplateData{
+		User:     "bots",
+		Services: []string{"evmosd"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateSudoers(content); err != nil {
+		t.Errorf("validateSudoers() error = %v", err)
+	}
+
+	if err := validateSudoers("bots ALL= NOPASSWD /usr/sbin/reboot\n"); err == nil {
+		t.Errorf("validateSudoers() expected error for invalid content")
+	}
+}
+
+func Test_checkLegacyVisudoArgs(t *testing.T) {
+	nodes := []node.Node{
+		{Binary: "evmosd", Prefix: "es"},
+		{Binary: "crawld", Prefix: "ec"},
+	}
+
+	tests := []struct {
+		name               string
+		args               []string
+		isUserFlagProvided bool
+		wantErr            bool
+	}{
+		{
+			name:    "legacy syntax service and user name",
+			args:    []string{"evmosd", "bots"},
+			wantErr: true,
+		},
+		{
+			name:               "two services with user flag",
+			args:               []string{"evmosd", "nginx"},
+			isUserFlagProvided: true,
+		},
+		{
+			name: "second argument is a node",
+			args: []string{"nginx", "es"},
+		},
+		{
+			name: "single service",
+			args: []string{"evmosd"},
+		},
+		{
+			name: "more than two services",
+			args: []string{"evmosd", "nginx", "postgresql"},
+		},
+		{
+			name: "no service",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLegacyVisudoArgs(tt.args, tt.isUserFlagProvided, nodes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkLegacyVisudoArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !strings.Contains(err.Error(), "--user bots") {
+				t.Errorf("checkLegacyVisudoArgs() error = %v, want hint to use --user", err)
+			}
+		})
+	}
+}
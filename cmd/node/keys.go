@@ -0,0 +1,102 @@
+package node
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"path"
+	"time"
+)
+
+// key files of the node, relative to the home directory
+var nodeKeyFiles = []string{
+	path.Join("config", "priv_validator_key.json"),
+	path.Join("config", "node_key.json"),
+}
+
+// BackupKeysCommands registers a sub-tree of commands
+func BackupKeysCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup-keys",
+		Short: "Backup priv_validator_key.json and node_key.json of the node",
+		Long: `Backup priv_validator_key.json and node_key.json of the node,
+into a new directory named <binary>-keys-<yyyyMMdd-HHmmss> within the working directory.
+The backup directory is set to mode 0700 and the backup files 0600.`,
+		Args: cobra.NoArgs,
+		Run:  backupNodeKeys,
+	}
+
+	utils.AddFlagWorkingDir(cmd)
+
+	return cmd
+}
+
+func backupNodeKeys(cmd *cobra.Command, _ []string) {
+	node := readNodeFromFlags(cmd)
+	workingDir := utils.ReadFlagWorkingDir(cmd)
+
+	backupDir := path.Join(workingDir, fmt.Sprintf("%s-keys-%s", node.Binary, time.Now().Format("20060102-150405")))
+
+	backedUpFiles, err := backupKeyFiles(node.HomeDir(), backupDir)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, file := range backedUpFiles {
+		fmt.Println("Backed up", file)
+	}
+	fmt.Println("Keys were backed up into", backupDir)
+}
+
+// backupKeyFiles copies the key files within the home directory into the backup directory.
+// Missing key files are skipped, but at least one key file must exist.
+func backupKeyFiles(homeDir, backupDir string) (backedUpFiles []string, err error) {
+	var existingKeyFiles []string
+	for _, keyFile := range nodeKeyFiles {
+		if _, errStat := os.Stat(path.Join(homeDir, keyFile)); errStat != nil {
+			if os.IsNotExist(errStat) {
+				libutils.PrintlnStdErr("WARN: key file does not exist, skipped:", path.Join(homeDir, keyFile))
+				continue
+			}
+			err = errors.Wrap(errStat, fmt.Sprintf("failed to check key file %s", keyFile))
+			return
+		}
+		existingKeyFiles = append(existingKeyFiles, keyFile)
+	}
+
+	if len(existingKeyFiles) < 1 {
+		err = fmt.Errorf("no key file was found within %s", homeDir)
+		return
+	}
+
+	if _, errStat := os.Stat(backupDir); errStat == nil {
+		err = fmt.Errorf("backup directory %s is already exists", backupDir)
+		return
+	}
+
+	if err = os.MkdirAll(backupDir, 0o700); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("failed to create backup directory %s", backupDir))
+		return
+	}
+
+	for _, keyFile := range existingKeyFiles {
+		bz, errRead := os.ReadFile(path.Join(homeDir, keyFile))
+		if errRead != nil {
+			err = errors.Wrap(errRead, fmt.Sprintf("failed to read key file %s", keyFile))
+			return
+		}
+
+		backupFile := path.Join(backupDir, path.Base(keyFile))
+		if err = os.WriteFile(backupFile, bz, 0o600); err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("failed to write backup file %s", backupFile))
+			return
+		}
+
+		backedUpFiles = append(backedUpFiles, backupFile)
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func Test_backupKeyFiles(t *testing.T) {
+	homeDir := t.TempDir()
+	backupRoot := t.TempDir()
+
+	if _, err := backupKeyFiles(homeDir, path.Join(backupRoot, "none")); err == nil {
+		t.Errorf("backup must fail when no key file exists")
+	}
+
+	if err := os.MkdirAll(path.Join(homeDir, "config"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(homeDir, "config", "priv_validator_key.json"), []byte(`{"priv_key":"secret"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	backupDir := path.Join(backupRoot, "keys")
+	files, err := backupKeyFiles(homeDir, backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// node_key.json does not exist and was skipped
+	if len(files) != 1 || files[0] != path.Join(backupDir, "priv_validator_key.json") {
+		t.Fatalf("backed up files = %v", files)
+	}
+
+	bz, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bz) != `{"priv_key":"secret"}` {
+		t.Errorf("content = %s", bz)
+	}
+
+	for file, wantMode := range map[string]os.FileMode{backupDir: 0o700, files[0]: 0o600} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != wantMode {
+			t.Errorf("mode of %s = %s, want %s", file, fi.Mode().Perm(), wantMode)
+		}
+	}
+
+	if _, err := backupKeyFiles(homeDir, backupDir); err == nil {
+		t.Errorf("backup must fail when backup directory is already exists")
+	}
+}
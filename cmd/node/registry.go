@@ -0,0 +1,236 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// actions can be performed on a node, each action is exposed as an alias of 'hkd a'
+const (
+	ActionRestart = "restart" // alias <prefix>rs
+	ActionStop    = "stop"    // alias <prefix>stop
+	ActionLogs    = "logs"    // alias <prefix>l
+	ActionReset   = "reset"   // alias <prefix>reset
+)
+
+const defaultRPCAddress = "http://localhost:26657"
+
+var allActions = []string{ActionRestart, ActionStop, ActionLogs, ActionReset}
+
+var regexBinaryName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.-]*$`)
+var regexAliasPrefix = regexp.MustCompile(`^[a-z\d][a-z\d_-]*$`)
+
+/*
+Sample content for node registry file .hkd_nodes.yaml:
+nodes:
+  - binary: osmosisd
+    prefix: osmo
+    service: osmosisd
+    home: ~/.osmosisd
+    rpc: http://localhost:26657
+    actions: [restart, stop, logs, reset]
+*/
+
+// registryFile is the node registry file, nodes declared in the file override the built-in nodes of the same binary
+type registryFile struct {
+	Nodes []Node `yaml:"nodes"`
+}
+
+// Node is a node, or a daemon, managed by this binary
+type Node struct {
+	// Binary is the name of the binary
+	Binary string `yaml:"binary"`
+	// Prefix is the prefix of the aliases, eg: 'es' for 'esrs', 'esstop'...
+	Prefix string `yaml:"prefix"`
+	// Service is the systemd service name, default is the binary name
+	Service string `yaml:"service"`
+	// Home is the home directory of the node, default is ~/.<binary>
+	Home string `yaml:"home"`
+	// RPC is the address of the local RPC server, default is http://localhost:26657
+	RPC string `yaml:"rpc"`
+	// Actions are the supported actions, default is restart, stop and logs
+	Actions []string `yaml:"actions"`
+	// Disabled removes the built-in node of the same binary
+	Disabled bool `yaml:"disabled"`
+}
+
+// BuiltInRegistry returns the nodes supported out of the box
+//
+//goland:noinspection SpellCheckingInspection
+func BuiltInRegistry() []Node {
+	cosmosActions := []string{ActionRestart, ActionStop, ActionLogs, ActionReset}
+	daemonActions := []string{ActionRestart, ActionStop, ActionLogs}
+
+	return []Node{
+		{Binary: "evmosd", Prefix: "es", Actions: cosmosActions},      // Evmos nodes
+		{Binary: "dymd", Prefix: "dym", Actions: cosmosActions},       // Dymension nodes
+		{Binary: "ethermintd", Prefix: "eth", Actions: cosmosActions}, // Ethermint dev nodes
+		{Binary: "gaid", Prefix: "ga", Actions: cosmosActions},        // CosmosHub nodes
+		{Binary: "crawld", Prefix: "ec", Actions: daemonActions},      // indexer
+		{Binary: "epod", Prefix: "ep", Actions: daemonActions},        // proxy
+	}
+}
+
+// DefaultRegistryFilePath returns ~/.hkd_nodes.yaml
+func DefaultRegistryFilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return constants.NODE_REGISTRY_FILE_NAME
+	}
+	return path.Join(home, constants.NODE_REGISTRY_FILE_NAME)
+}
+
+// LoadRegistry returns the built-in nodes merged with the nodes declared in the registry file, if the file exists.
+// Nodes declared in the file override the built-in nodes of the same binary.
+func LoadRegistry(registryFilePath string) ([]Node, error) {
+	nodes := BuiltInRegistry()
+
+	bz, err := os.ReadFile(registryFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nodes, nil
+		}
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read node registry file %s", registryFilePath))
+	}
+
+	var rf registryFile
+	decoder := yaml.NewDecoder(bytes.NewReader(bz))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rf); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to parse node registry file %s", registryFilePath))
+	}
+
+	nodes = mergeRegistry(nodes, rf.Nodes)
+
+	if err := validateRegistry(nodes); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid node registry file %s", registryFilePath))
+	}
+
+	return nodes, nil
+}
+
+// mergeRegistry overrides the base nodes by the nodes of the same binary, new nodes are appended.
+// Disabled nodes are removed.
+func mergeRegistry(base []Node, overrides []Node) []Node {
+	merged := append([]Node{}, base...)
+
+	for _, override := range overrides {
+		var overridden bool
+		for i, node := range merged {
+			if node.Binary == override.Binary {
+				merged[i] = override
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, override)
+		}
+	}
+
+	result := make([]Node, 0, len(merged))
+	for _, node := range merged {
+		if !node.Disabled {
+			result = append(result, node)
+		}
+	}
+
+	return result
+}
+
+func validateRegistry(nodes []Node) error {
+	uniquePrefixes := make(map[string]string)
+	for i, node := range nodes {
+		if !regexBinaryName.MatchString(node.Binary) {
+			return fmt.Errorf("node #%d: malformed binary name \"%s\"", i+1, node.Binary)
+		}
+
+		if !regexAliasPrefix.MatchString(node.Prefix) {
+			return fmt.Errorf("node %s: malformed prefix \"%s\"", node.Binary, node.Prefix)
+		}
+		if otherBinary, found := uniquePrefixes[node.Prefix]; found {
+			return fmt.Errorf("node %s: prefix \"%s\" is already used by node %s", node.Binary, node.Prefix, otherBinary)
+		}
+		uniquePrefixes[node.Prefix] = node.Binary
+
+		if len(node.Service) > 0 && !regexBinaryName.MatchString(node.Service) {
+			return fmt.Errorf("node %s: malformed service name \"%s\"", node.Binary, node.Service)
+		}
+
+		for _, action := range node.Actions {
+			if !isKnownAction(action) {
+				return fmt.Errorf("node %s: unknown action \"%s\", supported actions: %s", node.Binary, action, strings.Join(allActions, ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+func isKnownAction(action string) bool {
+	for _, knownAction := range allActions {
+		if action == knownAction {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceName returns the systemd service name of the node
+func (n Node) ServiceName() string {
+	if len(n.Service) > 0 {
+		return n.Service
+	}
+	return n.Binary
+}
+
+// HomeDir returns the home directory of the node, with the leading ~ expanded to home directory of current user
+func (n Node) HomeDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return n.HomeDirOf(homeDir)
+}
+
+// HomeDirOf returns the home directory of the node when running by the user has the provided home directory,
+// with the leading ~ expanded
+func (n Node) HomeDirOf(userHomeDir string) string {
+	if len(n.Home) < 1 {
+		return path.Join(userHomeDir, "."+n.Binary)
+	}
+
+	if len(userHomeDir) > 0 && (n.Home == "~" || strings.HasPrefix(n.Home, "~/")) {
+		return path.Join(userHomeDir, n.Home[1:])
+	}
+
+	return n.Home
+}
+
+// RPCAddress returns the address of the local RPC server of the node
+func (n Node) RPCAddress() string {
+	if len(n.RPC) > 0 {
+		return strings.TrimSuffix(n.RPC, "/")
+	}
+	return defaultRPCAddress
+}
+
+// HasAction returns true if the node supports the action
+func (n Node) HasAction(action string) bool {
+	actions := n.Actions
+	if actions == nil {
+		actions = []string{ActionRestart, ActionStop, ActionLogs}
+	}
+
+	for _, supportedAction := range actions {
+		if supportedAction == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("built-in when file not exists", func(t *testing.T) {
+		nodes, err := LoadRegistry(path.Join(dir, "not-exists.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(nodes, BuiltInRegistry()) {
+			t.Errorf("LoadRegistry() = %v, want built-in", nodes)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		content      string
+		wantBinaries []string
+		wantErr      string
+	}{
+		{
+			name:         "empty file",
+			content:      "",
+			wantBinaries: []string{"evmosd", "dymd", "ethermintd", "gaid", "crawld", "epod"},
+		},
+		{
+			name: "override, disable and add",
+			content: `
+nodes:
+  - binary: evmosd
+    prefix: evmos
+    service: evmos-node
+    home: ~/evmos-home
+    actions: [restart, logs]
+  - binary: gaid
+    disabled: true
+  - binary: osmosisd
+    prefix: osmo
+`,
+			wantBinaries: []string{"evmosd", "dymd", "ethermintd", "crawld", "epod", "osmosisd"},
+		},
+		{
+			name: "duplicated prefix",
+			content: `
+nodes:
+  - binary: osmosisd
+    prefix: es
+`,
+			wantErr: "already used by node evmosd",
+		},
+		{
+			name: "unknown action",
+			content: `
+nodes:
+  - binary: osmosisd
+    prefix: osmo
+    actions: [explode]
+`,
+			wantErr: "unknown action",
+		},
+		{
+			name: "malformed binary",
+			content: `
+nodes:
+  - binary: "osmosisd; reboot"
+    prefix: osmo
+`,
+			wantErr: "malformed binary name",
+		},
+		{
+			name: "missing prefix",
+			content: `
+nodes:
+  - binary: osmosisd
+`,
+			wantErr: "malformed prefix",
+		},
+		{
+			name: "unknown field",
+			content: `
+nodes:
+  - binary: osmosisd
+    prefix: osmo
+    whatever: true
+`,
+			wantErr: "failed to parse",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registryFilePath := path.Join(dir, fmt.Sprintf("registry-%d.yaml", i))
+			if err := os.WriteFile(registryFilePath, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			nodes, err := LoadRegistry(registryFilePath)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadRegistry() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var binaries []string
+			for _, node := range nodes {
+				binaries = append(binaries, node.Binary)
+			}
+			if !reflect.DeepEqual(binaries, tt.wantBinaries) {
+				t.Errorf("binaries = %v, want %v", binaries, tt.wantBinaries)
+			}
+		})
+	}
+}
+
+func TestNode(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	node := Node{Binary: "evmosd", Prefix: "es"}
+	if node.ServiceName() != "evmosd" {
+		t.Errorf("ServiceName() = %s", node.ServiceName())
+	}
+	if node.HomeDir() != path.Join(home, ".evmosd") {
+		t.Errorf("HomeDir() = %s", node.HomeDir())
+	}
+	if !node.HasAction(ActionRestart) || !node.HasAction(ActionLogs) || node.HasAction(ActionReset) {
+		t.Errorf("default actions must be restart, stop and logs")
+	}
+
+	node = Node{Binary: "evmosd", Prefix: "es", Service: "evmos-node", Home: "~/evmos", Actions: []string{ActionReset}}
+	if node.ServiceName() != "evmos-node" {
+		t.Errorf("ServiceName() = %s", node.ServiceName())
+	}
+	if node.HomeDir() != path.Join(home, "evmos") {
+		t.Errorf("HomeDir() = %s", node.HomeDir())
+	}
+	if node.HomeDirOf("/home/validator") != "/home/validator/evmos" {
+		t.Errorf("HomeDirOf() = %s", node.HomeDirOf("/home/validator"))
+	}
+	if node.HasAction(ActionRestart) || !node.HasAction(ActionReset) {
+		t.Errorf("actions must be the declared ones")
+	}
+}
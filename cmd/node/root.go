@@ -0,0 +1,123 @@
+package node
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+const (
+	flagNode         = "node"
+	flagHome         = "home"
+	flagRPC          = "rpc"
+	flagRegistryFile = "registry-file"
+	flagForce        = "force"
+)
+
+// Commands registers a sub-tree of commands
+func Commands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Cosmos node operations",
+		Long: fmt.Sprintf(`Cosmos node operations, for nodes declared in the node registry.
+The node is selected by --%s (binary name or alias prefix), or detected automatically when only one node of the registry is installed.`, flagNode),
+	}
+
+	cmd.AddCommand(
+		StatusCommands(),
+		PeersCommands(),
+		BackupKeysCommands(),
+		SnapshotCommands(),
+		RestoreCommands(),
+	)
+
+	cmd.PersistentFlags().String(
+		flagNode,
+		"",
+		"binary name or alias prefix of the node in the registry, eg: evmosd or es",
+	)
+
+	cmd.PersistentFlags().String(
+		flagHome,
+		"",
+		"override home directory of the node",
+	)
+
+	cmd.PersistentFlags().String(
+		flagRPC,
+		"",
+		fmt.Sprintf("override address of the local RPC server, default %s", defaultRPCAddress),
+	)
+
+	cmd.PersistentFlags().String(
+		flagRegistryFile,
+		DefaultRegistryFilePath(),
+		"node registry file",
+	)
+
+	return cmd
+}
+
+// readNodeFromFlags selects the node from registry, with overrides provided via flags
+func readNodeFromFlags(cmd *cobra.Command) Node {
+	registryFile, _ := cmd.Flags().GetString(flagRegistryFile)
+	nodes, err := LoadRegistry(registryFile)
+	if err != nil {
+		panic(err)
+	}
+
+	selector, _ := cmd.Flags().GetString(flagNode)
+	node, err := selectNode(nodes, strings.TrimSpace(selector), func(n Node) bool {
+		if utils.HasBinaryName(n.Binary) {
+			return true
+		}
+		_, err := os.Stat(n.HomeDir())
+		return err == nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if home, _ := cmd.Flags().GetString(flagHome); len(strings.TrimSpace(home)) > 0 {
+		node.Home = strings.TrimSpace(home)
+	}
+
+	if rpc, _ := cmd.Flags().GetString(flagRPC); len(strings.TrimSpace(rpc)) > 0 {
+		node.RPC = strings.TrimSpace(rpc)
+	}
+
+	return node
+}
+
+// selectNode returns the node matches the selector by binary name or prefix.
+// When selector is empty, returns the only node which is installed.
+func selectNode(nodes []Node, selector string, isInstalled func(Node) bool) (Node, error) {
+	if len(selector) > 0 {
+		for _, node := range nodes {
+			if node.Binary == selector || node.Prefix == selector {
+				return node, nil
+			}
+		}
+		return Node{}, fmt.Errorf("node \"%s\" is not declared in the node registry", selector)
+	}
+
+	var installed []Node
+	var binaries []string
+	for _, node := range nodes {
+		if isInstalled(node) {
+			installed = append(installed, node)
+			binaries = append(binaries, node.Binary)
+		}
+	}
+
+	switch len(installed) {
+	case 0:
+		return Node{}, fmt.Errorf("no node of the registry is installed, specify the node by --%s", flagNode)
+	case 1:
+		return installed[0], nil
+	default:
+		return Node{}, fmt.Errorf("multiple nodes are installed (%s), specify the node by --%s", strings.Join(binaries, ", "), flagNode)
+	}
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"testing"
+)
+
+func Test_selectNode(t *testing.T) {
+	nodes := BuiltInRegistry()
+
+	tests := []struct {
+		name       string
+		selector   string
+		installed  map[string]bool
+		wantBinary string
+		wantErr    bool
+	}{
+		{
+			name:       "by binary",
+			selector:   "dymd",
+			wantBinary: "dymd",
+		},
+		{
+			name:       "by prefix",
+			selector:   "es",
+			wantBinary: "evmosd",
+		},
+		{
+			name:     "not declared",
+			selector: "osmosisd",
+			wantErr:  true,
+		},
+		{
+			name:       "the only installed",
+			installed:  map[string]bool{"gaid": true},
+			wantBinary: "gaid",
+		},
+		{
+			name:    "none installed",
+			wantErr: true,
+		},
+		{
+			name:      "multiple installed",
+			installed: map[string]bool{"gaid": true, "evmosd": true},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectNode(nodes, tt.selector, func(n Node) bool {
+				return tt.installed[n.Binary]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("selectNode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Binary != tt.wantBinary {
+				t.Errorf("selectNode() = %s, want %s", got.Binary, tt.wantBinary)
+			}
+		})
+	}
+}
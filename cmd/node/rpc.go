@@ -0,0 +1,231 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	flagTimeout  = "timeout"
+	flagMinPeers = "min-peers"
+)
+
+// StatusCommands registers a sub-tree of commands
+func StatusCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show sync status of the node, by querying /status endpoint of the local RPC server",
+		Args:  cobra.NoArgs,
+		Run:   showNodeStatus,
+	}
+
+	cmd.Flags().Duration(flagTimeout, 5*time.Second, "timeout of the RPC request")
+
+	return cmd
+}
+
+// PeersCommands registers a sub-tree of commands
+func PeersCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "peers",
+		Short: "Show peers of the node, by querying /net_info endpoint of the local RPC server",
+		Long: fmt.Sprintf(`Show peers of the node, by querying /net_info endpoint of the local RPC server.
+Exit with non-zero code when number of peers is less than --%s.`, flagMinPeers),
+		Args: cobra.NoArgs,
+		Run:  showNodePeers,
+	}
+
+	cmd.Flags().Duration(flagTimeout, 5*time.Second, "timeout of the RPC request")
+	cmd.Flags().Int(flagMinPeers, 0, "minimum number of peers")
+
+	return cmd
+}
+
+func showNodeStatus(cmd *cobra.Command, _ []string) {
+	node := readNodeFromFlags(cmd)
+	timeout, _ := cmd.Flags().GetDuration(flagTimeout)
+
+	status, err := newRPCClient(node.RPCAddress(), timeout).status()
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to query status of node %s", node.Binary)))
+	}
+
+	catchingUp := "no"
+	if status.SyncInfo.CatchingUp {
+		catchingUp = "yes"
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Node:\t%s (%s)\n", node.Binary, status.NodeInfo.Moniker)
+	_, _ = fmt.Fprintf(tw, "Node ID:\t%s\n", status.NodeInfo.ID)
+	_, _ = fmt.Fprintf(tw, "Network:\t%s\n", status.NodeInfo.Network)
+	_, _ = fmt.Fprintf(tw, "Version:\t%s\n", status.NodeInfo.Version)
+	_, _ = fmt.Fprintf(tw, "Earliest block:\t%d\n", status.SyncInfo.EarliestBlockHeight)
+	_, _ = fmt.Fprintf(tw, "Latest block:\t%d\n", status.SyncInfo.LatestBlockHeight)
+	_, _ = fmt.Fprintf(tw, "Latest block time:\t%s (%s ago)\n", utils.FormatTime(status.SyncInfo.LatestBlockTime), time.Since(status.SyncInfo.LatestBlockTime).Round(time.Second))
+	_, _ = fmt.Fprintf(tw, "Catching up:\t%s\n", catchingUp)
+	_, _ = fmt.Fprintf(tw, "Voting power:\t%d\n", status.ValidatorInfo.VotingPower)
+	_ = tw.Flush()
+}
+
+func showNodePeers(cmd *cobra.Command, _ []string) {
+	node := readNodeFromFlags(cmd)
+	timeout, _ := cmd.Flags().GetDuration(flagTimeout)
+	minPeers, _ := cmd.Flags().GetInt(flagMinPeers)
+
+	netInfo, err := newRPCClient(node.RPCAddress(), timeout).netInfo()
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to query peers of node %s", node.Binary)))
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NODE ID\tMONIKER\tREMOTE IP\tDIRECTION")
+	for _, peer := range netInfo.Peers {
+		direction := "inbound"
+		if peer.IsOutbound {
+			direction = "outbound"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", peer.NodeInfo.ID, peer.NodeInfo.Moniker, peer.RemoteIP, direction)
+	}
+	_ = tw.Flush()
+
+	fmt.Printf("Total %d peers (%d outbound, %d inbound)\n", netInfo.NPeers, netInfo.outboundPeers(), netInfo.NPeers-netInfo.outboundPeers())
+
+	if netInfo.NPeers < minPeers {
+		fmt.Printf("Number of peers is less than %d\n", minPeers)
+		os.Exit(1)
+	}
+}
+
+// rpcClient queries the RPC server of Tendermint/CometBFT
+type rpcClient struct {
+	address    string
+	httpClient *http.Client
+}
+
+func newRPCClient(address string, timeout time.Duration) *rpcClient {
+	return &rpcClient{
+		address: address,
+		httpClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// rpcResponse is the JSON-RPC response envelope
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+}
+
+// rpcStatus is the result of /status
+type rpcStatus struct {
+	NodeInfo struct {
+		ID      string `json:"id"`
+		Network string `json:"network"`
+		Version string `json:"version"`
+		Moniker string `json:"moniker"`
+	} `json:"node_info"`
+	SyncInfo struct {
+		LatestBlockHeight   int64     `json:"latest_block_height,string"`
+		LatestBlockTime     time.Time `json:"latest_block_time"`
+		EarliestBlockHeight int64     `json:"earliest_block_height,string"`
+		CatchingUp          bool      `json:"catching_up"`
+	} `json:"sync_info"`
+	ValidatorInfo struct {
+		Address     string `json:"address"`
+		VotingPower int64  `json:"voting_power,string"`
+	} `json:"validator_info"`
+}
+
+// rpcNetInfo is the result of /net_info
+type rpcNetInfo struct {
+	Listening bool `json:"listening"`
+	NPeers    int  `json:"n_peers,string"`
+	Peers     []struct {
+		NodeInfo struct {
+			ID      string `json:"id"`
+			Moniker string `json:"moniker"`
+		} `json:"node_info"`
+		IsOutbound bool   `json:"is_outbound"`
+		RemoteIP   string `json:"remote_ip"`
+	} `json:"peers"`
+}
+
+func (n rpcNetInfo) outboundPeers() int {
+	var count int
+	for _, peer := range n.Peers {
+		if peer.IsOutbound {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *rpcClient) status() (*rpcStatus, error) {
+	var status rpcStatus
+	if err := c.query("/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *rpcClient) netInfo() (*rpcNetInfo, error) {
+	var netInfo rpcNetInfo
+	if err := c.query("/net_info", &netInfo); err != nil {
+		return nil, err
+	}
+	return &netInfo, nil
+}
+
+// query sends GET request to the endpoint and decodes the result
+func (c *rpcClient) query(endpoint string, result any) error {
+	url := c.address + endpoint
+	response, err := c.httpClient.Get(url)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to query %s", url))
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	bz, err := io.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to read response of %s", url))
+	}
+
+	var envelope rpcResponse
+	if err := json.Unmarshal(bz, &envelope); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to decode response of %s, status %s", url, response.Status))
+	}
+
+	if envelope.Error != nil {
+		return fmt.Errorf("RPC error %d from %s: %s %s", envelope.Error.Code, url, envelope.Error.Message, envelope.Error.Data)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %s from %s", response.Status, url)
+	}
+
+	if len(envelope.Result) < 1 {
+		return fmt.Errorf("missing result in response of %s", url)
+	}
+
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to decode result of %s", url))
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeRPCServer serves the provided responses by endpoint
+func newFakeRPCServer(t *testing.T, responses map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, found := responses[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("404 page not found"))
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_rpcClient_status(t *testing.T) {
+	server := newFakeRPCServer(t, map[string]string{
+		"/status": `{
+  "jsonrpc": "2.0",
+  "id": -1,
+  "result": {
+    "node_info": {
+      "id": "5d4e0f4f0e3a2b1c",
+      "listen_addr": "tcp://0.0.0.0:26656",
+      "network": "evmos_9001-2",
+      "version": "0.37.2",
+      "moniker": "my-node"
+    },
+    "sync_info": {
+      "latest_block_hash": "ABC",
+      "latest_block_height": "17000000",
+      "latest_block_time": "2023-08-01T10:00:00.123456789Z",
+      "earliest_block_height": "16000000",
+      "catching_up": true
+    },
+    "validator_info": {
+      "address": "ABCDEF",
+      "voting_power": "100"
+    }
+  }
+}`,
+	})
+
+	status, err := newRPCClient(server.URL, time.Second).status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.NodeInfo.Moniker != "my-node" || status.NodeInfo.Network != "evmos_9001-2" || status.NodeInfo.ID != "5d4e0f4f0e3a2b1c" {
+		t.Errorf("node info = %+v", status.NodeInfo)
+	}
+	if status.SyncInfo.LatestBlockHeight != 17000000 || status.SyncInfo.EarliestBlockHeight != 16000000 || !status.SyncInfo.CatchingUp {
+		t.Errorf("sync info = %+v", status.SyncInfo)
+	}
+	if want := time.Date(2023, 8, 1, 10, 0, 0, 123456789, time.UTC); !status.SyncInfo.LatestBlockTime.Equal(want) {
+		t.Errorf("latest block time = %s, want %s", status.SyncInfo.LatestBlockTime, want)
+	}
+	if status.ValidatorInfo.VotingPower != 100 {
+		t.Errorf("voting power = %d", status.ValidatorInfo.VotingPower)
+	}
+}
+
+func Test_rpcClient_netInfo(t *testing.T) {
+	server := newFakeRPCServer(t, map[string]string{
+		"/net_info": `{
+  "jsonrpc": "2.0",
+  "id": -1,
+  "result": {
+    "listening": true,
+    "listeners": ["Listener(@)"],
+    "n_peers": "3",
+    "peers": [
+      {"node_info": {"id": "a1", "moniker": "peer-1"}, "is_outbound": true, "remote_ip": "10.0.0.1"},
+      {"node_info": {"id": "b2", "moniker": "peer-2"}, "is_outbound": false, "remote_ip": "10.0.0.2"},
+      {"node_info": {"id": "c3", "moniker": "peer-3"}, "is_outbound": true, "remote_ip": "10.0.0.3"}
+    ]
+  }
+}`,
+	})
+
+	netInfo, err := newRPCClient(server.URL, time.Second).netInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if netInfo.NPeers != 3 || len(netInfo.Peers) != 3 {
+		t.Errorf("n_peers = %d, peers = %d", netInfo.NPeers, len(netInfo.Peers))
+	}
+	if netInfo.outboundPeers() != 2 {
+		t.Errorf("outbound peers = %d, want 2", netInfo.outboundPeers())
+	}
+	if netInfo.Peers[1].NodeInfo.Moniker != "peer-2" || netInfo.Peers[1].RemoteIP != "10.0.0.2" {
+		t.Errorf("peer = %+v", netInfo.Peers[1])
+	}
+}
+
+func Test_rpcClient_errors(t *testing.T) {
+	server := newFakeRPCServer(t, map[string]string{
+		"/status":   `{"jsonrpc": "2.0", "id": -1, "error": {"code": -32603, "message": "Internal error", "data": "node is not ready"}}`,
+		"/net_info": `{"jsonrpc": "2.0", "id": -1}`,
+	})
+
+	client := newRPCClient(server.URL, time.Second)
+
+	if _, err := client.status(); err == nil || !strings.Contains(err.Error(), "node is not ready") {
+		t.Errorf("status() error = %v", err)
+	}
+
+	if _, err := client.netInfo(); err == nil || !strings.Contains(err.Error(), "missing result") {
+		t.Errorf("netInfo() error = %v", err)
+	}
+
+	notFoundClient := newRPCClient(server.URL+"/not-found", time.Second)
+	if _, err := notFoundClient.status(); err == nil {
+		t.Errorf("status() must fail when endpoint is not found")
+	}
+
+	server.Close()
+	if _, err := client.status(); err == nil {
+		t.Errorf("status() must fail when server is unreachable")
+	}
+}
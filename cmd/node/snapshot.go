@@ -0,0 +1,425 @@
+package node
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	flagOutputFile    = "output-file"
+	flagMaxDataSizeGB = "max-data-size-gb"
+)
+
+const (
+	// defaultMaxDataSizeGB is the default size guard of data directory, same as the guard of alias <prefix>reset
+	defaultMaxDataSizeGB = 1000
+	bytesPerGB           = 1_000_000_000
+	// snapshotRootDir is the root directory of entries within the snapshot archive
+	snapshotRootDir = "data"
+	// privValidatorStateFile is kept when restoring, to prevent double signing
+	privValidatorStateFile = "priv_validator_state.json"
+)
+
+// SnapshotCommands registers a sub-tree of commands
+func SnapshotCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Snapshot data directory of the node into a tar.gz archive",
+		Long: fmt.Sprintf(`Snapshot data directory of the node into a tar.gz archive within the working directory.
+The service of the node must be stopped, unless --%s is provided.
+Data directory larger than --%s is not supported.`, flagForce, flagMaxDataSizeGB),
+		Args: cobra.NoArgs,
+		Run:  snapshotNodeData,
+	}
+
+	utils.AddFlagWorkingDir(cmd)
+
+	cmd.Flags().String(
+		flagOutputFile,
+		"",
+		"name of the output archive file, file name only, default has layout: <binary>-data-yyyy-MM-dd.tar.gz",
+	)
+
+	cmd.Flags().Bool(flagForce, false, "snapshot even though the service of the node is active")
+	cmd.Flags().Int64(flagMaxDataSizeGB, defaultMaxDataSizeGB, "maximum size of the data directory, in GB")
+
+	return cmd
+}
+
+// RestoreCommands registers a sub-tree of commands
+func RestoreCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore [snapshot file]",
+		Short: "Restore data directory of the node from a snapshot archive (tar or tar.gz)",
+		Long: fmt.Sprintf(`Restore data directory of the node from a snapshot archive (tar or tar.gz), entries of the archive must be within the '%s/' directory.
+The archive is extracted next to the data directory then swapped in, the current data directory is kept with suffix .bak-<yyyyMMdd-HHmmss>.
+The current %s is kept to prevent double signing.
+The service of the node must be stopped, unless --%s is provided.
+Current data directory and the extracted data larger than --%s are not supported.`, snapshotRootDir, privValidatorStateFile, flagForce, flagMaxDataSizeGB),
+		Args: cobra.ExactArgs(1),
+		Run:  restoreNodeData,
+	}
+
+	cmd.Flags().Bool(flagForce, false, "restore even though the service of the node is active")
+	cmd.Flags().Int64(flagMaxDataSizeGB, defaultMaxDataSizeGB, "maximum size of the current data directory and of the extracted data, in GB")
+
+	return cmd
+}
+
+func snapshotNodeData(cmd *cobra.Command, _ []string) {
+	node := readNodeFromFlags(cmd)
+	workingDir := utils.ReadFlagWorkingDir(cmd)
+	maxDataSize := readMaxDataSizeFromFlags(cmd)
+	ensureServiceStopped(cmd, node)
+
+	outputFileName, _ := cmd.Flags().GetString(flagOutputFile)
+	outputFileName = strings.TrimSpace(outputFileName)
+	if len(outputFileName) < 1 {
+		outputFileName = fmt.Sprintf("%s-data-%s.tar.gz", node.Binary, time.Now().Format("2006-01-02"))
+	} else if filepath.Base(outputFileName) != outputFileName {
+		panic(fmt.Errorf("output file must be a file name only, use flag --%s to specify the directory", utils.FlagWorkingDir))
+	}
+	outputFile := path.Join(workingDir, outputFileName)
+
+	dataDir, err := resolveDataDir(node.HomeDir())
+	if err != nil {
+		panic(err)
+	}
+
+	if err := checkDataSize(dataDir, maxDataSize); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Creating snapshot of %s into %s\n", dataDir, outputFile)
+	if err := createSnapshot(dataDir, outputFile); err != nil {
+		panic(err)
+	}
+
+	if fi, err := os.Stat(outputFile); err == nil {
+		fmt.Printf("Snapshot created: %s (%.2f GB)\n", outputFile, float64(fi.Size())/bytesPerGB)
+	}
+}
+
+func restoreNodeData(cmd *cobra.Command, args []string) {
+	node := readNodeFromFlags(cmd)
+	maxDataSize := readMaxDataSizeFromFlags(cmd)
+	ensureServiceStopped(cmd, node)
+
+	snapshotFile := args[0]
+	if _, err := os.Stat(snapshotFile); err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to check snapshot file %s", snapshotFile)))
+	}
+
+	dataDir, err := resolveDataDir(node.HomeDir())
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		panic(err)
+	}
+	if err != nil {
+		// data directory does not exist, restore into the default location
+		dataDir = path.Join(node.HomeDir(), snapshotRootDir)
+	} else if err := checkDataSize(dataDir, maxDataSize); err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Restoring snapshot %s into %s\n", snapshotFile, dataDir)
+	backupDataDir, err := restoreSnapshot(snapshotFile, dataDir, maxDataSize)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("Snapshot restored into", dataDir)
+	if len(backupDataDir) > 0 {
+		fmt.Println("Previous data directory was kept at", backupDataDir, "remove it when no longer needed")
+	}
+}
+
+func readMaxDataSizeFromFlags(cmd *cobra.Command) int64 {
+	maxDataSizeGB, _ := cmd.Flags().GetInt64(flagMaxDataSizeGB)
+	if maxDataSizeGB < 1 {
+		panic(fmt.Errorf("--%s must be positive", flagMaxDataSizeGB))
+	}
+	return maxDataSizeGB * bytesPerGB
+}
+
+// ensureServiceStopped exits if the service of node is active, unless forced
+func ensureServiceStopped(cmd *cobra.Command, node Node) {
+	force, _ := cmd.Flags().GetBool(flagForce)
+	if force || !utils.IsServiceActive(node.ServiceName()) {
+		return
+	}
+	panic(fmt.Errorf("service %s is active, stop it first or provide --%s", node.ServiceName(), flagForce))
+}
+
+// resolveDataDir returns the actual path of data directory within the home directory, symlink is resolved
+func resolveDataDir(homeDir string) (string, error) {
+	dataDir, err := filepath.EvalSymlinks(path.Join(homeDir, snapshotRootDir))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve data directory")
+	}
+
+	fi, err := os.Stat(dataDir)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("failed to check data directory %s", dataDir))
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("data directory %s is not a directory", dataDir)
+	}
+
+	return dataDir, nil
+}
+
+// checkDataSize returns error if size of the directory reaches the limit
+func checkDataSize(dataDir string, maxDataSize int64) error {
+	totalSize, err := utils.SumDirectorySize(dataDir, maxDataSize)
+	if err != nil && !utils.IsErrorLimitSumDirectorySizeReached(err) {
+		return errors.Wrap(err, fmt.Sprintf("failed to calculate total size of %s", dataDir))
+	}
+	if totalSize >= maxDataSize {
+		return fmt.Errorf("data directory %s with size >= %.2f GB is not supported", dataDir, float64(maxDataSize)/bytesPerGB)
+	}
+	return nil
+}
+
+// createSnapshot archives the data directory into a tar.gz file, entries are placed within the 'data/' directory.
+// The archive is written into a temporary file then renamed.
+func createSnapshot(dataDir, outputFile string) (err error) {
+	if _, errStat := os.Stat(outputFile); errStat == nil {
+		return fmt.Errorf("output file %s is already exists", outputFile)
+	}
+
+	tmpFile := outputFile + ".tmp"
+	file, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create file %s", tmpFile))
+	}
+	defer func() {
+		_ = file.Close()
+		if err != nil {
+			_ = os.Remove(tmpFile)
+		}
+	}()
+
+	bufferedWriter := bufio.NewWriter(file)
+	gzipWriter := gzip.NewWriter(bufferedWriter)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(dataDir, func(filePath string, info os.FileInfo, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+
+		relativePath, errRel := filepath.Rel(dataDir, filePath)
+		if errRel != nil {
+			return errRel
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("symlink %s is not supported", filePath)
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil // sockets, pipes...
+		}
+
+		header, errHeader := tar.FileInfoHeader(info, "")
+		if errHeader != nil {
+			return errHeader
+		}
+		header.Name = path.Join(snapshotRootDir, filepath.ToSlash(relativePath))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if errWrite := tarWriter.WriteHeader(header); errWrite != nil {
+			return errWrite
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		source, errOpen := os.Open(filePath)
+		if errOpen != nil {
+			return errOpen
+		}
+		defer func() {
+			_ = source.Close()
+		}()
+
+		_, errCopy := io.Copy(tarWriter, source)
+		return errCopy
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to archive %s", dataDir))
+	}
+
+	for _, closer := range []io.Closer{tarWriter, gzipWriter} {
+		if err = closer.Close(); err != nil {
+			return errors.Wrap(err, "failed to finalize archive")
+		}
+	}
+	if err = bufferedWriter.Flush(); err != nil {
+		return errors.Wrap(err, "failed to finalize archive")
+	}
+	if err = file.Close(); err != nil {
+		return errors.Wrap(err, "failed to finalize archive")
+	}
+
+	if err = os.Rename(tmpFile, outputFile); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to rename %s to %s", tmpFile, outputFile))
+	}
+
+	return nil
+}
+
+// restoreSnapshot extracts the snapshot next to the data directory, then swaps it with the current data directory.
+// The current data directory, if exists, is kept with suffix .bak-<yyyyMMdd-HHmmss> and its path is returned.
+// The current priv_validator_state.json is copied into the restored data directory.
+func restoreSnapshot(snapshotFile, dataDir string, maxDataSize int64) (backupDataDir string, err error) {
+	suffix := time.Now().Format("20060102-150405")
+	restoringDir := fmt.Sprintf("%s.restoring-%s", dataDir, suffix)
+
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(restoringDir)
+		}
+	}()
+
+	if err = extractSnapshot(snapshotFile, restoringDir, maxDataSize); err != nil {
+		return
+	}
+
+	if _, errStat := os.Stat(dataDir); errStat == nil {
+		currentStateFile := path.Join(dataDir, privValidatorStateFile)
+		if bz, errRead := os.ReadFile(currentStateFile); errRead == nil {
+			if err = os.WriteFile(path.Join(restoringDir, privValidatorStateFile), bz, 0o600); err != nil {
+				err = errors.Wrap(err, fmt.Sprintf("failed to keep %s", privValidatorStateFile))
+				return
+			}
+			fmt.Println("Kept current", privValidatorStateFile)
+		}
+
+		backupDataDir = fmt.Sprintf("%s.bak-%s", dataDir, suffix)
+		if err = os.Rename(dataDir, backupDataDir); err != nil {
+			err = errors.Wrap(err, fmt.Sprintf("failed to move current data directory to %s", backupDataDir))
+			backupDataDir = ""
+			return
+		}
+	}
+
+	if err = os.Rename(restoringDir, dataDir); err != nil {
+		err = errors.Wrap(err, fmt.Sprintf("failed to move restored data into %s", dataDir))
+		if len(backupDataDir) > 0 {
+			_ = os.Rename(backupDataDir, dataDir)
+			backupDataDir = ""
+		}
+		return
+	}
+
+	return
+}
+
+// extractSnapshot extracts entries within the 'data/' directory of the archive (tar or tar.gz) into the target directory.
+// Entries outside the 'data/' directory, links and entries escaping the target directory are rejected.
+func extractSnapshot(snapshotFile, targetDir string, maxDataSize int64) error {
+	file, err := os.Open(snapshotFile)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to open snapshot file %s", snapshotFile))
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	bufferedReader := bufio.NewReader(file)
+	var reader io.Reader = bufferedReader
+	if magic, err := bufferedReader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return errors.Wrap(err, "failed to read gzip archive")
+		}
+		defer func() {
+			_ = gzipReader.Close()
+		}()
+		reader = gzipReader
+	}
+
+	if err := os.MkdirAll(targetDir, 0o700); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create directory %s", targetDir))
+	}
+
+	var totalSize int64
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read archive")
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == snapshotRootDir {
+			continue
+		}
+		if !strings.HasPrefix(name, snapshotRootDir+"/") {
+			return fmt.Errorf("entry %s is not within the %s/ directory", header.Name, snapshotRootDir)
+		}
+		relativePath := strings.TrimPrefix(name, snapshotRootDir+"/")
+		if relativePath == ".." || strings.HasPrefix(relativePath, "../") || path.IsAbs(relativePath) {
+			return fmt.Errorf("entry %s escapes the %s/ directory", header.Name, snapshotRootDir)
+		}
+		target := filepath.Join(targetDir, filepath.FromSlash(relativePath))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("failed to create directory %s", target))
+			}
+		case tar.TypeReg:
+			totalSize += header.Size
+			if totalSize >= maxDataSize {
+				return fmt.Errorf("extracted data with size >= %.2f GB is not supported", float64(maxDataSize)/bytesPerGB)
+			}
+
+			if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("failed to create directory %s", filepath.Dir(target)))
+			}
+			if err := extractSnapshotFile(tarReader, target, header); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("entry %s has unsupported type %c", header.Name, header.Typeflag)
+		}
+	}
+
+	return nil
+}
+
+func extractSnapshotFile(tarReader *tar.Reader, target string, header *tar.Header) error {
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, header.FileInfo().Mode().Perm()|0o600)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create file %s", target))
+	}
+
+	if _, err := io.CopyN(file, tarReader, header.Size); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, fmt.Sprintf("failed to extract file %s", target))
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to extract file %s", target))
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package node
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, file, content string) {
+	if err := os.MkdirAll(path.Dir(file), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, file string) string {
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bz)
+}
+
+func Test_snapshotAndRestore(t *testing.T) {
+	homeDir := t.TempDir()
+	outputDir := t.TempDir()
+	dataDir := path.Join(homeDir, "data")
+
+	writeTestFile(t, path.Join(dataDir, "application.db", "000001.ldb"), "app")
+	writeTestFile(t, path.Join(dataDir, "blockstore.db", "CURRENT"), "block")
+	writeTestFile(t, path.Join(dataDir, privValidatorStateFile), `{"height":"100"}`)
+	if err := os.MkdirAll(path.Join(dataDir, "snapshots"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	resolvedDataDir, err := resolveDataDir(homeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkDataSize(resolvedDataDir, 1_000); err != nil {
+		t.Errorf("checkDataSize() error = %v", err)
+	}
+	if err := checkDataSize(resolvedDataDir, 5); err == nil {
+		t.Errorf("checkDataSize() must fail when size reaches the limit")
+	}
+
+	snapshotFile := path.Join(outputDir, "snapshot.tar.gz")
+	if err := createSnapshot(resolvedDataDir, snapshotFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := createSnapshot(resolvedDataDir, snapshotFile); err == nil {
+		t.Errorf("createSnapshot() must fail when output file is already exists")
+	}
+
+	// node keeps running after the snapshot
+	writeTestFile(t, path.Join(dataDir, privValidatorStateFile), `{"height":"200"}`)
+	writeTestFile(t, path.Join(dataDir, "application.db", "000002.ldb"), "newer")
+
+	if _, err := restoreSnapshot(snapshotFile, dataDir, 5); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("restoreSnapshot() must fail when extracted data reaches the limit, got %v", err)
+	}
+	if readTestFile(t, path.Join(dataDir, "application.db", "000002.ldb")) != "newer" {
+		t.Errorf("data directory must be untouched when restoring failed")
+	}
+
+	backupDataDir, err := restoreSnapshot(snapshotFile, dataDir, 1_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTestFile(t, path.Join(dataDir, "application.db", "000001.ldb")); got != "app" {
+		t.Errorf("restored content = %s", got)
+	}
+	if got := readTestFile(t, path.Join(dataDir, "blockstore.db", "CURRENT")); got != "block" {
+		t.Errorf("restored content = %s", got)
+	}
+	if _, err := os.Stat(path.Join(dataDir, "application.db", "000002.ldb")); !os.IsNotExist(err) {
+		t.Errorf("file created after snapshot must not exist in restored data")
+	}
+	if fi, err := os.Stat(path.Join(dataDir, "snapshots")); err != nil || !fi.IsDir() {
+		t.Errorf("empty directory must be restored")
+	}
+
+	// current priv_validator_state.json must be kept to prevent double signing
+	if got := readTestFile(t, path.Join(dataDir, privValidatorStateFile)); got != `{"height":"200"}` {
+		t.Errorf("priv_validator_state.json = %s", got)
+	}
+
+	if !strings.HasPrefix(backupDataDir, dataDir+".bak-") {
+		t.Errorf("backup data dir = %s", backupDataDir)
+	}
+	if got := readTestFile(t, path.Join(backupDataDir, "application.db", "000002.ldb")); got != "newer" {
+		t.Errorf("backup content = %s", got)
+	}
+}
+
+func Test_extractSnapshot_rejects(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+		wantErr string
+	}{
+		{
+			name:    "outside data directory",
+			headers: []*tar.Header{{Name: "config/priv_validator_key.json", Typeflag: tar.TypeReg, Mode: 0o600}},
+			wantErr: "is not within",
+		},
+		{
+			name:    "escape data directory",
+			headers: []*tar.Header{{Name: "data/../../etc/passwd", Typeflag: tar.TypeReg, Mode: 0o600}},
+			wantErr: "is not within",
+		},
+		{
+			name:    "absolute path",
+			headers: []*tar.Header{{Name: "/data/x", Typeflag: tar.TypeReg, Mode: 0o600}},
+			wantErr: "is not within",
+		},
+		{
+			name:    "symlink",
+			headers: []*tar.Header{{Name: "data/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0o777}},
+			wantErr: "unsupported type",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archiveFile := path.Join(dir, tt.name+".tar")
+			file, err := os.Create(archiveFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tarWriter := tar.NewWriter(file)
+			for _, header := range tt.headers {
+				if err := tarWriter.WriteHeader(header); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = tarWriter.Close()
+			_ = file.Close()
+
+			targetDir := path.Join(dir, "target", fmt.Sprintf("%d", i))
+			err = extractSnapshot(archiveFile, targetDir, 1_000)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("extractSnapshot() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"github.com/EscanBE/house-keeper/cmd/db"
 	list "github.com/EscanBE/house-keeper/cmd/files"
 	"github.com/EscanBE/house-keeper/cmd/gen"
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"github.com/EscanBE/house-keeper/cmd/secrets"
+	"github.com/EscanBE/house-keeper/cmd/svc"
 	"github.com/EscanBE/house-keeper/constants"
 	"github.com/spf13/cobra"
 	"os"
@@ -33,4 +36,7 @@ func init() {
 	rootCmd.AddCommand(db.Commands())
 	rootCmd.AddCommand(config.Commands())
 	rootCmd.AddCommand(gen.Commands())
+	rootCmd.AddCommand(secrets.Commands())
+	rootCmd.AddCommand(node.Commands())
+	rootCmd.AddCommand(svc.Commands())
 }
@@ -0,0 +1,31 @@
+package secrets
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// GetCommands registers a sub-tree of commands
+func GetCommands() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [name]",
+		Short: "Print a secret stored in the vault",
+		Args:  cobra.ExactArgs(1),
+		Run:   getSecret,
+	}
+}
+
+func getSecret(_ *cobra.Command, args []string) {
+	name := readSecretName(args)
+
+	vault := openExistingVault()
+
+	secret, found := vault.Get(name)
+	if !found {
+		fmt.Println("Secret does not exists:", name)
+		os.Exit(1)
+	}
+
+	fmt.Println(secret)
+}
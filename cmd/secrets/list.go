@@ -0,0 +1,37 @@
+package secrets
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// ListCommands registers a sub-tree of commands
+func ListCommands() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List names of the secrets stored in the vault",
+		Args:    cobra.NoArgs,
+		Run:     listSecrets,
+	}
+}
+
+func listSecrets(_ *cobra.Command, _ []string) {
+	vault := openExistingVault()
+
+	names := vault.Names()
+	if len(names) < 1 {
+		fmt.Println("Vault is empty")
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "NAME\tUPDATED AT")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\n", name, utils.FormatTime(vault.UpdatedAt(name).Local()))
+	}
+	_ = writer.Flush()
+}
@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// RemoveCommands registers a sub-tree of commands
+func RemoveCommands() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm [name]",
+		Short: "Remove a secret from the vault",
+		Args:  cobra.ExactArgs(1),
+		Run:   removeSecret,
+	}
+}
+
+func removeSecret(_ *cobra.Command, args []string) {
+	name := readSecretName(args)
+
+	vault := openExistingVault()
+
+	if !vault.Remove(name) {
+		fmt.Println("Secret does not exists:", name)
+		os.Exit(1)
+	}
+
+	if err := vault.Save(); err != nil {
+		panic(errors.Wrap(err, "failed to save vault"))
+	}
+
+	fmt.Println("Removed secret", name)
+}
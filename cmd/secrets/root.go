@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var regexSecretName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.@/-]*$`)
+
+// Commands registers a sub-tree of commands
+func Commands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage secrets stored in the encrypted vault",
+		Long: fmt.Sprintf(`Manage secrets stored in the encrypted vault.
+Vault file is ~/%s, can be changed via environment variable %s.
+Vault is unlocked by the passphrase provided via environment variable %s (file path) or %s, otherwise prompted.
+Stored secrets can be used as password source: --password-file vault:<name>`,
+			constants.SECRETS_VAULT_FILE_NAME, constants.ENV_VAULT_FILE,
+			constants.ENV_VAULT_KEY_FILE, constants.ENV_VAULT_PASSPHRASE,
+		),
+	}
+
+	cmd.AddCommand(
+		SetCommands(),
+		GetCommands(),
+		ListCommands(),
+		RemoveCommands(),
+	)
+
+	return cmd
+}
+
+// readSecretName reads the secret name from the first argument
+func readSecretName(args []string) string {
+	name := strings.TrimSpace(args[0])
+	if !regexSecretName.MatchString(name) {
+		panic(fmt.Errorf("bad secret name \"%s\", only letters, digits and _.@/- are allowed", name))
+	}
+	return name
+}
+
+// openExistingVault opens the vault, program exits if the vault does not exist
+func openExistingVault() *Vault {
+	vaultFile := DefaultVaultFilePath()
+
+	if _, err := os.Stat(vaultFile); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println("Vault does not exists:", vaultFile)
+			os.Exit(1)
+		}
+		panic(errors.Wrap(err, fmt.Sprintf("problem when checking vault file %s", vaultFile)))
+	}
+
+	vault, err := OpenVault(vaultFile, ReadVaultPassphrase())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to open secrets vault"))
+	}
+
+	return vault
+}
+
+// OpenOrCreateVault opens the vault, creates a new one if not exists.
+// When creating, passphrase must be typed twice if it is prompted.
+func OpenOrCreateVault() *Vault {
+	vaultFile := DefaultVaultFilePath()
+
+	_, err := os.Stat(vaultFile)
+	if err == nil {
+		vault, err := OpenVault(vaultFile, ReadVaultPassphrase())
+		if err != nil {
+			panic(errors.Wrap(err, "failed to open secrets vault"))
+		}
+		return vault
+	}
+
+	if !os.IsNotExist(err) {
+		panic(errors.Wrap(err, fmt.Sprintf("problem when checking vault file %s", vaultFile)))
+	}
+
+	fmt.Println("Creating new vault", vaultFile)
+	return NewVault(vaultFile, readNewVaultPassphrase())
+}
+
+// readNewVaultPassphrase reads passphrase for a new vault, prompted passphrase must be confirmed
+func readNewVaultPassphrase() string {
+	if len(strings.TrimSpace(os.Getenv(constants.ENV_VAULT_KEY_FILE))) > 0 || len(os.Getenv(constants.ENV_VAULT_PASSPHRASE)) > 0 {
+		return ReadVaultPassphrase()
+	}
+
+	passphrase := ReadVaultPassphrase()
+	if PromptSecret("Confirm vault passphrase:") != passphrase {
+		fmt.Println("Aborted! Passphrases do not match")
+		os.Exit(1)
+	}
+
+	return passphrase
+}
@@ -0,0 +1,46 @@
+package secrets
+
+import "testing"
+
+func Test_readSecretName(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantPanic bool
+	}{
+		{
+			name: "pg-backup",
+		},
+		{
+			name: "db/backup_2.password",
+		},
+		{
+			name: "user@server",
+		},
+		{
+			name:      "-starts-with-dash",
+			wantPanic: true,
+		},
+		{
+			name:      "contains space",
+			wantPanic: true,
+		},
+		{
+			name:      "",
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if (r != nil) != tt.wantPanic {
+					t.Errorf("readSecretName() panic = %v, wantPanic %v", r, tt.wantPanic)
+				}
+			}()
+
+			if got := readSecretName([]string{tt.name}); got != tt.name {
+				t.Errorf("readSecretName() = %v, want %v", got, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package secrets
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+const (
+	flagFrom = "from"
+)
+
+// SetCommands registers a sub-tree of commands
+func SetCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set [name]",
+		Short: "Add or replace a secret in the vault",
+		Long: `Add or replace a secret in the vault.
+Secret is prompted, or read from the first line of stdin if not running in a terminal, or read from the source provided via --from.`,
+		Args: cobra.ExactArgs(1),
+		Run:  setSecret,
+	}
+
+	cmd.PersistentFlags().String(
+		flagFrom,
+		"",
+		fmt.Sprintf("read secret from the source instead of prompting, eg: --%s ~/password.txt, %s", flagFrom, SourceUsage),
+	)
+
+	return cmd
+}
+
+func setSecret(cmd *cobra.Command, args []string) {
+	name := readSecretName(args)
+
+	var secret string
+	from, _ := cmd.Flags().GetString(flagFrom)
+	from = strings.TrimSpace(from)
+	if len(from) > 0 {
+		if source := ParseSource(from); source.Kind == SourceKindVault && source.Value == name {
+			panic(fmt.Errorf("secret can not be copied from itself"))
+		}
+		secret = ReadSecret(from)
+	}
+
+	vault := OpenOrCreateVault()
+
+	if len(secret) < 1 {
+		secret = readSecretInput(name)
+	}
+
+	_, exists := vault.Get(name)
+	vault.Set(name, secret)
+
+	if err := vault.Save(); err != nil {
+		panic(errors.Wrap(err, "failed to save vault"))
+	}
+
+	if exists {
+		fmt.Println("Replaced secret", name)
+	} else {
+		fmt.Println("Added secret", name)
+	}
+}
+
+// readSecretInput prompts for the secret, or reads the first line of stdin if not running in a terminal
+func readSecretInput(name string) string {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) < 1 {
+			if err != nil {
+				panic(errors.Wrap(err, "failed to read secret from stdin"))
+			}
+			panic(fmt.Errorf("secret read from stdin is empty"))
+		}
+		return line
+	}
+
+	secret := PromptSecret(fmt.Sprintf("Secret %s:", name))
+	if PromptSecret("Confirm secret:") != secret {
+		fmt.Println("Aborted! Secrets do not match")
+		os.Exit(1)
+	}
+
+	return secret
+}
@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/pkg/errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Supported kinds of secret source
+const (
+	SourceKindFile  = "file"
+	SourceKindEnv   = "env"
+	SourceKindCmd   = "cmd"
+	SourceKindVault = "vault"
+)
+
+// SourceUsage describes the supported secret sources, to be appended to flag descriptions
+const SourceUsage = "also accepts secret source env:<NAME>, cmd:<command> or vault:<name>"
+
+// Source is a parsed secret source
+type Source struct {
+	Kind  string
+	Value string
+}
+
+// ParseSource parses the secret source with format 'kind:value',
+// value without a supported kind prefix is treated as a file path.
+func ParseSource(source string) Source {
+	source = strings.TrimSpace(source)
+
+	for _, kind := range []string{SourceKindFile, SourceKindEnv, SourceKindCmd, SourceKindVault} {
+		if strings.HasPrefix(source, kind+":") {
+			return Source{
+				Kind:  kind,
+				Value: strings.TrimSpace(strings.TrimPrefix(source, kind+":")),
+			}
+		}
+	}
+
+	return Source{
+		Kind:  SourceKindFile,
+		Value: source,
+	}
+}
+
+// IsFile returns true if the secret is stored in a plain file
+func (s Source) IsFile() bool {
+	return s.Kind == SourceKindFile
+}
+
+// String returns the source in format 'kind:value'
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%s", s.Kind, s.Value)
+}
+
+// ReadSecret reads secret from the provided source.
+// For file source, program will exit if permission of the file is not restricted to owner.
+func ReadSecret(source string) string {
+	return ParseSource(source).Read()
+}
+
+// Read reads secret from the source, panic if the secret could not be read or is empty.
+// For file source, program will exit if permission of the file is not restricted to owner.
+func (s Source) Read() string {
+	if len(s.Value) < 1 {
+		panic(fmt.Errorf("missing value of secret source %s", s.Kind))
+	}
+
+	var secret string
+
+	switch s.Kind {
+	case SourceKindFile:
+		return utils.ReadPasswordFile(s.Value)
+	case SourceKindEnv:
+		secret = strings.TrimSpace(os.Getenv(s.Value))
+		if len(secret) < 1 {
+			panic(fmt.Errorf("environment variable %s is empty", s.Value))
+		}
+	case SourceKindCmd:
+		secret = readSecretFromCommand(s.Value)
+	case SourceKindVault:
+		secret = readSecretFromVault(s.Value)
+	default:
+		panic(fmt.Errorf("not supported secret source %s", s.Kind))
+	}
+
+	return secret
+}
+
+// readSecretFromCommand executes the command using bash and takes the first line of the output as the secret,
+// eg: pass show my-secret
+func readSecretFromCommand(command string) string {
+	var stdout bytes.Buffer
+
+	launchCmd := exec.Command("/bin/bash", "-c", command)
+	launchCmd.Stdin = os.Stdin
+	launchCmd.Stdout = &stdout
+	launchCmd.Stderr = os.Stderr
+
+	if err := launchCmd.Run(); err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to execute secret command: %s", command)))
+	}
+
+	secret := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	if len(secret) < 1 {
+		panic(fmt.Errorf("secret command returns empty output: %s", command))
+	}
+
+	return secret
+}
+
+func readSecretFromVault(name string) string {
+	vault, err := OpenVault(DefaultVaultFilePath(), ReadVaultPassphrase())
+	if err != nil {
+		panic(errors.Wrap(err, "failed to open secrets vault"))
+	}
+
+	secret, found := vault.Get(name)
+	if !found {
+		panic(fmt.Errorf("secret %s does not exists in vault %s", name, vault.file))
+	}
+
+	return secret
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   Source
+	}{
+		{
+			source: "/home/user/password.txt",
+			want:   Source{Kind: SourceKindFile, Value: "/home/user/password.txt"},
+		},
+		{
+			source: "file:~/password.txt",
+			want:   Source{Kind: SourceKindFile, Value: "~/password.txt"},
+		},
+		{
+			source: "env:PG_BACKUP_PASSWORD",
+			want:   Source{Kind: SourceKindEnv, Value: "PG_BACKUP_PASSWORD"},
+		},
+		{
+			source: "cmd:pass show db/backup",
+			want:   Source{Kind: SourceKindCmd, Value: "pass show db/backup"},
+		},
+		{
+			source: " vault:pg-backup ",
+			want:   Source{Kind: SourceKindVault, Value: "pg-backup"},
+		},
+		{
+			source: "unknown:value",
+			want:   Source{Kind: SourceKindFile, Value: "unknown:value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := ParseSource(tt.source); got != tt.want {
+				t.Errorf("ParseSource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	passwordFile := path.Join(dir, "password.txt")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HKD_TEST_SECRET", " from-env ")
+
+	tests := []struct {
+		name      string
+		source    string
+		want      string
+		wantPanic bool
+	}{
+		{
+			name:   "plain file path",
+			source: passwordFile,
+			want:   "from-file",
+		},
+		{
+			name:   "file",
+			source: "file:" + passwordFile,
+			want:   "from-file",
+		},
+		{
+			name:      "file not exists",
+			source:    path.Join(dir, "not-exists"),
+			wantPanic: true,
+		},
+		{
+			name:   "env",
+			source: "env:HKD_TEST_SECRET",
+			want:   "from-env",
+		},
+		{
+			name:      "env not set",
+			source:    "env:HKD_TEST_SECRET_NOT_SET",
+			wantPanic: true,
+		},
+		{
+			name:   "cmd takes the first line",
+			source: "cmd:printf 'from-cmd\\nsecond line\\n'",
+			want:   "from-cmd",
+		},
+		{
+			name:      "cmd failed",
+			source:    "cmd:exit 1",
+			wantPanic: true,
+		},
+		{
+			name:      "cmd empty output",
+			source:    "cmd:true",
+			wantPanic: true,
+		},
+		{
+			name:      "missing value",
+			source:    "env:",
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if (r != nil) != tt.wantPanic {
+					t.Errorf("ReadSecret() panic = %v, wantPanic %v", r, tt.wantPanic)
+				}
+			}()
+
+			if got := ReadSecret(tt.source); got != tt.want {
+				t.Errorf("ReadSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
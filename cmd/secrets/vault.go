@@ -0,0 +1,280 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const vaultFormatVersion = 1
+
+// scrypt parameters used when creating new vault, stored in the vault file so they can be changed later
+var (
+	vaultScryptN = 1 << 15
+	vaultScryptR = 8
+	vaultScryptP = 1
+)
+
+// Vault is an encrypted file which stores named secrets.
+// Content is encrypted using AES-256-GCM with the key derived from passphrase using scrypt.
+type Vault struct {
+	file       string
+	passphrase string
+	entries    map[string]vaultEntry
+}
+
+// vaultEntry is a secret stored in the vault
+type vaultEntry struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// vaultFile is the on-disk format of the vault
+type vaultFile struct {
+	Version int    `json:"version"`
+	Kdf     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// DefaultVaultFilePath returns path of the vault file, provided by environment variable HKD_VAULT_FILE
+// or the default file within home directory.
+func DefaultVaultFilePath() string {
+	if file := strings.TrimSpace(os.Getenv(constants.ENV_VAULT_FILE)); len(file) > 0 {
+		return file
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(errors.Wrap(err, "failed to get home directory"))
+	}
+
+	return path.Join(home, constants.SECRETS_VAULT_FILE_NAME)
+}
+
+// ReadVaultPassphrase reads the master passphrase to unlock the vault.
+// Lookup order: key file provided by environment variable HKD_VAULT_KEY_FILE,
+// environment variable HKD_VAULT_PASSPHRASE, then prompt if running in a terminal.
+func ReadVaultPassphrase() string {
+	if keyFile := strings.TrimSpace(os.Getenv(constants.ENV_VAULT_KEY_FILE)); len(keyFile) > 0 {
+		return utils.ReadPasswordFile(keyFile)
+	}
+
+	if passphrase := os.Getenv(constants.ENV_VAULT_PASSPHRASE); len(passphrase) > 0 {
+		return passphrase
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		panic(fmt.Errorf("missing vault passphrase, either environment variable %s or %s is required", constants.ENV_VAULT_KEY_FILE, constants.ENV_VAULT_PASSPHRASE))
+	}
+
+	return PromptSecret("Vault passphrase:")
+}
+
+// PromptSecret reads a secret from terminal without echoing
+func PromptSecret(question string) string {
+	_, _ = fmt.Fprint(os.Stderr, question, " ")
+	bz, err := term.ReadPassword(int(os.Stdin.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to read input"))
+	}
+
+	secret := strings.TrimSpace(string(bz))
+	if len(secret) < 1 {
+		panic(fmt.Errorf("input is empty"))
+	}
+
+	return secret
+}
+
+// NewVault creates an empty vault, it is not persisted until saved
+func NewVault(file, passphrase string) *Vault {
+	return &Vault{
+		file:       file,
+		passphrase: passphrase,
+		entries:    make(map[string]vaultEntry),
+	}
+}
+
+// OpenVault reads and decrypts the vault file
+func OpenVault(file, passphrase string) (*Vault, error) {
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var vf vaultFile
+	if err := json.Unmarshal(bz, &vf); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("malformed vault file %s", file))
+	}
+
+	if vf.Version != vaultFormatVersion {
+		return nil, fmt.Errorf("not supported vault format version %d", vf.Version)
+	}
+
+	if vf.Kdf != "scrypt" {
+		return nil, fmt.Errorf("not supported key derivation function %s", vf.Kdf)
+	}
+
+	gcm, err := newVaultCipher(passphrase, vf.Salt, vf.N, vf.R, vf.P)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, vf.Nonce, vf.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt vault %s, wrong passphrase?", file)
+	}
+
+	vault := NewVault(file, passphrase)
+	if err := json.Unmarshal(plaintext, &vault.entries); err != nil {
+		return nil, errors.Wrap(err, "malformed vault content")
+	}
+
+	return vault, nil
+}
+
+// Save encrypts and writes the vault file atomically, file is only accessible by owner
+func (v *Vault) Save() error {
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal vault content")
+	}
+
+	vf := vaultFile{
+		Version: vaultFormatVersion,
+		Kdf:     "scrypt",
+		Salt:    make([]byte, 32),
+		N:       vaultScryptN,
+		R:       vaultScryptR,
+		P:       vaultScryptP,
+	}
+
+	if _, err := rand.Read(vf.Salt); err != nil {
+		return errors.Wrap(err, "failed to generate salt")
+	}
+
+	gcm, err := newVaultCipher(v.passphrase, vf.Salt, vf.N, vf.R, vf.P)
+	if err != nil {
+		return err
+	}
+
+	vf.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(vf.Nonce); err != nil {
+		return errors.Wrap(err, "failed to generate nonce")
+	}
+
+	vf.Data = gcm.Seal(nil, vf.Nonce, plaintext, nil)
+
+	bz, err := json.Marshal(vf)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal vault file")
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(v.file), filepath.Base(v.file)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary vault file")
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+
+	if err := tmpFile.Chmod(constants.RECOMMENDED_FILE_PERMISSION); err != nil {
+		_ = tmpFile.Close()
+		return errors.Wrap(err, "failed to set permission of temporary vault file")
+	}
+
+	if _, err := tmpFile.Write(bz); err != nil {
+		_ = tmpFile.Close()
+		return errors.Wrap(err, "failed to write temporary vault file")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temporary vault file")
+	}
+
+	if err := os.Rename(tmpFile.Name(), v.file); err != nil {
+		return errors.Wrap(err, "failed to replace vault file")
+	}
+
+	return nil
+}
+
+// File returns path of the vault file
+func (v *Vault) File() string {
+	return v.file
+}
+
+// Get returns the secret with the provided name
+func (v *Vault) Get(name string) (secret string, found bool) {
+	entry, found := v.entries[name]
+	return entry.Value, found
+}
+
+// Set adds or replaces the secret with the provided name
+func (v *Vault) Set(name, secret string) {
+	v.entries[name] = vaultEntry{
+		Value:     secret,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+// Remove removes the secret with the provided name, returns false if not exists
+func (v *Vault) Remove(name string) bool {
+	if _, found := v.entries[name]; !found {
+		return false
+	}
+	delete(v.entries, name)
+	return true
+}
+
+// Names returns sorted names of the secrets
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UpdatedAt returns the last time the secret was updated
+func (v *Vault) UpdatedAt(name string) time.Time {
+	return v.entries[name].UpdatedAt
+}
+
+func newVaultCipher(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	if len(passphrase) < 1 {
+		return nil, fmt.Errorf("vault passphrase is empty")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive vault key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
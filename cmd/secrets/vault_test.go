@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	// reduce cost of key derivation to speed up test
+	originalScryptN := vaultScryptN
+	vaultScryptN = 1 << 10
+	defer func() {
+		vaultScryptN = originalScryptN
+	}()
+
+	vaultFile := path.Join(t.TempDir(), ".hkd_secrets")
+
+	vault := NewVault(vaultFile, "master passphrase")
+	vault.Set("pg-backup", "secret-1")
+	vault.Set("github-token", "secret-2")
+	if err := vault.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	fi, err := os.Stat(vaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("permission of vault file = %o, want 600", perm)
+	}
+
+	bz, err := os.ReadFile(vaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bz), "secret-1") || strings.Contains(string(bz), "pg-backup") {
+		t.Errorf("vault file must not contain plain text")
+	}
+
+	if _, err := OpenVault(vaultFile, "wrong passphrase"); err == nil {
+		t.Errorf("OpenVault() expected error with wrong passphrase")
+	}
+
+	opened, err := OpenVault(vaultFile, "master passphrase")
+	if err != nil {
+		t.Fatalf("OpenVault() error = %v", err)
+	}
+
+	if got := opened.Names(); !reflect.DeepEqual(got, []string{"github-token", "pg-backup"}) {
+		t.Errorf("Names() = %v", got)
+	}
+
+	if secret, found := opened.Get("pg-backup"); !found || secret != "secret-1" {
+		t.Errorf("Get() = %v, %v", secret, found)
+	}
+
+	if !opened.Remove("pg-backup") {
+		t.Errorf("Remove() expected true")
+	}
+	if opened.Remove("pg-backup") {
+		t.Errorf("Remove() expected false for removed secret")
+	}
+	if err := opened.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reopened, err := OpenVault(vaultFile, "master passphrase")
+	if err != nil {
+		t.Fatalf("OpenVault() error = %v", err)
+	}
+	if _, found := reopened.Get("pg-backup"); found {
+		t.Errorf("removed secret must not be found")
+	}
+	if secret, found := reopened.Get("github-token"); !found || secret != "secret-2" {
+		t.Errorf("Get() = %v, %v", secret, found)
+	}
+}
+
+func TestReadSecret_vault(t *testing.T) {
+	originalScryptN := vaultScryptN
+	vaultScryptN = 1 << 10
+	defer func() {
+		vaultScryptN = originalScryptN
+	}()
+
+	vaultFile := path.Join(t.TempDir(), ".hkd_secrets")
+	t.Setenv("HKD_VAULT_FILE", vaultFile)
+	t.Setenv("HKD_VAULT_PASSPHRASE", "master passphrase")
+	t.Setenv("HKD_VAULT_KEY_FILE", "")
+
+	vault := NewVault(vaultFile, "master passphrase")
+	vault.Set("pg-backup", "secret-1")
+	if err := vault.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ReadSecret("vault:pg-backup"); got != "secret-1" {
+		t.Errorf("ReadSecret() = %v, want secret-1", got)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("ReadSecret() expected panic for not exists secret")
+		}
+	}()
+	_ = ReadSecret("vault:not-exists")
+}
@@ -0,0 +1,142 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	"time"
+)
+
+// dbusManager manages systemd services via the D-Bus API of systemd
+type dbusManager struct {
+	conn    *systemd.Conn
+	timeout time.Duration
+}
+
+var _ serviceManager = &dbusManager{}
+
+func newDbusManager(timeout time.Duration) (*dbusManager, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := systemd.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbusManager{
+		conn:    conn,
+		timeout: timeout,
+	}, nil
+}
+
+func (m *dbusManager) name() string {
+	return "D-Bus"
+}
+
+func (m *dbusManager) status(unit string) (serviceStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	properties, err := m.conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return serviceStatus{}, newServiceError(unit, operationStatus, classifyDbusError(err), err)
+	}
+
+	status := serviceStatus{
+		unit:          unit,
+		loadState:     stringProperty(properties, "LoadState"),
+		activeState:   stringProperty(properties, "ActiveState"),
+		subState:      stringProperty(properties, "SubState"),
+		unitFileState: stringProperty(properties, "UnitFileState"),
+	}
+
+	if since, ok := properties["ActiveEnterTimestamp"].(uint64); ok && since > 0 {
+		status.since = utils.FormatTime(time.UnixMicro(int64(since)))
+	}
+
+	if status.loadState == "loaded" {
+		if serviceProperties, err := m.conn.GetUnitTypePropertiesContext(ctx, unit, "Service"); err == nil {
+			status.mainPID, _ = serviceProperties["MainPID"].(uint32)
+		}
+	}
+
+	return status, status.checkLoaded()
+}
+
+func (m *dbusManager) perform(operation, unit string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	if operation == operationEnable {
+		if _, _, err := m.conn.EnableUnitFilesContext(ctx, []string{unit}, false, false); err != nil {
+			return newServiceError(unit, operation, classifyDbusError(err), err)
+		}
+		if err := m.conn.ReloadContext(ctx); err != nil {
+			return newServiceError(unit, operation, classifyDbusError(err), err)
+		}
+		return nil
+	}
+
+	var startJob func(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	switch operation {
+	case operationStart:
+		startJob = m.conn.StartUnitContext
+	case operationStop:
+		startJob = m.conn.StopUnitContext
+	case operationRestart:
+		startJob = m.conn.RestartUnitContext
+	default:
+		return fmt.Errorf("not supported operation %s", operation)
+	}
+
+	resultChan := make(chan string, 1)
+	if _, err := startJob(ctx, unit, "replace", resultChan); err != nil {
+		return newServiceError(unit, operation, classifyDbusError(err), err)
+	}
+
+	select {
+	case result := <-resultChan:
+		if result != "done" {
+			return newServiceError(unit, operation, errorKindJobFailed, fmt.Errorf("job completed with result '%s', check logs of the service for details", result))
+		}
+		return nil
+	case <-ctx.Done():
+		return newServiceError(unit, operation, errorKindJobFailed, fmt.Errorf("job was not completed within %s", m.timeout))
+	}
+}
+
+func (m *dbusManager) close() {
+	m.conn.Close()
+}
+
+// classifyDbusError returns kind of the error returned from systemd via D-Bus
+func classifyDbusError(err error) string {
+	var name string
+	switch e := err.(type) {
+	case dbus.Error:
+		name = e.Name
+	case *dbus.Error:
+		name = e.Name
+	default:
+		return errorKindUnknown
+	}
+
+	switch name {
+	case "org.freedesktop.systemd1.NoSuchUnit", "org.freedesktop.DBus.Error.FileNotFound":
+		return errorKindNotFound
+	case "org.freedesktop.DBus.Error.AccessDenied", "org.freedesktop.DBus.Error.InteractiveAuthorizationRequired":
+		return errorKindPermissionDenied
+	case "org.freedesktop.DBus.Error.ServiceUnknown", "org.freedesktop.DBus.Error.NoReply", "org.freedesktop.DBus.Error.Disconnected":
+		return errorKindUnavailable
+	default:
+		return errorKindUnknown
+	}
+}
+
+func stringProperty(properties map[string]interface{}, name string) string {
+	value, _ := properties[name].(string)
+	return value
+}
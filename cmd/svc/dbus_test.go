@@ -0,0 +1,53 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/godbus/dbus/v5"
+	"testing"
+)
+
+func Test_classifyDbusError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "no such unit",
+			err:  dbus.Error{Name: "org.freedesktop.systemd1.NoSuchUnit"},
+			want: errorKindNotFound,
+		},
+		{
+			name: "interactive authorization required",
+			err:  dbus.Error{Name: "org.freedesktop.DBus.Error.InteractiveAuthorizationRequired"},
+			want: errorKindPermissionDenied,
+		},
+		{
+			name: "access denied, pointer",
+			err:  dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", nil),
+			want: errorKindPermissionDenied,
+		},
+		{
+			name: "no reply",
+			err:  dbus.Error{Name: "org.freedesktop.DBus.Error.NoReply"},
+			want: errorKindUnavailable,
+		},
+		{
+			name: "other D-Bus error",
+			err:  dbus.Error{Name: "org.freedesktop.systemd1.UnitMasked"},
+			want: errorKindUnknown,
+		},
+		{
+			name: "not D-Bus error",
+			err:  fmt.Errorf("something else"),
+			want: errorKindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyDbusError(tt.err); got != tt.want {
+				t.Errorf("classifyDbusError() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
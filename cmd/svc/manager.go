@@ -0,0 +1,96 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operations can be performed on a service
+const (
+	operationStatus  = "status"
+	operationStart   = "start"
+	operationStop    = "stop"
+	operationRestart = "restart"
+	operationEnable  = "enable"
+)
+
+// kinds of service error
+const (
+	errorKindNotFound         = "not found"
+	errorKindPermissionDenied = "permission denied"
+	errorKindJobFailed        = "job failed"
+	errorKindUnavailable      = "unavailable"
+	errorKindUnknown          = "error"
+)
+
+// serviceManager manages systemd services, via D-Bus or by shelling out to systemctl
+type serviceManager interface {
+	// name returns the name of the backend, for displaying purpose
+	name() string
+	// status returns the status of the unit
+	status(unit string) (serviceStatus, error)
+	// perform starts, stops, restarts or enables the unit and waits until the job is completed
+	perform(operation, unit string) error
+	// close releases the resources of the backend
+	close()
+}
+
+// serviceStatus is the status of a systemd unit
+type serviceStatus struct {
+	unit          string
+	loadState     string // loaded, not-found, masked...
+	activeState   string // active, inactive, failed, activating...
+	subState      string // running, dead, exited...
+	unitFileState string // enabled, disabled, static...
+	mainPID       uint32
+	since         string // time when the unit entered the active state, empty if never
+}
+
+// serviceError is the structured error of an operation on a service
+type serviceError struct {
+	unit      string
+	operation string
+	kind      string
+	err       error
+}
+
+func newServiceError(unit, operation, kind string, err error) *serviceError {
+	return &serviceError{
+		unit:      unit,
+		operation: operation,
+		kind:      kind,
+		err:       err,
+	}
+}
+
+func (e *serviceError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %v", e.operation, e.unit, e.kind, e.err)
+}
+
+func (e *serviceError) Unwrap() error {
+	return e.err
+}
+
+// isPermissionDenied returns true if the error is a service error caused by lacking of permission
+func isPermissionDenied(err error) bool {
+	se, ok := err.(*serviceError)
+	return ok && se.kind == errorKindPermissionDenied
+}
+
+// checkLoaded returns not found error if the unit is not loaded because the unit file does not exist
+func (s serviceStatus) checkLoaded() error {
+	if s.loadState == "not-found" {
+		return newServiceError(s.unit, operationStatus, errorKindNotFound, fmt.Errorf("unit %s could not be found", s.unit))
+	}
+	return nil
+}
+
+// unitName returns the systemd unit name of the service, the '.service' suffix is appended if missing
+func unitName(service string) string {
+	for _, suffix := range []string{".service", ".timer", ".socket", ".target", ".mount", ".path"} {
+		if strings.HasSuffix(service, suffix) {
+			return service
+		}
+	}
+	return service + ".service"
+}
@@ -0,0 +1,358 @@
+package svc
+
+import (
+	"fmt"
+	libutils "github.com/EscanBE/go-lib/utils"
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	flagNoDbus       = "no-dbus"
+	flagTimeout      = "timeout"
+	flagRegistryFile = "registry-file"
+	flagNow          = "now"
+	flagFollow       = "follow"
+	flagLines        = "lines"
+	flagSince        = "since"
+)
+
+var regexServiceName = regexp.MustCompile(`^[a-zA-Z\d][a-zA-Z\d_.@:-]*$`)
+
+// Commands registers a sub-tree of commands
+func Commands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "svc",
+		Short: "Manage systemd services",
+		Long: fmt.Sprintf(`Manage systemd services, via D-Bus API of systemd.
+When D-Bus is unavailable (or --%s), fallback to systemctl, with sudo if current user is not root.
+Services can be specified by service name, or by binary name or alias prefix of nodes declared in the node registry.
+When no service is specified, detected services are used, they are services of nodes those service file exists within /etc/systemd/system.`, flagNoDbus),
+	}
+
+	cmd.AddCommand(
+		StatusCommands(),
+		operationCommands(operationStart, "Start services"),
+		operationCommands(operationStop, "Stop services"),
+		operationCommands(operationRestart, "Restart services"),
+		EnableCommands(),
+		LogsCommands(),
+	)
+
+	cmd.PersistentFlags().Bool(
+		flagNoDbus,
+		false,
+		"do not use D-Bus, shell out to systemctl instead",
+	)
+
+	cmd.PersistentFlags().Duration(
+		flagTimeout,
+		2*time.Minute,
+		"timeout of each operation, including waiting for the job to be completed",
+	)
+
+	cmd.PersistentFlags().String(
+		flagRegistryFile,
+		node.DefaultRegistryFilePath(),
+		"node registry file, used to detect services and resolve binary name or alias prefix into service name",
+	)
+
+	return cmd
+}
+
+// StatusCommands registers a sub-tree of commands
+func StatusCommands() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status [service...]",
+		Short: "Show status of services",
+		Run: func(cmd *cobra.Command, args []string) {
+			units := readUnits(cmd, args)
+
+			manager := newServiceManager(cmd)
+			defer manager.close()
+
+			statuses, errs := collectStatuses(manager, units)
+			printStatuses(statuses)
+			exitOnErrors(errs)
+		},
+	}
+}
+
+// operationCommands registers a sub-tree of commands, which performs the operation on services
+func operationCommands(operation, short string) *cobra.Command {
+	return &cobra.Command{
+		Use:   fmt.Sprintf("%s [service...]", operation),
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			performOperations(cmd, args, operation)
+		},
+	}
+}
+
+// EnableCommands registers a sub-tree of commands
+func EnableCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable [service...]",
+		Short: "Enable services to be started at boot",
+		Run: func(cmd *cobra.Command, args []string) {
+			now, _ := cmd.Flags().GetBool(flagNow)
+			if now {
+				performOperations(cmd, args, operationEnable, operationStart)
+			} else {
+				performOperations(cmd, args, operationEnable)
+			}
+		},
+	}
+
+	cmd.Flags().Bool(flagNow, false, "start services after enabled")
+
+	return cmd
+}
+
+// LogsCommands registers a sub-tree of commands
+func LogsCommands() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs [service...]",
+		Short: "Show logs of services, via journalctl",
+		Run: func(cmd *cobra.Command, args []string) {
+			units := readUnits(cmd, args)
+			follow, _ := cmd.Flags().GetBool(flagFollow)
+			lines, _ := cmd.Flags().GetInt(flagLines)
+			since, _ := cmd.Flags().GetString(flagSince)
+
+			journalctlArgs := buildJournalctlArgs(units, follow, lines, strings.TrimSpace(since))
+
+			var ec int
+			if os.Geteuid() == 0 {
+				ec = utils.LaunchAppWithDirectStd("journalctl", journalctlArgs, nil)
+			} else {
+				ec = utils.LaunchAppWithDirectStd("sudo", append([]string{"journalctl"}, journalctlArgs...), nil)
+			}
+			if ec != 0 {
+				os.Exit(ec)
+			}
+		},
+	}
+
+	cmd.Flags().BoolP(flagFollow, "f", false, "follow new logs")
+	cmd.Flags().IntP(flagLines, "n", 100, "number of recent log lines to show")
+	cmd.Flags().String(flagSince, "", "show logs since the time, eg: \"1 hour ago\", \"2023-08-01 10:00\"")
+
+	return cmd
+}
+
+func performOperations(cmd *cobra.Command, args []string, operations ...string) {
+	units := readUnits(cmd, args)
+
+	manager := newServiceManager(cmd)
+	defer manager.close()
+
+	var fallback serviceManager
+	if _, isDbus := manager.(*dbusManager); isDbus && os.Geteuid() != 0 {
+		fallback = newSystemctlManager(true)
+	}
+
+	var errs []error
+	for _, unit := range units {
+		for _, operation := range operations {
+			fmt.Printf("%s %s...\n", operation, unit)
+			if err := performWithFallback(manager, fallback, operation, unit); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	statuses, statusErrs := collectStatuses(manager, units)
+	printStatuses(statuses)
+	exitOnErrors(append(errs, statusErrs...))
+}
+
+// performWithFallback performs the operation using the manager,
+// if rejected because of lacking permission, retry using the fallback manager if provided.
+func performWithFallback(manager, fallback serviceManager, operation, unit string) error {
+	err := manager.perform(operation, unit)
+	if err == nil || fallback == nil || !isPermissionDenied(err) {
+		return err
+	}
+
+	libutils.PrintlnStdErr("WARN:", err.Error())
+	libutils.PrintlnStdErr("WARN: retrying using", fallback.name())
+	return fallback.perform(operation, unit)
+}
+
+// newServiceManager returns the D-Bus service manager, or the systemctl one when D-Bus is unavailable
+func newServiceManager(cmd *cobra.Command) serviceManager {
+	noDbus, _ := cmd.Flags().GetBool(flagNoDbus)
+	timeout, _ := cmd.Flags().GetDuration(flagTimeout)
+
+	fallback := newSystemctlManager(os.Geteuid() != 0)
+
+	if noDbus {
+		return fallback
+	}
+
+	manager, err := newDbusManager(timeout)
+	if err != nil {
+		libutils.PrintlnStdErr("WARN: D-Bus is unavailable, fallback to", fallback.name()+":", err.Error())
+		return fallback
+	}
+
+	return manager
+}
+
+// readUnits returns the units of services provided via arguments, or the detected services if no argument
+func readUnits(cmd *cobra.Command, args []string) []string {
+	registryFile, _ := cmd.Flags().GetString(flagRegistryFile)
+	nodes, err := node.LoadRegistry(registryFile)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(args) < 1 {
+		units := detectUnits(nodes, utils.IsExistsServiceFile)
+		if len(units) < 1 {
+			panic(fmt.Errorf("no service of the node registry was detected, specify the services"))
+		}
+		return units
+	}
+
+	units, err := resolveUnits(args, nodes)
+	if err != nil {
+		panic(err)
+	}
+	return units
+}
+
+// detectUnits returns units of the nodes those service file exists
+func detectUnits(nodes []node.Node, isExistsServiceFile func(serviceName string) bool) []string {
+	var units []string
+	for _, n := range nodes {
+		if isExistsServiceFile(n.ServiceName()) {
+			units = append(units, unitName(n.ServiceName()))
+		}
+	}
+	return units
+}
+
+// resolveUnits resolves the services into units, binary name and alias prefix of nodes are resolved into service name
+func resolveUnits(services []string, nodes []node.Node) ([]string, error) {
+	var units []string
+	uniqueUnits := make(map[string]bool)
+
+	for _, service := range services {
+		service = strings.TrimSpace(service)
+
+		for _, n := range nodes {
+			if n.Binary == service || n.Prefix == service {
+				service = n.ServiceName()
+				break
+			}
+		}
+
+		if !regexServiceName.MatchString(service) {
+			return nil, fmt.Errorf("malformed service name \"%s\"", service)
+		}
+
+		unit := unitName(service)
+		if uniqueUnits[unit] {
+			continue
+		}
+		uniqueUnits[unit] = true
+		units = append(units, unit)
+	}
+
+	return units, nil
+}
+
+func collectStatuses(manager serviceManager, units []string) (statuses []serviceStatus, errs []error) {
+	for _, unit := range units {
+		status, err := manager.status(unit)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(status.unit) > 0 {
+			statuses = append(statuses, status)
+		}
+	}
+	return
+}
+
+func printStatuses(statuses []serviceStatus) {
+	if len(statuses) < 1 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "UNIT\tLOAD\tACTIVE\tSUB\tENABLED\tPID\tSINCE")
+	for _, status := range statuses {
+		pid := "-"
+		if status.mainPID > 0 {
+			pid = fmt.Sprintf("%d", status.mainPID)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			status.unit,
+			valueOrDash(status.loadState),
+			valueOrDash(status.activeState),
+			valueOrDash(status.subState),
+			valueOrDash(status.unitFileState),
+			pid,
+			valueOrDash(status.since),
+		)
+	}
+	_ = tw.Flush()
+}
+
+// exitOnErrors prints the errors as a table then exit with non-zero code, if any
+func exitOnErrors(errs []error) {
+	if len(errs) < 1 {
+		return
+	}
+
+	libutils.PrintlnStdErr()
+	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "UNIT\tOPERATION\tERROR\tDETAIL")
+	for _, err := range errs {
+		if se, ok := err.(*serviceError); ok {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%v\n", se.unit, se.operation, se.kind, se.err)
+		} else {
+			_, _ = fmt.Fprintf(tw, "-\t-\t%s\t%v\n", errorKindUnknown, err)
+		}
+	}
+	_ = tw.Flush()
+
+	os.Exit(1)
+}
+
+// buildJournalctlArgs builds arguments for journalctl to show logs of the units
+func buildJournalctlArgs(units []string, follow bool, lines int, since string) []string {
+	var args []string
+	for _, unit := range units {
+		args = append(args, "-u", unit)
+	}
+	if follow {
+		args = append(args, "--follow")
+	} else {
+		args = append(args, "--no-pager")
+	}
+	if lines > 0 {
+		args = append(args, "--lines", fmt.Sprintf("%d", lines))
+	}
+	if len(since) > 0 {
+		args = append(args, "--since", since)
+	}
+	return args
+}
+
+func valueOrDash(value string) string {
+	if len(value) < 1 {
+		return "-"
+	}
+	return value
+}
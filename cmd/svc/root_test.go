@@ -0,0 +1,195 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/node"
+	"reflect"
+	"testing"
+)
+
+func Test_resolveUnits(t *testing.T) {
+	nodes := []node.Node{
+		{Binary: "evmosd", Prefix: "es"},
+		{Binary: "crawld", Prefix: "ec", Service: "evmos-crawler"},
+	}
+
+	tests := []struct {
+		name     string
+		services []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "service name",
+			services: []string{"nginx"},
+			want:     []string{"nginx.service"},
+		},
+		{
+			name:     "unit name",
+			services: []string{"nginx.service", "backup.timer"},
+			want:     []string{"nginx.service", "backup.timer"},
+		},
+		{
+			name:     "binary name and alias prefix of nodes",
+			services: []string{"evmosd", "ec"},
+			want:     []string{"evmosd.service", "evmos-crawler.service"},
+		},
+		{
+			name:     "duplicated",
+			services: []string{"es", "evmosd", "evmosd.service"},
+			want:     []string{"evmosd.service"},
+		},
+		{
+			name:     "template instance",
+			services: []string{"getty@tty1"},
+			want:     []string{"getty@tty1.service"},
+		},
+		{
+			name:     "malformed",
+			services: []string{"nginx; reboot"},
+			wantErr:  true,
+		},
+		{
+			name:     "looks like a flag",
+			services: []string{"--force"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveUnits(tt.services, nodes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveUnits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveUnits() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_detectUnits(t *testing.T) {
+	nodes := []node.Node{
+		{Binary: "evmosd", Prefix: "es"},
+		{Binary: "dymd", Prefix: "dym"},
+		{Binary: "crawld", Prefix: "ec", Service: "evmos-crawler"},
+	}
+
+	got := detectUnits(nodes, func(serviceName string) bool {
+		return serviceName == "evmosd" || serviceName == "evmos-crawler"
+	})
+
+	want := []string{"evmosd.service", "evmos-crawler.service"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("detectUnits() got = %v, want %v", got, want)
+	}
+}
+
+func Test_buildJournalctlArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		units  []string
+		follow bool
+		lines  int
+		since  string
+		want   []string
+	}{
+		{
+			name:  "no follow",
+			units: []string{"evmosd.service"},
+			lines: 100,
+			want:  []string{"-u", "evmosd.service", "--no-pager", "--lines", "100"},
+		},
+		{
+			name:   "follow multiple units since",
+			units:  []string{"evmosd.service", "crawld.service"},
+			follow: true,
+			since:  "1 hour ago",
+			want:   []string{"-u", "evmosd.service", "-u", "crawld.service", "--follow", "--since", "1 hour ago"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildJournalctlArgs(tt.units, tt.follow, tt.lines, tt.since); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildJournalctlArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeManager records the operations performed and returns the provided error
+type fakeManager struct {
+	err       error
+	performed []string
+}
+
+func (m *fakeManager) name() string {
+	return "fake"
+}
+
+func (m *fakeManager) status(unit string) (serviceStatus, error) {
+	return serviceStatus{unit: unit}, nil
+}
+
+func (m *fakeManager) perform(operation, unit string) error {
+	m.performed = append(m.performed, operation+" "+unit)
+	return m.err
+}
+
+func (m *fakeManager) close() {
+}
+
+func Test_performWithFallback(t *testing.T) {
+	errDenied := newServiceError("a.service", operationRestart, errorKindPermissionDenied, fmt.Errorf("denied"))
+	errFailed := newServiceError("a.service", operationRestart, errorKindJobFailed, fmt.Errorf("failed"))
+
+	tests := []struct {
+		name             string
+		managerErr       error
+		withFallback     bool
+		wantErr          error
+		wantFallbackUsed bool
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:             "permission denied, use fallback",
+			managerErr:       errDenied,
+			withFallback:     true,
+			wantFallbackUsed: true,
+		},
+		{
+			name:       "permission denied, no fallback",
+			managerErr: errDenied,
+			wantErr:    errDenied,
+		},
+		{
+			name:         "other error does not use fallback",
+			managerErr:   errFailed,
+			withFallback: true,
+			wantErr:      errFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &fakeManager{err: tt.managerErr}
+			fallback := &fakeManager{}
+
+			var err error
+			if tt.withFallback {
+				err = performWithFallback(manager, fallback, operationRestart, "a.service")
+			} else {
+				err = performWithFallback(manager, nil, operationRestart, "a.service")
+			}
+
+			if err != tt.wantErr {
+				t.Errorf("performWithFallback() error = %v, want %v", err, tt.wantErr)
+			}
+			if gotFallbackUsed := len(fallback.performed) > 0; gotFallbackUsed != tt.wantFallbackUsed {
+				t.Errorf("performWithFallback() fallback used = %t, want %t", gotFallbackUsed, tt.wantFallbackUsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/cmd/utils"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemctlManager manages systemd services by shelling out to systemctl, used when D-Bus is unavailable
+type systemctlManager struct {
+	// useSudo indicates that operations those modify state of services are executed with sudo
+	useSudo bool
+}
+
+var _ serviceManager = &systemctlManager{}
+
+func newSystemctlManager(useSudo bool) *systemctlManager {
+	return &systemctlManager{
+		useSudo: useSudo,
+	}
+}
+
+func (m *systemctlManager) name() string {
+	if m.useSudo {
+		return "sudo systemctl"
+	}
+	return "systemctl"
+}
+
+func (m *systemctlManager) status(unit string) (serviceStatus, error) {
+	output, err := exec.Command(
+		"systemctl", "show",
+		"--property=LoadState,ActiveState,SubState,UnitFileState,MainPID,ActiveEnterTimestamp",
+		"--", unit,
+	).CombinedOutput()
+	if err != nil {
+		return serviceStatus{}, newServiceError(unit, operationStatus, classifySystemctlOutput(string(output)), systemctlError(output, err))
+	}
+
+	status := parseSystemctlShow(unit, string(output))
+	return status, status.checkLoaded()
+}
+
+func (m *systemctlManager) perform(operation, unit string) error {
+	switch operation {
+	case operationStart, operationStop, operationRestart, operationEnable:
+		break
+	default:
+		return fmt.Errorf("not supported operation %s", operation)
+	}
+
+	// no '--' separator, so the command matches sudoers rules generated by 'hkd gen visudo',
+	// unit name was validated to not start with '-'
+	var cmd *exec.Cmd
+	if m.useSudo {
+		cmd = exec.Command("sudo", "systemctl", operation, unit)
+	} else {
+		cmd = exec.Command("systemctl", operation, unit)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return newServiceError(unit, operation, classifySystemctlOutput(string(output)), systemctlError(output, err))
+	}
+
+	return nil
+}
+
+func (m *systemctlManager) close() {
+}
+
+// parseSystemctlShow parses output of 'systemctl show --property=...' of a single unit
+func parseSystemctlShow(unit, output string) serviceStatus {
+	status := serviceStatus{
+		unit: unit,
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		spl := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(spl) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(spl[1])
+		switch spl[0] {
+		case "LoadState":
+			status.loadState = value
+		case "ActiveState":
+			status.activeState = value
+		case "SubState":
+			status.subState = value
+		case "UnitFileState":
+			status.unitFileState = value
+		case "MainPID":
+			mainPID, _ := strconv.ParseUint(value, 10, 32)
+			status.mainPID = uint32(mainPID)
+		case "ActiveEnterTimestamp":
+			if len(value) < 1 || value == "n/a" {
+				break
+			}
+			if since, err := time.ParseInLocation("Mon 2006-01-02 15:04:05 MST", value, time.Local); err == nil {
+				status.since = utils.FormatTime(since)
+			} else {
+				status.since = value
+			}
+		}
+	}
+
+	return status
+}
+
+// classifySystemctlOutput returns kind of the error based on the output of systemctl
+func classifySystemctlOutput(output string) string {
+	output = strings.ToLower(output)
+	switch {
+	case strings.Contains(output, "not found") || strings.Contains(output, "does not exist"):
+		return errorKindNotFound
+	case strings.Contains(output, "access denied") ||
+		strings.Contains(output, "interactive authentication required") ||
+		strings.Contains(output, "password is required") ||
+		strings.Contains(output, "not in the sudoers"):
+		return errorKindPermissionDenied
+	case strings.Contains(output, "failed to connect to bus") || strings.Contains(output, "not been booted with systemd"):
+		return errorKindUnavailable
+	case strings.Contains(output, "job for") && strings.Contains(output, "failed"):
+		return errorKindJobFailed
+	default:
+		return errorKindUnknown
+	}
+}
+
+// systemctlError returns error contains the output of systemctl, if any
+func systemctlError(output []byte, err error) error {
+	msg := strings.TrimSpace(string(output))
+	if len(msg) < 1 {
+		return err
+	}
+	return fmt.Errorf("%s (%v)", strings.ReplaceAll(msg, "\n", " "), err)
+}
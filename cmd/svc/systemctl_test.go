@@ -0,0 +1,112 @@
+package svc
+
+import (
+	"testing"
+)
+
+func Test_parseSystemctlShow(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		want       serviceStatus
+		wantLoaded bool
+	}{
+		{
+			name: "running",
+			output: `LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+MainPID=1234
+ActiveEnterTimestamp=n/a
+`,
+			want: serviceStatus{
+				unit:          "evmosd.service",
+				loadState:     "loaded",
+				activeState:   "active",
+				subState:      "running",
+				unitFileState: "enabled",
+				mainPID:       1234,
+			},
+			wantLoaded: true,
+		},
+		{
+			name: "not found",
+			output: `MainPID=0
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+ActiveEnterTimestamp=
+UnitFileState=
+`,
+			want: serviceStatus{
+				unit:        "evmosd.service",
+				loadState:   "not-found",
+				activeState: "inactive",
+				subState:    "dead",
+			},
+			wantLoaded: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSystemctlShow("evmosd.service", tt.output)
+			if got != tt.want {
+				t.Errorf("parseSystemctlShow() = %+v, want %+v", got, tt.want)
+			}
+			if err := got.checkLoaded(); (err == nil) != tt.wantLoaded {
+				t.Errorf("checkLoaded() error = %v, wantLoaded %t", err, tt.wantLoaded)
+			}
+		})
+	}
+}
+
+func Test_parseSystemctlShow_since(t *testing.T) {
+	got := parseSystemctlShow("evmosd.service", "ActiveEnterTimestamp=Tue 2023-08-01 10:11:12 UTC")
+	if got.since != "2023-Aug-01 10:11:12" {
+		t.Errorf("parseSystemctlShow() since = %s, want 2023-Aug-01 10:11:12", got.since)
+	}
+}
+
+func Test_classifySystemctlOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{
+			output: "Failed to restart evmosd.service: Unit evmosd.service not found.",
+			want:   errorKindNotFound,
+		},
+		{
+			output: "Failed to enable unit: Unit file evmosd.service does not exist.",
+			want:   errorKindNotFound,
+		},
+		{
+			output: "Failed to restart evmosd.service: Access denied",
+			want:   errorKindPermissionDenied,
+		},
+		{
+			output: "sudo: a password is required",
+			want:   errorKindPermissionDenied,
+		},
+		{
+			output: "System has not been booted with systemd as init system (PID 1). Can't operate.\nFailed to connect to bus: Host is down",
+			want:   errorKindUnavailable,
+		},
+		{
+			output: "Job for evmosd.service failed because the control process exited with error code.",
+			want:   errorKindJobFailed,
+		},
+		{
+			output: "something else",
+			want:   errorKindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			if got := classifySystemctlOutput(tt.output); got != tt.want {
+				t.Errorf("classifySystemctlOutput() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
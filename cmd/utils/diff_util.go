@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffOpKind byte
+
+const (
+	diffOpEqual  diffOpKind = ' '
+	diffOpDelete diffOpKind = '-'
+	diffOpInsert diffOpKind = '+'
+)
+
+type diffOp struct {
+	kind diffOpKind
+	line string
+	// aIdx and bIdx are 0-based indexes of the line in each side before applying this op
+	aIdx int
+	bIdx int
+}
+
+// UnifiedDiff returns line-based unified diff between the two contents, empty if identical
+func UnifiedDiff(fromName, toName, from, to string) string {
+	a := splitDiffLines(from)
+	b := splitDiffLines(to)
+
+	ops := computeDiffOps(a, b)
+
+	var changed bool
+	for _, op := range ops {
+		if op.kind != diffOpEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	for _, hunk := range groupDiffHunks(ops) {
+		hunkOps := ops[hunk[0]:hunk[1]]
+
+		aStart, bStart := hunkOps[0].aIdx, hunkOps[0].bIdx
+		var aLen, bLen int
+		for _, op := range hunkOps {
+			if op.kind != diffOpInsert {
+				aLen++
+			}
+			if op.kind != diffOpDelete {
+				bLen++
+			}
+		}
+
+		sb.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", formatDiffRange(aStart, aLen), formatDiffRange(bStart, bLen)))
+		for _, op := range hunkOps {
+			sb.WriteByte(byte(op.kind))
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String()
+}
+
+func splitDiffLines(content string) []string {
+	if len(content) < 1 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// computeDiffOps computes edit script based on the longest common subsequence
+func computeDiffOps(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			ops = append(ops, diffOp{kind: diffOpEqual, line: a[i], aIdx: i, bIdx: j})
+			i++
+			j++
+		} else if j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]) {
+			ops = append(ops, diffOp{kind: diffOpDelete, line: a[i], aIdx: i, bIdx: j})
+			i++
+		} else {
+			ops = append(ops, diffOp{kind: diffOpInsert, line: b[j], aIdx: i, bIdx: j})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// groupDiffHunks returns [start, end) ranges of ops, each range is a hunk including context lines
+func groupDiffHunks(ops []diffOp) [][2]int {
+	var hunks [][2]int
+
+	for idx := 0; idx < len(ops); idx++ {
+		if ops[idx].kind == diffOpEqual {
+			continue
+		}
+
+		start := idx - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+
+		// extend until there are more than 2*context equal lines after the last change
+		end := idx + 1
+		lastChange := idx
+		for end < len(ops) {
+			if ops[end].kind != diffOpEqual {
+				lastChange = end
+			} else if end-lastChange > 2*diffContextLines {
+				break
+			}
+			end++
+		}
+
+		end = lastChange + 1 + diffContextLines
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		hunks = append(hunks, [2]int{start, end})
+		idx = end - 1
+	}
+
+	return hunks
+}
+
+func formatDiffRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -0,0 +1,26 @@
+package utils
+
+import "strings"
+
+// OverlayEnvVars returns a copy of base environment variables with the overlay variables (KEY=value) applied,
+// existing variables with the same key are replaced.
+func OverlayEnvVars(base []string, overlay ...string) []string {
+	overlayKeys := make(map[string]bool)
+	for _, envVar := range overlay {
+		overlayKeys[envVarKey(envVar)] = true
+	}
+
+	result := make([]string, 0, len(base)+len(overlay))
+	for _, envVar := range base {
+		if overlayKeys[envVarKey(envVar)] {
+			continue
+		}
+		result = append(result, envVar)
+	}
+
+	return append(result, overlay...)
+}
+
+func envVarKey(envVar string) string {
+	return strings.SplitN(envVar, "=", 2)[0]
+}
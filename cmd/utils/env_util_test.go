@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOverlayEnvVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    []string
+		overlay []string
+		want    []string
+	}{
+		{
+			name:    "append new",
+			base:    []string{"PATH=/usr/bin", "LANG=C.UTF-8"},
+			overlay: []string{"PGPASSWORD=1234567"},
+			want:    []string{"PATH=/usr/bin", "LANG=C.UTF-8", "PGPASSWORD=1234567"},
+		},
+		{
+			name:    "replace existing",
+			base:    []string{"PATH=/usr/bin", "PGPASSWORD=old", "LANG=C.UTF-8"},
+			overlay: []string{"PGPASSWORD=new"},
+			want:    []string{"PATH=/usr/bin", "LANG=C.UTF-8", "PGPASSWORD=new"},
+		},
+		{
+			name:    "key prefix is not the same key",
+			base:    []string{"PGPASSWORD_X=1", "PGPASSWORD=old"},
+			overlay: []string{"PGPASSWORD=new"},
+			want:    []string{"PGPASSWORD_X=1", "PGPASSWORD=new"},
+		},
+		{
+			name:    "value contains equal sign",
+			base:    []string{"A=b=c"},
+			overlay: []string{"B=x=y"},
+			want:    []string{"A=b=c", "B=x=y"},
+		},
+		{
+			name:    "empty base",
+			base:    nil,
+			overlay: []string{"A=1"},
+			want:    []string{"A=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OverlayEnvVars(tt.base, tt.overlay...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OverlayEnvVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
 )
 
@@ -38,3 +39,45 @@ func IsFileAndExists(file string) (bool, error) {
 
 	return true, nil
 }
+
+// WriteFileAtomically writes content into a temporary file within the same directory,
+// then renames it to the target file, so the target file is never partially written.
+func WriteFileAtomically(file string, content []byte, mode fs.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to create temporary file for %s", file))
+	}
+	tmpFilePath := tmpFile.Name()
+
+	cleanup := func() {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFilePath)
+	}
+
+	if _, err := tmpFile.Write(content); err != nil {
+		cleanup()
+		return errors.Wrap(err, fmt.Sprintf("failed to write temporary file %s", tmpFilePath))
+	}
+
+	if err := tmpFile.Chmod(mode); err != nil {
+		cleanup()
+		return errors.Wrap(err, fmt.Sprintf("failed to set mode of temporary file %s", tmpFilePath))
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		cleanup()
+		return errors.Wrap(err, fmt.Sprintf("failed to sync temporary file %s", tmpFilePath))
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFilePath)
+		return errors.Wrap(err, fmt.Sprintf("failed to close temporary file %s", tmpFilePath))
+	}
+
+	if err := os.Rename(tmpFilePath, file); err != nil {
+		_ = os.Remove(tmpFilePath)
+		return errors.Wrap(err, fmt.Sprintf("failed to move temporary file into %s", file))
+	}
+
+	return nil
+}
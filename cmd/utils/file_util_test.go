@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -112,3 +114,44 @@ func TestValidatePasswordFileMode(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "output.conf")
+
+	if err := os.WriteFile(file, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomically(file, []byte("new"), 0o440); err != nil {
+		t.Fatalf("WriteFileAtomically() error = %v", err)
+	}
+
+	bz, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bz) != "new" {
+		t.Errorf("content = %s, want new", string(bz))
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o440 {
+		t.Errorf("mode = %o, want 440", fi.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file must be removed, got %d entries", len(entries))
+	}
+
+	if err := WriteFileAtomically(filepath.Join(dir, "not-exists", "output.conf"), []byte("new"), 0o644); err == nil {
+		t.Errorf("WriteFileAtomically() expected error when directory does not exist")
+	}
+}
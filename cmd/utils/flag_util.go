@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	flagWorkingDir = "working-directory"
+	// FlagWorkingDir is the flag registered by AddFlagWorkingDir
+	FlagWorkingDir = "working-directory"
 )
 
 func AddFlagWorkingDir(cmd *cobra.Command) {
@@ -18,14 +19,14 @@ func AddFlagWorkingDir(cmd *cobra.Command) {
 	}
 
 	cmd.PersistentFlags().String(
-		flagWorkingDir,
+		FlagWorkingDir,
 		curDir,
 		"the working directory",
 	)
 }
 
 func ReadFlagWorkingDir(cmd *cobra.Command) string {
-	workingDir, _ := cmd.Flags().GetString(flagWorkingDir)
+	workingDir, _ := cmd.Flags().GetString(FlagWorkingDir)
 	workingDir = strings.TrimSpace(workingDir)
 	if len(workingDir) < 1 {
 		panic(fmt.Errorf("empty working directory"))
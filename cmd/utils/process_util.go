@@ -103,3 +103,75 @@ func LaunchAppWithSetup(appName string, args []string, setup func(launchCmd *exe
 	}
 	return 0
 }
+
+// LaunchAppsPiped launches two apps, stdout of the producer is piped into stdin of the consumer.
+// Returns exit code of producer and consumer respectively.
+func LaunchAppsPiped(producerName string, producerArgs []string, producerEnvVars []string, consumerName string, consumerArgs []string, consumerEnvVars []string) (producerEc, consumerEc int) {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		libutils.PrintlnStdErr("problem when creating pipe between", producerName, "and", consumerName, err)
+		return 1, 1
+	}
+
+	producerCmd := exec.Command(producerName, producerArgs...)
+	if len(producerEnvVars) > 0 {
+		producerCmd.Env = producerEnvVars
+	}
+	producerCmd.Stdout = pipeWriter
+	producerCmd.Stderr = os.Stderr
+
+	consumerCmd := exec.Command(consumerName, consumerArgs...)
+	if len(consumerEnvVars) > 0 {
+		consumerCmd.Env = consumerEnvVars
+	}
+	consumerCmd.Stdin = pipeReader
+	consumerCmd.Stdout = os.Stdout
+	consumerCmd.Stderr = os.Stderr
+
+	if err := consumerCmd.Start(); err != nil {
+		libutils.PrintlnStdErr("problem when starting", consumerName, err)
+		_ = pipeReader.Close()
+		_ = pipeWriter.Close()
+		return 1, 1
+	}
+
+	// parent no longer needs the read side, consumer holds its own copy
+	_ = pipeReader.Close()
+
+	if err := producerCmd.Start(); err != nil {
+		libutils.PrintlnStdErr("problem when starting", producerName, err)
+		producerEc = 1
+	}
+
+	// closing write side so consumer receives EOF when producer exited
+	_ = pipeWriter.Close()
+
+	if producerEc == 0 {
+		if err := producerCmd.Wait(); err != nil {
+			libutils.PrintlnStdErr("problem when waiting process", producerName, err)
+			producerEc = 1
+		}
+	}
+
+	if err := consumerCmd.Wait(); err != nil {
+		libutils.PrintlnStdErr("problem when waiting process", consumerName, err)
+		consumerEc = 1
+	}
+
+	return
+}
+
+// LaunchAppAndCaptureOutput launches app and returns its stdout, stderr is forwarded to stderr of current process
+func LaunchAppAndCaptureOutput(appName string, args []string, envVars []string) (output string, ec int) {
+	launchCmd := exec.Command(appName, args...)
+	if len(envVars) > 0 {
+		launchCmd.Env = envVars
+	}
+	launchCmd.Stderr = os.Stderr
+	bz, err := launchCmd.Output()
+	if err != nil {
+		libutils.PrintfStdErr("problem when running process %s: %s\n", appName, err.Error())
+		return string(bz), 1
+	}
+	return string(bz), 0
+}
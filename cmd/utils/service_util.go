@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path"
+)
+
+// IsExistsServiceFile returns true if the systemd service file of the service exists within /etc/systemd/system
+func IsExistsServiceFile(serviceName string) bool {
+	file, err := os.Stat(path.Join("/etc/systemd/system", serviceName+".service"))
+	if err != nil {
+		return false // treat as not exists
+	}
+	return !file.IsDir()
+}
+
+// IsServiceActive returns true if the systemd service is active
+func IsServiceActive(serviceName string) bool {
+	return exec.Command("systemctl", "is-active", "--quiet", serviceName).Run() == nil
+}
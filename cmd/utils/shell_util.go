@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var regexShellSafeWord = regexp.MustCompile("^[a-zA-Z\\d_@%+=:,./-]+$")
+
+// ShellQuote quotes the input so it will be treated as a single word by POSIX shells
+func ShellQuote(word string) string {
+	if len(word) < 1 {
+		return "''"
+	}
+	if regexShellSafeWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'"'"'`) + "'"
+}
+
+// SplitShellWords splits the input into words like POSIX shells do, respecting single quotes,
+// double quotes and backslash escapes. Shell operators and expansions are not interpreted.
+func SplitShellWords(input string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var inWord bool
+
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated escape at end of input")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote at position %d", i)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				// within double quotes, backslash only escapes these characters
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// shellOperators are operators and expansions those are interpreted by shells, longer ones first
+var shellOperators = []string{"&&", "||", "$", "`", "|", "&", ";", ">", "<", "*", "?", "["}
+
+// FindShellSyntax returns the first syntax which is interpreted by shells but not by SplitShellWords, empty if not any:
+// operators (&&, ||, |, &, ;, >, <), parameter expansion and command substitution ($, `), tilde expansion (~ at beginning of word)
+// and globs (*, ?, [). Quoted and escaped ones are ignored, except expansions within double quotes since shells expand them.
+func FindShellSyntax(input string) string {
+	runes := []rune(input)
+	isWordStart := true
+	for i := 0; i < len(runes); i++ {
+		wasWordStart := isWordStart
+		isWordStart = runes[i] == ' ' || runes[i] == '\t'
+
+		switch runes[i] {
+		case '\\':
+			i++
+			continue
+		case '\'':
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+			}
+			continue
+		case '"':
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == '$' || runes[i] == '`' {
+					return string(runes[i])
+				}
+			}
+			continue
+		case '~':
+			if wasWordStart {
+				return "~"
+			}
+			continue
+		}
+
+		for _, operator := range shellOperators {
+			if strings.HasPrefix(string(runes[i:]), operator) {
+				return operator
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{
+			word: "",
+			want: "''",
+		},
+		{
+			word: "abc",
+			want: "abc",
+		},
+		{
+			word: "/mnt/md0/backup/db-2023-01-02.dump",
+			want: "/mnt/md0/backup/db-2023-01-02.dump",
+		},
+		{
+			word: "Hello World",
+			want: "'Hello World'",
+		},
+		{
+			word: "it's",
+			want: `'it'"'"'s'`,
+		},
+		{
+			word: "$(reboot)",
+			want: "'$(reboot)'",
+		},
+		{
+			word: "a;b",
+			want: "'a;b'",
+		},
+		{
+			word: "~/file",
+			want: "'~/file'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := ShellQuote(tt.word); got != tt.want {
+				t.Errorf("ShellQuote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			input: "",
+			want:  nil,
+		},
+		{
+			input: "  echo \t Hello  ",
+			want:  []string{"echo", "Hello"},
+		},
+		{
+			input: `echo "Hello World"`,
+			want:  []string{"echo", "Hello World"},
+		},
+		{
+			input: `echo 'it"s' "it's" it\'s`,
+			want:  []string{"echo", `it"s`, "it's", "it's"},
+		},
+		{
+			input: `echo "a \"b\" \n" 'c \d'`,
+			want:  []string{"echo", `a "b" \n`, `c \d`},
+		},
+		{
+			input: `echo ""a'b'"c d"`,
+			want:  []string{"echo", "abc d"},
+		},
+		{
+			input: `echo '' ""`,
+			want:  []string{"echo", "", ""},
+		},
+		{
+			input: "git fetch && git pull",
+			want:  []string{"git", "fetch", "&&", "git", "pull"},
+		},
+		{
+			input:   `echo "Hello`,
+			wantErr: true,
+		},
+		{
+			input:   `echo 'Hello`,
+			wantErr: true,
+		},
+		{
+			input:   `echo \`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := SplitShellWords(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SplitShellWords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitShellWords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindShellSyntax(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "git pull --rebase",
+			want:  "",
+		},
+		{
+			input: "cd foo && make",
+			want:  "&&",
+		},
+		{
+			input: "make || true",
+			want:  "||",
+		},
+		{
+			input: "ps aux | grep evmosd",
+			want:  "|",
+		},
+		{
+			input: "sleep 10 &",
+			want:  "&",
+		},
+		{
+			input: "cd foo; make",
+			want:  ";",
+		},
+		{
+			input: "echo 1 >> out.log",
+			want:  ">",
+		},
+		{
+			input: "mysql < dump.sql",
+			want:  "<",
+		},
+		{
+			input: "echo $(date)",
+			want:  "$",
+		},
+		{
+			input: "cd $HOME/app",
+			want:  "$",
+		},
+		{
+			input: `echo "now $(date)"`,
+			want:  "$",
+		},
+		{
+			input: `echo "home ${HOME}"`,
+			want:  "$",
+		},
+		{
+			input: "echo `date`",
+			want:  "`",
+		},
+		{
+			input: "cd ~/app",
+			want:  "~",
+		},
+		{
+			input: "rm /tmp/*.log",
+			want:  "*",
+		},
+		{
+			input: "ls file?.txt",
+			want:  "?",
+		},
+		{
+			input: "ls file[12].txt",
+			want:  "[",
+		},
+		{
+			input: `grep -E 'a|b' "c && d *" e\;f '$HOME' \$HOME a~b '~/x'`,
+			want:  "",
+		},
+		{
+			input: `echo "\"; ls \$x"`,
+			want:  "",
+		},
+		{
+			input: `echo 'it"s' | cat`,
+			want:  "|",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := FindShellSyntax(tt.input); got != tt.want {
+				t.Errorf("FindShellSyntax() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/EscanBE/house-keeper/constants"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+)
+
+// ReadPasswordFile reads password from the provided file.
+// Program will exit if permission of the password file is not restricted to owner.
+func ReadPasswordFile(passwordFile string) string {
+	fip, err := os.Stat(passwordFile)
+	if os.IsNotExist(err) {
+		panic(fmt.Errorf("supplied password file does not exists: %s", passwordFile))
+	}
+
+	bz, err := os.ReadFile(passwordFile)
+	if err != nil {
+		panic(errors.Wrap(err, fmt.Sprintf("failed to read password file: %s", passwordFile)))
+	}
+	password := strings.TrimSpace(string(bz))
+	if len(password) < 1 {
+		panic(fmt.Errorf("password file is empty: %s", passwordFile))
+	}
+
+	fipPerm := fip.Mode().Perm()
+	errPerm := ValidatePasswordFileMode(fipPerm)
+	if errPerm != nil {
+		fmt.Printf("Incorrect permission '%o' of password file: %s\n", fipPerm, errPerm)
+		fmt.Printf("Suggest setting permission to '%o'\n", constants.RECOMMENDED_FILE_PERMISSION)
+		os.Exit(1)
+	}
+
+	return password
+}
+
+// ReadRemotePasswordFromEnv reads password to access remote server from environment variables RSYNC_PASSWORD and SSHPASS.
+// Both are treated similar thus either needed, if both provided, they must be identical.
+func ReadRemotePasswordFromEnv() (password, rsyncPassword, sshPassword string) {
+	rsyncPassword = strings.TrimSpace(os.Getenv(constants.ENV_RSYNC_PASSWORD))
+	sshPassword = strings.TrimSpace(os.Getenv(constants.ENV_SSHPASS))
+
+	if len(rsyncPassword) > 0 && len(sshPassword) > 0 {
+		if rsyncPassword != sshPassword {
+			panic(fmt.Errorf("both environment variables %s and %s are set but mis-match, consider remove one to take the rest", constants.ENV_RSYNC_PASSWORD, constants.ENV_SSHPASS))
+		}
+
+		password = rsyncPassword
+	} else if len(rsyncPassword) > 0 {
+		password = rsyncPassword
+	} else if len(sshPassword) > 0 {
+		password = sshPassword
+	}
+
+	return
+}
+
+// BuildSshPassArgs builds arguments for sshpass, the actual command must be appended after.
+// If password file is provided, sshpass reads password from file, otherwise from environment variable SSHPASS.
+func BuildSshPassArgs(passwordFile string, passphraseMode bool) []string {
+	var args []string
+	if passphraseMode {
+		//goland:noinspection SpellCheckingInspection
+		args = []string{"-P", "assphrase"}
+	}
+	if len(passwordFile) > 0 {
+		args = append(args, "-f", passwordFile)
+	} else {
+		args = append(args, "-e")
+	}
+	return args
+}
@@ -3,5 +3,9 @@ package utils
 import "time"
 
 func NowStr() string {
-	return time.Now().Format("2006-Jan-02 15:04:05")
+	return FormatTime(time.Now())
+}
+
+func FormatTime(t time.Time) string {
+	return t.Format("2006-Jan-02 15:04:05")
 }
@@ -16,18 +16,46 @@ const (
 //goland:noinspection GoSnakeCaseUsage
 const (
 	ENV_PG_PASSWORD    = "PGPASSWORD"
+	ENV_PG_PASSFILE    = "PGPASSFILE"
+	ENV_PG_SERVICE     = "PGSERVICE"
+	ENV_PG_SERVICEFILE = "PGSERVICEFILE"
+	ENV_PG_SSLMODE     = "PGSSLMODE"
+	ENV_PG_SSLROOTCERT = "PGSSLROOTCERT"
+	ENV_MYSQL_PASSWORD = "MYSQL_PWD"
+	ENV_REDIS_PASSWORD = "REDISCLI_AUTH"
 	ENV_RSYNC_PASSWORD = "RSYNC_PASSWORD"
 	ENV_SSHPASS        = "SSHPASS"
 )
 
+//goland:noinspection GoSnakeCaseUsage
+const (
+	SECRETS_VAULT_FILE_NAME = ".hkd_secrets"
+	ENV_VAULT_FILE          = "HKD_VAULT_FILE"
+	ENV_VAULT_PASSPHRASE    = "HKD_VAULT_PASSPHRASE"
+	ENV_VAULT_KEY_FILE      = "HKD_VAULT_KEY_FILE"
+)
+
 //goland:noinspection GoSnakeCaseUsage
 const (
 	PREDEFINED_ALIAS_FILE_NAME = ".hkd_alias"
 )
 
+//goland:noinspection GoSnakeCaseUsage
+const (
+	NODE_REGISTRY_FILE_NAME = ".hkd_nodes.yaml"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	SCHEDULE_CONFIG_FILE_NAME = ".hkd_schedule.yaml"
+	SCHEDULE_STATUS_FILE_NAME = ".hkd_schedule_status.json"
+)
+
 //goland:noinspection GoSnakeCaseUsage
 const (
 	BUTLER_REPO          = "https://github.com/EscanBE/butler.git"
 	BUTLER_BINARY_NAME   = "bud"
 	BUTLER_REPO_DIR_NAME = "butler"
+
+	BUTLER_GIT_TOKEN_SECRET_NAME = "github-token"
 )
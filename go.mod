@@ -3,10 +3,17 @@ module github.com/EscanBE/house-keeper
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/EscanBE/go-ienumerable v0.2.1
 	github.com/EscanBE/go-lib v1.1.0
+	github.com/coreos/go-systemd/v22 v22.5.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/pkg/errors v0.9.1
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/cobra v1.7.0
+	golang.org/x/crypto v0.3.0
+	golang.org/x/term v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,7 +22,6 @@ require (
 	github.com/ethereum/go-ethereum v1.10.26 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/crypto v0.3.0 // indirect
 	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect